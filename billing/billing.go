@@ -0,0 +1,169 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// Ledger ist die lokale Kostenbuchhaltung, z.B. *openai.AiCommunicationService.
+type Ledger interface {
+	CostsBetween(start, end time.Time) float64
+}
+
+// Client fragt die Costs-API der Organisation ab. Die API verlangt einen Admin-Key.
+type Client struct {
+	AdminKey   string
+	BaseURL    string   // Default: https://api.openai.com/v1
+	ProjectIDs []string // optional, schränkt die Abfrage auf Projekte ein
+	HTTPClient *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		AdminKey:   os.Getenv("OPENAI_ADMIN_KEY"),
+		BaseURL:    defaultBaseURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+type costsPage struct {
+	Data []struct {
+		StartTime int64 `json:"start_time"`
+		EndTime   int64 `json:"end_time"`
+		Results   []struct {
+			Amount struct {
+				Value    float64 `json:"value"`
+				Currency string  `json:"currency"`
+			} `json:"amount"`
+		} `json:"results"`
+	} `json:"data"`
+	HasMore  bool   `json:"has_more"`
+	NextPage string `json:"next_page"`
+}
+
+// ActualCosts liefert die von OpenAI abgerechneten Kosten (USD) im Zeitraum [start, end).
+func (c *Client) ActualCosts(ctx context.Context, start, end time.Time) (float64, error) {
+	total := 0.0
+	page := ""
+	for {
+		p, err := c.fetchCosts(ctx, start, end, page)
+		if err != nil {
+			return 0, log.WrapError(err)
+		}
+		for _, bucket := range p.Data {
+			for _, result := range bucket.Results {
+				if result.Amount.Currency != "" && !strings.EqualFold(result.Amount.Currency, "usd") {
+					return 0, fmt.Errorf("unexpected currency in costs API: %s", result.Amount.Currency)
+				}
+				total += result.Amount.Value
+			}
+		}
+		if !p.HasMore || p.NextPage == "" {
+			return total, nil
+		}
+		page = p.NextPage
+	}
+}
+
+func (c *Client) fetchCosts(ctx context.Context, start, end time.Time, page string) (*costsPage, error) {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	query := url.Values{}
+	query.Set("start_time", strconv.FormatInt(start.Unix(), 10))
+	query.Set("end_time", strconv.FormatInt(end.Unix(), 10))
+	query.Set("bucket_width", "1d")
+	query.Set("limit", "180")
+	for _, projectID := range c.ProjectIDs {
+		query.Add("project_ids", projectID)
+	}
+	if page != "" {
+		query.Set("page", page)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/organization/costs?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.AdminKey)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("costs API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var p costsPage
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("cannot parse costs API response: %w", err)
+	}
+	return &p, nil
+}
+
+// Reconciliation ist der Abgleich zwischen lokal geschätzten und tatsächlich abgerechneten Kosten.
+type Reconciliation struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Estimated  float64   `json:"estimated"`
+	Actual     float64   `json:"actual"`
+	Drift      float64   `json:"drift"`      // Actual - Estimated
+	DriftRatio float64   `json:"driftRatio"` // Drift bezogen auf Actual
+	Flagged    bool      `json:"flagged"`    // |DriftRatio| > Toleranz
+}
+
+// Reconcile gleicht die lokale Kostenbuchhaltung mit der Costs-API ab.
+// tolerance ist die zulässige relative Abweichung, z.B. 0.1 für 10 %.
+// Hinweis: die Costs-API liefert die Kosten der gesamten Organisation bzw. der
+// angegebenen Projekte, nicht nur die dieses Prozesses.
+func Reconcile(ctx context.Context, c *Client, ledger Ledger, start, end time.Time, tolerance float64) (*Reconciliation, error) {
+	actual, err := c.ActualCosts(ctx, start, end)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	estimated := ledger.CostsBetween(start, end)
+
+	r := &Reconciliation{
+		Start:     start,
+		End:       end,
+		Estimated: estimated,
+		Actual:    actual,
+		Drift:     actual - estimated,
+	}
+	switch {
+	case actual != 0:
+		r.DriftRatio = r.Drift / actual
+	case estimated != 0:
+		r.DriftRatio = -1
+	}
+	r.Flagged = math.Abs(r.DriftRatio) > tolerance
+	if r.Flagged {
+		log.Warn("cost drift detected: estimated $%.4f, actual $%.4f (%.1f%%)", estimated, actual, r.DriftRatio*100)
+	}
+	return r, nil
+}
@@ -0,0 +1,56 @@
+package billing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type staticLedger float64
+
+func (l staticLedger) CostsBetween(start, end time.Time) float64 {
+	return float64(l)
+}
+
+func TestReconcile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/organization/costs", r.URL.Path)
+		require.Equal(t, "Bearer admin-key", r.Header.Get("Authorization"))
+		if r.URL.Query().Get("page") == "" {
+			w.Write([]byte(`{"data":[{"results":[{"amount":{"value":1.5,"currency":"usd"}}]}],"has_more":true,"next_page":"p2"}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"results":[{"amount":{"value":0.5,"currency":"usd"}}]}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	c := &Client{AdminKey: "admin-key", BaseURL: server.URL}
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+
+	r, err := Reconcile(context.Background(), c, staticLedger(1.9), start, end, 0.1)
+	require.NoError(t, err)
+	require.InDelta(t, 2.0, r.Actual, 1e-9)
+	require.InDelta(t, 0.1, r.Drift, 1e-9)
+	require.False(t, r.Flagged)
+
+	r, err = Reconcile(context.Background(), c, staticLedger(1.0), start, end, 0.1)
+	require.NoError(t, err)
+	require.True(t, r.Flagged)
+}
+
+func TestActualCosts_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid admin key"}}`))
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL}
+	_, err := c.ActualCosts(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	require.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/dchaykin/myailib/openai"
+)
+
+// AnnualReport enthält die Kennzahlen eines Geschäftsberichts in voller Währungseinheit.
+// Nicht berichtete Kennzahlen sind nil.
+type AnnualReport struct {
+	Company     string          `json:"company"`
+	FiscalYear  string          `json:"fiscalYear"` // z.B. "2024" oder "2023/2024"
+	Currency    string          `json:"currency"`   // ISO 4217
+	Revenue     *openai.Decimal `json:"revenue,omitempty"`
+	EBITDA      *openai.Decimal `json:"ebitda,omitempty"`
+	EBIT        *openai.Decimal `json:"ebit,omitempty"`
+	NetIncome   *openai.Decimal `json:"netIncome,omitempty"`
+	TotalAssets *openai.Decimal `json:"totalAssets,omitempty"`
+	Equity      *openai.Decimal `json:"equity,omitempty"`
+	Cash        *openai.Decimal `json:"cash,omitempty"`
+	Employees   *int            `json:"employees,omitempty"`
+}
+
+func (AnnualReport) DocumentType() string { return TypeAnnualReport }
+
+// EquityRatio liefert die Eigenkapitalquote in Prozent, auf zwei Stellen gerundet.
+func (r *AnnualReport) EquityRatio() (openai.Decimal, bool) {
+	return percentage(r.Equity, r.TotalAssets)
+}
+
+// EBITMargin liefert die EBIT-Marge in Prozent, auf zwei Stellen gerundet.
+func (r *AnnualReport) EBITMargin() (openai.Decimal, bool) {
+	return percentage(r.EBIT, r.Revenue)
+}
+
+// NetMargin liefert die Umsatzrendite in Prozent, auf zwei Stellen gerundet.
+func (r *AnnualReport) NetMargin() (openai.Decimal, bool) {
+	return percentage(r.NetIncome, r.Revenue)
+}
+
+// Check meldet Kennzahlen, die einander widersprechen, z.B. mehr Eigenkapital als Bilanzsumme.
+func (r *AnnualReport) Check() []Inconsistency {
+	issues := []Inconsistency{}
+	if r.Equity != nil && r.TotalAssets != nil && r.Equity.Cmp(*r.TotalAssets) > 0 {
+		issues = append(issues, Inconsistency{Path: "equity", Message: fmt.Sprintf("%s exceeds total assets %s", r.Equity, r.TotalAssets)})
+	}
+	if r.Cash != nil && r.TotalAssets != nil && r.Cash.Cmp(*r.TotalAssets) > 0 {
+		issues = append(issues, Inconsistency{Path: "cash", Message: fmt.Sprintf("%s exceeds total assets %s", r.Cash, r.TotalAssets)})
+	}
+	if r.EBIT != nil && r.EBITDA != nil && r.EBIT.Cmp(*r.EBITDA) > 0 {
+		issues = append(issues, Inconsistency{Path: "ebit", Message: fmt.Sprintf("%s exceeds EBITDA %s", r.EBIT, r.EBITDA)})
+	}
+	return issues
+}
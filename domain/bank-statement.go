@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/dchaykin/myailib/openai"
+)
+
+// BankStatement ist ein Kontoauszug.
+type BankStatement struct {
+	AccountHolder  string         `json:"accountHolder"`
+	IBAN           string         `json:"iban"`
+	BIC            string         `json:"bic,omitempty"`
+	Currency       string         `json:"currency"`    // ISO 4217
+	PeriodStart    string         `json:"periodStart"` // JJJJ-MM-TT
+	PeriodEnd      string         `json:"periodEnd"`
+	OpeningBalance openai.Decimal `json:"openingBalance"` // negativ bei Soll
+	ClosingBalance openai.Decimal `json:"closingBalance"`
+	Transactions   []Transaction  `json:"transactions"`
+}
+
+// Transaction ist eine Buchung.
+type Transaction struct {
+	BookingDate  string         `json:"bookingDate"`         // JJJJ-MM-TT
+	ValueDate    string         `json:"valueDate,omitempty"` // Wertstellung
+	Amount       openai.Decimal `json:"amount"`              // negativ bei Belastung
+	Counterparty string         `json:"counterparty,omitempty"`
+	Purpose      string         `json:"purpose,omitempty"` // Verwendungszweck
+}
+
+func (BankStatement) DocumentType() string { return TypeBankStatement }
+
+// Check prüft, ob Anfangssaldo plus Buchungen den Endsaldo ergeben; fehlt eine Buchung oder
+// ist ein Vorzeichen falsch, fällt das hier auf. Buchungen außerhalb des Zeitraums werden
+// ebenfalls gemeldet.
+func (s *BankStatement) Check() []Inconsistency {
+	issues := []Inconsistency{}
+	amounts := make([]openai.Decimal, len(s.Transactions))
+	for i, t := range s.Transactions {
+		amounts[i] = t.Amount
+		// JJJJ-MM-TT lässt sich als Text vergleichen
+		if t.BookingDate < s.PeriodStart || (s.PeriodEnd != "" && t.BookingDate > s.PeriodEnd) {
+			issues = append(issues, Inconsistency{
+				Path:    fmt.Sprintf("transactions[%d].bookingDate", i),
+				Message: fmt.Sprintf("%s is outside %s to %s", t.BookingDate, s.PeriodStart, s.PeriodEnd),
+			})
+		}
+	}
+	if closing := s.OpeningBalance.Add(openai.SumDecimals(amounts...)); !closing.Equal(s.ClosingBalance) {
+		issues = append(issues, Inconsistency{
+			Path:    "closingBalance",
+			Message: fmt.Sprintf("opening balance plus transactions is %s, not %s", closing, s.ClosingBalance),
+		})
+	}
+	return issues
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dchaykin/myailib/openai"
+)
+
+// Inconsistency ist ein Widerspruch zwischen Werten eines Dokuments, den das Schema nicht
+// erkennen kann, z.B. eine Summe, die nicht aufgeht. Das deutet auf einen Lesefehler hin.
+type Inconsistency struct {
+	Path    string `json:"path"` // Feldpfad wie "lines[0].netAmount"
+	Message string `json:"message"`
+}
+
+func (i Inconsistency) String() string {
+	return i.Path + ": " + i.Message
+}
+
+// Checker ist ein Modell, das seine Werte gegeneinander prüfen kann.
+type Checker interface {
+	Check() []Inconsistency
+}
+
+// CheckError fasst die Widersprüche eines Dokuments als Fehler zusammen; nil, wenn es keine gibt.
+func CheckError(doc Checker) error {
+	issues := doc.Check()
+	if len(issues) == 0 {
+		return nil
+	}
+	parts := make([]string, len(issues))
+	for i, issue := range issues {
+		parts[i] = issue.String()
+	}
+	return fmt.Errorf("inconsistent values: %s", strings.Join(parts, "; "))
+}
+
+var cent = openai.NewDecimal(1, 2)
+
+// withinCent meldet, ob a und b höchstens einen Cent auseinanderliegen.
+func withinCent(a, b openai.Decimal) bool {
+	diff := a.Sub(b)
+	return diff.Cmp(cent) <= 0 && diff.Neg().Cmp(cent) <= 0
+}
+
+// percentage liefert part/total in Prozent, auf zwei Stellen gerundet.
+func percentage(part, total *openai.Decimal) (openai.Decimal, bool) {
+	if part == nil || total == nil || total.IsZero() {
+		return openai.Decimal{}, false
+	}
+	ratio, err := part.Mul(openai.NewDecimal(100, 0)).Div(*total, 2)
+	return ratio, err == nil
+}
@@ -0,0 +1,175 @@
+// Package domain enthält fertige Modelle für häufige Finanzdokumente: Rechnung, Kontoauszug
+// und Kennzahlen eines Geschäftsberichts. Zu jedem Modell gehören ein JSON-Schema und eine
+// System-Message für die Extraktion; Beträge sind openai.Decimal und bleiben damit exakt.
+//
+//	invoice, result, err := domain.Extract[domain.Invoice](ctx, service, "rechnung.pdf", domain.LanguageGerman)
+//
+// Die System-Messages gibt es auf Deutsch und Englisch. Für einen BatchConverter mit
+// Klassifizierung liefert ProfileRoutes die Routen je Typ, SelectRoute wählt zusätzlich die
+// Sprache nach dem erkannten Profil.
+package domain
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"slices"
+
+	"github.com/dchaykin/myailib/openai"
+)
+
+// Dokumenttypen, wie sie auch DocumentProfile.Type verwendet.
+const (
+	TypeInvoice       = "invoice"
+	TypeBankStatement = "bank-statement"
+	TypeAnnualReport  = "annual-report"
+)
+
+// Document ist ein Modell dieses Pakets.
+type Document interface {
+	DocumentType() string
+}
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// model beschreibt einen Dokumenttyp; die Anweisungen je Sprache stehen in catalog.
+type model struct {
+	data   []byte
+	schema *openai.JSONSchema
+}
+
+var models = map[string]*model{
+	TypeInvoice:       {},
+	TypeBankStatement: {},
+	TypeAnnualReport:  {},
+}
+
+func init() {
+	for docType, m := range models {
+		data, err := schemaFiles.ReadFile("schemas/" + docType + ".json")
+		if err != nil {
+			panic(err)
+		}
+		if m.schema, err = openai.ParseJSONSchema(data); err != nil {
+			panic(fmt.Sprintf("schema %s: %v", docType, err))
+		}
+		m.data = data
+	}
+}
+
+func lookup(docType string) (*model, error) {
+	m, ok := models[docType]
+	if !ok {
+		return nil, fmt.Errorf("unknown document type %q", docType)
+	}
+	return m, nil
+}
+
+// Types liefert die unterstützten Dokumenttypen.
+func Types() []string {
+	types := make([]string, 0, len(models))
+	for docType := range models {
+		types = append(types, docType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// SchemaJSON liefert das JSON-Schema des Typs, z.B. für JobManifest.SchemaFile.
+func SchemaJSON(docType string) ([]byte, error) {
+	m, err := lookup(docType)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(m.data), nil
+}
+
+// Schema liefert das Schema des Typs zum Prüfen von Antworten, siehe openai.WithSchema.
+func Schema(docType string) (*openai.JSONSchema, error) {
+	m, err := lookup(docType)
+	if err != nil {
+		return nil, err
+	}
+	return m.schema, nil
+}
+
+// SystemMessage liefert die Anweisung zur Extraktion des Typs samt Schema in der Sprache,
+// siehe Languages; leer = DefaultLanguage. Die Schlüssel des Schemas bleiben in jeder Sprache gleich.
+func SystemMessage(docType, language string) (string, error) {
+	m, err := lookup(docType)
+	if err != nil {
+		return "", err
+	}
+	return systemMessage(docType, m, language)
+}
+
+// Decode liest eine Antwort in das Modell T, ohne sie gegen das Schema zu prüfen.
+func Decode[T Document](content string) (*T, error) {
+	doc := new(T)
+	if err := openai.DecodeJSON([]byte(content), doc, openai.NumberJSON); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", (*doc).DocumentType(), err)
+	}
+	return doc, nil
+}
+
+// Extract extrahiert das Modell T aus fileName mit der System-Message in language. Die Antwort wird gegen das Schema geprüft,
+// bei Verstößen kommt ein *openai.SchemaError. opts gelten zusätzlich, z.B. openai.WithModel;
+// WithFieldConfidence und WithCitations passen nicht dazu, denn sie ändern die Form der Antwort.
+func Extract[T Document](ctx context.Context, p openai.Provider, fileName, language string, opts ...openai.RequestOption) (*T, *openai.Result, error) {
+	var zero T
+	docType := zero.DocumentType()
+	m, err := lookup(docType)
+	if err != nil {
+		return nil, nil, err
+	}
+	systemMessage, err := systemMessage(docType, m, language)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = append([]openai.RequestOption{openai.WithSchema(m.schema), openai.WithDocumentType(docType)}, opts...)
+	result, err := p.Generate(ctx, openai.Request{SystemMessage: systemMessage, FileName: fileName}, opts...)
+	if err != nil {
+		return nil, result, err
+	}
+	doc, err := Decode[T](result.Content)
+	return doc, result, err
+}
+
+// ProfileRoutes liefert für openai.DocumentProfiler je Typ System-Message in language und
+// Schema; die Ergebnisse landen in einem Unterordner je Typ.
+func ProfileRoutes(language string) (map[string]openai.ProfileRoute, error) {
+	routes := map[string]openai.ProfileRoute{}
+	for docType, m := range models {
+		systemMessage, err := systemMessage(docType, m, language)
+		if err != nil {
+			return nil, err
+		}
+		route := openai.ProfileRoute{SystemMessage: systemMessage, Folder: docType}
+		route.Schema = m.schema
+		routes[docType] = route
+	}
+	return routes, nil
+}
+
+// SelectRoute wählt als openai.DocumentProfiler.Select die Route zum erkannten Typ in der
+// Sprache des Dokuments. Liegt die Sprache nicht vor, gilt fallback; leer = DefaultLanguage.
+func SelectRoute(fallback string) func(openai.DocumentProfile) (openai.ProfileRoute, bool) {
+	return func(profile openai.DocumentProfile) (openai.ProfileRoute, bool) {
+		m, ok := models[profile.Type]
+		if !ok {
+			return openai.ProfileRoute{}, false
+		}
+		language := profile.Language
+		if _, ok := catalog[language]; !ok {
+			language = fallback
+		}
+		systemMessage, err := systemMessage(profile.Type, m, language)
+		if err != nil {
+			return openai.ProfileRoute{}, false
+		}
+		route := openai.ProfileRoute{SystemMessage: systemMessage, Folder: profile.Type}
+		route.Schema = m.schema
+		return route, true
+	}
+}
@@ -0,0 +1,247 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dchaykin/myailib/openai"
+	"github.com/stretchr/testify/require"
+)
+
+type providerFunc func(ctx context.Context, req openai.Request, opts ...openai.RequestOption) (*openai.Result, error)
+
+func (f providerFunc) Generate(ctx context.Context, req openai.Request, opts ...openai.RequestOption) (*openai.Result, error) {
+	return f(ctx, req, opts...)
+}
+
+func dec(s string) openai.Decimal {
+	return openai.MustDecimal(s)
+}
+
+func decPtr(s string) *openai.Decimal {
+	d := dec(s)
+	return &d
+}
+
+func testInvoice() Invoice {
+	return Invoice{
+		Number:    "RE-2024-0815",
+		IssueDate: "2024-03-01",
+		DueDate:   "2024-03-15",
+		Currency:  "EUR",
+		Seller:    Party{Name: "Muster GmbH", VATID: "DE123456789", Address: &Address{Street: "Hauptstr. 1", PostalCode: "10115", City: "Berlin", Country: "DE"}},
+		Buyer:     Party{Name: "Kunde AG"},
+		Lines: []InvoiceLine{
+			{Description: "Beratung", Quantity: dec("3"), UnitPrice: dec("120.00"), TaxRate: decPtr("19"), NetAmount: dec("360.00")},
+			{Description: "Reisekosten", Quantity: dec("1"), UnitPrice: dec("45.50"), TaxRate: decPtr("19"), NetAmount: dec("45.50")},
+		},
+		NetTotal:   dec("405.50"),
+		TaxTotal:   dec("77.05"),
+		GrossTotal: dec("482.55"),
+		IBAN:       "DE89370400440532013000",
+	}
+}
+
+func testBankStatement() BankStatement {
+	return BankStatement{
+		AccountHolder:  "Muster GmbH",
+		IBAN:           "DE89370400440532013000",
+		Currency:       "EUR",
+		PeriodStart:    "2024-03-01",
+		PeriodEnd:      "2024-03-31",
+		OpeningBalance: dec("1000.00"),
+		ClosingBalance: dec("1382.55"),
+		Transactions: []Transaction{
+			{BookingDate: "2024-03-05", Amount: dec("482.55"), Counterparty: "Kunde AG", Purpose: "RE-2024-0815"},
+			{BookingDate: "2024-03-28", Amount: dec("-100.00"), Purpose: "Miete"},
+		},
+	}
+}
+
+func testAnnualReport() AnnualReport {
+	employees := 120
+	return AnnualReport{
+		Company:     "Muster AG",
+		FiscalYear:  "2023",
+		Currency:    "EUR",
+		Revenue:     decPtr("25000000"),
+		EBIT:        decPtr("2000000"),
+		NetIncome:   decPtr("1250000"),
+		TotalAssets: decPtr("18000000"),
+		Equity:      decPtr("6000000"),
+		Employees:   &employees,
+	}
+}
+
+func TestSchemasAcceptModels(t *testing.T) {
+	docs := map[string]Document{
+		TypeInvoice:       testInvoice(),
+		TypeBankStatement: testBankStatement(),
+		TypeAnnualReport:  testAnnualReport(),
+	}
+	require.Equal(t, Types(), []string{TypeAnnualReport, TypeBankStatement, TypeInvoice})
+	for docType, doc := range docs {
+		require.Equal(t, docType, doc.DocumentType())
+		data, err := json.Marshal(doc)
+		require.NoError(t, err)
+		schema, err := Schema(docType)
+		require.NoError(t, err)
+		require.NoError(t, schema.Check(string(data)), docType)
+	}
+
+	schema, err := Schema(TypeInvoice)
+	require.NoError(t, err)
+	violations := schema.Validate(`{"number": "1", "issueDate": "2024-03-01", "currency": "euro"}`)
+	require.NotEmpty(t, violations)
+
+	_, err = Schema("receipt")
+	require.ErrorContains(t, err, "unknown document type")
+}
+
+func TestDecodeKeepsAmountsExact(t *testing.T) {
+	doc, err := Decode[BankStatement](`{"accountHolder": "A", "iban": "DE89370400440532013000", "currency": "EUR",
+		"periodStart": "2024-01-01", "periodEnd": "2024-01-31", "openingBalance": 0.1, "closingBalance": 0.3,
+		"transactions": [{"bookingDate": "2024-01-02", "amount": 0.1}, {"bookingDate": "2024-01-03", "amount": 0.1}]}`)
+	require.NoError(t, err)
+	require.Equal(t, "0.1", doc.OpeningBalance.String())
+	require.Empty(t, doc.Check())
+
+	_, err = Decode[Invoice](`{"number": "1"} trailing`)
+	require.ErrorContains(t, err, "invalid invoice")
+}
+
+func TestInvoiceCheck(t *testing.T) {
+	inv := testInvoice()
+	require.Empty(t, inv.Check())
+	require.NoError(t, CheckError(&inv))
+
+	inv.Lines[0].NetAmount = dec("36.00")
+	inv.GrossTotal = dec("482.45")
+	issues := inv.Check()
+	require.Len(t, issues, 3)
+	require.Equal(t, "lines[0].netAmount", issues[0].Path)
+	require.Equal(t, "netTotal", issues[1].Path)
+	require.Equal(t, "grossTotal", issues[2].Path)
+	require.ErrorContains(t, CheckError(&inv), "grossTotal: net plus tax is 482.55, not 482.45")
+
+	// Rundung um einen Cent ist erlaubt
+	inv = testInvoice()
+	inv.Lines[0] = InvoiceLine{Description: "Schrauben", Quantity: dec("3"), UnitPrice: dec("0.333"), NetAmount: dec("1.00")}
+	inv.NetTotal, inv.TaxTotal, inv.GrossTotal = dec("46.50"), dec("8.84"), dec("55.34")
+	require.Empty(t, inv.Check())
+}
+
+func TestBankStatementCheck(t *testing.T) {
+	s := testBankStatement()
+	require.Empty(t, s.Check())
+
+	s.Transactions[1].Amount = dec("100.00")
+	s.Transactions[0].BookingDate = "2024-04-01"
+	issues := s.Check()
+	require.Len(t, issues, 2)
+	require.Equal(t, "transactions[0].bookingDate", issues[0].Path)
+	require.Equal(t, "closingBalance", issues[1].Path)
+	require.Contains(t, issues[1].Message, "1582.55")
+}
+
+func TestAnnualReportRatios(t *testing.T) {
+	r := testAnnualReport()
+	require.Empty(t, r.Check())
+
+	ratio, ok := r.EquityRatio()
+	require.True(t, ok)
+	require.Equal(t, "33.33", ratio.String())
+	margin, ok := r.NetMargin()
+	require.True(t, ok)
+	require.Equal(t, "5.00", margin.String())
+	margin, ok = r.EBITMargin()
+	require.True(t, ok)
+	require.Equal(t, "8.00", margin.String())
+
+	r.Revenue = nil
+	_, ok = r.NetMargin()
+	require.False(t, ok)
+
+	r.Equity = decPtr("20000000")
+	issues := r.Check()
+	require.Len(t, issues, 1)
+	require.Equal(t, "equity", issues[0].Path)
+}
+
+func TestExtract(t *testing.T) {
+	data, err := json.Marshal(testInvoice())
+	require.NoError(t, err)
+
+	var got openai.Request
+	p := providerFunc(func(ctx context.Context, req openai.Request, opts ...openai.RequestOption) (*openai.Result, error) {
+		got = req
+		return &openai.Result{Content: string(data)}, nil
+	})
+	inv, result, err := Extract[Invoice](context.Background(), p, "rechnung.pdf", "")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	require.Equal(t, "rechnung.pdf", got.FileName)
+	require.Contains(t, got.SystemMessage, "(Rechnung)")
+	require.Contains(t, got.SystemMessage, `"grossTotal"`)
+	require.Equal(t, "RE-2024-0815", inv.Number)
+	require.True(t, inv.GrossTotal.Equal(dec("482.55")))
+}
+
+func TestPromptLanguages(t *testing.T) {
+	require.Equal(t, []string{LanguageGerman, LanguageEnglish}, Languages())
+	for _, language := range Languages() {
+		for _, docType := range Types() {
+			message, err := SystemMessage(docType, language)
+			require.NoError(t, err)
+			require.Contains(t, message, `"$schema"`)
+		}
+	}
+	message, err := SystemMessage(TypeBankStatement, "EN")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(message, "Extract the data from the document (bank statement)."))
+	require.Contains(t, message, "Include all transactions")
+	german, err := SystemMessage(TypeBankStatement, "")
+	require.NoError(t, err)
+	require.Contains(t, german, "Kontoauszug")
+
+	_, err = SystemMessage(TypeInvoice, "fr")
+	require.ErrorContains(t, err, `unsupported prompt language "fr"`)
+	_, err = ProfileRoutes("fr")
+	require.Error(t, err)
+
+	var got openai.Request
+	p := providerFunc(func(ctx context.Context, req openai.Request, opts ...openai.RequestOption) (*openai.Result, error) {
+		got = req
+		return &openai.Result{Content: `{"company": "Example Inc.", "fiscalYear": "2024", "currency": "USD"}`}, nil
+	})
+	report, _, err := Extract[AnnualReport](context.Background(), p, "report.pdf", LanguageEnglish)
+	require.NoError(t, err)
+	require.Equal(t, "Example Inc.", report.Company)
+	require.Contains(t, got.SystemMessage, "full currency units")
+}
+
+func TestProfileRoutes(t *testing.T) {
+	routes, err := ProfileRoutes("")
+	require.NoError(t, err)
+	require.Len(t, routes, len(Types()))
+	for docType, route := range routes {
+		require.Equal(t, docType, route.Folder)
+		require.NotNil(t, route.Schema)
+		require.True(t, strings.HasPrefix(route.SystemMessage, "Extrahiere"))
+	}
+	require.Contains(t, routes[TypeAnnualReport].SystemMessage, "voller Währungseinheit")
+
+	selectRoute := SelectRoute(LanguageEnglish)
+	route, ok := selectRoute(openai.DocumentProfile{Type: TypeInvoice, Language: "de"})
+	require.True(t, ok)
+	require.Contains(t, route.SystemMessage, "(Rechnung)")
+	require.Equal(t, TypeInvoice, route.Folder)
+	require.NotNil(t, route.Schema)
+	route, ok = selectRoute(openai.DocumentProfile{Type: TypeInvoice, Language: "fr"})
+	require.True(t, ok)
+	require.Contains(t, route.SystemMessage, "(invoice)")
+	_, ok = selectRoute(openai.DocumentProfile{Type: "receipt", Language: "en"})
+	require.False(t, ok)
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/dchaykin/myailib/openai"
+)
+
+// Invoice ist eine Rechnung.
+type Invoice struct {
+	Number       string         `json:"number"`
+	IssueDate    string         `json:"issueDate"`         // JJJJ-MM-TT
+	DueDate      string         `json:"dueDate,omitempty"` // JJJJ-MM-TT
+	Currency     string         `json:"currency"`          // ISO 4217
+	Seller       Party          `json:"seller"`
+	Buyer        Party          `json:"buyer"`
+	Lines        []InvoiceLine  `json:"lines"`
+	NetTotal     openai.Decimal `json:"netTotal"`
+	TaxTotal     openai.Decimal `json:"taxTotal"`
+	GrossTotal   openai.Decimal `json:"grossTotal"`
+	PaymentTerms string         `json:"paymentTerms,omitempty"`
+	IBAN         string         `json:"iban,omitempty"`
+}
+
+// Party ist Rechnungssteller oder -empfänger.
+type Party struct {
+	Name    string   `json:"name"`
+	VATID   string   `json:"vatId,omitempty"` // USt-IdNr.
+	Address *Address `json:"address,omitempty"`
+}
+
+// Address ist eine Postanschrift.
+type Address struct {
+	Street     string `json:"street,omitempty"`
+	PostalCode string `json:"postalCode,omitempty"`
+	City       string `json:"city,omitempty"`
+	Country    string `json:"country,omitempty"` // ISO 3166-1 Alpha-2
+}
+
+// InvoiceLine ist eine Rechnungsposition; Beträge netto.
+type InvoiceLine struct {
+	Description string          `json:"description"`
+	Quantity    openai.Decimal  `json:"quantity"`
+	UnitPrice   openai.Decimal  `json:"unitPrice"`
+	TaxRate     *openai.Decimal `json:"taxRate,omitempty"` // Prozent, z.B. 19
+	NetAmount   openai.Decimal  `json:"netAmount"`
+}
+
+func (Invoice) DocumentType() string { return TypeInvoice }
+
+// Check prüft, ob die Beträge zueinander passen: Menge mal Einzelpreis je Position, Summe der
+// Positionen und netto plus Steuer gleich brutto, jeweils bis auf einen Cent Rundung.
+func (inv *Invoice) Check() []Inconsistency {
+	issues := []Inconsistency{}
+	lines := make([]openai.Decimal, len(inv.Lines))
+	for i, line := range inv.Lines {
+		lines[i] = line.NetAmount
+		if amount := line.Quantity.Mul(line.UnitPrice); !withinCent(amount, line.NetAmount) {
+			issues = append(issues, Inconsistency{
+				Path:    fmt.Sprintf("lines[%d].netAmount", i),
+				Message: fmt.Sprintf("%s x %s = %s, not %s", line.Quantity, line.UnitPrice, amount.Round(2), line.NetAmount),
+			})
+		}
+	}
+	if len(inv.Lines) > 0 {
+		if sum := openai.SumDecimals(lines...); !withinCent(sum, inv.NetTotal) {
+			issues = append(issues, Inconsistency{Path: "netTotal", Message: fmt.Sprintf("lines sum up to %s, not %s", sum, inv.NetTotal)})
+		}
+	}
+	if gross := inv.NetTotal.Add(inv.TaxTotal); !withinCent(gross, inv.GrossTotal) {
+		issues = append(issues, Inconsistency{Path: "grossTotal", Message: fmt.Sprintf("net plus tax is %s, not %s", gross, inv.GrossTotal)})
+	}
+	return issues
+}
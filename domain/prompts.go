@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sprachen der System-Messages als ISO 639-1.
+const (
+	LanguageGerman  = "de"
+	LanguageEnglish = "en"
+
+	DefaultLanguage = LanguageGerman
+)
+
+// prompt ist die Anweisung zu einem Dokumenttyp in einer Sprache.
+type prompt struct {
+	title string // Art des Dokuments
+	hint  string // zusätzliche Anweisung, optional
+}
+
+// catalog enthält je Sprache den gemeinsamen Rahmen der Anweisung und die Angaben je Typ.
+var catalog = map[string]struct {
+	intro   string // %s = prompt.title
+	rules   string
+	answer  string // vor dem Schema
+	prompts map[string]prompt
+}{
+	LanguageGerman: {
+		intro: "Extrahiere die Angaben aus dem Dokument (%s).",
+		rules: "Beträge schreibst du als Zahl mit Punkt als Dezimaltrenner und ohne Tausendertrennzeichen, " +
+			"Datumsangaben im Format JJJJ-MM-TT. Fehlende Angaben setzt du auf null.",
+		answer: "Antworte ausschließlich mit JSON gemäß folgendem JSON-Schema:",
+		prompts: map[string]prompt{
+			TypeInvoice:       {title: "Rechnung"},
+			TypeBankStatement: {title: "Kontoauszug", hint: "Übernimm alle Buchungen in der Reihenfolge des Auszugs."},
+			TypeAnnualReport: {title: "Geschäftsbericht", hint: "Gib Beträge in voller Währungseinheit an, auch wenn der " +
+				"Bericht in Tausend oder Millionen angibt, und nimm die Werte des Konzerns, falls vorhanden."},
+		},
+	},
+	LanguageEnglish: {
+		intro: "Extract the data from the document (%s).",
+		rules: "Write amounts as numbers with a dot as decimal separator and without thousands separators, " +
+			"dates in the format YYYY-MM-DD. Set missing values to null.",
+		answer: "Answer with JSON only, following this JSON schema:",
+		prompts: map[string]prompt{
+			TypeInvoice:       {title: "invoice"},
+			TypeBankStatement: {title: "bank statement", hint: "Include all transactions in the order of the statement."},
+			TypeAnnualReport: {title: "annual report", hint: "State amounts in full currency units, even if the report " +
+				"uses thousands or millions, and take the group figures if available."},
+		},
+	},
+}
+
+// Languages liefert die Sprachen, in denen System-Messages vorliegen.
+func Languages() []string {
+	languages := make([]string, 0, len(catalog))
+	for language := range catalog {
+		languages = append(languages, language)
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// normalizeLanguage liefert die Sprache in Kleinbuchstaben, leer = DefaultLanguage.
+func normalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return DefaultLanguage
+	}
+	return language
+}
+
+// systemMessage setzt die Anweisung zu m in der Sprache zusammen.
+func systemMessage(docType string, m *model, language string) (string, error) {
+	c, ok := catalog[normalizeLanguage(language)]
+	if !ok {
+		return "", fmt.Errorf("unsupported prompt language %q", language)
+	}
+	p := c.prompts[docType]
+	message := fmt.Sprintf(c.intro, p.title) + " " + c.rules
+	if p.hint != "" {
+		message += " " + p.hint
+	}
+	return message + "\n\n" + c.answer + "\n" + strings.TrimSpace(string(m.data)), nil
+}
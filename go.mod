@@ -6,6 +6,8 @@ require (
 	github.com/dchaykin/mygolib v0.0.0-20250820145504-825eb7c6725f
 	github.com/openai/openai-go v1.12.0
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/text v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,5 +17,5 @@ require (
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/net v0.43.0 // indirect
 )
@@ -0,0 +1,133 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// SaveTranscript schreibt das Transkript als JSON nach dir und liefert den Dateinamen.
+// Der Name beginnt mit dem Startzeitpunkt, damit sich Läufe chronologisch sortieren.
+func (r *AgentResult) SaveTranscript(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", log.WrapError(err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	f, err := os.CreateTemp(dir, "agent-"+r.StartedAt.UTC().Format("20060102-150405")+"-*.json")
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return "", log.WrapError(err)
+	}
+	return f.Name(), log.WrapError(f.Close())
+}
+
+// LoadTranscript liest ein mit SaveTranscript geschriebenes Transkript.
+func LoadTranscript(fileName string) (*AgentResult, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	r := &AgentResult{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, log.WrapError(err)
+	}
+	return r, nil
+}
+
+// WriteHTML rendert das Transkript als eigenständige HTML-Seite zum Debuggen und Prüfen.
+func (r *AgentResult) WriteHTML(w io.Writer) error {
+	return log.WrapError(transcriptTemplate.Execute(w, r))
+}
+
+// Duration ist die Laufzeit des Agenten.
+func (r *AgentResult) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"cost": func(v float64) string { return fmt.Sprintf("$%.4f", v) },
+}).Parse(`<!DOCTYPE html>
+<html lang="de">
+<head>
+<meta charset="utf-8">
+<title>Agent-Transkript {{.StartedAt.Format "2006-01-02 15:04:05"}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+pre { white-space: pre-wrap; background: #f5f5f5; padding: .5em; margin: .25em 0; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: .25em .5em; text-align: left; vertical-align: top; }
+.step { border-left: 3px solid #88a; padding-left: 1em; margin: 1.5em 0; }
+.error { color: #b00; }
+.denied, .dry-run { color: #a60; }
+</style>
+</head>
+<body>
+<h1>Agent-Transkript</h1>
+<table>
+<tr><th>Modell</th><td>{{.Model}}</td></tr>
+<tr><th>Start</th><td>{{.StartedAt.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Dauer</th><td>{{.Duration}}</td></tr>
+<tr><th>Tokens</th><td>{{.Tokens}}</td></tr>
+<tr><th>Kosten</th><td>{{cost .TotalCost}}</td></tr>
+{{- if .Error}}
+<tr><th>Abbruch</th><td class="error">{{.Error}}</td></tr>
+{{- end}}
+</table>
+{{- if .Goal}}
+<h2>Ziel</h2>
+<pre>{{.Goal}}</pre>
+{{- end}}
+<h2>Eingabe</h2>
+<pre>{{.Input}}</pre>
+{{- range .Steps}}
+<div class="step">
+<h3>Schritt {{.Iteration}}</h3>
+<p>{{.PromptTokens}} Prompt-Tokens, {{.CompletionTokens}} Completion-Tokens, {{cost .Cost}}</p>
+{{- if .Content}}
+<pre>{{.Content}}</pre>
+{{- end}}
+{{- if .ToolCalls}}
+<table>
+<tr><th>Tool</th><th>Status</th><th>Argumente</th><th>Ergebnis</th><th>Dauer</th></tr>
+{{- range .ToolCalls}}
+<tr>
+<td>{{.Name}}</td>
+<td class="{{.Status}}">{{.Status}}</td>
+<td><pre>{{.Arguments}}</pre></td>
+<td>{{if .Error}}<pre class="error">{{.Error}}</pre>{{else}}<pre>{{.Result}}</pre>{{end}}</td>
+<td>{{.Duration}}</td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+</div>
+{{- end}}
+{{- if .Answer}}
+<h2>Antwort</h2>
+<pre>{{.Answer}}</pre>
+{{- end}}
+{{- if .Citations}}
+<h2>Quellen</h2>
+<ul>
+{{- range .Citations}}
+<li><a href="{{.URL}}">{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_Transcript(t *testing.T) {
+	ai, _ := newAgentTestService(t, false)
+	dir := filepath.Join(t.TempDir(), "transcripts")
+	agent := NewAgent(ai, "Beantworte Fragen mit Hilfe der Suche.", searchTool(nil))
+	agent.Transcripts = dir
+
+	result, err := agent.Run(context.Background(), "Was ist <myailib>?")
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "agent-*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	loaded, err := LoadTranscript(files[0])
+	require.NoError(t, err)
+	require.Equal(t, "Was ist <myailib>?", loaded.Input)
+	require.Equal(t, result.Model, loaded.Model)
+	require.Len(t, loaded.Steps, 2)
+	require.Equal(t, "1 Treffer für myailib", loaded.Steps[0].ToolCalls[0].Result)
+	require.Equal(t, result.TotalCost, loaded.TotalCost)
+	require.Positive(t, loaded.Duration())
+
+	var html bytes.Buffer
+	require.NoError(t, loaded.WriteHTML(&html))
+	require.Contains(t, html.String(), "Was ist &lt;myailib&gt;?")
+	require.Contains(t, html.String(), "Schritt 2")
+	require.Contains(t, html.String(), "1 Treffer für myailib")
+}
+
+func TestAgent_TranscriptOnError(t *testing.T) {
+	ai, _ := newAgentTestService(t, true)
+	dir := t.TempDir()
+	agent := NewAgent(ai, "", searchTool(nil))
+	agent.MaxIterations = 2
+	agent.Transcripts = dir
+
+	_, err := agent.Run(context.Background(), "los")
+	require.ErrorIs(t, err, ErrAgentIterations)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	loaded, err := LoadTranscript(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, loaded.Error, "iteration limit")
+	require.Len(t, loaded.Steps, 2)
+}
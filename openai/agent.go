@@ -0,0 +1,426 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/shared"
+)
+
+const (
+	defaultAgentIterations   = 10
+	defaultAgentRepeatedCall = 3
+)
+
+var (
+	// ErrAgentIterations meldet, dass der Agent MaxIterations erreicht hat, ohne fertig zu werden.
+	ErrAgentIterations = errors.New("agent iteration limit reached")
+	// ErrAgentBudget meldet, dass der Agent MaxTokens oder MaxCost überschritten hat.
+	ErrAgentBudget = errors.New("agent budget exceeded")
+	// ErrAgentTimeout meldet, dass der Agent MaxDuration überschritten hat.
+	ErrAgentTimeout = errors.New("agent time limit reached")
+	// ErrAgentLoop meldet, dass das Modell denselben Tool-Aufruf immer wieder vorschlägt.
+	ErrAgentLoop = errors.New("agent loop detected")
+)
+
+// AgentStopError beschreibt, welche Schutzgrenze einen Lauf beendet hat. Reason ist eines
+// der ErrAgent-Sentinels, errors.Is funktioniert damit direkt.
+type AgentStopError struct {
+	Reason    error
+	Iteration int
+	Detail    string
+}
+
+func (e *AgentStopError) Error() string {
+	return fmt.Sprintf("%v in iteration %d: %s", e.Reason, e.Iteration, e.Detail)
+}
+
+func (e *AgentStopError) Unwrap() error {
+	return e.Reason
+}
+
+// Tool ist eine Funktion, die das Modell im Agenten-Loop aufrufen kann.
+type Tool struct {
+	Name        string         // a-z, A-Z, 0-9, _ und -, höchstens 64 Zeichen
+	Description string         // wann und wozu das Modell das Tool nutzen soll
+	Parameters  map[string]any // JSON-Schema der Argumente; nil = keine Argumente
+	// Run erhält die Argumente als JSON und liefert das Ergebnis als Text für das Modell.
+	// Ein Fehler wird dem Modell als Ergebnis gemeldet, der Loop läuft weiter.
+	Run     func(ctx context.Context, arguments string) (string, error)
+	Timeout time.Duration // je Aufruf; 0 = Agent.ToolTimeout
+	// ReadOnly markiert Tools ohne Seiteneffekte; sie laufen ohne Freigabe, auch im Dry-Run.
+	ReadOnly bool
+	// Citations liest Quellen aus dem Ergebnis, die in AgentResult.Citations landen. Optional.
+	Citations func(result string) []WebCitation
+}
+
+// ToolCallStatus gibt an, was mit einem vorgeschlagenen Tool-Aufruf passiert ist.
+type ToolCallStatus string
+
+const (
+	ToolCallExecuted ToolCallStatus = "executed"
+	ToolCallDenied   ToolCallStatus = "denied"  // von Agent.Approve abgelehnt
+	ToolCallDryRun   ToolCallStatus = "dry-run" // nur protokolliert, siehe Agent.DryRun
+)
+
+// AgentToolCall ist ein Tool-Aufruf innerhalb eines Schritts.
+type AgentToolCall struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Arguments string         `json:"arguments"`
+	Status    ToolCallStatus `json:"status,omitempty"`
+	Result    string         `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Duration  time.Duration  `json:"duration"`
+}
+
+// AgentStep ist eine Runde des Loops: eine Antwort des Modells und die dabei aufgerufenen Tools.
+type AgentStep struct {
+	Iteration        int             `json:"iteration"`
+	Content          string          `json:"content,omitempty"`
+	ToolCalls        []AgentToolCall `json:"toolCalls,omitempty"`
+	PromptTokens     int64           `json:"promptTokens"`
+	CompletionTokens int64           `json:"completionTokens"`
+	Cost             float64         `json:"cost"`
+}
+
+// AgentResult ist das Protokoll eines Laufs. Bei Abbruch enthält es die bis dahin
+// ausgeführten Schritte. Als Transkript siehe SaveTranscript und WriteHTML.
+type AgentResult struct {
+	Model      string        `json:"model"`
+	Goal       string        `json:"goal,omitempty"`
+	Input      string        `json:"input"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Error      string        `json:"error,omitempty"` // Grund des Abbruchs
+	Answer     string        `json:"answer"`
+	Citations  []WebCitation `json:"citations,omitempty"` // Quellen aus Websuche und Tools
+	Steps      []AgentStep   `json:"steps"`
+	Tokens     int64         `json:"tokens"`
+	TotalCost  float64       `json:"totalCost"`
+}
+
+// Agent führt einfache autonome Abläufe aus (z.B. suchen -> abrufen -> zusammenfassen):
+// das Modell ruft so lange Tools auf, bis es eine Antwort ohne Tool-Aufruf liefert oder
+// ein Limit greift. Jeder Schritt wird in Costs des Services erfasst.
+type Agent struct {
+	Service       *AiCommunicationService
+	Goal          string // System-Message mit Ziel und Regeln
+	Tools         []Tool
+	MaxIterations int               // Default: 10
+	MaxTokens     int64             // Summe aus Prompt- und Completion-Tokens, 0 = unbegrenzt
+	MaxCost       float64           // USD, 0 = unbegrenzt
+	WebSearch     *WebSearchOptions // Websuche des Providers, optional
+	ToolTimeout   time.Duration     // je Tool-Aufruf, wenn Tool.Timeout 0 ist; 0 = unbegrenzt
+	// Approve wird vor jedem Aufruf eines Tools ohne ReadOnly gefragt. Bei false erfährt das
+	// Modell, dass der Aufruf abgelehnt wurde; ein Fehler bricht den Lauf ab.
+	Approve func(ctx context.Context, call AgentToolCall) (bool, error)
+	// DryRun protokolliert Aufrufe von Tools ohne ReadOnly nur, statt sie auszuführen.
+	DryRun bool
+	// Transcripts ist ein Verzeichnis, in dem jeder Lauf als JSON-Transkript landet. Optional.
+	Transcripts string
+	// MaxDuration begrenzt die Laufzeit inklusive Tools, 0 = unbegrenzt.
+	MaxDuration time.Duration
+	// MaxRepeatedCalls ist, wie oft derselbe Tool-Aufruf (Name und Argumente) vorkommen darf,
+	// bevor der Lauf als Endlosschleife abbricht. Default: 3, negativ = keine Prüfung.
+	MaxRepeatedCalls int
+}
+
+func NewAgent(service *AiCommunicationService, goal string, tools ...Tool) *Agent {
+	return &Agent{
+		Service:          service,
+		Goal:             goal,
+		Tools:            tools,
+		MaxIterations:    defaultAgentIterations,
+		MaxRepeatedCalls: defaultAgentRepeatedCall,
+	}
+}
+
+// Run startet den Loop mit input als erster Nachricht des Benutzers.
+func (a *Agent) Run(ctx context.Context, input string, opts ...RequestOption) (*AgentResult, error) {
+	result := &AgentResult{Goal: a.Goal, Input: input, StartedAt: time.Now(), Steps: []AgentStep{}}
+	err := a.run(ctx, input, opts, result)
+	result.FinishedAt = time.Now()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if a.Transcripts != "" {
+		if _, werr := result.SaveTranscript(a.Transcripts); werr != nil {
+			log.Warn("agent transcript not written: %v", werr)
+		}
+	}
+	return result, err
+}
+
+func (a *Agent) run(parent context.Context, input string, opts []RequestOption, result *AgentResult) error {
+	ctx := parent
+	if a.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, a.MaxDuration)
+		defer cancel()
+	}
+	iteration := 0
+	// timedOut ersetzt den Fehler, wenn MaxDuration statt des Aufrufers abgebrochen hat.
+	timedOut := func(err error) error {
+		if ctx.Err() != nil && parent.Err() == nil {
+			return &AgentStopError{Reason: ErrAgentTimeout, Iteration: iteration, Detail: fmt.Sprintf("exceeded %s", a.MaxDuration)}
+		}
+		return err
+	}
+	ai := a.Service
+	ai.init()
+	cfg := ai.newRequestConfig(opts)
+	if cfg.budgetErr != nil {
+		return cfg.budgetErr
+	}
+	result.Model = string(cfg.model)
+
+	tools := map[string]Tool{}
+	params := openai.ChatCompletionNewParams{
+		Model:       cfg.model,
+		Temperature: openai.Float(cfg.temperature),
+	}
+	for _, tool := range a.Tools {
+		if _, ok := tools[tool.Name]; ok {
+			return fmt.Errorf("duplicate agent tool %q", tool.Name)
+		}
+		tools[tool.Name] = tool
+		params.Tools = append(params.Tools, tool.param())
+	}
+	if a.Goal != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(a.Goal))
+	}
+	params.Messages = append(params.Messages, openai.UserMessage(input))
+	cfg.safety.apply(&params)
+	if cfg.user != "" {
+		params.User = param.NewOpt(cfg.user)
+	}
+	if a.WebSearch != nil {
+		params.WebSearchOptions = openai.ChatCompletionNewParamsWebSearchOptions{SearchContextSize: a.WebSearch.ContextSize}
+		// Such-Modelle akzeptieren keine Temperatur
+		params.Temperature = param.Opt[float64]{}
+	}
+
+	maxIterations := a.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultAgentIterations
+	}
+	maxRepeated := a.MaxRepeatedCalls
+	if maxRepeated == 0 {
+		maxRepeated = defaultAgentRepeatedCall
+	}
+	seen := map[string]int{}
+	for iteration = 1; iteration <= maxIterations; iteration++ {
+		releaseBudget, err := ai.reserveBudget(estimateTokens(a.Goal, input))
+		if err != nil {
+			return err
+		}
+		completion, err := ai.createChatCompletion(ctx, params, cfg, estimateTokens(a.Goal, input))
+		if err != nil {
+			releaseBudget()
+			return timedOut(err)
+		}
+		step := AgentStep{
+			Iteration:        iteration,
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+			Cost:             ai.addCosts(completion.Usage, cfg),
+		}
+		releaseBudget()
+		result.Tokens += completion.Usage.TotalTokens
+		result.TotalCost += step.Cost
+
+		choice := completion.Choices[0]
+		step.Content = choice.Message.Content
+		result.addCitations(messageCitations(choice.Message))
+		if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) == 0 {
+			result.Steps = append(result.Steps, step)
+			switch choice.FinishReason {
+			case "length":
+				return ErrMaxLength
+			case "content_filter":
+				return ErrContentFiltered
+			}
+			if choice.Message.Refusal != "" {
+				return fmt.Errorf("%w: %s", ErrRefused, choice.Message.Refusal)
+			}
+			answer, err := ai.limitResponse(choice.Message.Content)
+			if err != nil {
+				return err
+			}
+			result.Answer = NormalizeText(answer)
+			return nil
+		}
+
+		if maxRepeated > 0 {
+			for _, call := range choice.Message.ToolCalls {
+				key := call.Function.Name + "\x00" + compactJSON(call.Function.Arguments)
+				if seen[key]++; seen[key] > maxRepeated {
+					result.Steps = append(result.Steps, step)
+					return &AgentStopError{Reason: ErrAgentLoop, Iteration: iteration,
+						Detail: fmt.Sprintf("tool %s called %d times with arguments %s", call.Function.Name, seen[key], call.Function.Arguments)}
+				}
+			}
+		}
+
+		params.Messages = append(params.Messages, choice.Message.ToParam())
+		calls, err := a.runTools(ctx, tools, choice.Message.ToolCalls, cfg)
+		if err != nil {
+			result.Steps = append(result.Steps, step)
+			return err
+		}
+		for _, tc := range calls {
+			step.ToolCalls = append(step.ToolCalls, tc)
+			if tool := tools[tc.Name]; tool.Citations != nil && tc.Error == "" {
+				result.addCitations(tool.Citations(tc.Result))
+			}
+			output := tc.Result
+			if tc.Error != "" {
+				output = "error: " + tc.Error
+			}
+			params.Messages = append(params.Messages, openai.ToolMessage(output, tc.ID))
+		}
+		result.Steps = append(result.Steps, step)
+
+		if a.MaxTokens > 0 && result.Tokens > a.MaxTokens {
+			return &AgentStopError{Reason: ErrAgentBudget, Iteration: iteration, Detail: fmt.Sprintf("%d of %d tokens used", result.Tokens, a.MaxTokens)}
+		}
+		if a.MaxCost > 0 && result.TotalCost > a.MaxCost {
+			return &AgentStopError{Reason: ErrAgentBudget, Iteration: iteration, Detail: fmt.Sprintf("spent $%.4f of $%.4f", result.TotalCost, a.MaxCost)}
+		}
+		if ctx.Err() != nil {
+			return timedOut(ctx.Err())
+		}
+	}
+	return &AgentStopError{Reason: ErrAgentIterations, Iteration: maxIterations, Detail: fmt.Sprintf("no answer after %d iterations", maxIterations)}
+}
+
+// runTools führt die Tool-Aufrufe einer Antwort parallel aus und liefert die Ergebnisse
+// in der Reihenfolge der Aufrufe. Freigaben werden vorher nacheinander eingeholt; scheitert
+// eine, läuft keines der Tools.
+func (a *Agent) runTools(ctx context.Context, tools map[string]Tool, calls []openai.ChatCompletionMessageToolCall, cfg requestConfig) ([]AgentToolCall, error) {
+	results := make([]AgentToolCall, len(calls))
+	for i, call := range calls {
+		tc := AgentToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments}
+		tool, ok := tools[call.Function.Name]
+		switch {
+		case !ok || tool.Run == nil:
+			tc.Error = fmt.Sprintf("unknown tool %q", call.Function.Name)
+		case tool.ReadOnly:
+		case a.DryRun:
+			log.Info("agent dry run: %s(%s)", tc.Name, tc.Arguments)
+			tc.Status = ToolCallDryRun
+			tc.Result = "dry run: the tool call was recorded but not executed"
+		case a.Approve != nil:
+			approved, err := a.Approve(ctx, tc)
+			if err != nil {
+				return nil, fmt.Errorf("approval of tool %s failed: %w", tc.Name, err)
+			}
+			if !approved {
+				tc.Status = ToolCallDenied
+				tc.Error = fmt.Sprintf("tool call %s was not approved", tc.Name)
+			}
+		}
+		results[i] = tc
+	}
+
+	var wg sync.WaitGroup
+	for i, tc := range results {
+		if tc.Status != "" || tc.Error != "" {
+			a.auditToolCall(tc, cfg)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = a.runTool(ctx, tools[tc.Name], tc)
+			a.auditToolCall(results[i], cfg)
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// auditToolCall protokolliert den Tool-Aufruf im Audit-Log des Services.
+func (a *Agent) auditToolCall(tc AgentToolCall, cfg requestConfig) {
+	a.Service.audit(AuditRecord{
+		User:       cfg.user,
+		Model:      string(cfg.model),
+		Tool:       tc.Name,
+		ToolStatus: string(tc.Status),
+		Error:      tc.Error,
+	})
+}
+
+func (a *Agent) runTool(ctx context.Context, tool Tool, tc AgentToolCall) AgentToolCall {
+	tc.Status = ToolCallExecuted
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		timeout = a.ToolTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type output struct {
+		result string
+		err    error
+	}
+	done := make(chan output, 1)
+	start := time.Now()
+	go func() {
+		result, err := tool.Run(ctx, tc.Arguments)
+		done <- output{result, err}
+	}()
+	var result string
+	var err error
+	select {
+	case out := <-done:
+		result, err = out.result, out.err
+	case <-ctx.Done():
+		// auch Tools, die ctx ignorieren, halten den Loop nicht auf
+		err = ctx.Err()
+	}
+	tc.Duration = time.Since(start)
+	if errors.Is(err, context.DeadlineExceeded) && timeout > 0 {
+		err = fmt.Errorf("tool %s timed out after %s", tool.Name, timeout)
+	}
+	if err != nil {
+		log.Warn("agent tool %s failed: %v", tool.Name, err)
+		tc.Error = err.Error()
+		return tc
+	}
+	tc.Result = result
+	return tc
+}
+
+// compactJSON entfernt Leerraum, damit gleiche Argumente unabhängig von der Formatierung
+// als gleich erkannt werden.
+func compactJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+func (t Tool) param() openai.ChatCompletionToolParam {
+	fn := shared.FunctionDefinitionParam{Name: t.Name}
+	if t.Description != "" {
+		fn.Description = param.NewOpt(t.Description)
+	}
+	if t.Parameters != nil {
+		fn.Parameters = shared.FunctionParameters(t.Parameters)
+	}
+	return openai.ChatCompletionToolParam{Function: fn}
+}
@@ -0,0 +1,271 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testToolCallCompletion = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"created": 1700000000,
+	"model": "gpt-4.1",
+	"choices": [{"index": 0, "finish_reason": "tool_calls", "message": {"role": "assistant", "content": null,
+		"tool_calls": [{"id": "call-1", "type": "function", "function": {"name": "search", "arguments": "{\"q\": \"myailib\"}"}}]}}],
+	"usage": {"prompt_tokens": 100, "completion_tokens": 20, "total_tokens": 120}
+}`
+
+// newAgentTestService ruft das Tool "search" auf, solange keine Tool-Antwort vorliegt, und
+// antwortet danach mit testChatCompletion. Mit loop ruft es das Tool immer wieder auf.
+func newAgentTestService(t *testing.T, loop bool) (*AiCommunicationService, *[]string) {
+	t.Helper()
+	var toolResults []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Tools []struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			} `json:"tools"`
+			Messages []struct {
+				Role       string `json:"role"`
+				Content    any    `json:"content"`
+				ToolCallID string `json:"tool_call_id"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "search", body.Tools[0].Function.Name)
+		w.Header().Set("Content-Type", "application/json")
+		last := body.Messages[len(body.Messages)-1]
+		if last.Role == "tool" {
+			require.Equal(t, "call-1", last.ToolCallID)
+			toolResults = append(toolResults, last.Content.(string))
+			if !loop {
+				_, _ = w.Write([]byte(testChatCompletion))
+				return
+			}
+		}
+		_, _ = w.Write([]byte(testToolCallCompletion))
+	})
+	return ai, &toolResults
+}
+
+func searchTool(err error) Tool {
+	return Tool{
+		Name:        "search",
+		Description: "Websuche",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"q": map[string]any{"type": "string"}},
+		},
+		Run: func(ctx context.Context, arguments string) (string, error) {
+			var args struct{ Q string }
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", err
+			}
+			return "1 Treffer für " + args.Q, err
+		},
+	}
+}
+
+func TestAgent_Run(t *testing.T) {
+	ai, toolResults := newAgentTestService(t, false)
+	agent := NewAgent(ai, "Beantworte Fragen mit Hilfe der Suche.", searchTool(nil))
+
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, result.Answer)
+	require.Len(t, result.Steps, 2)
+	require.Equal(t, "search", result.Steps[0].ToolCalls[0].Name)
+	require.Equal(t, "1 Treffer für myailib", result.Steps[0].ToolCalls[0].Result)
+	require.Equal(t, []string{"1 Treffer für myailib"}, *toolResults)
+	require.EqualValues(t, 240, result.Tokens)
+	require.InDelta(t, 2*costOf(100, 20), result.TotalCost, 1e-9)
+	require.InDelta(t, ai.TotalCosts(), result.TotalCost, 1e-9)
+}
+
+func TestAgent_ToolErrorIsReported(t *testing.T) {
+	ai, toolResults := newAgentTestService(t, false)
+	agent := NewAgent(ai, "", searchTool(errors.New("offline")))
+
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Equal(t, "offline", result.Steps[0].ToolCalls[0].Error)
+	require.Equal(t, []string{"error: offline"}, *toolResults)
+}
+
+func TestAgent_Limits(t *testing.T) {
+	ai, _ := newAgentTestService(t, true)
+	agent := NewAgent(ai, "", searchTool(nil))
+	agent.MaxIterations = 3
+
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.ErrorIs(t, err, ErrAgentIterations)
+	require.Len(t, result.Steps, 3)
+
+	agent.MaxTokens = 200
+	result, err = agent.Run(context.Background(), "Was ist myailib?")
+	require.ErrorIs(t, err, ErrAgentBudget)
+	require.Len(t, result.Steps, 2)
+}
+
+func TestAgent_ParallelToolCalls(t *testing.T) {
+	var toolMessages []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role       string `json:"role"`
+				Content    any    `json:"content"`
+				ToolCallID string `json:"tool_call_id"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		if body.Messages[len(body.Messages)-1].Role == "tool" {
+			for _, m := range body.Messages {
+				if m.Role == "tool" {
+					toolMessages = append(toolMessages, m.ToolCallID+": "+m.Content.(string))
+				}
+			}
+			_, _ = w.Write([]byte(testChatCompletion))
+			return
+		}
+		_, _ = w.Write([]byte(strings.Replace(testToolCallCompletion,
+			`"tool_calls": [{"id": "call-1", "type": "function", "function": {"name": "search", "arguments": "{\"q\": \"myailib\"}"}}]`,
+			`"tool_calls": [
+				{"id": "call-1", "type": "function", "function": {"name": "slow", "arguments": "{}"}},
+				{"id": "call-2", "type": "function", "function": {"name": "fast", "arguments": "{}"}},
+				{"id": "call-3", "type": "function", "function": {"name": "stuck", "arguments": "{}"}}]`, 1)))
+	})
+
+	release := make(chan struct{})
+	defer close(release)
+	tool := func(name string, d time.Duration) Tool {
+		return Tool{Name: name, Run: func(ctx context.Context, arguments string) (string, error) {
+			time.Sleep(d)
+			return name, nil
+		}}
+	}
+	agent := NewAgent(ai, "",
+		tool("slow", 100*time.Millisecond),
+		tool("fast", 0),
+		Tool{Name: "stuck", Timeout: 150 * time.Millisecond, Run: func(ctx context.Context, arguments string) (string, error) {
+			<-release // ignoriert ctx
+			return "", nil
+		}},
+	)
+
+	start := time.Now()
+	result, err := agent.Run(context.Background(), "los")
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Equal(t, []string{"call-1: slow", "call-2: fast", "call-3: error: tool stuck timed out after 150ms"}, toolMessages)
+	require.Len(t, result.Steps[0].ToolCalls, 3)
+}
+
+func TestAgent_ApprovalAndDryRun(t *testing.T) {
+	ai, toolResults := newAgentTestService(t, false)
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+	var proposed []AgentToolCall
+	agent := NewAgent(ai, "", searchTool(nil))
+	agent.Approve = func(ctx context.Context, call AgentToolCall) (bool, error) {
+		proposed = append(proposed, call)
+		return false, nil
+	}
+
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Len(t, proposed, 1)
+	require.Equal(t, `{"q": "myailib"}`, proposed[0].Arguments)
+	require.Equal(t, []string{"error: tool call search was not approved"}, *toolResults)
+	require.Equal(t, ToolCallDenied, result.Steps[0].ToolCalls[0].Status)
+
+	agent.Approve = func(ctx context.Context, call AgentToolCall) (bool, error) {
+		return false, errors.New("approval service down")
+	}
+	_, err = agent.Run(context.Background(), "Was ist myailib?")
+	require.ErrorContains(t, err, "approval service down")
+
+	// Dry-Run gilt vor Approve; ReadOnly-Tools laufen trotzdem
+	agent.DryRun = true
+	result, err = agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Equal(t, ToolCallDryRun, result.Steps[0].ToolCalls[0].Status)
+	require.Contains(t, (*toolResults)[1], "not executed")
+
+	agent.Tools[0].ReadOnly = true
+	result, err = agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Equal(t, ToolCallExecuted, result.Steps[0].ToolCalls[0].Status)
+	require.Equal(t, "1 Treffer für myailib", (*toolResults)[2])
+
+	var statuses []string
+	for _, rec := range audit.records {
+		if rec.Tool != "" {
+			statuses = append(statuses, rec.ToolStatus)
+		}
+	}
+	require.Equal(t, []string{"denied", "dry-run", "executed"}, statuses)
+}
+
+func TestAgent_ApprovalErrorRunsNoTool(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testToolCallCompletion,
+			`"tool_calls": [{"id": "call-1", "type": "function", "function": {"name": "search", "arguments": "{\"q\": \"myailib\"}"}}]`,
+			`"tool_calls": [
+				{"id": "call-1", "type": "function", "function": {"name": "send", "arguments": "{}"}},
+				{"id": "call-2", "type": "function", "function": {"name": "send", "arguments": "{}"}}]`, 1)))
+	})
+	var runs atomic.Int32
+	agent := NewAgent(ai, "", Tool{Name: "send", Run: func(ctx context.Context, arguments string) (string, error) {
+		runs.Add(1)
+		return "sent", nil
+	}})
+	agent.Approve = func(ctx context.Context, call AgentToolCall) (bool, error) {
+		if call.ID == "call-2" {
+			return false, errors.New("approval service down")
+		}
+		return true, nil
+	}
+
+	_, err := agent.Run(context.Background(), "los")
+	require.ErrorContains(t, err, "approval service down")
+	require.Zero(t, runs.Load())
+}
+
+func TestAgent_Safeguards(t *testing.T) {
+	ai, toolResults := newAgentTestService(t, true)
+	agent := NewAgent(ai, "", searchTool(nil))
+
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.ErrorIs(t, err, ErrAgentLoop)
+	var stop *AgentStopError
+	require.ErrorAs(t, err, &stop)
+	require.Equal(t, 4, stop.Iteration)
+	require.Contains(t, stop.Detail, "search called 4 times")
+	require.Len(t, result.Steps, 4)
+	require.Len(t, *toolResults, 3) // der vierte Aufruf wird nicht mehr ausgeführt
+
+	agent.MaxRepeatedCalls = -1
+	agent.MaxDuration = 50 * time.Millisecond
+	agent.Tools[0].Run = func(ctx context.Context, arguments string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	start := time.Now()
+	_, err = agent.Run(context.Background(), "Was ist myailib?")
+	require.ErrorIs(t, err, ErrAgentTimeout)
+	require.ErrorAs(t, err, &stop)
+	require.Equal(t, 1, stop.Iteration)
+	require.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,326 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// Kategorien personenbezogener Daten für Anonymizer.Fields. Eigene Kategorien sind
+// möglich, sie bestehen aus Großbuchstaben, Ziffern und "_".
+const (
+	PIIPerson  = "PERSON"
+	PIIAddress = "ADDRESS"
+	PIIEmail   = "EMAIL"
+	PIIPhone   = "PHONE"
+	PIIIBAN    = "IBAN"
+	PIIID      = "ID" // z.B. Steuer- oder Kundennummer
+)
+
+var (
+	piiCategoryPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	// piiTokenPattern erkennt Platzhalter wie "[PERSON-1]".
+	piiTokenPattern = regexp.MustCompile(`\[([A-Z][A-Z0-9_]*)-([1-9][0-9]*)\]`)
+	// piiIndexPattern entfernt Array-Indizes wie "[0]" aus einem Pfad.
+	piiIndexPattern = regexp.MustCompile(`\[[0-9]+\]`)
+)
+
+// piiPatterns erkennen personenbezogene Daten in beliebigem Text, siehe Anonymizer.Patterns.
+var piiPatterns = []struct {
+	category string
+	re       *regexp.Regexp
+}{
+	{PIIEmail, regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{PIIIBAN, regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]{4}){3,7}(?: ?[A-Z0-9]{1,3})?\b`)},
+	// international mit "+" oder national mit Vorwahl ab "0", mindestens sieben Ziffern
+	{PIIPhone, regexp.MustCompile(`(?:\+[1-9][0-9]{0,2}|\b0[1-9][0-9]{1,4})(?:[ /-]?\(0\))?(?:[ /-]?[0-9]{2,}){1,4}\b`)},
+}
+
+// minKnownValue ist die Mindestlänge bekannter Werte, die AnonymizeText auch in freiem Text
+// ersetzt; kürzere wie Ländercodes kämen zu oft zufällig vor.
+const minKnownValue = 4
+
+// Anonymizer ersetzt personenbezogene Daten in Ergebnissen durch Platzhalter wie
+// "[PERSON-1]", damit sie z.B. an Analyse-Teams gehen können. Gleiche Werte erhalten
+// dieselben Platzhalter, auch über Dateien und Läufe hinweg; Groß-/Kleinschreibung und
+// Leerzeichen spielen dabei keine Rolle. Die Zuordnung liegt getrennt in MappingFile und
+// lässt sich mit Restore umkehren.
+type Anonymizer struct {
+	// Fields ordnet JSON-Feldern eine Kategorie zu, z.B. {"name": PIIPerson}. Ein Name ohne
+	// Punkt trifft das Feld in jeder Tiefe, ein Pfad wie "buyer.name" nur dort (Arrays ohne
+	// Index). Objekte und Arrays werden samt Inhalt ersetzt.
+	Fields map[string]string
+	// Patterns ersetzt zusätzlich E-Mail-Adressen, IBANs und Telefonnummern in allen Textwerten.
+	Patterns bool
+	// MappingFile nimmt die Zuordnung Platzhalter -> Originalwert auf (JSON, Modus 0600). Die
+	// Datei gehört nicht zu den weitergegebenen Ergebnissen und sollte außerhalb davon liegen.
+	MappingFile string
+
+	mu     sync.Mutex
+	tokens map[string]string // Kategorie + Schlüssel des Werts -> Platzhalter
+	values map[string]string // Platzhalter -> Originalwert
+	counts map[string]int    // höchste Nummer je Kategorie
+	dirty  bool
+}
+
+// NewAnonymizer lädt die Zuordnung aus mappingFile, falls vorhanden.
+func NewAnonymizer(mappingFile string, fields map[string]string) (*Anonymizer, error) {
+	for field, category := range fields {
+		if !piiCategoryPattern.MatchString(category) {
+			return nil, fmt.Errorf("invalid category %q for field %s", category, field)
+		}
+	}
+	a := &Anonymizer{
+		Fields:      fields,
+		MappingFile: mappingFile,
+		tokens:      map[string]string{},
+		values:      map[string]string{},
+		counts:      map[string]int{},
+	}
+	if mappingFile == "" {
+		return a, nil
+	}
+	data, err := os.ReadFile(mappingFile)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid anonymization mapping %s: %w", mappingFile, err)
+	}
+	for token, value := range values {
+		m := piiTokenPattern.FindStringSubmatch(token)
+		if m == nil || m[0] != token {
+			return nil, fmt.Errorf("invalid anonymization mapping %s: bad token %q", mappingFile, token)
+		}
+		n, _ := strconv.Atoi(m[2])
+		a.counts[m[1]] = max(a.counts[m[1]], n)
+		a.tokens[piiKey(m[1], value)] = token
+		a.values[token] = value
+	}
+	return a, nil
+}
+
+// piiKey vereinheitlicht einen Wert, damit Schreibvarianten derselben Angabe denselben
+// Platzhalter erhalten.
+func piiKey(category, value string) string {
+	fields := strings.Fields(strings.ToLower(value))
+	if category == PIIIBAN || category == PIIPhone {
+		return category + "\x00" + strings.Join(fields, "")
+	}
+	return category + "\x00" + strings.Join(fields, " ")
+}
+
+// token liefert den Platzhalter für value; a.mu muss gesperrt sein.
+func (a *Anonymizer) token(category, value string) string {
+	key := piiKey(category, value)
+	if token, ok := a.tokens[key]; ok {
+		return token
+	}
+	a.counts[category]++
+	token := fmt.Sprintf("[%s-%d]", category, a.counts[category])
+	a.tokens[key] = token
+	a.values[token] = strings.TrimSpace(value)
+	a.dirty = true
+	return token
+}
+
+// Anonymize ersetzt die personenbezogenen Daten in content. JSON wird nach Fields und
+// Patterns bearbeitet und kompakt zurückgegeben, anderer Text nur nach Patterns. Die
+// Zuordnung ist danach erst im Speicher, siehe Save.
+func (a *Anonymizer) Anonymize(content string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var v any
+	if err := DecodeJSON([]byte(content), &v, NumberJSON); err != nil {
+		return a.replacePatterns(content), nil
+	}
+	v = a.anonymizeValue(v, "", "")
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", log.WrapError(err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// anonymizeValue bearbeitet v unter path; category ist gesetzt, wenn ein übergeordnetes
+// Feld in Fields steht.
+func (a *Anonymizer) anonymizeValue(v any, path, category string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// sortiert, damit die Nummern der Platzhalter nicht vom Zufall abhängen
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			item := v[key]
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			itemCategory := category
+			if c, ok := a.Fields[itemPath]; ok {
+				itemCategory = c
+			} else if c, ok := a.Fields[key]; ok {
+				itemCategory = c
+			}
+			v[key] = a.anonymizeValue(item, itemPath, itemCategory)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = a.anonymizeValue(item, path, category)
+		}
+	case string:
+		if category != "" && strings.TrimSpace(v) != "" {
+			return a.token(category, v)
+		}
+		return a.replacePatterns(v)
+	case json.Number:
+		if category != "" {
+			return a.token(category, v.String())
+		}
+	case float64:
+		if category != "" {
+			return a.token(category, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	return v
+}
+
+// replacePatterns ersetzt die Treffer von piiPatterns; a.mu muss gesperrt sein.
+func (a *Anonymizer) replacePatterns(text string) string {
+	if !a.Patterns {
+		return text
+	}
+	for _, p := range piiPatterns {
+		text = p.re.ReplaceAllStringFunc(text, func(match string) string {
+			if piiTokenPattern.MatchString(match) {
+				return match
+			}
+			return a.token(p.category, match)
+		})
+	}
+	return text
+}
+
+// AnonymizeText ersetzt in freiem Text, z.B. Zitaten, die bereits zugeordneten Werte und
+// bei Patterns die erkannten Muster. Neue Namen erkennt es nicht.
+func (a *Anonymizer) AnonymizeText(text string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.replacePatterns(a.replaceKnown(text))
+}
+
+// replaceKnown ersetzt die bereits zugeordneten Werte in text; a.mu muss gesperrt sein.
+func (a *Anonymizer) replaceKnown(text string) string {
+	// längere Werte zuerst, damit "Max Muster" nicht als "[PERSON-2] Muster" endet
+	tokens := slices.Collect(maps.Keys(a.values))
+	slices.SortFunc(tokens, func(x, y string) int {
+		return len(a.values[y]) - len(a.values[x])
+	})
+	for _, token := range tokens {
+		if value := a.values[token]; utf8.RuneCountInString(value) >= minKnownValue {
+			text = strings.ReplaceAll(text, value, token)
+		}
+	}
+	return text
+}
+
+// anonymizeAt anonymisiert einen einzelnen Wert, der im Ergebnis unter path steht, z.B.
+// "items[0].buyer" aus FieldChange.Path. Freier Text außerhalb von Fields wird wie bei
+// AnonymizeText behandelt.
+func (a *Anonymizer) anonymizeAt(path string, v any) any {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path = piiIndexPattern.ReplaceAllString(path, "")
+	category := ""
+	prefix := ""
+	for _, key := range strings.Split(path, ".") {
+		if prefix != "" {
+			prefix += "."
+		}
+		prefix += key
+		if c, ok := a.Fields[prefix]; ok {
+			category = c
+		} else if c, ok := a.Fields[key]; ok {
+			category = c
+		}
+	}
+	if text, ok := v.(string); ok && category == "" {
+		return a.replacePatterns(a.replaceKnown(text))
+	}
+	return a.anonymizeValue(v, path, category)
+}
+
+// Lookup liefert den Originalwert zu einem Platzhalter.
+func (a *Anonymizer) Lookup(token string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	value, ok := a.values[token]
+	return value, ok
+}
+
+// Restore setzt die Originalwerte wieder ein. Innerhalb von JSON-Strings bleiben sie
+// gültig maskiert; unbekannte Platzhalter bleiben stehen.
+func (a *Anonymizer) Restore(content string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return piiTokenPattern.ReplaceAllStringFunc(content, func(token string) string {
+		value, ok := a.values[token]
+		if !ok {
+			return token
+		}
+		quoted, _ := json.Marshal(value)
+		return string(quoted[1 : len(quoted)-1])
+	})
+}
+
+// Save schreibt die Zuordnung nach MappingFile, sofern sich etwas geändert hat.
+func (a *Anonymizer) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.dirty || a.MappingFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(a.values, "", "  ")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	dir := filepath.Dir(a.MappingFile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return log.WrapError(err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return log.WrapError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return log.WrapError(err)
+	}
+	if err := os.Rename(tmp.Name(), a.MappingFile); err != nil {
+		return log.WrapError(err)
+	}
+	a.dirty = false
+	return nil
+}
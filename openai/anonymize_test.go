@@ -0,0 +1,192 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymizer_ConsistentTokens(t *testing.T) {
+	a, err := NewAnonymizer("", map[string]string{"name": PIIPerson, "buyer.address": PIIAddress, "customerNo": PIIID})
+	require.NoError(t, err)
+	a.Patterns = true
+
+	content, err := a.Anonymize(`{"seller": {"name": "Muster GmbH"}, "buyer": {"name": "Max  Muster",
+		"address": {"street": "Hauptstr. 1", "city": "Berlin"}}, "contact": {"name": "max muster"},
+		"customerNo": 4711, "note": "Rückfragen an max@example.com oder +49 30 1234567",
+		"iban": "DE89 3704 0044 0532 0130 00", "total": 12.50, "paid": true, "dueDate": null}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"seller": {"name": "[PERSON-2]"}, "buyer": {"name": "[PERSON-1]",
+		"address": {"street": "[ADDRESS-2]", "city": "[ADDRESS-1]"}}, "contact": {"name": "[PERSON-1]"},
+		"customerNo": "[ID-1]", "note": "Rückfragen an [EMAIL-1] oder [PHONE-1]",
+		"iban": "[IBAN-1]", "total": 12.50, "paid": true, "dueDate": null}`, content)
+	require.Contains(t, content, `12.50`, "numbers stay exact")
+
+	// Platzhalter gelten über Dokumente hinweg
+	content, err = a.Anonymize(`{"items": [{"name": "MAX MUSTER"}, {"name": "Erika Beispiel"}], "iban": "DE89370400440532013000"}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"items": [{"name": "[PERSON-1]"}, {"name": "[PERSON-3]"}], "iban": "[IBAN-1]"}`, content)
+
+	value, ok := a.Lookup("[PERSON-1]")
+	require.True(t, ok)
+	require.Equal(t, "Max  Muster", value)
+
+	// kein JSON: nur Muster
+	require.Equal(t, "Mail an [EMAIL-1], Max Muster", must(a.Anonymize("Mail an max@example.com, Max Muster")))
+	require.Equal(t, "Zitat von [PERSON-3]: [IBAN-1]", a.AnonymizeText("Zitat von Erika Beispiel: DE89 3704 0044 0532 0130 00"))
+
+	_, err = NewAnonymizer("", map[string]string{"name": "person"})
+	require.ErrorContains(t, err, `invalid category "person"`)
+}
+
+func must(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type sinkFunc func(ctx context.Context, result DocumentResult) error
+
+func (f sinkFunc) Publish(ctx context.Context, result DocumentResult) error {
+	return f(ctx, result)
+}
+
+func TestAnonymizer_MappingFile(t *testing.T) {
+	mappingFile := filepath.Join(t.TempDir(), "private", "mapping.json")
+	a, err := NewAnonymizer(mappingFile, map[string]string{"name": PIIPerson})
+	require.NoError(t, err)
+	content, err := a.Anonymize(`{"name": "Anna \"Nina\" Schmidt"}`)
+	require.NoError(t, err)
+	require.NoFileExists(t, mappingFile)
+	require.NoError(t, a.Save())
+
+	info, err := os.Stat(mappingFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// neuer Lauf: gleiche Werte behalten ihren Platzhalter, neue zählen weiter
+	a, err = NewAnonymizer(mappingFile, map[string]string{"name": PIIPerson})
+	require.NoError(t, err)
+	next, err := a.Anonymize(`[{"name": "Anna \"Nina\" Schmidt"}, {"name": "Jonas Weber"}]`)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"name": "[PERSON-1]"}, {"name": "[PERSON-2]"}]`, next)
+
+	restored := a.Restore(content)
+	require.JSONEq(t, `{"name": "Anna \"Nina\" Schmidt"}`, restored)
+	require.Equal(t, "[PERSON-9] bleibt", a.Restore("[PERSON-9] bleibt"))
+
+	require.NoError(t, os.WriteFile(mappingFile, []byte(`{"PERSON-1": "x"}`), 0600))
+	_, err = NewAnonymizer(mappingFile, nil)
+	require.ErrorContains(t, err, "bad token")
+}
+
+func TestBatchConverter_Anonymizer(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF), 0644))
+	}
+	dest := filepath.Join(t.TempDir(), "out")
+	mappingFile := filepath.Join(t.TempDir(), "mapping.json")
+
+	bc := NewBatchConverter(newBatchTestService(t, func() string { return `{"customer": "Max Muster", "amount": 10}` }), "system", src, dest)
+	var err error
+	bc.Anonymizer, err = NewAnonymizer(mappingFile, map[string]string{"customer": PIIPerson})
+	require.NoError(t, err)
+	var published []DocumentResult
+	bc.Sinks = []ResultSink{sinkFunc(func(ctx context.Context, r DocumentResult) error {
+		published = append(published, r)
+		return nil
+	})}
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Converted)
+
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		data, err := os.ReadFile(filepath.Join(dest, name))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"customer": "[PERSON-1]", "amount": 10}`, string(data))
+	}
+	require.Len(t, published, 2)
+	require.NotContains(t, published[0].Content, "Muster")
+
+	// kein Original im Zielordner, auch nicht in Journal, Index oder Provenienz
+	files := 0
+	require.NoError(t, filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		files++
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NotContains(t, string(data), "Muster", path)
+		return nil
+	}))
+	require.Greater(t, files, 2)
+
+	// fortgesetzter Lauf findet das Journal neben der Zuordnung
+	result, err = bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Skipped)
+
+	bc.JournalFile = filepath.Join(dest, "journal.jsonl")
+	_, err = bc.Run()
+	require.ErrorContains(t, err, "must not be in the destination folder")
+
+	data, err := os.ReadFile(mappingFile)
+	require.NoError(t, err)
+	var mapping map[string]string
+	require.NoError(t, json.Unmarshal(data, &mapping))
+	require.Equal(t, map[string]string{"[PERSON-1]": "Max Muster"}, mapping)
+}
+
+func TestBatchConverter_AnonymizesEvalChanges(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	hash, err := FileHash(filepath.Join(src, "a.pdf"))
+	require.NoError(t, err)
+	dest := filepath.Join(t.TempDir(), "out")
+
+	ai := newBatchTestService(t, func() string {
+		return `{"customer": "Max Muster", "contact": "max@example.com", "amount": 10}`
+	})
+	ai.Corrections = NewCorrectionStore()
+	require.NoError(t, ai.Corrections.RecordCorrection(hash,
+		json.RawMessage(`{"customer": "Erika Schmidt", "contact": "erika@example.com", "amount": 12}`)))
+	bc := NewBatchConverter(ai, "system", src, dest)
+	bc.Anonymizer, err = NewAnonymizer(filepath.Join(t.TempDir(), "mapping.json"), map[string]string{"customer": PIIPerson})
+	require.NoError(t, err)
+	bc.Anonymizer.Patterns = true
+	var published []DocumentResult
+	bc.Sinks = []ResultSink{sinkFunc(func(ctx context.Context, r DocumentResult) error {
+		published = append(published, r)
+		return nil
+	})}
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, []FieldChange{
+		{Path: "amount", Kind: DiffChanged, Old: float64(12), New: float64(10)},
+		{Path: "contact", Kind: DiffChanged, Old: "[EMAIL-2]", New: "[EMAIL-1]"},
+		{Path: "customer", Kind: DiffChanged, Old: "[PERSON-2]", New: "[PERSON-1]"},
+	}, result.Documents[0].EvalChanges)
+	require.Len(t, published, 1)
+	require.Equal(t, result.Documents[0].EvalChanges, published[0].EvalChanges)
+
+	require.NoError(t, filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		for _, value := range []string{"Muster", "Schmidt", "@example.com"} {
+			require.NotContains(t, string(data), value, path)
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// modulePrefix ist der Importpfad dieses Moduls samt "/". Frames darin überspringt
+// callerOf, damit Aufrufe über domain oder BatchConverter dem Aufrufer der Bibliothek
+// zugeordnet werden.
+var modulePrefix = path.Dir(reflect.TypeOf(AiCommunicationService{}).PkgPath()) + "/"
+
+// WithComponent ordnet Kosten und Audit-Einträge dieses Aufrufs einem Teilsystem zu, z.B.
+// "invoice-import", und ersetzt Service.Component und das Paket des Aufrufers.
+func WithComponent(component string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.component = component
+	}
+}
+
+// attribute setzt Aufrufer und, falls nicht vorgegeben, die Komponente des Aufrufs. Muss in der
+// Goroutine des Aufrufers laufen, sonst fehlt er im Stack.
+func (cfg *requestConfig) attribute() {
+	pkg, caller := callerOf()
+	cfg.caller = caller
+	if cfg.component == "" {
+		cfg.component = pkg
+	}
+}
+
+// callerOf liefert Paket und Funktion des ersten Aufrufers außerhalb dieses Moduls; Tests
+// des Moduls zählen als Aufrufer. Leer, wenn der Stack nur aus Bibliothek und Runtime besteht.
+func callerOf() (pkg, function string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		f, more := frames.Next()
+		internal := strings.HasPrefix(f.Function, modulePrefix) && !strings.HasSuffix(f.File, "_test.go")
+		if f.Function != "" && !internal && !strings.HasPrefix(f.Function, "runtime.") {
+			return funcPackage(f.Function)
+		}
+		if !more {
+			return "", ""
+		}
+	}
+}
+
+// funcPackage zerlegt einen Funktionsnamen wie "example.com/app/billing.(*Job).Run" in
+// Paket und Funktion "billing.(*Job).Run".
+func funcPackage(name string) (pkg, function string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return name, name[slash+1:]
+	}
+	return name[:slash+1+dot], name[slash+1:]
+}
+
+// CostsByComponent liefert die geschätzten Kosten (USD) im Zeitraum [start, end) je
+// Komponente, siehe WithComponent. Aufrufe ohne Zuordnung stehen unter "".
+func (ai *AiCommunicationService) CostsByComponent(start, end time.Time) map[string]float64 {
+	ai.costsMu.Lock()
+	defer ai.costsMu.Unlock()
+	costs := map[string]float64{}
+	for _, cost := range ai.Costs {
+		if !cost.Timestamp.Before(start) && cost.Timestamp.Before(end) {
+			costs[cost.Component] += cost.TotalCost
+		}
+	}
+	return costs
+}
+
+// AuditCostsByComponent summiert die Kosten von Audit-Einträgen im Zeitraum [start, end) je
+// Komponente, z.B. über die Logs eines Monats für die Aufteilung der Rechnung.
+func AuditCostsByComponent(records []AuditRecord, start, end time.Time) map[string]float64 {
+	costs := map[string]float64{}
+	for _, rec := range records {
+		if !rec.Time.Before(start) && rec.Time.Before(end) {
+			costs[rec.Component] += rec.Cost
+		}
+	}
+	return costs
+}
@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncPackage(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"example.com/app/billing.(*Job).Run":   {"example.com/app/billing", "billing.(*Job).Run"},
+		"example.com/app/billing.Import.func1": {"example.com/app/billing", "billing.Import.func1"},
+		"main.main":                            {"main", "main.main"},
+		"example.com/v2.pkg/x.Do":              {"example.com/v2.pkg/x", "x.Do"},
+	} {
+		pkg, function := funcPackage(name)
+		require.Equal(t, want, [2]string{pkg, function}, name)
+	}
+}
+
+func TestGenerateContent_Attribution(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+
+	_, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/dchaykin/myailib/openai", ai.Costs[0].Component)
+	require.Equal(t, "openai.TestGenerateContent_Attribution", ai.Costs[0].Caller)
+	require.Equal(t, ai.Costs[0].Component, audit.records[0].Component)
+	require.Equal(t, ai.Costs[0].Caller, audit.records[0].Caller)
+
+	ai.Component = "reporting"
+	_, err = ai.GenerateContent("system")
+	require.NoError(t, err)
+	_, err = ai.GenerateContent("system", WithComponent("invoice-import"))
+	require.NoError(t, err)
+	require.Equal(t, "reporting", ai.Costs[1].Component)
+	require.Equal(t, "invoice-import", audit.records[2].Component)
+	require.Equal(t, "openai.TestGenerateContent_Attribution", audit.records[2].Caller)
+
+	start, end := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+	costs := ai.CostsByComponent(start, end)
+	require.Len(t, costs, 3)
+	require.InDelta(t, ai.Costs[2].TotalCost, costs["invoice-import"], 1e-9)
+	require.Equal(t, costs, AuditCostsByComponent(audit.records, start, end))
+	require.Empty(t, AuditCostsByComponent(audit.records, end, end.Add(time.Hour)))
+}
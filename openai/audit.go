@@ -0,0 +1,234 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// AuditRecord beschreibt einen Request an die API für das Audit-Log.
+type AuditRecord struct {
+	Time             time.Time `json:"time"`
+	Tenant           string    `json:"tenant,omitempty"`
+	User             string    `json:"user,omitempty"`
+	Component        string    `json:"component,omitempty"` // Teilsystem, siehe WithComponent
+	Caller           string    `json:"caller,omitempty"`    // aufrufende Funktion
+	Model            string    `json:"model"`
+	PromptVersion    string    `json:"promptVersion,omitempty"`
+	DocumentType     string    `json:"documentType,omitempty"`
+	Variant          string    `json:"variant,omitempty"`
+	FinishReason     string    `json:"finishReason,omitempty"`
+	PromptTokens     int64     `json:"promptTokens,omitempty"`
+	CompletionTokens int64     `json:"completionTokens,omitempty"`
+	Cost             float64   `json:"cost,omitempty"`       // USD
+	Tool             string    `json:"tool,omitempty"`       // Tool-Aufruf eines Agenten
+	ToolStatus       string    `json:"toolStatus,omitempty"` // siehe ToolCallStatus
+	Error            string    `json:"error,omitempty"`
+}
+
+// AuditLog nimmt Audit-Einträge entgegen. Implementierungen müssen nebenläufig nutzbar sein.
+type AuditLog interface {
+	Record(rec AuditRecord) error
+}
+
+// FileAuditLog schreibt Audit-Einträge als JSON-Lines-Datei (nur anhängend).
+type FileAuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    io.WriteCloser // Kompression, nil = unkomprimiert
+	enc  *Encryption    // nil = unverschlüsselt
+}
+
+func OpenAuditLog(path string) (*FileAuditLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	return &FileAuditLog{file: f}, nil
+}
+
+// OpenEncryptedAuditLog wie OpenAuditLog, verschlüsselt aber jeden Eintrag einzeln (eine
+// base64-Zeile je Eintrag, Datei mit Modus 0600). Lesen mit ReadEncryptedAuditLog.
+func OpenEncryptedAuditLog(path string, enc *Encryption) (*FileAuditLog, error) {
+	if enc == nil {
+		return nil, fmt.Errorf("encrypted audit log %s needs an encryption", path)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	return &FileAuditLog{file: f, enc: enc}, nil
+}
+
+// OpenCompressedAuditLog wie OpenAuditLog, schreibt aber komprimiert (siehe Compression).
+// Jedes Öffnen hängt einen eigenen komprimierten Abschnitt an; nach jedem Eintrag wird
+// geleert, soweit das Verfahren Flush bietet. Lesen mit ReadAuditLog oder OpenDecompressed.
+func OpenCompressedAuditLog(path, compression string) (*FileAuditLog, error) {
+	c, err := lookupCompression(compression)
+	if err != nil {
+		return nil, err
+	}
+	a, err := OpenAuditLog(path)
+	if err != nil || c == nil {
+		return a, err
+	}
+	if a.w, err = c.NewWriter(a.file); err != nil {
+		a.file.Close()
+		return nil, log.WrapError(err)
+	}
+	return a, nil
+}
+
+// ReadAuditLog liest ein Audit-Log, auch komprimiert. Bricht ein komprimiertes Log nach
+// einem Absturz mitten im letzten Abschnitt ab, kommen die Einträge bis dahin.
+func ReadAuditLog(path string) ([]AuditRecord, error) {
+	return readAuditLog(path, nil)
+}
+
+// ReadEncryptedAuditLog liest ein mit OpenEncryptedAuditLog geschriebenes Audit-Log;
+// unverschlüsselte Einträge aus der Zeit davor werden mitgelesen.
+func ReadEncryptedAuditLog(path string, enc *Encryption) ([]AuditRecord, error) {
+	return readAuditLog(path, enc)
+}
+
+func readAuditLog(path string, enc *Encryption) ([]AuditRecord, error) {
+	r, err := OpenDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' {
+			if enc == nil {
+				return records, fmt.Errorf("audit log %s is encrypted, use ReadEncryptedAuditLog", path)
+			}
+			if line, err = openAuditLine(enc, line); err != nil {
+				return records, fmt.Errorf("invalid audit record in %s: %w", path, err)
+			}
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return records, fmt.Errorf("invalid audit record in %s: %w", path, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Warn("audit log %s ends with an incomplete block", path)
+			return records, nil
+		}
+		return records, log.WrapError(err)
+	}
+	return records, nil
+}
+
+func openAuditLine(enc *Encryption, line []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return enc.Open(context.Background(), sealed)
+}
+
+func (a *FileAuditLog) Record(rec AuditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	if a.enc != nil {
+		sealed, err := a.enc.Seal(context.Background(), data)
+		if err != nil {
+			return err
+		}
+		data = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.w == nil {
+		if _, err := a.file.Write(append(data, '\n')); err != nil {
+			return log.WrapError(err)
+		}
+		return nil
+	}
+	if _, err := a.w.Write(append(data, '\n')); err != nil {
+		return log.WrapError(err)
+	}
+	if f, ok := a.w.(interface{ Flush() error }); ok {
+		return log.WrapError(f.Flush())
+	}
+	return nil
+}
+
+func (a *FileAuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.w != nil {
+		if err := a.w.Close(); err != nil {
+			a.file.Close()
+			return log.WrapError(err)
+		}
+	}
+	return a.file.Close()
+}
+
+// HashUserID bildet eine nicht rückrechenbare, stabile ID für das user-Feld, damit keine
+// Klarnamen oder E-Mail-Adressen an OpenAI gehen.
+func HashUserID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// WithUser setzt für diesen Aufruf das user-Feld (z.B. HashUserID(tenant+"/"+user)),
+// damit OpenAI Missbrauch dem richtigen Nutzer zuordnet. Die ID landet auch in Costs
+// und im Audit-Log.
+func WithUser(user string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.user = user
+	}
+}
+
+// audit schreibt einen Eintrag, sofern ein Audit-Log gesetzt ist. Fehler werden nur
+// protokolliert, der Request selbst ist bereits erfolgt.
+func (ai *AiCommunicationService) audit(rec AuditRecord) {
+	if ai.Audit == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if err := ai.Audit.Record(rec); err != nil {
+		log.Warn("audit record failed: %v", err)
+	}
+}
+
+// auditRecord füllt die Felder aus der Konfiguration des Aufrufs.
+func (ai *AiCommunicationService) auditRecord(systemMessage string, cfg requestConfig) AuditRecord {
+	return AuditRecord{
+		User:          cfg.user,
+		Component:     cfg.component,
+		Caller:        cfg.caller,
+		Model:         string(cfg.model),
+		PromptVersion: ai.promptVersion(systemMessage, cfg),
+		DocumentType:  cfg.documentType,
+		Variant:       cfg.variant,
+	}
+}
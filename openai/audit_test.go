@@ -0,0 +1,87 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryAuditLog struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (m *memoryAuditLog) Record(rec AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, rec)
+	return nil
+}
+
+func TestGenerateContent_UserAndAudit(t *testing.T) {
+	var body map[string]any
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+	user := HashUserID("tenant-1/alice@example.com")
+
+	_, err := ai.GenerateContent("system", WithUser(user), WithDocumentType("invoice"))
+	require.NoError(t, err)
+
+	require.Equal(t, user, body["user"])
+	require.Equal(t, user, ai.Costs[0].User)
+	require.Len(t, audit.records, 1)
+	rec := audit.records[0]
+	require.Equal(t, user, rec.User)
+	require.Equal(t, "invoice", rec.DocumentType)
+	require.Equal(t, "stop", rec.FinishReason)
+	require.EqualValues(t, 100, rec.PromptTokens)
+	require.InDelta(t, ai.TotalCosts(), rec.Cost, 1e-9)
+	require.Empty(t, rec.Error)
+}
+
+func TestGenerateContent_AuditsFailures(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": {"message": "bad", "type": "invalid_request_error", "param": null, "code": null}}`))
+	})
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+
+	_, err := ai.GenerateContent("system")
+	require.Error(t, err)
+	require.Len(t, audit.records, 1)
+	require.Contains(t, audit.records[0].Error, "bad")
+}
+
+func TestFileAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := OpenAuditLog(path)
+	require.NoError(t, err)
+	require.NoError(t, a.Record(AuditRecord{Model: "gpt-4.1", User: "u1"}))
+	require.NoError(t, a.Record(AuditRecord{Model: "gpt-4.1", User: "u2"}))
+	require.NoError(t, a.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var users []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		users = append(users, rec.User)
+	}
+	require.Equal(t, []string{"u1", "u2"}, users)
+}
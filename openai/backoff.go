@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffPolicy legt die Wartezeiten zwischen Wiederholungen fest, wenn der Server
+// keine Wartezeit vorgibt. Die Wartezeit wächst je Versuch um Multiplier bis Max.
+type BackoffPolicy struct {
+	Initial    time.Duration // Wartezeit vor der ersten Wiederholung, Default: 1s
+	Max        time.Duration // Obergrenze, Default: 30s
+	Multiplier float64       // Default: 2
+	Jitter     float64       // zufällige Abweichung als Anteil (0.2 = ±20 %), 0 = keine
+}
+
+// DefaultBackoffPolicy wird verwendet, wenn am Service keine Policy gesetzt ist.
+var DefaultBackoffPolicy = BackoffPolicy{
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Delay liefert die Wartezeit vor der Wiederholung nach dem Versuch attempt (0-basiert).
+func (p BackoffPolicy) Delay(attempt int) time.Duration {
+	initial := p.Initial
+	if initial <= 0 {
+		initial = DefaultBackoffPolicy.Initial
+	}
+	maxDelay := p.Max
+	if maxDelay <= 0 {
+		maxDelay = DefaultBackoffPolicy.Max
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultBackoffPolicy.Multiplier
+	}
+
+	d := float64(initial) * math.Pow(multiplier, float64(attempt))
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(min(d, float64(maxDelay)))
+}
+
+// sleepContext wartet d, bricht aber sofort mit ctx.Err() ab, wenn der Kontext endet.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package openai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffPolicy_Delay(t *testing.T) {
+	p := BackoffPolicy{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	require.Equal(t, 100*time.Millisecond, p.Delay(0))
+	require.Equal(t, 200*time.Millisecond, p.Delay(1))
+	require.Equal(t, 800*time.Millisecond, p.Delay(3))
+	require.Equal(t, time.Second, p.Delay(10))
+}
+
+func TestBackoffPolicy_Jitter(t *testing.T) {
+	p := BackoffPolicy{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+	for range 100 {
+		d := p.Delay(1)
+		require.GreaterOrEqual(t, d, 1600*time.Millisecond)
+		require.LessOrEqual(t, d, 2400*time.Millisecond)
+	}
+}
+
+func TestSleepContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := sleepContext(ctx, time.Minute)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), time.Second)
+
+	require.NoError(t, sleepContext(context.Background(), time.Millisecond))
+}
@@ -0,0 +1,95 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// indexFileName ist die Ergebnisliste eines Zielverzeichnisses, siehe LoadBatchIndex.
+const indexFileName = ".myailib-index.jsonl"
+
+// batchIndex hängt jedes Dokument sofort nach seiner Bearbeitung an die Ergebnisliste an,
+// damit die Buchführung einen Absturz mitten im Lauf übersteht.
+type batchIndex struct {
+	file *os.File
+}
+
+func openBatchIndex(destFolder string) (*batchIndex, error) {
+	file, err := os.OpenFile(filepath.Join(destFolder, indexFileName), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	terminateLastLine(file)
+	return &batchIndex{file: file}, nil
+}
+
+// terminateLastLine beginnt nach einem Absturz mitten in der Zeile eine neue, damit der
+// nächste angehängte Eintrag lesbar bleibt. file muss lesbar sein.
+func terminateLastLine(file *os.File) {
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			file.Write([]byte{'\n'})
+		}
+	}
+}
+
+// add schreibt das Dokument ohne Inhalt; der liegt in OutputFile.
+func (x *batchIndex) add(doc DocumentResult) error {
+	doc.Content = ""
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	if _, err := x.file.Write(append(data, '\n')); err != nil {
+		return log.WrapError(err)
+	}
+	return log.WrapError(x.file.Sync())
+}
+
+func (x *batchIndex) Close() error {
+	return x.file.Close()
+}
+
+// LoadBatchIndex liest die Ergebnisliste eines Zielverzeichnisses über alle bisherigen Läufe.
+// Je Quelldatei gilt der letzte Eintrag; übersprungene Dateien behalten ein früheres Ergebnis.
+func LoadBatchIndex(destFolder string) (*BatchResult, error) {
+	f, err := os.Open(filepath.Join(destFolder, indexFileName))
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	defer f.Close()
+
+	docs := map[string]DocumentResult{}
+	order := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var doc DocumentResult
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			// abgeschnittene letzte Zeile nach Absturz ignorieren
+			log.Warn("skipping corrupt index line in %s: %v", destFolder, err)
+			continue
+		}
+		existing, ok := docs[doc.SourceFile]
+		if !ok {
+			order = append(order, doc.SourceFile)
+		} else if doc.Status == DocumentSkipped && existing.Status != DocumentSkipped {
+			continue
+		}
+		docs[doc.SourceFile] = doc
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, log.WrapError(err)
+	}
+
+	result := &BatchResult{Documents: []DocumentResult{}}
+	for _, sourceFile := range order {
+		result.add(docs[sourceFile])
+	}
+	return result, nil
+}
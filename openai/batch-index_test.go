@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchConverter_WritesIndexIncrementally(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF), 0644))
+	}
+	dest := filepath.Join(t.TempDir(), "out")
+	ai := newBatchTestService(t, func() string { return `{"ok": true}` })
+
+	bc := NewBatchConverter(ai, "system", src, dest)
+	_, err := bc.Run()
+	require.NoError(t, err)
+
+	// abgebrochene Zeile nach einem Absturz
+	f, err := os.OpenFile(filepath.Join(dest, indexFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"sourceFile": "`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// zweiter Lauf überspringt alles, die Liste behält die Ergebnisse
+	require.NoError(t, os.WriteFile(filepath.Join(src, "c.pdf"), []byte(testPDF), 0644))
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Skipped)
+	require.Equal(t, 1, result.Converted)
+
+	index, err := LoadBatchIndex(dest)
+	require.NoError(t, err)
+	require.Equal(t, 3, index.Converted)
+	require.Len(t, index.Documents, 3)
+	require.Equal(t, filepath.Join(src, "a.pdf"), index.Documents[0].SourceFile)
+	require.Empty(t, index.Documents[0].Content)
+	require.InDelta(t, 3*costOf(100, 20), index.TotalCost, 1e-9)
+}
@@ -0,0 +1,760 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+const (
+	// journalFileName ist das Journal, mit dem ein Batch-Lauf nach einem Absturz fortsetzt.
+	journalFileName = ".myailib-journal.jsonl"
+	// lockFileName sperrt das Zielverzeichnis für andere Läufe.
+	lockFileName        = ".myailib.lock"
+	defaultBatchLockTTL = 10 * time.Minute
+)
+
+// DocumentStatus ist der Ausgang der Konvertierung eines Dokuments.
+type DocumentStatus string
+
+const (
+	DocumentDone    DocumentStatus = "done"
+	DocumentFailed  DocumentStatus = "failed"
+	DocumentSkipped DocumentStatus = "skipped"
+	// DocumentBadInput: leeres oder beschädigtes Dokument, vor dem Upload abgelehnt (ErrBadInput)
+	DocumentBadInput DocumentStatus = "bad-input"
+)
+
+// DocumentResult ist das Ergebnis der Konvertierung eines Dokuments.
+type DocumentResult struct {
+	SourceFile string         `json:"sourceFile"`
+	OutputFile string         `json:"outputFile"`
+	Content    string         `json:"content"`
+	Cost       float64        `json:"cost"` // USD, 0 wenn aus Journal oder Cache
+	Status     DocumentStatus `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Reason     string         `json:"reason,omitempty"`  // Grund bei DocumentSkipped
+	Variant    string         `json:"variant,omitempty"` // Varianten-ID, wenn ein Experiment läuft
+	Provenance *Provenance    `json:"provenance,omitempty"`
+	// Confidence enthält bei FieldConfidence die Konfidenz je Feldpfad; Content ist dann nur das Ergebnis.
+	Confidence map[string]FieldConfidence `json:"confidence,omitempty"`
+	Citations  map[string][]Citation      `json:"citations,omitempty"` // bei Citations die Fundstellen je Feldpfad
+	Review     string                     `json:"review,omitempty"`    // Grund, falls zur Prüfung vorgelegt
+	// EvalChanges sind die Abweichungen von einer vorhandenen Korrektur, siehe CorrectionStore.Evaluate.
+	EvalChanges []FieldChange `json:"evalChanges,omitempty"`
+	// Unsupported sind die Angaben, die der Prüfdurchlauf nicht belegen konnte, siehe VerificationPolicy.
+	Unsupported []UnsupportedClaim `json:"unsupported,omitempty"`
+	Profile     *DocumentProfile   `json:"profile,omitempty"`     // Ergebnis des Vorlaufs, siehe BatchConverter.Profiler
+	ContentHash string             `json:"contentHash,omitempty"` // bei OutputNamingContent der SHA-256 des Ergebnisses
+	// Violations sind bei Schema die Verstöße der Antwort; das Dokument gilt dann als fehlgeschlagen.
+	Violations  []SchemaViolation `json:"violations,omitempty"`
+	CompletedAt time.Time         `json:"completedAt"`
+}
+
+// BatchResult ist der Bericht über einen Batch-Lauf. Bei Abbruch enthält er den bis
+// dahin erreichten Stand; nicht begonnene Dateien sind als übersprungen vermerkt.
+type BatchResult struct {
+	Documents []DocumentResult `json:"documents"`
+	Converted int              `json:"converted"`
+	Failed    int              `json:"failed"`
+	Skipped   int              `json:"skipped"`
+	BadInput  int              `json:"badInput"`
+	TotalCost float64          `json:"totalCost"`
+	Cancelled bool             `json:"cancelled"`
+	Review    int              `json:"review"` // zur Prüfung vorgelegte Dokumente
+	// EvalCases zählt Dokumente mit Korrektur, EvalMatches davon die ohne Abweichung.
+	EvalCases   int `json:"evalCases,omitempty"`
+	EvalMatches int `json:"evalMatches,omitempty"`
+	// BudgetExceeded meldet den Stopp wegen MaxCost; BudgetNeeded schätzt dann, welches
+	// Budget (USD) für den kompletten Lauf nötig gewesen wäre.
+	BudgetExceeded bool      `json:"budgetExceeded"`
+	BudgetNeeded   float64   `json:"budgetNeeded,omitempty"`
+	StartedAt      time.Time `json:"startedAt"`
+	FinishedAt     time.Time `json:"finishedAt"`
+
+	index *batchIndex
+}
+
+func (r *BatchResult) add(doc DocumentResult) {
+	if r.index != nil {
+		if err := r.index.add(doc); err != nil {
+			log.Warn("failed to update result index: %v", err)
+		}
+	}
+	r.Documents = append(r.Documents, doc)
+	r.TotalCost += doc.Cost
+	if doc.EvalChanges != nil {
+		r.EvalCases++
+		if len(doc.EvalChanges) == 0 {
+			r.EvalMatches++
+		}
+	}
+	if doc.Review != "" {
+		r.Review++
+	}
+	switch doc.Status {
+	case DocumentDone:
+		r.Converted++
+	case DocumentSkipped:
+		r.Skipped++
+	case DocumentBadInput:
+		r.BadInput++
+	default:
+		r.Failed++
+	}
+}
+
+func (r *BatchResult) skipRemaining(srcFolder string, fileNames []string, reason string) {
+	for _, fileName := range fileNames {
+		r.add(DocumentResult{
+			SourceFile: filepath.Join(srcFolder, fileName),
+			Status:     DocumentSkipped,
+			Reason:     reason,
+		})
+	}
+}
+
+// Job ist das Handle eines im Hintergrund laufenden Batch-Laufs.
+type Job struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *BatchResult
+	err    error
+}
+
+// Cancel beginnt keine weiteren Dateien, bricht laufende Requests ab und kehrt sofort zurück.
+// Den Teilbericht liefert Wait.
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// Done wird geschlossen, sobald der Lauf beendet ist.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait wartet auf das Ende des Laufs und liefert den (ggf. partiellen) Bericht.
+func (j *Job) Wait() (*BatchResult, error) {
+	<-j.done
+	return j.result, j.err
+}
+
+// ResultSink nimmt fertige Ergebnisse entgegen, z.B. um sie an nachgelagerte Systeme weiterzugeben.
+type ResultSink interface {
+	Publish(ctx context.Context, result DocumentResult) error
+}
+
+// BatchConverter konvertiert alle Dateien eines Verzeichnisses und legt die Ergebnisse
+// unter gleichem Namen im Zielverzeichnis ab.
+type BatchConverter struct {
+	Service         *AiCommunicationService
+	SystemMessage   string
+	SrcFolder       string
+	DestFolder      string
+	Pattern         string       // Glob auf den Dateinamen, z.B. "*.pdf"; leer = alle Dateien
+	MaxCost         float64      // USD, 0 = unbegrenzt; vor jeder Datei wird mit der Kostenschätzung geprüft
+	DocumentType    string       // Kategorie für Kostenerfassung und -schätzung, z.B. "invoice"
+	ContinueOnError bool         // Fehler vermerken und weitermachen statt abzubrechen
+	Sinks           []ResultSink // erhalten jedes fertige Ergebnis, optional
+	Experiment      *Experiment  // verteilt die Dateien auf Varianten (Schlüssel: Dateiname), optional
+	// WriteProvenance legt neben jedem Ergebnis <Datei>.provenance.json ab; mit ProvenanceKey
+	// wird sie per HMAC signiert. Die Herkunft steht unabhängig davon in DocumentResult.
+	WriteProvenance bool
+	ProvenanceKey   []byte
+	// Canonicalize schreibt JSON-Ergebnisse in kanonischer Form (siehe CanonicalJSON), damit
+	// erneute Läufe bei gleichem Inhalt byte-identische Dateien erzeugen. Default: an.
+	Canonicalize bool
+	// FieldConfidence fordert Konfidenz und Seitenangaben je Feld an, siehe WithFieldConfidence.
+	FieldConfidence bool
+	// Citations fordert Fundstellen (Seite, Zitat) je Feld an, siehe WithCitations.
+	Citations bool
+	// Review erhält Ergebnisse, die fehlschlagen, von Validate abgelehnt werden oder Felder
+	// unter MinConfidence enthalten. Optional.
+	Review        []ReviewSink
+	Validate      func(content string) error
+	MinConfidence float64
+	// Schema prüft jede Antwort, siehe WithSchema; Verstöße stehen je Feldpfad in
+	// DocumentResult.Violations. Routen des Profilers können ein eigenes Schema festlegen. Optional.
+	Schema *JSONSchema
+	// Profiler klassifiziert jedes Dokument vorab und wählt danach Prompt, Modell und Schema. Optional.
+	Profiler *DocumentProfiler
+	// FollowSymlinks verarbeitet Dateien hinter symbolischen Links, Default: an. SkipHidden
+	// übergeht Dateien, deren Name mit "." beginnt, Default: aus. MaxFileSize übergeht größere
+	// Dateien vor dem Upload; 0 = Upload-Limit des Services. Übergangene Dateien stehen mit
+	// Grund im Bericht.
+	FollowSymlinks bool
+	SkipHidden     bool
+	MaxFileSize    int64
+	// LockTTL: Ein Lauf sperrt das Zielverzeichnis, ein zweiter Lauf darauf endet mit ErrLocked.
+	// Die Sperre wird laufend erneuert; ist sie älter als LockTTL, gilt der Lauf als
+	// abgestürzt und sie wird übernommen. Default: 10min.
+	LockTTL time.Duration
+	// Permissions legt Rechte und Eigentümer der Ergebnisse und neu angelegter Ordner fest.
+	Permissions OutputPermissions
+	// Compression speichert die Ergebnisse komprimiert unter <Datei><Endung>, z.B. "a.pdf.gz"
+	// mit CompressionGzip; Lesen mit OpenDecompressed. Leer = unkomprimiert.
+	Compression string
+	// Naming legt die Dateinamen der Ergebnisse fest, siehe OutputNamingContent. ObjectStore
+	// ist dabei der Ordner der Ergebnisse; Default: "objects" im Zielordner. Er muss auf
+	// demselben Dateisystem liegen wie der Zielordner.
+	Naming      OutputNaming
+	ObjectStore string
+	// Anonymizer ersetzt personenbezogene Daten in Ergebnis und Zitaten durch Platzhalter,
+	// bevor sie geschrieben werden; auch Validate, Review und Sinks erhalten das anonymisierte
+	// Ergebnis. Das Journal mit den Originalen liegt dann außerhalb des Zielordners, siehe
+	// JournalFile. Optional.
+	Anonymizer *Anonymizer
+	// JournalFile ist das Journal, mit dem ein Lauf nach einem Absturz fortsetzt. Default:
+	// im Zielordner, mit Anonymizer neben Anonymizer.MappingFile. Mit Anonymizer darf es
+	// nicht im Zielordner liegen.
+	JournalFile string
+	// Job benennt den Lauf in CPU- und Heap-Profilen (pprof-Label "job"), z.B. der Name des
+	// Manifests. Optional.
+	Job string
+}
+
+func NewBatchConverter(service *AiCommunicationService, systemMessage, srcFolder, destFolder string) *BatchConverter {
+	return &BatchConverter{
+		Service:        service,
+		SystemMessage:  systemMessage,
+		SrcFolder:      srcFolder,
+		DestFolder:     destFolder,
+		Canonicalize:   true,
+		FollowSymlinks: true,
+	}
+}
+
+// Close schließt Sinks und Review-Ziele, die io.Closer implementieren, z.B. SQLSink.
+func (bc *BatchConverter) Close() error {
+	targets := []any{}
+	for _, sink := range bc.Sinks {
+		targets = append(targets, sink)
+	}
+	for _, sink := range bc.Review {
+		targets = append(targets, sink)
+	}
+	closed := map[io.Closer]bool{}
+	var errs []error
+	for _, target := range targets {
+		c, ok := target.(io.Closer)
+		if !ok || closed[c] {
+			continue
+		}
+		closed[c] = true
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run konvertiert alle Dateien und liefert den Bericht über den Lauf.
+func (bc *BatchConverter) Run() (*BatchResult, error) {
+	return bc.RunContext(context.Background())
+}
+
+// RunContext wie Run; endet ctx, werden keine weiteren Dateien begonnen, laufende
+// Requests abgebrochen und der bis dahin erreichte Stand zurückgegeben.
+func (bc *BatchConverter) RunContext(ctx context.Context) (*BatchResult, error) {
+	ctx, restoreLabels := withJobLabel(ctx, bc.Job)
+	defer restoreLabels()
+	result := &BatchResult{StartedAt: time.Now()}
+	defer func() {
+		result.FinishedAt = time.Now()
+	}()
+
+	compression, err := lookupCompression(bc.Compression)
+	if err != nil {
+		return result, err
+	}
+	if err := validOutputNaming(bc.Naming); err != nil {
+		return result, err
+	}
+	entries, err := os.ReadDir(bc.SrcFolder)
+	if err != nil {
+		return result, err
+	}
+
+	if err := bc.Permissions.mkdirAll(bc.DestFolder); err != nil {
+		return result, fmt.Errorf("failed to create destination folder: %w", err)
+	}
+
+	lockTTL := bc.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = defaultBatchLockTTL
+	}
+	lockPath := filepath.Join(bc.DestFolder, lockFileName)
+	lock, err := acquireFileLock(lockPath, lockTTL)
+	if err != nil {
+		return result, fmt.Errorf("destination folder %s is in use: %w", bc.DestFolder, err)
+	}
+	defer lock.release()
+	defer lock.refresh(lockTTL / 3)()
+	removeStaleOutputSets(bc.DestFolder, 0)
+	if bc.Naming == OutputNamingContent {
+		removeStaleOutputSets(bc.objectStore(), lockTTL)
+	}
+
+	journalFile, err := bc.journalFile()
+	if err != nil {
+		return result, err
+	}
+	journal, err := OpenJournal(journalFile)
+	if err != nil {
+		return result, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer journal.Close()
+
+	// Ergebnisliste nach jeder Datei fortschreiben, siehe LoadBatchIndex
+	result.index, err = openBatchIndex(bc.DestFolder)
+	if err != nil {
+		return result, fmt.Errorf("failed to open result index: %w", err)
+	}
+	defer func() {
+		result.index.Close()
+		result.index = nil
+	}()
+
+	files := bc.selectFiles(entries, result)
+
+	opts := []RequestOption{
+		WithPriority(PriorityBatch),
+		WithTag("convertDir:" + bc.SrcFolder),
+		WithDocumentType(bc.DocumentType),
+	}
+	if bc.FieldConfidence {
+		opts = append(opts, WithFieldConfidence())
+	}
+	if bc.Citations {
+		opts = append(opts, WithCitations())
+	}
+	if bc.Schema != nil {
+		opts = append(opts, WithSchema(bc.Schema))
+	}
+	cfg := bc.Service.newRequestConfig(opts)
+	// nur die Kosten dieses Laufs, nicht die anderer Aufrufer desselben Services
+	spent := 0.0
+	for i, fileName := range files {
+		if ctx.Err() != nil {
+			result.Cancelled = true
+			result.skipRemaining(bc.SrcFolder, files[i:], "cancelled")
+			return result, ctx.Err()
+		}
+		if bc.MaxCost > 0 {
+			if spent+bc.estimateCost(fileName) > bc.MaxCost {
+				result.BudgetExceeded = true
+				result.BudgetNeeded = spent + bc.estimateRemaining(files[i:])
+				result.skipRemaining(bc.SrcFolder, files[i:], "budget exceeded")
+				log.Warn("Budget of $%.4f reached after $%.4f; about $%.4f needed to finish", bc.MaxCost, spent, result.BudgetNeeded)
+				return result, fmt.Errorf("%w: spent $%.4f of $%.4f, about $%.4f needed", ErrBudgetExceeded, spent, bc.MaxCost, result.BudgetNeeded)
+			}
+		}
+
+		fileCfg := cfg
+		if bc.Experiment != nil {
+			fileCfg = bc.Service.newRequestConfig(append(opts, WithExperiment(bc.Experiment, fileName)))
+		}
+		doc, err := bc.convertFile(ctx, journal, fileName, fileCfg, compression)
+		if errors.Is(err, ErrBadInput) {
+			doc.Status = DocumentBadInput
+		}
+		if err != nil && ctx.Err() != nil {
+			result.Cancelled = true
+			result.skipRemaining(bc.SrcFolder, files[i:], "cancelled")
+			return result, ctx.Err()
+		}
+		if doc.Status != DocumentSkipped {
+			bc.review(ctx, &doc, fileCfg, err)
+		}
+		result.add(doc)
+		spent += doc.Cost
+		if doc.Status == DocumentBadInput {
+			// betrifft nur dieses Dokument, der Lauf geht weiter
+			log.Warn("skipping bad input: %v", err)
+			continue
+		}
+		if err != nil {
+			if !bc.ContinueOnError {
+				result.skipRemaining(bc.SrcFolder, files[i+1:], "aborted after error")
+				return result, err
+			}
+			log.Error(log.WrapError(err))
+			continue
+		}
+		if doc.Status != DocumentDone {
+			continue
+		}
+
+		log.Info("Converted file: %s", fileName)
+		bc.publish(ctx, doc)
+	}
+	return result, nil
+}
+
+// journalFile liefert den Pfad des Journals, siehe JournalFile. Mit Anonymizer enthält das
+// Journal die Originale und gehört daher nicht zu den Ergebnissen.
+func (bc *BatchConverter) journalFile() (string, error) {
+	if bc.Anonymizer == nil {
+		if bc.JournalFile != "" {
+			return bc.JournalFile, nil
+		}
+		return filepath.Join(bc.DestFolder, journalFileName), nil
+	}
+	dest, err := filepath.Abs(bc.DestFolder)
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	path := bc.JournalFile
+	if path == "" {
+		if bc.Anonymizer.MappingFile == "" {
+			return "", fmt.Errorf("anonymized results need JournalFile or Anonymizer.MappingFile outside the destination folder")
+		}
+		// je Zielordner ein eigenes Journal, die Zuordnung kann mehreren Läufen dienen
+		path = filepath.Join(filepath.Dir(bc.Anonymizer.MappingFile), ".myailib-journal-"+contentHash(dest)[:16]+".jsonl")
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", log.WrapError(err)
+	}
+	if isWithin(path, dest) {
+		return "", fmt.Errorf("journal %s must not be in the destination folder of anonymized results", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", log.WrapError(err)
+	}
+	return path, nil
+}
+
+// selectFiles liefert die zu konvertierenden Dateien. Dateien, die zum Pattern passen, aber
+// übergangen werden, landen mit Grund als DocumentSkipped im Bericht.
+func (bc *BatchConverter) selectFiles(entries []os.DirEntry, result *BatchResult) []string {
+	maxSize := bc.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = bc.Service.MaxUploadSize
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUploadSize
+	}
+	files := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if bc.Pattern != "" {
+			if ok, _ := filepath.Match(bc.Pattern, name); !ok {
+				continue
+			}
+		}
+		reason := ""
+		var info os.FileInfo
+		var err error
+		if entry.Type()&os.ModeSymlink != 0 {
+			if !bc.FollowSymlinks {
+				reason = "symlink not followed"
+			} else if info, err = os.Stat(filepath.Join(bc.SrcFolder, name)); err != nil {
+				reason = "broken symlink"
+			} else if info.IsDir() {
+				continue
+			}
+		} else if info, err = entry.Info(); err != nil {
+			reason = fmt.Sprintf("cannot stat file: %v", err)
+		}
+		switch {
+		case reason != "":
+		case bc.SkipHidden && strings.HasPrefix(name, "."):
+			reason = "hidden file"
+		case !info.Mode().IsRegular():
+			reason = "not a regular file"
+		case info.Size() > maxSize:
+			reason = fmt.Sprintf("file too large: %d bytes (max %d)", info.Size(), maxSize)
+		}
+		if reason != "" {
+			log.Info("Skipping %s: %s", name, reason)
+			result.skipRemaining(bc.SrcFolder, []string{name}, reason)
+			continue
+		}
+		files = append(files, name)
+	}
+	return files
+}
+
+// evaluate vergleicht das Ergebnis mit einer vorhandenen Korrektur der Quelldatei.
+func (bc *BatchConverter) evaluate(doc *DocumentResult) {
+	if bc.Service.Corrections == nil {
+		return
+	}
+	hash, err := fileSHA256(doc.SourceFile)
+	if err != nil {
+		return
+	}
+	changes, ok, err := bc.Service.Corrections.Evaluate(hash, doc.Content)
+	if err != nil {
+		log.Error(log.WrapError(err))
+		return
+	}
+	if ok {
+		doc.EvalChanges = changes
+	}
+}
+
+func (bc *BatchConverter) estimateCost(fileName string) float64 {
+	info, err := os.Stat(filepath.Join(bc.SrcFolder, fileName))
+	if err != nil {
+		return 0
+	}
+	return bc.Service.EstimateCost(bc.DocumentType, int(info.Size()))
+}
+
+func (bc *BatchConverter) estimateRemaining(fileNames []string) float64 {
+	total := 0.0
+	for _, fileName := range fileNames {
+		total += bc.estimateCost(fileName)
+	}
+	return total
+}
+
+// Start führt den Batch im Hintergrund aus und liefert ein Handle zum Abbrechen.
+func (bc *BatchConverter) Start(ctx context.Context) *Job {
+	ctx, cancel := context.WithCancel(ctx)
+	job := &Job{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(job.done)
+		defer cancel()
+		job.result, job.err = bc.RunContext(ctx)
+	}()
+	return job
+}
+
+func (bc *BatchConverter) convertFile(ctx context.Context, journal *Journal, fileName string, cfg requestConfig, compression *Compression) (DocumentResult, error) {
+	outputName := fileName
+	if compression != nil {
+		outputName += compression.Ext
+	}
+	destFilePath := filepath.Join(bc.DestFolder, outputName)
+	doc := DocumentResult{
+		SourceFile: filepath.Join(bc.SrcFolder, fileName),
+		OutputFile: destFilePath,
+		Status:     DocumentFailed,
+		Variant:    cfg.variant,
+	}
+
+	fileHash, err := fileSHA256(doc.SourceFile)
+	if err != nil {
+		doc.Error = err.Error()
+		return doc, fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	entry, err := journal.Enqueue(JournalEntry{
+		ID:            fileName,
+		SystemMessage: bc.SystemMessage,
+		Prompt:        cfg.prompt,
+		FileName:      doc.SourceFile,
+		InputHash:     journalInputHash(fileHash, bc.SystemMessage, cfg.prompt),
+	})
+	if err != nil {
+		doc.Error = err.Error()
+		return doc, fmt.Errorf("failed to journal %s: %w", fileName, err)
+	}
+
+	doc.Content = entry.Result
+	doc.Profile = entry.Profile
+	systemMessage := bc.SystemMessage
+	usage := &callUsage{}
+	cfg.usage = usage
+	if bc.Profiler != nil {
+		if doc.Profile == nil && entry.Status != JournalDone {
+			profile, err := bc.Profiler.profile(ctx, bc.Service, doc.SourceFile, usage)
+			doc.Cost = usage.cost()
+			if err != nil {
+				journal.MarkFailed(fileName, err)
+				doc.Error = err.Error()
+				return doc, fmt.Errorf("failed to profile %s: %w", fileName, err)
+			}
+			if err := journal.SetProfile(fileName, profile); err != nil {
+				doc.Error = err.Error()
+				return doc, fmt.Errorf("failed to journal %s: %w", fileName, err)
+			}
+			doc.Profile = &profile
+		}
+		if doc.Profile != nil {
+			var folder string
+			systemMessage, cfg, folder = bc.Profiler.apply(*doc.Profile, systemMessage, cfg)
+			if folder != "" {
+				if !filepath.IsAbs(folder) {
+					folder = filepath.Join(bc.DestFolder, folder)
+				}
+				if err := bc.Permissions.mkdirAll(folder); err != nil {
+					doc.Error = err.Error()
+					return doc, fmt.Errorf("failed to create output folder for %s: %w", fileName, err)
+				}
+				destFilePath = filepath.Join(folder, outputName)
+				doc.OutputFile = destFilePath
+			}
+		}
+	}
+
+	contentNaming := bc.Naming == OutputNamingContent
+	if entry.Status == JournalDone {
+		// bei OutputNamingContent steht der Pfad erst mit dem fertigen Inhalt fest, siehe unten
+		if _, err := os.Stat(destFilePath); err == nil && !contentNaming {
+			doc.Status = DocumentSkipped
+			doc.Reason = "already converted"
+			return doc, nil
+		}
+		// Ergebnis liegt im Journal, nur das Schreiben fehlte
+	} else {
+		if p := cfg.verification; p != nil {
+			verification := *p
+			verification.OnResult = func(r VerificationResult) {
+				doc.Unsupported = r.Unsupported
+				if p.OnResult != nil {
+					p.OnResult(r)
+				}
+			}
+			cfg.verification = &verification
+		}
+		doc.Content, err = bc.Service.generateContentWithPDF(ctx, systemMessage, doc.SourceFile, cfg)
+		doc.Cost = usage.cost()
+		if err != nil {
+			journal.MarkFailed(fileName, err)
+			doc.Error = err.Error()
+			var schemaErr *SchemaError
+			if errors.As(err, &schemaErr) {
+				doc.Violations = schemaErr.Violations
+			}
+			return doc, fmt.Errorf("failed to generate content from PDF %s: %w", fileName, err)
+		}
+		if err := journal.MarkDone(fileName, doc.Content); err != nil {
+			doc.Error = err.Error()
+			return doc, fmt.Errorf("failed to journal %s: %w", fileName, err)
+		}
+	}
+
+	if cfg.extraction() {
+		extraction, err := ParseExtraction(doc.Content)
+		if err != nil {
+			doc.Error = err.Error()
+			return doc, fmt.Errorf("invalid result for %s: %w", fileName, err)
+		}
+		doc.Content, doc.Confidence, doc.Citations = string(extraction.Data), extraction.Confidence, extraction.Citations
+	}
+	if bc.Canonicalize {
+		if canonical, err := CanonicalJSON(doc.Content); err == nil {
+			doc.Content = canonical
+		} else {
+			log.Warn("Result for %s is not valid JSON, writing it unchanged: %v", fileName, err)
+		}
+	}
+	bc.evaluate(&doc)
+	if bc.Anonymizer != nil {
+		if err := bc.anonymize(&doc); err != nil {
+			doc.Error = err.Error()
+			return doc, fmt.Errorf("failed to anonymize result for %s: %w", fileName, err)
+		}
+	}
+	doc.Provenance, err = bc.Service.newProvenance(systemMessage, doc.SourceFile, doc.Content, cfg, bc.ProvenanceKey)
+	if err != nil {
+		doc.Error = err.Error()
+		return doc, fmt.Errorf("failed to record provenance for %s: %w", fileName, err)
+	}
+	data := []byte(doc.Content)
+	if compression != nil {
+		if data, err = compression.compress(data); err != nil {
+			doc.Error = err.Error()
+			return doc, fmt.Errorf("failed to compress result for %s: %w", fileName, err)
+		}
+	}
+	outputs := newOutputSet(bc.Permissions)
+	provenanceFile := destFilePath + provenanceSuffix
+	if contentNaming {
+		// Arbeitsverzeichnis im ObjectStore, damit es nicht in den Hash-Ordnern liegen bleibt
+		outputs.stageDir = bc.objectStore()
+		doc.ContentHash = contentHash(doc.Content)
+		provenanceFile = filepath.Join(filepath.Dir(destFilePath), fileName+provenanceSuffix)
+		destFilePath = bc.objectPath(doc.ContentHash, compression)
+		doc.OutputFile = destFilePath
+		if err := bc.Permissions.mkdirAll(filepath.Dir(destFilePath)); err != nil {
+			doc.Error = err.Error()
+			return doc, fmt.Errorf("failed to create object folder for %s: %w", fileName, err)
+		}
+	}
+	if !contentNaming || !objectExists(destFilePath) {
+		outputs.add(destFilePath, data)
+	} else if entry.Status == JournalDone {
+		doc.Status = DocumentSkipped
+		doc.Reason = "already converted"
+		return doc, nil
+	}
+	if bc.WriteProvenance {
+		data, err := marshalProvenance(doc.Provenance)
+		if err != nil {
+			doc.Error = err.Error()
+			return doc, fmt.Errorf("failed to write provenance for %s: %w", destFilePath, err)
+		}
+		outputs.add(provenanceFile, data)
+	}
+	if err := outputs.commit(); err != nil {
+		doc.Error = err.Error()
+		return doc, fmt.Errorf("failed to write content to file %s: %w", destFilePath, err)
+	}
+	doc.Status = DocumentDone
+	doc.CompletedAt = time.Now()
+	return doc, nil
+}
+
+// journalInputHash fasst Datei, System-Message und Prompt zusammen; ändert sich eines davon,
+// wird die Datei trotz Journal-Eintrag erneut konvertiert.
+func journalInputHash(fileHash, systemMessage, prompt string) string {
+	return contentHash(strings.Join([]string{fileHash, systemMessage, prompt}, "\x00"))
+}
+
+// anonymize ersetzt die personenbezogenen Daten in doc und sichert die Zuordnung, bevor
+// das Ergebnis geschrieben wird.
+func (bc *BatchConverter) anonymize(doc *DocumentResult) error {
+	content, err := bc.Anonymizer.Anonymize(doc.Content)
+	if err != nil {
+		return err
+	}
+	if bc.Canonicalize {
+		if canonical, err := CanonicalJSON(content); err == nil {
+			content = canonical
+		}
+	}
+	doc.Content = content
+	for path, citations := range doc.Citations {
+		for i := range citations {
+			citations[i].Quote = bc.Anonymizer.AnonymizeText(citations[i].Quote)
+		}
+		doc.Citations[path] = citations
+	}
+	for i, change := range doc.EvalChanges {
+		doc.EvalChanges[i].Old = bc.Anonymizer.anonymizeAt(change.Path, change.Old)
+		doc.EvalChanges[i].New = bc.Anonymizer.anonymizeAt(change.Path, change.New)
+	}
+	return bc.Anonymizer.Save()
+}
+
+// publish reicht das Ergebnis an alle Sinks weiter. Fehler werden nur protokolliert,
+// denn das Ergebnis liegt bereits im Zielverzeichnis.
+func (bc *BatchConverter) publish(ctx context.Context, result DocumentResult) {
+	for _, sink := range bc.Sinks {
+		if err := sink.Publish(ctx, result); err != nil {
+			log.Error(log.WrapError(fmt.Errorf("failed to publish result for %s: %w", result.SourceFile, err)))
+		}
+	}
+}
+
+func convertDir(systemMessage, prompt, srcFolder, destFolder string) error {
+	aiService := NewAiCommunicationService(prompt)
+	// Pausen zwischen den Dateien passen sich an beobachtete 429-Antworten an
+	aiService.RateLimiter = NewRateLimiter(0, 0)
+
+	_, err := NewBatchConverter(aiService, systemMessage, srcFolder, destFolder).Run()
+	return err
+}
@@ -0,0 +1,221 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+const testUploadedFile = `{"id": "file-1", "object": "file", "bytes": 1, "created_at": 1, "filename": "a.pdf", "purpose": "user_data", "status": "processed"}`
+
+// testPDF ist das kleinste Dokument, das CheckInputFile als PDF akzeptiert.
+const testPDF = "%PDF-1.4\n%%EOF\n"
+
+// newBatchTestService beantwortet Uploads und Chat-Requests; content ist die Antwort des Modells.
+func newBatchTestService(t *testing.T, content func() string) *AiCommunicationService {
+	t.Helper()
+	return newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files") {
+			_, _ = w.Write([]byte(testUploadedFile))
+			return
+		}
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `{\"ok\": true}`, strings.ReplaceAll(content(), `"`, `\"`), 1)))
+	})
+}
+
+func TestBatchConverter_CancelReturnsPartialResult(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF), 0644))
+	}
+
+	bc := NewBatchConverter(NewAiCommunicationService(""), "system", src, filepath.Join(t.TempDir(), "out"))
+	bc.Pattern = "*.pdf"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := bc.Start(ctx)
+	job.Cancel()
+
+	result, err := job.Wait()
+	require.ErrorIs(t, err, context.Canceled)
+	require.True(t, result.Cancelled)
+	require.Equal(t, 2, result.Skipped)
+	require.Len(t, result.Documents, 2)
+	require.Equal(t, "cancelled", result.Documents[0].Reason)
+}
+
+func TestBatchConverter_BudgetExceeded(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), make([]byte, 4000), 0644))
+	}
+
+	bc := NewBatchConverter(NewAiCommunicationService(""), "system", src, filepath.Join(t.TempDir(), "out"))
+	// Default-Schätzung je Datei: 1000 Prompt- + 500 Completion-Tokens = $0.0125
+	bc.MaxCost = 0.01
+
+	result, err := bc.Run()
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.True(t, result.BudgetExceeded)
+	require.InDelta(t, 0.025, result.BudgetNeeded, 1e-9)
+	require.Equal(t, 2, result.Skipped)
+	require.Equal(t, "budget exceeded", result.Documents[1].Reason)
+}
+
+func TestBatchConverter_CostsOfThisRunOnly(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF+name), 0644))
+	}
+
+	// ein anderer Aufrufer desselben Services verbraucht während des Laufs $1 je Request
+	var ai *AiCommunicationService
+	ai = newBatchTestService(t, func() string {
+		ai.AddCosts(openai.CompletionUsage{PromptTokens: 200000, TotalTokens: 200000})
+		return `{"ok": true}`
+	})
+	bc := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out"))
+	bc.MaxCost = 0.5
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Converted)
+	for _, doc := range result.Documents {
+		require.InDelta(t, costOf(100, 20), doc.Cost, 1e-9)
+	}
+	require.InDelta(t, 2*costOf(100, 20), result.TotalCost, 1e-9)
+}
+
+func TestBatchConverter_CanonicalOutput(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+
+	answers := []string{`{"total": 1.50, "id": "A-1"}`, `{"id":"A-1","total":1.5}`}
+	outputs := []string{}
+	for _, answer := range answers {
+		ai := newBatchTestService(t, func() string { return answer })
+		bc := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out"))
+		result, err := bc.Run()
+		require.NoError(t, err)
+		data, err := os.ReadFile(result.Documents[0].OutputFile)
+		require.NoError(t, err)
+		outputs = append(outputs, string(data))
+	}
+	require.Equal(t, "{\n  \"id\": \"A-1\",\n  \"total\": 1.5\n}\n", outputs[0])
+	require.Equal(t, outputs[0], outputs[1])
+}
+
+func TestBatchConverter_LocksDestination(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	dest := t.TempDir()
+	lockPath := filepath.Join(dest, lockFileName)
+	require.NoError(t, os.WriteFile(lockPath, []byte("pid=1"), 0644))
+
+	bc := NewBatchConverter(newBatchTestService(t, func() string { return `{"ok": true}` }), "system", src, dest)
+	_, err := bc.Run()
+	require.ErrorIs(t, err, ErrLocked)
+	require.NoFileExists(t, filepath.Join(dest, "a.pdf"))
+
+	// verwaiste Sperre eines abgestürzten Laufs wird übernommen und danach freigegeben
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(lockPath, old, old))
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Converted)
+	require.NoFileExists(t, lockPath)
+}
+
+func TestBatchConverter_SelectFiles(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, ".hidden.pdf"), []byte(testPDF), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "big.pdf"), make([]byte, 100), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(src, "a.pdf"), filepath.Join(src, "link.pdf")))
+	require.NoError(t, os.Mkdir(filepath.Join(src, "sub.pdf"), 0755))
+
+	bc := NewBatchConverter(newBatchTestService(t, func() string { return `{"ok": true}` }), "system", src, t.TempDir())
+	require.False(t, bc.SkipHidden, "hidden files are converted unless the caller opts out")
+	bc.Pattern = "*.pdf"
+	bc.FollowSymlinks = false
+	bc.SkipHidden = true
+	bc.MaxFileSize = 50
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Converted)
+	reasons := map[string]string{}
+	for _, doc := range result.Documents {
+		reasons[filepath.Base(doc.SourceFile)] = doc.Reason
+	}
+	require.Equal(t, map[string]string{
+		"a.pdf":       "",
+		".hidden.pdf": "hidden file",
+		"big.pdf":     "file too large: 100 bytes (max 50)",
+		"link.pdf":    "symlink not followed",
+	}, reasons)
+}
+
+func TestBatchConverter_RerunWithChangedInputs(t *testing.T) {
+	src := t.TempDir()
+	fileName := filepath.Join(src, "a.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+	dest := filepath.Join(t.TempDir(), "out")
+
+	answer := `{"v": 1}`
+	ai := newBatchTestService(t, func() string { return answer })
+	run := func(systemMessage string) *BatchResult {
+		result, err := NewBatchConverter(ai, systemMessage, src, dest).Run()
+		require.NoError(t, err)
+		return result
+	}
+	output := func() string {
+		data, err := os.ReadFile(filepath.Join(dest, "a.pdf"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	require.Equal(t, 1, run("system").Converted)
+	answer = `{"v": 2}`
+	require.Equal(t, 1, run("system").Skipped, "unchanged inputs are taken from the journal")
+
+	// geänderter Prompt: erneut konvertieren statt "already converted"
+	require.Equal(t, 1, run("new system").Converted)
+	require.Contains(t, output(), `"v": 2`)
+
+	// geänderte Datei
+	answer = `{"v": 3}`
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF+"\n"), 0644))
+	require.Equal(t, 1, run("new system").Converted)
+	require.Contains(t, output(), `"v": 3`)
+}
+
+// closingSink zählt, wie oft Close aufgerufen wird.
+type closingSink struct {
+	closed int
+}
+
+func (s *closingSink) Publish(context.Context, DocumentResult) error { return nil }
+func (s *closingSink) Submit(context.Context, ReviewItem) error      { return nil }
+func (s *closingSink) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestBatchConverter_Close(t *testing.T) {
+	sink := &closingSink{}
+	bc := NewBatchConverter(NewAiCommunicationService(""), "system", t.TempDir(), t.TempDir())
+	bc.Sinks = []ResultSink{sink, sinkFunc(func(context.Context, DocumentResult) error { return nil })}
+	bc.Review = []ReviewSink{sink}
+	require.NoError(t, bc.Close())
+	require.Equal(t, 1, sink.closed, "a sink used for results and review is closed once")
+}
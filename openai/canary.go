@@ -0,0 +1,123 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+const defaultCanaryMinRequests = 20
+
+// Canary schickt Percent Prozent der Requests an ein neues Modell. Übersteigt der Anteil
+// an Validierungsfehlern und Verweigerungen MaxFailureRate, wird der Canary gestoppt und
+// alle Requests gehen wieder an das bisherige Modell. Ein fehlgeschlagener Canary-Request
+// wird mit dem bisherigen Modell wiederholt, der Aufrufer merkt davon nichts.
+type Canary struct {
+	Model          openai.ChatModel
+	Percent        float64                    // 0..100
+	Validate       func(content string) error // prüft Antworten des neuen Modells, optional
+	MaxFailureRate float64                    // 0..1
+	MinRequests    int                        // Mindestanzahl Requests vor der ersten Bewertung, Default: 20
+
+	mu         sync.Mutex
+	requests   int
+	failures   int
+	stopped    bool
+	stopReason string
+}
+
+// CanaryStats ist der aktuelle Stand des Canary.
+type CanaryStats struct {
+	Requests   int
+	Failures   int
+	Stopped    bool
+	StopReason string
+}
+
+// Stats liefert den aktuellen Stand.
+func (c *Canary) Stats() CanaryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CanaryStats{Requests: c.requests, Failures: c.failures, Stopped: c.stopped, StopReason: c.stopReason}
+}
+
+// Reset setzt Zähler und Rollback zurück, z.B. nach einer Korrektur am Prompt.
+func (c *Canary) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests, c.failures = 0, 0
+	c.stopped, c.stopReason = false, ""
+}
+
+// pick entscheidet, ob ein Request an das neue Modell geht.
+func (c *Canary) pick() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.stopped && c.Model != "" && rand.Float64()*100 < c.Percent
+}
+
+// record erfasst das Ergebnis eines Canary-Requests und stoppt den Canary bei Bedarf.
+func (c *Canary) record(failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if failed {
+		c.failures++
+	}
+	minRequests := c.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultCanaryMinRequests
+	}
+	if c.stopped || c.requests < minRequests {
+		return
+	}
+	if rate := float64(c.failures) / float64(c.requests); rate > c.MaxFailureRate {
+		c.stopped = true
+		c.stopReason = fmt.Sprintf("failure rate %.2f exceeds %.2f after %d requests", rate, c.MaxFailureRate, c.requests)
+		log.Warn("canary %s stopped: %s", c.Model, c.stopReason)
+	}
+}
+
+// check meldet, ob die Antwort des neuen Modells als Fehlschlag zählt.
+func (c *Canary) check(content string, err error) error {
+	if err != nil {
+		if errors.Is(err, ErrRefused) || errors.Is(err, ErrContentFiltered) {
+			return err
+		}
+		return nil
+	}
+	if c.Validate != nil {
+		return c.Validate(content)
+	}
+	return nil
+}
+
+// wrap schaltet den Canary vor request.
+func (c *Canary) wrap(request requestFunc) requestFunc {
+	return func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+		if !c.pick() {
+			return request(ctx, systemMessage, f, cfg)
+		}
+		canaryCfg := cfg
+		canaryCfg.model = c.Model
+		if canaryCfg.variant == "" {
+			canaryCfg.variant = "canary"
+		}
+		content, err := request(ctx, systemMessage, f, canaryCfg)
+		failure := c.check(content, err)
+		// andere Fehler (Netzwerk, Rate-Limits) sagen nichts über das Modell aus
+		if err == nil || failure != nil {
+			c.record(failure != nil)
+		}
+		if failure == nil {
+			return content, err
+		}
+		log.Info("canary %s failed, falling back to %s: %v", c.Model, cfg.model, failure)
+		return request(ctx, systemMessage, f, cfg)
+	}
+}
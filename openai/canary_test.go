@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanary_RollbackOnValidationFailures(t *testing.T) {
+	ai, models := newModelTestService(t, map[string]string{
+		string(openai.ChatModelGPT4_1):     `{"total": 12}`,
+		string(openai.ChatModelGPT4_1Mini): `{"summe": 12}`,
+	})
+	ai.Canary = &Canary{
+		Model:   openai.ChatModelGPT4_1Mini,
+		Percent: 100,
+		Validate: func(content string) error {
+			if !strings.Contains(content, "total") {
+				return errors.New("total missing")
+			}
+			return nil
+		},
+		MaxFailureRate: 0.5,
+		MinRequests:    3,
+	}
+
+	for range 3 {
+		content, err := ai.GenerateContent("system")
+		require.NoError(t, err)
+		require.Equal(t, `{"total": 12}`, content)
+	}
+	stats := ai.Canary.Stats()
+	require.True(t, stats.Stopped)
+	require.Equal(t, 3, stats.Failures)
+	require.Len(t, *models, 6)
+
+	// nach dem Rollback nur noch das bisherige Modell
+	_, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Len(t, *models, 7)
+	require.Equal(t, string(openai.ChatModelGPT4_1), (*models)[6])
+	require.Equal(t, "canary", ai.Costs[0].Variant)
+}
+
+func TestCanary_KeepsHealthyModel(t *testing.T) {
+	ai, models := newModelTestService(t, map[string]string{
+		string(openai.ChatModelGPT4_1Mini): `{"total": 12}`,
+	})
+	ai.Canary = &Canary{Model: openai.ChatModelGPT4_1Mini, Percent: 100, MaxFailureRate: 0.1, MinRequests: 2}
+
+	for range 3 {
+		_, err := ai.GenerateContent("system")
+		require.NoError(t, err)
+	}
+	require.False(t, ai.Canary.Stats().Stopped)
+	require.Equal(t, 3, ai.Canary.Stats().Requests)
+	require.Len(t, *models, 3)
+}
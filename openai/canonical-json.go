@@ -0,0 +1,76 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CanonicalJSON bringt JSON in eine feste Form: Schlüssel sortiert, zwei Leerzeichen
+// Einrückung, Zahlen ohne überflüssige Nullen und Exponenten (1.50 -> 1.5, 1e2 -> 100),
+// kein HTML-Escaping, abschließender Zeilenumbruch. Gleicher Inhalt ergibt so dieselben Bytes.
+func CanonicalJSON(content string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return "", fmt.Errorf("invalid JSON: trailing data")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(canonicalValue(v)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// canonicalValue ersetzt alle Zahlen durch ihre kanonische Schreibweise; die Sortierung
+// der Schlüssel übernimmt encoding/json.
+func canonicalValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = canonicalValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = canonicalValue(item)
+		}
+	case json.Number:
+		return canonicalNumber(v)
+	}
+	return v
+}
+
+// canonicalNumber lässt Ganzzahlen exakt, auch außerhalb von float64. Alle anderen Zahlen
+// werden über float64 in die kürzeste Darstellung gebracht, Exponenten nur bei sehr großen
+// oder sehr kleinen Beträgen.
+func canonicalNumber(n json.Number) json.Number {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if s == "-0" {
+			return "0"
+		}
+		return n
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return n
+	}
+	if f == 0 {
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e21 || abs < 1e-6 {
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+}
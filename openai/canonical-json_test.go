@@ -0,0 +1,37 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	a, err := CanonicalJSON(`{"b": [1.50, 1e2, -0.0], "a": {"y": "<&>", "x": 12345678901234567890}}`)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "a": {
+    "x": 12345678901234567890,
+    "y": "<&>"
+  },
+  "b": [
+    1.5,
+    100,
+    0
+  ]
+}
+`, a)
+
+	b, err := CanonicalJSON(`{"a":{"x":12345678901234567890,"y":"<&>"},"b":[1.5,100,0]}`)
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+
+	again, err := CanonicalJSON(a)
+	require.NoError(t, err)
+	require.Equal(t, a, again)
+
+	_, err = CanonicalJSON(`{"a": 1} {"b": 2}`)
+	require.Error(t, err)
+	_, err = CanonicalJSON("kein JSON")
+	require.Error(t, err)
+}
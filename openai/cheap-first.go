@@ -0,0 +1,83 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// CheapFirstPolicy beschreibt die Strategie "günstiges Modell zuerst": der Aufruf geht
+// zunächst an Model und wird nur dann mit dem eigentlichen Modell wiederholt, wenn die
+// Antwort die Prüfung nicht besteht. Beide Versuche landen in Costs.
+type CheapFirstPolicy struct {
+	Model openai.ChatModel // günstiges Modell für den ersten Versuch
+
+	// Validate prüft die Antwort des günstigen Modells; ein Fehler führt zur Eskalation. Optional.
+	Validate func(content string) error
+
+	// MinConfidence eskaliert, wenn das Feld "confidence" (0..1) der JSON-Antwort darunter
+	// liegt oder fehlt. 0 = nicht prüfen.
+	MinConfidence float64
+}
+
+// WithCheapModelFirst aktiviert für diesen Aufruf die Strategie und ersetzt die Policy des Services.
+func WithCheapModelFirst(p CheapFirstPolicy) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.cheapFirst = &p
+	}
+}
+
+// check liefert den Grund für eine Eskalation oder nil, wenn die Antwort reicht.
+func (p *CheapFirstPolicy) check(content string) error {
+	if p.Validate != nil {
+		if err := p.Validate(content); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	if p.MinConfidence > 0 {
+		var answer struct {
+			Confidence *float64 `json:"confidence"`
+		}
+		if err := json.Unmarshal([]byte(content), &answer); err != nil {
+			return fmt.Errorf("answer is not a JSON object: %w", err)
+		}
+		if answer.Confidence == nil {
+			return errors.New("answer has no confidence")
+		}
+		if *answer.Confidence < p.MinConfidence {
+			return fmt.Errorf("confidence %.2f below %.2f", *answer.Confidence, p.MinConfidence)
+		}
+	}
+	return nil
+}
+
+// cheapFirstRequest schaltet die Strategie vor request.
+func (ai *AiCommunicationService) cheapFirstRequest(request requestFunc) requestFunc {
+	return func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+		policy := cfg.cheapFirst
+
+		cheapCfg := cfg
+		cheapCfg.model = policy.Model
+		// Hedging und Idempotency-Key gelten nur für den Versuch mit dem eigentlichen Modell
+		cheapCfg.hedge = nil
+		cheapCfg.idempotencyKey = ""
+		content, err := ai.requestJsonContent(ctx, systemMessage, f, cheapCfg)
+		switch {
+		case err == nil:
+			reason := policy.check(content)
+			if reason == nil {
+				return content, nil
+			}
+			log.Info("Escalating from %s to %s: %v", policy.Model, cfg.model, reason)
+		case errors.Is(err, ErrMaxLength):
+			log.Info("Escalating from %s to %s: %v", policy.Model, cfg.model, err)
+		default:
+			return "", err
+		}
+		return request(ctx, systemMessage, f, cfg)
+	}
+}
@@ -0,0 +1,67 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+// newModelTestService antwortet je Modell mit dem angegebenen Inhalt.
+func newModelTestService(t *testing.T, answers map[string]string) (*AiCommunicationService, *[]string) {
+	t.Helper()
+	var models []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		models = append(models, body.Model)
+		content, err := json.Marshal(answers[body.Model])
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, string(content), 1)))
+	})
+	return ai, &models
+}
+
+func TestCheapFirst_EscalatesOnLowConfidence(t *testing.T) {
+	ai, models := newModelTestService(t, map[string]string{
+		string(openai.ChatModelGPT4_1Mini): `{"total": 10, "confidence": 0.4}`,
+		string(openai.ChatModelGPT4_1):     `{"total": 12, "confidence": 0.95}`,
+	})
+	ai.CheapFirst = &CheapFirstPolicy{Model: openai.ChatModelGPT4_1Mini, MinConfidence: 0.8}
+
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"total": 12, "confidence": 0.95}`, content)
+	require.Equal(t, []string{string(openai.ChatModelGPT4_1Mini), string(openai.ChatModelGPT4_1)}, *models)
+
+	require.Len(t, ai.Costs, 2)
+	require.Equal(t, string(openai.ChatModelGPT4_1Mini), ai.Costs[0].Model)
+	require.Equal(t, string(openai.ChatModelGPT4_1), ai.Costs[1].Model)
+}
+
+func TestCheapFirst_KeepsValidAnswer(t *testing.T) {
+	ai, models := newModelTestService(t, map[string]string{
+		string(openai.ChatModelGPT4_1Mini): `{"total": 10}`,
+	})
+
+	content, err := ai.GenerateContent("system", WithCheapModelFirst(CheapFirstPolicy{
+		Model: openai.ChatModelGPT4_1Mini,
+		Validate: func(content string) error {
+			if !strings.Contains(content, "total") {
+				return errors.New("total missing")
+			}
+			return nil
+		},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, `{"total": 10}`, content)
+	require.Equal(t, []string{string(openai.ChatModelGPT4_1Mini)}, *models)
+	require.Len(t, ai.Costs, 1)
+}
@@ -0,0 +1,167 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// CodeInterpreterToolName ist der Name, unter dem CodeInterpreterTool dem Modell angeboten wird.
+const CodeInterpreterToolName = "run_python"
+
+const (
+	defaultSandboxImage   = "python:3.12-alpine"
+	defaultSandboxTimeout = 30 * time.Second
+	defaultSandboxMemory  = "256m"
+	defaultSandboxOutput  = 16 * 1024
+)
+
+// ExecResult ist das Ergebnis einer Code-Ausführung.
+type ExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	TimedOut bool   `json:"timedOut,omitempty"`
+}
+
+// CodeExecutor führt vom Modell erzeugten Code isoliert aus. Die Chat-Completions-API bietet
+// keinen Code-Interpreter des Providers; eigene Umgebungen werden über dieses Interface angebunden.
+type CodeExecutor interface {
+	Execute(ctx context.Context, code string) (ExecResult, error)
+}
+
+// ContainerExecutor führt Python-Code in einem Wegwerf-Container aus: ohne Netzwerk, mit
+// schreibgeschütztem Dateisystem, ohne Capabilities und mit begrenztem Speicher, CPU und
+// Laufzeit. Der Code wird über stdin übergeben.
+type ContainerExecutor struct {
+	Runtime   string        // "docker" oder "podman", Default: docker
+	Image     string        // Default: python:3.12-alpine
+	Timeout   time.Duration // Default: 30s
+	Memory    string        // Default: 256m
+	CPUs      string        // Default: 1
+	MaxOutput int           // Bytes je Ausgabe-Stream, Default: 16KB
+}
+
+func NewContainerExecutor() *ContainerExecutor {
+	return &ContainerExecutor{
+		Runtime:   "docker",
+		Image:     defaultSandboxImage,
+		Timeout:   defaultSandboxTimeout,
+		Memory:    defaultSandboxMemory,
+		CPUs:      "1",
+		MaxOutput: defaultSandboxOutput,
+	}
+}
+
+func (e *ContainerExecutor) Execute(ctx context.Context, code string) (ExecResult, error) {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultSandboxTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	runtime := e.Runtime
+	if runtime == "" {
+		runtime = "docker"
+	}
+	cmd := exec.CommandContext(ctx, runtime, e.args()...)
+	cmd.Stdin = bytes.NewBufferString(code)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	result := ExecResult{
+		Stdout: truncateOutput(stdout.String(), e.MaxOutput),
+		Stderr: truncateOutput(stderr.String(), e.MaxOutput),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.TimedOut = true
+		result.ExitCode = -1
+		return result, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return result, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("sandbox failed: %w", err)
+	}
+	return result, nil
+}
+
+// args liefert die Argumente für "<runtime> run".
+func (e *ContainerExecutor) args() []string {
+	image, memory, cpus := e.Image, e.Memory, e.CPUs
+	if image == "" {
+		image = defaultSandboxImage
+	}
+	if memory == "" {
+		memory = defaultSandboxMemory
+	}
+	if cpus == "" {
+		cpus = "1"
+	}
+	return []string{
+		"run", "--rm", "-i",
+		"--network", "none",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,size=16m",
+		"--memory", memory,
+		"--cpus", cpus,
+		"--pids-limit", "64",
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--user", "65534:65534",
+		image, "python", "-",
+	}
+}
+
+func truncateOutput(s string, limit int) string {
+	if limit <= 0 {
+		limit = defaultSandboxOutput
+	}
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "\n[output truncated]"
+}
+
+// CodeInterpreterTool bietet dem Modell die Ausführung von Python-Code an, z.B. für
+// Berechnungen über extrahierte Tabellen. Ergebnis ist ExecResult als JSON.
+func CodeInterpreterTool(executor CodeExecutor) Tool {
+	return Tool{
+		Name:        CodeInterpreterToolName,
+		Description: "Führt Python 3 in einer isolierten Umgebung ohne Netzwerk aus und liefert stdout, stderr und Exit-Code. Ergebnisse mit print ausgeben.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"code": map[string]any{"type": "string", "description": "Python-Code"},
+			},
+			"required": []string{"code"},
+		},
+		Run: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil || args.Code == "" {
+				return "", fmt.Errorf("invalid code arguments")
+			}
+			result, err := executor.Execute(ctx, args.Code)
+			if err != nil {
+				return "", err
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	}
+}
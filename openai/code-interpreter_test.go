@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime legt ein Skript an, das statt eines Containers script ausführt.
+func fakeRuntime(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "runtime")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+func TestContainerExecutor(t *testing.T) {
+	e := NewContainerExecutor()
+	e.Runtime = fakeRuntime(t, `cat; echo oops >&2; exit 3`)
+	e.MaxOutput = 10
+
+	result, err := e.Execute(context.Background(), "print(1 + 2)\n")
+	require.NoError(t, err)
+	require.Equal(t, "print(1 + \n[output truncated]", result.Stdout)
+	require.Equal(t, "oops\n", result.Stderr)
+	require.Equal(t, 3, result.ExitCode)
+
+	e.Runtime = fakeRuntime(t, `sleep 5`)
+	e.Timeout = 100 * time.Millisecond
+	result, err = e.Execute(context.Background(), "while True: pass")
+	require.NoError(t, err)
+	require.True(t, result.TimedOut)
+
+	args := NewContainerExecutor().args()
+	require.Contains(t, args, "none")
+	require.Contains(t, args, "--read-only")
+	require.Equal(t, []string{defaultSandboxImage, "python", "-"}, args[len(args)-3:])
+}
+
+func TestCodeInterpreterTool(t *testing.T) {
+	e := NewContainerExecutor()
+	e.Runtime = fakeRuntime(t, `cat`)
+	tool := CodeInterpreterTool(e)
+
+	out, err := tool.Run(context.Background(), `{"code": "print(42)"}`)
+	require.NoError(t, err)
+	var result ExecResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	require.Equal(t, "print(42)", result.Stdout)
+
+	_, err = tool.Run(context.Background(), `{}`)
+	require.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package openai
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// CompressionGzip ist das eingebaute Verfahren. Weitere wie zstd lassen sich mit
+// RegisterCompression ergänzen, z.B. mit github.com/klauspost/compress/zstd:
+//
+//	openai.RegisterCompression(openai.Compression{
+//		Name:  "zstd",
+//		Ext:   ".zst",
+//		Magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+//		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+//		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+//			d, err := zstd.NewReader(r)
+//			if err != nil {
+//				return nil, err
+//			}
+//			return d.IOReadCloser(), nil
+//		},
+//	})
+const CompressionGzip = "gzip"
+
+// Compression beschreibt ein Kompressionsverfahren für Ergebnisdateien und Audit-Logs.
+type Compression struct {
+	Name  string // z.B. "gzip"
+	Ext   string // an den Dateinamen angehängt, z.B. ".gz"
+	Magic []byte // Anfang komprimierter Daten, erkennt sie auch ohne Endung; optional
+	// NewWriter komprimiert nach w; Close schreibt den Rest, schließt w aber nicht. Bietet
+	// der Writer Flush, wird nach jedem Audit-Eintrag geleert.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]Compression{
+		CompressionGzip: {
+			Name:      CompressionGzip,
+			Ext:       ".gz",
+			Magic:     []byte{0x1f, 0x8b},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+			NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		},
+	}
+)
+
+// RegisterCompression macht ein Verfahren unter c.Name bekannt; ein vorhandenes wird ersetzt.
+func RegisterCompression(c Compression) error {
+	if c.Name == "" {
+		return fmt.Errorf("compression name must not be empty")
+	}
+	if c.Ext == "" || c.NewWriter == nil || c.NewReader == nil {
+		return fmt.Errorf("compression %q needs extension, writer and reader", c.Name)
+	}
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[c.Name] = c
+	return nil
+}
+
+// LookupCompression liefert das unter name registrierte Verfahren.
+func LookupCompression(name string) (Compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	c, ok := compressions[name]
+	return c, ok
+}
+
+// lookupCompression wie LookupCompression, aber mit Fehler für unbekannte Namen; leer = keine.
+func lookupCompression(name string) (*Compression, error) {
+	if name == "" {
+		return nil, nil
+	}
+	c, ok := LookupCompression(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", name)
+	}
+	return &c, nil
+}
+
+// compress komprimiert data vollständig im Speicher.
+func (c *Compression) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := c.NewWriter(&buf)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, log.WrapError(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, log.WrapError(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// detectCompression erkennt das Verfahren an der Endung von fileName oder an head.
+func detectCompression(fileName string, head []byte) *Compression {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	for _, c := range compressions {
+		if strings.HasSuffix(fileName, c.Ext) {
+			return &c
+		}
+	}
+	for _, c := range compressions {
+		if len(c.Magic) > 0 && bytes.HasPrefix(head, c.Magic) {
+			return &c
+		}
+	}
+	return nil
+}
+
+// OpenDecompressed öffnet eine Ergebnisdatei oder ein Audit-Log und entpackt es, falls es
+// komprimiert ist (erkannt an Endung oder Inhalt). Unkomprimierte Dateien kommen unverändert.
+func OpenDecompressed(fileName string) (io.ReadCloser, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	head := make([]byte, 8)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, log.WrapError(err)
+	}
+	c := detectCompression(fileName, head[:n])
+	if c == nil || n == 0 {
+		return f, nil
+	}
+	r, err := c.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decompress %s (%s): %w", fileName, c.Name, err)
+	}
+	return &decompressedFile{ReadCloser: r, file: f}, nil
+}
+
+// ReadDecompressed liest eine Datei wie OpenDecompressed vollständig.
+func ReadDecompressed(fileName string) ([]byte, error) {
+	r, err := OpenDecompressed(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	return data, nil
+}
+
+// decompressedFile schließt mit dem Reader auch die Datei.
+type decompressedFile struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (d *decompressedFile) Close() error {
+	err := d.ReadCloser.Close()
+	if ferr := d.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
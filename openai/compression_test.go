@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"compress/flate"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchConverter_Compression(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	dest := filepath.Join(t.TempDir(), "out")
+
+	bc := NewBatchConverter(newBatchTestService(t, func() string { return `{"id": "A-1"}` }), "system", src, dest)
+	bc.Compression = CompressionGzip
+	bc.WriteProvenance = true
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Converted)
+
+	outputFile := filepath.Join(dest, "a.pdf.gz")
+	require.Equal(t, outputFile, result.Documents[0].OutputFile)
+	require.NoFileExists(t, filepath.Join(dest, "a.pdf"))
+	raw, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x1f, 0x8b}, raw[:2])
+	data, err := ReadDecompressed(outputFile)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": "A-1"}`, string(data))
+	_, err = LoadProvenance(outputFile)
+	require.NoError(t, err)
+
+	result, err = bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Skipped, "compressed output counts as converted")
+
+	bc.Compression = "brotli"
+	_, err = bc.Run()
+	require.ErrorContains(t, err, `unknown compression "brotli"`)
+}
+
+func TestCompressedAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl.gz")
+	for _, model := range []string{"gpt-4.1", "gpt-4o"} {
+		a, err := OpenCompressedAuditLog(path, CompressionGzip)
+		require.NoError(t, err)
+		require.NoError(t, a.Record(AuditRecord{Model: model, PromptTokens: 10}))
+		require.NoError(t, a.Record(AuditRecord{Model: model, PromptTokens: 20}))
+		require.NoError(t, a.Close())
+	}
+	records, err := ReadAuditLog(path)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, "gpt-4o", records[3].Model)
+
+	// ohne Close (Absturz) bleiben die geleerten Einträge lesbar
+	crashed := filepath.Join(t.TempDir(), "audit.jsonl.gz")
+	a, err := OpenCompressedAuditLog(crashed, CompressionGzip)
+	require.NoError(t, err)
+	require.NoError(t, a.Record(AuditRecord{Model: "gpt-4.1"}))
+	require.NoError(t, a.file.Close())
+	records, err = ReadAuditLog(crashed)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// unkomprimierte Logs liest ReadAuditLog unverändert
+	plain := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err = OpenCompressedAuditLog(plain, "")
+	require.NoError(t, err)
+	require.NoError(t, a.Record(AuditRecord{Model: "gpt-4.1"}))
+	require.NoError(t, a.Close())
+	records, err = ReadAuditLog(plain)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+}
+
+func TestRegisterCompression(t *testing.T) {
+	require.Error(t, RegisterCompression(Compression{Name: "deflate"}))
+	require.NoError(t, RegisterCompression(Compression{
+		Name:      "deflate",
+		Ext:       ".deflate",
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.BestSpeed) },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	}))
+	c, err := lookupCompression("deflate")
+	require.NoError(t, err)
+	data, err := c.compress([]byte("hallo hallo hallo"))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "a.json.deflate")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	got, err := ReadDecompressed(path)
+	require.NoError(t, err)
+	require.Equal(t, "hallo hallo hallo", string(got))
+
+	// gzip wird auch ohne Endung am Inhalt erkannt
+	gz, _ := LookupCompression(CompressionGzip)
+	data, err = gz.compress([]byte("inhalt"))
+	require.NoError(t, err)
+	path = filepath.Join(t.TempDir(), "ohne-endung")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	got, err = ReadDecompressed(path)
+	require.NoError(t, err)
+	require.Equal(t, "inhalt", string(got))
+}
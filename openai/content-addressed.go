@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// OutputNaming legt fest, unter welchem Namen der BatchConverter Ergebnisse ablegt.
+type OutputNaming string
+
+const (
+	// OutputNamingSource legt jedes Ergebnis unter dem Namen der Quelldatei ab (Default).
+	OutputNamingSource OutputNaming = "source"
+	// OutputNamingContent legt Ergebnisse unter ihrem SHA-256 ab, z.B. objects/3f/3fa4….json.
+	// Gleiche Ergebnisse werden nur einmal gespeichert, auch über Jobs hinweg, wenn diese
+	// denselben ObjectStore nutzen; parallele Läufe schreiben dabei gefahrlos dieselbe
+	// Datei. Welche Quelle zu welchem Ergebnis gehört, steht im Ergebnisindex (siehe
+	// LoadContentIndex), Herkunftsdateien liegen unter dem Namen der Quelle im Zielordner.
+	OutputNamingContent OutputNaming = "content"
+)
+
+const (
+	// objectsDir ist der Default-ObjectStore im Zielordner.
+	objectsDir = "objects"
+	objectExt  = ".json"
+)
+
+// validOutputNaming prüft den Namensmodus; leer = OutputNamingSource.
+func validOutputNaming(n OutputNaming) error {
+	switch n {
+	case "", OutputNamingSource, OutputNamingContent:
+		return nil
+	default:
+		return fmt.Errorf("unknown output naming %q, expected %q or %q", n, OutputNamingSource, OutputNamingContent)
+	}
+}
+
+// objectStore liefert den Ordner für inhaltsadressierte Ergebnisse.
+func (bc *BatchConverter) objectStore() string {
+	if bc.ObjectStore != "" {
+		return bc.ObjectStore
+	}
+	return filepath.Join(bc.DestFolder, objectsDir)
+}
+
+// objectPath liefert den Pfad des Ergebnisses mit dem Hash hash.
+func (bc *BatchConverter) objectPath(hash string, compression *Compression) string {
+	name := hash + objectExt
+	if compression != nil {
+		name += compression.Ext
+	}
+	return filepath.Join(bc.objectStore(), hash[:2], name)
+}
+
+// LoadContentIndex liefert zu jeder Quelldatei das Ergebnis im ObjectStore, wie es der
+// Ergebnisindex des Zielordners verzeichnet. Quellen ohne erfolgreiches Ergebnis fehlen.
+func LoadContentIndex(destFolder string) (map[string]string, error) {
+	result, err := LoadBatchIndex(destFolder)
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]string{}
+	for _, doc := range result.Documents {
+		if doc.ContentHash == "" || doc.OutputFile == "" {
+			continue
+		}
+		if doc.Status == DocumentDone || doc.Status == DocumentSkipped {
+			index[doc.SourceFile] = doc.OutputFile
+		}
+	}
+	return index, nil
+}
+
+// objectExists meldet, ob das Ergebnis schon abgelegt ist.
+func objectExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,84 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchConverter_ContentNaming(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF), 0644))
+	}
+	answers := map[string]string{"a.pdf": `{"id": 1}`, "b.pdf": `{"id": 1}`, "c.pdf": `{"id": 2}`}
+	var mu sync.Mutex
+	calls := 0
+	ai := newBatchTestService(t, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return []string{answers["a.pdf"], answers["b.pdf"], answers["c.pdf"]}[(calls-1)%3]
+	})
+	store := filepath.Join(t.TempDir(), "store")
+	dest := filepath.Join(t.TempDir(), "out")
+
+	bc := NewBatchConverter(ai, "system", src, dest)
+	bc.Naming = OutputNamingContent
+	bc.ObjectStore = store
+	bc.WriteProvenance = true
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Converted)
+
+	// a und b liefern dasselbe Ergebnis und teilen sich eine Datei
+	docs := result.Documents
+	require.Equal(t, docs[0].OutputFile, docs[1].OutputFile)
+	require.NotEqual(t, docs[0].OutputFile, docs[2].OutputFile)
+	data, err := os.ReadFile(docs[0].OutputFile)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": 1}`, string(data))
+	hash := contentHash(string(data))
+	require.Equal(t, hash, docs[0].ContentHash)
+	require.Equal(t, filepath.Join(store, hash[:2], hash+".json"), docs[0].OutputFile)
+	require.NoFileExists(t, filepath.Join(dest, "a.pdf"))
+	require.FileExists(t, filepath.Join(dest, "a.pdf"+provenanceSuffix))
+	require.FileExists(t, filepath.Join(dest, "b.pdf"+provenanceSuffix))
+
+	index, err := LoadContentIndex(dest)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		filepath.Join(src, "a.pdf"): docs[0].OutputFile,
+		filepath.Join(src, "b.pdf"): docs[0].OutputFile,
+		filepath.Join(src, "c.pdf"): docs[2].OutputFile,
+	}, index)
+
+	// zweiter Lauf: alles schon da
+	result, err = bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Skipped)
+	require.Equal(t, 3, calls)
+
+	// ein anderer Job mit demselben Store legt vorhandene Ergebnisse nicht erneut ab
+	other := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out2"))
+	other.Naming = OutputNamingContent
+	other.ObjectStore = store
+	result, err = other.Run()
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Converted)
+	objects := 0
+	require.NoError(t, filepath.WalkDir(store, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			objects++
+		}
+		return err
+	}))
+	require.Equal(t, 2, objects, "no leftover staging files")
+
+	bc.Naming = "hash"
+	_, err = bc.Run()
+	require.ErrorContains(t, err, `unknown output naming "hash"`)
+}
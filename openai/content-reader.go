@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// GenerateContentReader stellt den Request gestreamt und liefert die Antwort als Reader,
+// z.B. um große Ausgaben wie generierte CSV-Dateien direkt auf die Platte zu schreiben,
+// ohne sie ganz im Speicher zu halten. Fehler vor dem ersten Stück (z.B. 401, Rate-Limit
+// nach allen Versuchen) kommen direkt zurück, spätere aus Read. Der Server wird nur so
+// schnell gelesen wie der Reader; Close bricht den Stream ab. Es gelten die Einschränkungen
+// von Stream, insbesondere laufen keine Post-Prozessoren.
+func (ai *AiCommunicationService) GenerateContentReader(ctx context.Context, req Request, opts ...RequestOption) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	started := make(chan struct{})
+	var startOnce sync.Once
+	start := func() { startOnce.Do(func() { close(started) }) }
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := ai.Stream(ctx, req, func(chunk string) error {
+			start()
+			_, err := io.WriteString(pw, chunk)
+			return err
+		}, opts...)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	select {
+	case <-started:
+	case err := <-done:
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	return &contentReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// contentReader bricht beim Schließen den Stream dahinter ab.
+type contentReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *contentReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dchaykin/myailib/openai/internal/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateContentReader(t *testing.T) {
+	ai, srv := newMockService(t)
+	csv := "datum;betrag\n" + strings.Repeat("2025-01-31;1234,50\n", 200)
+	srv.Enqueue(mockserver.Response{Content: csv})
+
+	r, err := ai.GenerateContentReader(context.Background(), Request{SystemMessage: "system"})
+	require.NoError(t, err)
+	fileName := filepath.Join(t.TempDir(), "out.csv")
+	f, err := os.Create(fileName)
+	require.NoError(t, err)
+	_, err = io.Copy(f, r)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, r.Close())
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, csv, string(data))
+	require.InDelta(t, costOf(100, 20), ai.TotalCosts(), 1e-9)
+}
+
+func TestGenerateContentReader_Errors(t *testing.T) {
+	ai, srv := newMockService(t)
+	ai.MaxAttempts = 1
+
+	srv.Enqueue(mockserver.Response{Status: 401, Message: "Incorrect API key provided", Code: "invalid_api_key"})
+	_, err := ai.GenerateContentReader(context.Background(), Request{SystemMessage: "system"})
+	var oe *OpenAIError
+	require.ErrorAs(t, err, &oe)
+	require.True(t, oe.IsAuth())
+
+	// Fehler nach dem ersten Stück kommen aus Read
+	srv.Enqueue(mockserver.Response{Content: strings.Repeat("x", 100), FinishReason: "length"})
+	r, err := ai.GenerateContentReader(context.Background(), Request{SystemMessage: "system"})
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, ErrMaxLength)
+	require.NoError(t, r.Close())
+
+	// vorzeitiges Close bricht den Stream ab
+	srv.Enqueue(mockserver.Response{Content: strings.Repeat("y", 10_000)})
+	r, err = ai.GenerateContentReader(context.Background(), Request{SystemMessage: "system"})
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	_, err = r.Read(buf)
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+}
@@ -0,0 +1,144 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// ErrConversationNotFound meldet, dass unter der ID keine Conversation gespeichert ist.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// conversationSnapshot ist der gespeicherte Stand einer Conversation.
+type conversationSnapshot struct {
+	System        string             `json:"system,omitempty"`
+	Model         string             `json:"model,omitempty"`
+	ContextWindow int                `json:"contextWindow,omitempty"`
+	Turns         []ConversationTurn `json:"turns"`
+}
+
+// FileConversationStore speichert Conversations als Dateien in Dir, damit ein Dialog z.B.
+// nach einem Neustart weitergehen kann. Die Dateien enthalten den vollständigen Verlauf und
+// sind nur für den Eigentümer lesbar.
+type FileConversationStore struct {
+	Dir string
+	// Encryption verschlüsselt die gespeicherten Verläufe. Optional.
+	Encryption *Encryption
+}
+
+func NewFileConversationStore(dir string) (*FileConversationStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, log.WrapError(err)
+	}
+	return &FileConversationStore{Dir: dir}, nil
+}
+
+func (s *FileConversationStore) path(id string) (string, error) {
+	if id == "" || strings.HasPrefix(id, ".") || unsafeFileNameRe.MatchString(id) {
+		return "", fmt.Errorf("invalid conversation id %q", id)
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+// Save speichert den aktuellen Stand von c unter id und ersetzt einen früheren.
+func (s *FileConversationStore) Save(ctx context.Context, id string, c *Conversation) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	snapshot := conversationSnapshot{
+		System:        c.System,
+		Model:         string(c.model),
+		ContextWindow: c.ContextWindow,
+		Turns:         append([]ConversationTurn{}, c.turns...),
+	}
+	c.mu.Unlock()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	if s.Encryption != nil {
+		if data, err = s.Encryption.Seal(ctx, data); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, ".tmp-*")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return log.WrapError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return log.WrapError(err)
+	}
+	return log.WrapError(os.Rename(tmp.Name(), path))
+}
+
+// Load liest die unter id gespeicherte Conversation; weitere Runden laufen über service.
+func (s *FileConversationStore) Load(ctx context.Context, id string, service *AiCommunicationService) (*Conversation, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	}
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	switch {
+	case s.Encryption != nil:
+		// unverschlüsselte Verläufe aus der Zeit davor bleiben lesbar
+		if IsEncrypted(data) {
+			if data, err = s.Encryption.Open(ctx, data); err != nil {
+				return nil, fmt.Errorf("conversation %s: %w", id, err)
+			}
+		}
+	case IsEncrypted(data):
+		return nil, fmt.Errorf("conversation %s: %w: store has no encryption", id, ErrDecrypt)
+	}
+	var snapshot conversationSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid conversation %s: %w", id, err)
+	}
+
+	c := NewConversation(service, snapshot.System)
+	c.ContextWindow = snapshot.ContextWindow
+	c.model = openai.ChatModel(snapshot.Model)
+	c.turns = snapshot.Turns
+	if len(c.turns) > 0 {
+		if c.System != "" {
+			c.messages = append(c.messages, openai.SystemMessage(c.System))
+		}
+		for _, turn := range c.turns {
+			c.messages = append(c.messages, openai.UserMessage(turn.User), openai.AssistantMessage(turn.Assistant))
+		}
+		c.footprint = c.turns[len(c.turns)-1].ContextTokens
+	}
+	return c, nil
+}
+
+// Delete entfernt die unter id gespeicherte Conversation; fehlt sie, ist das kein Fehler.
+func (s *FileConversationStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return log.WrapError(err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// ErrContextExhausted meldet, dass die nächste Runde einer Conversation nicht mehr ins
+// Kontextfenster passt. Dann sollte die Anwendung zusammenfassen oder neu beginnen.
+var ErrContextExhausted = errors.New("conversation exceeds context window")
+
+// Conversation führt einen Dialog über mehrere Runden. Jede Runde schickt den bisherigen
+// Verlauf erneut an die API; wie viel davon belegt ist, zeigen ContextTokens und
+// RemainingContext. Eine Conversation darf von mehreren Goroutinen genutzt werden, die
+// Runden laufen dann nacheinander.
+type Conversation struct {
+	Service *AiCommunicationService
+	System  string // System-Message jeder Runde, optional
+	// ContextWindow übersteuert das Kontextfenster des Modells (Tokens); 0 = ContextWindow(Modell).
+	ContextWindow int
+
+	mu        sync.Mutex
+	model     openai.ChatModel
+	messages  []openai.ChatCompletionMessageParamUnion
+	turns     []ConversationTurn
+	footprint int64 // Tokens des Verlaufs laut letzter Antwort
+}
+
+// ConversationTurn ist eine Runde: Nachricht, Antwort und die dabei gezählten Tokens.
+type ConversationTurn struct {
+	User             string  `json:"user"`
+	Assistant        string  `json:"assistant"`
+	Model            string  `json:"model"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	Cost             float64 `json:"cost"`
+	// ContextTokens ist der Verlauf nach dieser Runde (Prompt- plus Completion-Tokens).
+	ContextTokens int64 `json:"contextTokens"`
+}
+
+func NewConversation(service *AiCommunicationService, system string) *Conversation {
+	return &Conversation{Service: service, System: system}
+}
+
+// Send schickt message als nächste Nachricht des Benutzers und liefert die Antwort. Passt
+// die Runde voraussichtlich nicht mehr ins Kontextfenster, kommt ErrContextExhausted ohne
+// Request. Bei einem Fehler bleibt der Verlauf unverändert.
+func (c *Conversation) Send(ctx context.Context, message string, opts ...RequestOption) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ai := c.Service
+	ai.init()
+	cfg := ai.newRequestConfig(opts)
+	if cfg.taskErr != nil {
+		return "", log.WrapError(cfg.taskErr)
+	}
+	if c.model == "" {
+		c.model = cfg.model
+	}
+	if window := c.window(); window > 0 {
+		if need := c.contextTokens() + int64(estimateTokens(message)); need > int64(window) {
+			return "", fmt.Errorf("%w: about %d tokens needed, window is %d", ErrContextExhausted, need, window)
+		}
+	}
+
+	messages := c.messages
+	if len(messages) == 0 && c.System != "" {
+		messages = append(messages, openai.SystemMessage(c.System))
+	}
+	messages = append(messages[:len(messages):len(messages)], openai.UserMessage(message))
+	params := openai.ChatCompletionNewParams{
+		Messages:    messages,
+		Model:       cfg.model,
+		Temperature: openai.Float(cfg.temperature),
+	}
+	cfg.safety.apply(&params)
+	if cfg.user != "" {
+		params.User = param.NewOpt(cfg.user)
+	}
+
+	releaseBudget, err := ai.reserveBudget(int(c.contextTokens()) + estimateTokens(message))
+	if err != nil {
+		return "", err
+	}
+	defer releaseBudget()
+	completion, err := ai.createChatCompletion(ctx, params, cfg, int(c.contextTokens())+estimateTokens(message))
+	if err != nil {
+		return "", err
+	}
+	choice := completion.Choices[0]
+	if err := finishReasonError(choice.FinishReason); err != nil {
+		return "", err
+	}
+	cost := ai.addCosts(completion.Usage, cfg)
+	rec := ai.auditRecord(c.System, cfg)
+	rec.FinishReason = choice.FinishReason
+	rec.PromptTokens = completion.Usage.PromptTokens
+	rec.CompletionTokens = completion.Usage.CompletionTokens
+	rec.Cost = cost
+	ai.audit(rec)
+	if choice.Message.Refusal != "" {
+		return "", fmt.Errorf("%w: %s", ErrRefused, choice.Message.Refusal)
+	}
+	answer, err := ai.limitResponse(choice.Message.Content)
+	if err != nil {
+		return "", err
+	}
+
+	c.messages = append(messages, openai.AssistantMessage(answer))
+	c.footprint = completion.Usage.PromptTokens + completion.Usage.CompletionTokens
+	c.turns = append(c.turns, ConversationTurn{
+		User:             message,
+		Assistant:        answer,
+		Model:            completion.Model,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		Cost:             cost,
+		ContextTokens:    c.footprint,
+	})
+	return answer, nil
+}
+
+// Turns liefert die bisherigen Runden.
+func (c *Conversation) Turns() []ConversationTurn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ConversationTurn(nil), c.turns...)
+}
+
+// ContextTokens liefert, wie viele Tokens der Verlauf im Kontextfenster belegt: nach einer
+// Runde die von der API gezählten Prompt- und Completion-Tokens, davor die Schätzung der
+// System-Message.
+func (c *Conversation) ContextTokens() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.contextTokens()
+}
+
+func (c *Conversation) contextTokens() int64 {
+	if len(c.turns) > 0 {
+		return c.footprint
+	}
+	return int64(estimateTokens(c.System))
+}
+
+// RemainingContext liefert die im Kontextfenster noch freien Tokens für weitere Nachrichten
+// und Antworten; -1, wenn das Kontextfenster des Modells unbekannt ist.
+func (c *Conversation) RemainingContext() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	window := c.window()
+	if window <= 0 {
+		return -1
+	}
+	return max(int64(window)-c.contextTokens(), 0)
+}
+
+// window liefert das Kontextfenster; c.mu muss gehalten werden.
+func (c *Conversation) window() int {
+	if c.ContextWindow > 0 {
+		return c.ContextWindow
+	}
+	model := c.model
+	if model == "" && c.Service != nil {
+		model = c.Service.Model
+	}
+	return ContextWindow(model)
+}
@@ -0,0 +1,80 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dchaykin/myailib/openai/internal/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversation(t *testing.T) {
+	ai, srv := newMockService(t)
+	srv.Enqueue(
+		mockserver.Response{Content: "Hallo!", PromptTokens: 50, CompletionTokens: 10},
+		mockserver.ServerError(),
+		mockserver.Response{Content: "Gut, danke.", PromptTokens: 80, CompletionTokens: 20},
+	)
+	conv := NewConversation(ai, "Du bist freundlich.")
+	window := int64(ContextWindow(ai.Model))
+	require.EqualValues(t, estimateTokens("Du bist freundlich."), conv.ContextTokens())
+
+	answer, err := conv.Send(context.Background(), "Hallo")
+	require.NoError(t, err)
+	require.Equal(t, "Hallo!", answer)
+	require.EqualValues(t, 60, conv.ContextTokens())
+	require.Equal(t, window-60, conv.RemainingContext())
+
+	ai.MaxAttempts = 1
+	_, err = conv.Send(context.Background(), "Wie geht es?")
+	require.Error(t, err)
+	require.Len(t, conv.Turns(), 1, "failed turn is not recorded")
+
+	answer, err = conv.Send(context.Background(), "Wie geht es?")
+	require.NoError(t, err)
+	require.Equal(t, "Gut, danke.", answer)
+	require.EqualValues(t, 100, conv.ContextTokens())
+	require.Equal(t, window-100, conv.RemainingContext())
+
+	turns := conv.Turns()
+	require.Len(t, turns, 2)
+	require.EqualValues(t, 100, turns[1].ContextTokens)
+	require.InDelta(t, costOf(50, 10)+costOf(80, 20), ai.TotalCosts(), 1e-9)
+
+	var body struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	requests := srv.Requests()
+	require.NoError(t, json.Unmarshal(requests[len(requests)-1].Body, &body))
+	var roles []string
+	for _, m := range body.Messages {
+		roles = append(roles, m.Role)
+	}
+	require.Equal(t, []string{"system", "user", "assistant", "user"}, roles)
+	require.Equal(t, "Hallo!", body.Messages[2].Content)
+}
+
+func TestConversation_ContextExhausted(t *testing.T) {
+	ai, srv := newMockService(t)
+	srv.SetDefault(mockserver.Response{Content: "ok", PromptTokens: 900, CompletionTokens: 50})
+	conv := NewConversation(ai, "")
+	conv.ContextWindow = 1000
+
+	_, err := conv.Send(context.Background(), "erste Frage")
+	require.NoError(t, err)
+	require.EqualValues(t, 50, conv.RemainingContext())
+
+	_, err = conv.Send(context.Background(), strings.Repeat("x", 400))
+	require.ErrorIs(t, err, ErrContextExhausted)
+	require.Equal(t, 1, srv.ChatRequests())
+
+	conv = NewConversation(ai, "")
+	conv.ContextWindow = 0
+	ai.Model = "unknown-model"
+	require.EqualValues(t, -1, conv.RemainingContext())
+}
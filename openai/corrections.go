@@ -0,0 +1,211 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// defaultCorrectionExamples ist die Zahl der Beispiele, wenn CorrectionExamples 0 ist.
+const defaultCorrectionExamples = 3
+
+// Correction ist ein von einem Menschen korrigiertes Ergebnis. InputHash ist der SHA-256
+// der Quelldatei, wie ihn FileHash und Provenance.FileHash liefern.
+type Correction struct {
+	InputHash    string          `json:"inputHash"`
+	DocumentType string          `json:"documentType,omitempty"`
+	Corrected    json.RawMessage `json:"corrected"`
+	RecordedAt   time.Time       `json:"recordedAt"`
+}
+
+// CorrectionStore sammelt Korrekturen. Sie dienen als Few-Shot-Beispiele für folgende
+// Aufrufe mit demselben Dokumenttyp (AiCommunicationService.Corrections) und als
+// Testfälle, gegen die neue Ergebnisse verglichen werden (Evaluate). Mit Datei werden
+// Korrekturen als JSON Lines angehängt; je InputHash gilt die letzte.
+type CorrectionStore struct {
+	mu    sync.RWMutex
+	file  *os.File
+	items map[string]Correction
+	order []string // InputHash, älteste zuerst
+}
+
+// NewCorrectionStore legt einen Store an, der nur im Speicher lebt.
+func NewCorrectionStore() *CorrectionStore {
+	return &CorrectionStore{items: map[string]Correction{}}
+}
+
+// OpenCorrectionStore lädt die Korrekturen aus path und hängt neue dort an.
+func OpenCorrectionStore(path string) (*CorrectionStore, error) {
+	s := NewCorrectionStore()
+	if data, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(data)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var c Correction
+			if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+				log.Warn("skipping corrupt correction line in %s: %v", path, err)
+				continue
+			}
+			s.apply(c)
+		}
+		data.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, log.WrapError(err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, log.WrapError(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	terminateLastLine(file)
+	s.file = file
+	return s, nil
+}
+
+// RecordCorrection speichert das korrigierte Ergebnis zu einer Quelldatei.
+func (s *CorrectionStore) RecordCorrection(inputHash string, corrected json.RawMessage) error {
+	return s.RecordCorrectionFor("", inputHash, corrected)
+}
+
+// RecordCorrectionFor wie RecordCorrection, ordnet die Korrektur aber einem Dokumenttyp zu,
+// damit sie nur für ähnliche Dokumente als Beispiel dient.
+func (s *CorrectionStore) RecordCorrectionFor(docType, inputHash string, corrected json.RawMessage) error {
+	if inputHash == "" {
+		return fmt.Errorf("correction: input hash is required")
+	}
+	canonical, err := CanonicalJSON(string(corrected))
+	if err != nil {
+		return fmt.Errorf("correction for %s: %w", inputHash, err)
+	}
+	// einzeilig, damit Beispiele im Prompt kompakt bleiben
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(canonical)); err != nil {
+		return log.WrapError(err)
+	}
+	c := Correction{
+		InputHash:    inputHash,
+		DocumentType: docType,
+		Corrected:    json.RawMessage(compact.Bytes()),
+		RecordedAt:   time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return log.WrapError(err)
+		}
+		if _, err := s.file.Write(append(data, '\n')); err != nil {
+			return log.WrapError(err)
+		}
+		if err := s.file.Sync(); err != nil {
+			return log.WrapError(err)
+		}
+	}
+	s.apply(c)
+	return nil
+}
+
+// Get liefert die Korrektur zu einer Quelldatei.
+func (s *CorrectionStore) Get(inputHash string) (Correction, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.items[inputHash]
+	return c, ok
+}
+
+// Examples liefert bis zu n Korrekturen des Dokumenttyps, die neuesten zuerst.
+func (s *CorrectionStore) Examples(docType string, n int) []Correction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	examples := []Correction{}
+	for i := len(s.order) - 1; i >= 0 && len(examples) < n; i-- {
+		if c := s.items[s.order[i]]; c.DocumentType == docType {
+			examples = append(examples, c)
+		}
+	}
+	return examples
+}
+
+// Evaluate vergleicht ein Ergebnis mit der Korrektur zur selben Quelldatei. ok ist false,
+// wenn es keine Korrektur gibt; sonst listet changes die Abweichungen vom korrigierten Stand.
+func (s *CorrectionStore) Evaluate(inputHash, content string) (changes []FieldChange, ok bool, err error) {
+	c, ok := s.Get(inputHash)
+	if !ok {
+		return nil, false, nil
+	}
+	expected, err := decodeCanonical(c.Corrected, NumberFloat64)
+	if err != nil {
+		return nil, true, log.WrapError(err)
+	}
+	actual, err := decodeCanonical([]byte(content), NumberFloat64)
+	if err != nil {
+		return []FieldChange{{Kind: DiffChanged, Old: string(c.Corrected), New: content}}, true, nil
+	}
+	changes = []FieldChange{}
+	diffValues("", expected, actual, &changes)
+	return changes, true, nil
+}
+
+func (s *CorrectionStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+func (s *CorrectionStore) apply(c Correction) {
+	if _, ok := s.items[c.InputHash]; ok {
+		for i, hash := range s.order {
+			if hash == c.InputHash {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	s.items[c.InputHash] = c
+	s.order = append(s.order, c.InputHash)
+}
+
+// correctionExamples hängt korrigierte Ergebnisse desselben Dokumenttyps als Beispiele an
+// die System-Message an.
+func (ai *AiCommunicationService) correctionExamples(systemMessage string, cfg requestConfig) string {
+	n := ai.CorrectionExamples
+	if n <= 0 {
+		n = defaultCorrectionExamples
+	}
+	examples := ai.Corrections.Examples(cfg.documentType, n)
+	if len(examples) == 0 {
+		return systemMessage
+	}
+	var b strings.Builder
+	b.WriteString(systemMessage)
+	b.WriteString("\n\nBeispiele für korrekte Ergebnisse ähnlicher Dokumente:")
+	for _, c := range examples {
+		b.WriteString("\n")
+		b.Write(c.Corrected)
+	}
+	return b.String()
+}
+
+// FileHash liefert den SHA-256 einer Datei als Hex-String, z.B. als InputHash für RecordCorrection.
+func FileHash(fileName string) (string, error) {
+	hash, err := fileSHA256(fileName)
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	return hash, nil
+}
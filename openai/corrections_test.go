@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrectionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrections.jsonl")
+	s, err := OpenCorrectionStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.RecordCorrectionFor("invoice", "h1", json.RawMessage(`{"total": 1.50}`)))
+	require.NoError(t, s.RecordCorrectionFor("invoice", "h2", json.RawMessage(`{"total": 2}`)))
+	require.NoError(t, s.RecordCorrection("h3", json.RawMessage(`{"name": "x"}`)))
+	require.NoError(t, s.RecordCorrectionFor("invoice", "h1", json.RawMessage(`{"total": 1.25}`)))
+	require.Error(t, s.RecordCorrection("h4", json.RawMessage(`kein JSON`)))
+	require.NoError(t, s.Close())
+
+	s, err = OpenCorrectionStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	examples := s.Examples("invoice", 5)
+	require.Len(t, examples, 2)
+	require.Equal(t, "h1", examples[0].InputHash)
+	require.JSONEq(t, `{"total": 1.25}`, string(examples[0].Corrected))
+	require.Len(t, s.Examples("invoice", 1), 1)
+	require.Len(t, s.Examples("", 5), 1)
+
+	changes, ok, err := s.Evaluate("h2", `{"total": 2.0}`)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, changes)
+
+	changes, ok, err = s.Evaluate("h1", `{"total": 1.5}`)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []FieldChange{{Path: "total", Kind: DiffChanged, Old: 1.25, New: 1.5}}, changes)
+
+	_, ok, err = s.Evaluate("unknown", `{}`)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestCorrectionStore_TornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrections.jsonl")
+	s, err := OpenCorrectionStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.RecordCorrection("h1", json.RawMessage(`{"total": 1}`)))
+	require.NoError(t, s.Close())
+
+	// abgeschnittene Zeile wie nach einem Absturz
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"inputHash":"h2","corr`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = OpenCorrectionStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.RecordCorrection("h3", json.RawMessage(`{"total": 3}`)))
+	require.NoError(t, s.Close())
+
+	s, err = OpenCorrectionStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+	_, ok, err := s.Evaluate("h3", `{"total": 3}`)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestGenerateContent_CorrectionExamples(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Content any `json:"content"`
+		} `json:"messages"`
+	}
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	ai.Corrections = NewCorrectionStore()
+	require.NoError(t, ai.Corrections.RecordCorrectionFor("invoice", "h1", json.RawMessage(`{"total": 1.25}`)))
+
+	_, err := ai.GenerateContent("system", WithDocumentType("invoice"))
+	require.NoError(t, err)
+	require.Contains(t, body.Messages[0].Content, `{"total":1.25}`)
+
+	_, err = ai.GenerateContent("system", WithDocumentType("contract"))
+	require.NoError(t, err)
+	require.Equal(t, "system", body.Messages[0].Content)
+}
+
+func TestBatchConverter_EvaluatesCorrections(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF+"a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "b.pdf"), []byte(testPDF+"b"), 0644))
+	hashA, err := FileHash(filepath.Join(src, "a.pdf"))
+	require.NoError(t, err)
+	hashB, err := FileHash(filepath.Join(src, "b.pdf"))
+	require.NoError(t, err)
+
+	ai := newBatchTestService(t, func() string { return `{"ok": true}` })
+	ai.Corrections = NewCorrectionStore()
+	require.NoError(t, ai.Corrections.RecordCorrection(hashA, json.RawMessage(`{"ok": true}`)))
+	require.NoError(t, ai.Corrections.RecordCorrection(hashB, json.RawMessage(`{"ok": false}`)))
+
+	result, err := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out")).Run()
+	require.NoError(t, err)
+	require.Equal(t, 2, result.EvalCases)
+	require.Equal(t, 1, result.EvalMatches)
+	require.Equal(t, []FieldChange{{Path: "ok", Kind: DiffChanged, Old: false, New: true}}, result.Documents[1].EvalChanges)
+}
@@ -0,0 +1,148 @@
+package openai
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule liefert den nächsten Ausführungszeitpunkt nach t; der Nullwert heißt nie.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule versteht fünfstellige Cron-Ausdrücke ("Minute Stunde Tag Monat Wochentag"
+// mit *, Listen, Bereichen und Schritten) sowie @hourly, @daily, @weekly und @every <Dauer>.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	}
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q", spec)
+		}
+		return everySchedule(d), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	var cs cronSchedule
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		cs.fields[i] = set
+	}
+	cs.domAny = fields[2] == "*"
+	cs.dowAny = fields[4] == "*"
+	if cs.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never matches", spec)
+	}
+	return cs, nil
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+type cronSchedule struct {
+	fields [5]map[int]bool // Minute, Stunde, Tag, Monat, Wochentag
+	domAny bool
+	dowAny bool
+}
+
+func (c cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.fields[3][int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.fields[1][t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.fields[0][t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches folgt der Cron-Regel: sind Tag und Wochentag eingeschränkt, genügt einer von beiden.
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.fields[2][t.Day()]
+	dow := c.fields[4][int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func parseCronField(field string, lo, hi int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+		}
+
+		from, to := lo, hi
+		if rangePart != "*" {
+			a, b, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if hi == 6 && to == 7 { // Sonntag als 7
+			set[0] = true
+			to = 6
+			if from == 7 {
+				continue
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return nil, fmt.Errorf("value out of range in %q", part)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,13 @@
+// Package openai kapselt die Kommunikation mit OpenAI: Textgenerierung und Extraktion aus
+// Dokumenten, Stapelverarbeitung, Agenten sowie Kosten- und Lastkontrolle.
+//
+// Einstieg ist Provider mit Generate, den AiCommunicationService implementiert. Einstellungen
+// je Aufruf werden als RequestOption übergeben, das Ergebnis kommt als Result.
+//
+// Kompatibilität: Innerhalb von v1 bleiben exportierte Typen, Funktionen, Methoden und Felder
+// erhalten und behalten ihre Bedeutung; hinzukommen können neue Felder, Optionen, Funktionen
+// und Methoden, aber keine neuen Methoden an Provider. Veraltetes ist mit "Deprecated:"
+// markiert, nennt den Ersatz und entfällt frühestens mit v2. Ausgenommen sind experimentelle
+// Features (siehe Feature) und der Wortlaut von Fehlermeldungen; Fehler prüft man mit
+// errors.Is und errors.As gegen die exportierten Fehler und Typen.
+package openai
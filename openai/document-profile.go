@@ -0,0 +1,144 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// DocumentProfile beschreibt ein Dokument vor der eigentlichen Extraktion.
+type DocumentProfile struct {
+	Type     string `json:"type"`               // z.B. "invoice"
+	Language string `json:"language,omitempty"` // ISO 639-1
+	Pages    int    `json:"pages,omitempty"`
+	Scanned  bool   `json:"scanned"` // gescannt oder fotografiert statt digital erzeugt
+}
+
+// ProfileRoute legt fest, wie Dokumente eines Profils verarbeitet werden.
+type ProfileRoute struct {
+	Route
+	SystemMessage string // ersetzt BatchConverter.SystemMessage, z.B. mit eigenem Schema; leer = unverändert
+	// Folder nimmt die Ergebnisse dieses Typs auf; relativ zu BatchConverter.DestFolder, leer = DestFolder.
+	Folder string
+}
+
+// DocumentProfiler klassifiziert jedes Dokument in einem günstigen Vorlauf und wählt danach
+// Prompt, Modell und Schema der Extraktion. Seitenzahl und Scan werden bei PDFs lokal
+// bestimmt, soweit die Datei das zulässt; sonst gilt die Angabe des Modells.
+type DocumentProfiler struct {
+	Model openai.ChatModel // günstiges Modell für den Vorlauf; leer = Modell des Services
+	Types []string         // erlaubte Dokumenttypen; leer = Schlüssel von Routes
+	// Routes ordnet Dokumenttypen ihre Verarbeitung zu. Für andere Typen gelten die Vorgaben
+	// des BatchConverter.
+	Routes map[string]ProfileRoute
+	// Select wählt die Verarbeitung statt Routes, z.B. ein stärkeres Modell für Scans. Optional.
+	Select func(DocumentProfile) (ProfileRoute, bool)
+}
+
+// Profile klassifiziert die Datei.
+func (p *DocumentProfiler) Profile(ctx context.Context, ai *AiCommunicationService, fileName string) (DocumentProfile, error) {
+	return p.profile(ctx, ai, fileName, nil)
+}
+
+// profile wie Profile; usage sammelt Tokens und Kosten des Vorlaufs, optional.
+func (p *DocumentProfiler) profile(ctx context.Context, ai *AiCommunicationService, fileName string, usage *callUsage) (DocumentProfile, error) {
+	opts := []RequestOption{WithPrompt(""), WithPostProcessors(), WithPriority(PriorityBatch), WithDocumentType("profile")}
+	if p.Model != "" {
+		opts = append(opts, WithModel(p.Model))
+	}
+	cfg := ai.newRequestConfig(opts)
+	// der Vorlauf soll billig bleiben: keine Mehrfach- oder Prüfdurchläufe
+	cfg.cheapFirst, cfg.voting, cfg.verification, cfg.language = nil, nil, nil, nil
+	cfg.usage = usage
+
+	content, err := ai.generateContentWithPDF(ctx, profileSystemMessage(p.types()), fileName, cfg)
+	if err != nil {
+		return DocumentProfile{}, err
+	}
+	var profile DocumentProfile
+	if err := json.Unmarshal([]byte(content), &profile); err != nil {
+		return DocumentProfile{}, fmt.Errorf("invalid document profile: %w", err)
+	}
+	profile.Type = strings.TrimSpace(profile.Type)
+	profile.Language = strings.ToLower(strings.TrimSpace(profile.Language))
+	if pages, scanned, ok := analyzePDF(fileName); ok {
+		profile.Pages, profile.Scanned = pages, scanned
+	}
+	return profile, nil
+}
+
+func (p *DocumentProfiler) types() []string {
+	if len(p.Types) > 0 {
+		return p.Types
+	}
+	types := make([]string, 0, len(p.Routes))
+	for t := range p.Routes {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// route liefert die Verarbeitung für das Profil.
+func (p *DocumentProfiler) route(profile DocumentProfile) (ProfileRoute, bool) {
+	if p.Select != nil {
+		return p.Select(profile)
+	}
+	route, ok := p.Routes[profile.Type]
+	return route, ok
+}
+
+// apply überträgt die Verarbeitung des Profils auf System-Message und Konfiguration und
+// liefert den Ordner für das Ergebnis (leer = Zielverzeichnis).
+func (p *DocumentProfiler) apply(profile DocumentProfile, systemMessage string, cfg requestConfig) (string, requestConfig, string) {
+	if profile.Type != "" {
+		cfg.documentType = profile.Type
+	}
+	route, ok := p.route(profile)
+	if !ok {
+		return systemMessage, cfg, ""
+	}
+	route.Route.apply(&cfg)
+	if route.SystemMessage != "" {
+		systemMessage = route.SystemMessage
+	}
+	return systemMessage, cfg, route.Folder
+}
+
+func profileSystemMessage(types []string) string {
+	msg := `Klassifiziere das Dokument. Antworte ausschließlich mit JSON der Form ` +
+		`{"type": "<Dokumenttyp>", "language": "<ISO-639-1-Code der Sprache>", "pages": <Seitenzahl>, "scanned": <true, wenn gescannt oder fotografiert, sonst false>}.`
+	if len(types) > 0 {
+		msg += ` Wähle type aus: ` + strings.Join(types, ", ") + `. Passt keiner, setze type auf "other".`
+	}
+	return msg
+}
+
+var pdfPageRe = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// analyzePDF zählt die Seiten und erkennt Scans (Bilder ohne Schriften). ok ist false,
+// wenn die Datei kein PDF ist oder ihre Objekte komprimiert sind.
+func analyzePDF(fileName string) (pages int, scanned bool, ok bool) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		log.Warn("cannot analyze %s: %v", fileName, err)
+		return 0, false, false
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF")) || bytes.Contains(data, []byte("/ObjStm")) {
+		return 0, false, false
+	}
+	pages = len(pdfPageRe.FindAll(data, -1))
+	if pages == 0 {
+		return 0, false, false
+	}
+	scanned = bytes.Contains(data, []byte("/Image")) && !bytes.Contains(data, []byte("/Font"))
+	return pages, scanned, true
+}
@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newProfileTestService klassifiziert jede Datei als Rechnung und antwortet auf Extraktionen
+// mit Modell und System-Message des Requests.
+func newProfileTestService(t *testing.T) (*AiCommunicationService, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var models []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files") {
+			_, _ = w.Write([]byte(testUploadedFile))
+			return
+		}
+		var body struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content any `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		models = append(models, body.Model)
+		mu.Unlock()
+		system := body.Messages[0].Content.(string)
+		content := map[string]any{"model": body.Model, "system": system}
+		if strings.HasPrefix(system, "Klassifiziere") {
+			require.Contains(t, system, "bank-statement, invoice")
+			content = map[string]any{"type": "invoice", "language": "DE", "pages": 7, "scanned": false}
+		}
+		data, _ := json.Marshal(content)
+		quoted, _ := json.Marshal(string(data))
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, string(quoted), 1)))
+	})
+	return ai, &models
+}
+
+func TestBatchConverter_Profiler(t *testing.T) {
+	src := t.TempDir()
+	// digitale PDF mit zwei Seiten, lokal auswertbar
+	pdf := "%PDF-1.4\n1 0 obj << /Type /Pages /Kids [2 0 R 3 0 R] >>\n2 0 obj << /Type /Page /Resources << /Font << /F1 4 0 R >> >> >>\n3 0 obj << /Type /Page >>\n%%EOF\n"
+	require.NoError(t, os.WriteFile(filepath.Join(src, "invoice.pdf"), []byte(pdf), 0644))
+	ai, models := newProfileTestService(t)
+
+	bc := NewBatchConverter(ai, "Standard", src, filepath.Join(t.TempDir(), "out"))
+	bc.Profiler = &DocumentProfiler{
+		Model: "gpt-4.1-mini",
+		Routes: map[string]ProfileRoute{
+			"invoice":        {Route: Route{Model: "gpt-4.1"}, SystemMessage: "Rechnungsschema", Folder: "invoices"},
+			"bank-statement": {SystemMessage: "Kontoauszugsschema"},
+		},
+	}
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Converted)
+
+	doc := result.Documents[0]
+	require.Equal(t, &DocumentProfile{Type: "invoice", Language: "de", Pages: 2}, doc.Profile)
+	require.JSONEq(t, `{"model": "gpt-4.1", "system": "Rechnungsschema"}`, doc.Content)
+	require.Equal(t, []string{"gpt-4.1-mini", "gpt-4.1"}, *models)
+	require.Equal(t, 2*costOf(100, 20), doc.Cost)
+	require.Equal(t, filepath.Join(bc.DestFolder, "invoices", "invoice.pdf"), doc.OutputFile)
+	require.FileExists(t, doc.OutputFile)
+
+	// fortgesetzter Lauf: Profil aus dem Journal, kein neuer Vorlauf
+	result, err = bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, "already converted", result.Documents[0].Reason)
+	require.Len(t, *models, 2)
+}
+
+func TestAnalyzePDF(t *testing.T) {
+	dir := t.TempDir()
+	scan := filepath.Join(dir, "scan.pdf")
+	require.NoError(t, os.WriteFile(scan, []byte("%PDF-1.4\n<< /Type /Page >>\n<< /Subtype /Image >>\n%%EOF\n"), 0644))
+	pages, scanned, ok := analyzePDF(scan)
+	require.True(t, ok)
+	require.Equal(t, 1, pages)
+	require.True(t, scanned)
+
+	compressed := filepath.Join(dir, "compressed.pdf")
+	require.NoError(t, os.WriteFile(compressed, []byte("%PDF-1.7\n<< /Type /ObjStm >>\n%%EOF\n"), 0644))
+	_, _, ok = analyzePDF(compressed)
+	require.False(t, ok)
+}
@@ -0,0 +1,237 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// ErrDecrypt meldet, dass verschlüsselte Daten nicht gelesen werden können: falscher oder
+// unbekannter Schlüssel, veränderte Daten oder kein verschlüsseltes Format.
+var ErrDecrypt = errors.New("cannot decrypt data")
+
+// encryptionMagic leitet verschlüsselte Daten ein, danach folgen Version, Länge und ID des
+// Schlüssels, Nonce und Chiffrat samt GCM-Tag. Der Kopf ist als Additional Data geschützt.
+var encryptionMagic = []byte("MYAE")
+
+const (
+	encryptionVersion = 1
+	// DefaultKeyCacheTTL: so lange wird der aktuelle Schlüssel nicht erneut beim KeyProvider geholt.
+	DefaultKeyCacheTTL = 5 * time.Minute
+)
+
+// KeyProvider liefert AES-Schlüssel (16, 24 oder 32 Bytes) für die Verschlüsselung ruhender
+// Daten, z.B. aus Umgebungsvariablen (EnvKeyProvider) oder einem KMS. Implementierungen
+// müssen nebenläufig nutzbar sein.
+type KeyProvider interface {
+	// CurrentKey liefert den Schlüssel für neue Daten und seine ID.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key liefert den Schlüssel zu einer ID, nach einer Rotation auch ältere.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// EnvKeyProvider liest base64-kodierte Schlüssel aus Umgebungsvariablen. Current
+// verschlüsselt neue Daten, Previous bleiben nach einer Rotation zum Lesen erhalten. Die ID
+// eines Schlüssels ist sein Fingerabdruck, der Schlüssel selbst wird nie gespeichert.
+type EnvKeyProvider struct {
+	Current  string   // Name der Env-Variable, z.B. "MYAILIB_ENCRYPTION_KEY"
+	Previous []string // Namen der Env-Variablen früherer Schlüssel, optional
+}
+
+func (p EnvKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := envKey(p.Current)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyFingerprint(key), key, nil
+}
+
+func (p EnvKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	for _, name := range append([]string{p.Current}, p.Previous...) {
+		key, err := envKey(name)
+		if err != nil {
+			return nil, err
+		}
+		if keyFingerprint(key) == id {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown key %s", ErrDecrypt, id)
+}
+
+func envKey(name string) ([]byte, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return nil, fmt.Errorf("encryption key variable %s is not set", name)
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key variable %s is not valid base64: %w", name, err)
+	}
+	if err := validKey(key); err != nil {
+		return nil, fmt.Errorf("encryption key variable %s: %w", name, err)
+	}
+	return key, nil
+}
+
+func validKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("key must have 16, 24 or 32 bytes, got %d", len(key))
+}
+
+// keyFingerprint ist eine kurze, nicht umkehrbare ID des Schlüssels.
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Encryption verschlüsselt ruhende Daten mit AES-GCM, z.B. für FileResultCache,
+// FileConversationStore und OpenEncryptedAuditLog. Jeder Datensatz trägt die ID seines
+// Schlüssels, ältere Daten bleiben nach einer Rotation lesbar, solange der KeyProvider den
+// Schlüssel noch kennt.
+type Encryption struct {
+	Keys KeyProvider
+	// KeyCacheTTL legt fest, wie lange der aktuelle Schlüssel zwischengespeichert wird; Default:
+	// DefaultKeyCacheTTL, negativ = bei jedem Datensatz neu holen.
+	KeyCacheTTL time.Duration
+
+	mu         sync.Mutex
+	current    *cipherKey
+	currentExp time.Time
+	ciphers    map[string]cipher.AEAD
+}
+
+type cipherKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func NewEncryption(keys KeyProvider) *Encryption {
+	return &Encryption{Keys: keys, KeyCacheTTL: DefaultKeyCacheTTL}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// currentKey liefert den Schlüssel für neue Daten, zwischengespeichert nach KeyCacheTTL.
+func (e *Encryption) currentKey(ctx context.Context) (*cipherKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current != nil && time.Now().Before(e.currentExp) {
+		return e.current, nil
+	}
+	id, key, err := e.Keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) == 0 || len(id) > 255 {
+		return nil, fmt.Errorf("key id must have 1 to 255 bytes, got %d", len(id))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	ttl := e.KeyCacheTTL
+	if ttl == 0 {
+		ttl = DefaultKeyCacheTTL
+	}
+	e.current, e.currentExp = &cipherKey{id: id, aead: aead}, time.Now().Add(ttl)
+	return e.current, nil
+}
+
+// keyByID liefert den Schlüssel zu id; bekannte Schlüssel werden nicht erneut geholt.
+func (e *Encryption) keyByID(ctx context.Context, id string) (cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if aead, ok := e.ciphers[id]; ok {
+		return aead, nil
+	}
+	key, err := e.Keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if e.ciphers == nil {
+		e.ciphers = map[string]cipher.AEAD{}
+	}
+	e.ciphers[id] = aead
+	return aead, nil
+}
+
+// Seal verschlüsselt data mit dem aktuellen Schlüssel.
+func (e *Encryption) Seal(ctx context.Context, data []byte) ([]byte, error) {
+	key, err := e.currentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append(bytes.Clone(encryptionMagic), encryptionVersion, byte(len(key.id))), key.id...)
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, log.WrapError(err)
+	}
+	out := append(header, nonce...)
+	return key.aead.Seal(out, nonce, data, header), nil
+}
+
+// Open entschlüsselt mit Seal verschlüsselte Daten.
+func (e *Encryption) Open(ctx context.Context, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, fmt.Errorf("%w: not encrypted", ErrDecrypt)
+	}
+	rest := data[len(encryptionMagic):]
+	if len(rest) < 2 || rest[0] != encryptionVersion {
+		return nil, fmt.Errorf("%w: unsupported format", ErrDecrypt)
+	}
+	idLen := int(rest[1])
+	if len(rest) < 2+idLen {
+		return nil, fmt.Errorf("%w: truncated data", ErrDecrypt)
+	}
+	id := string(rest[2 : 2+idLen])
+	aead, err := e.keyByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	headerLen := len(encryptionMagic) + 2 + idLen
+	body := data[headerLen:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: truncated data", ErrDecrypt)
+	}
+	nonce, ciphertext := body[:aead.NonceSize()], body[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, data[:headerLen])
+	if err != nil {
+		return nil, fmt.Errorf("%w: data was modified or the key is wrong", ErrDecrypt)
+	}
+	return plain, nil
+}
+
+// IsEncrypted meldet, ob data mit Encryption.Seal verschlüsselt wurde.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptionMagic)
+}
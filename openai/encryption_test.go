@@ -0,0 +1,175 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dchaykin/myailib/openai/internal/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func setTestKey(t *testing.T, name string, b byte) {
+	t.Helper()
+	t.Setenv(name, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32)))
+}
+
+func newTestEncryption(t *testing.T) *Encryption {
+	t.Helper()
+	setTestKey(t, "TEST_ENCRYPTION_KEY", 1)
+	return NewEncryption(EnvKeyProvider{Current: "TEST_ENCRYPTION_KEY"})
+}
+
+func TestEncryption_SealOpen(t *testing.T) {
+	ctx := context.Background()
+	enc := newTestEncryption(t)
+	sealed, err := enc.Seal(ctx, []byte(`{"iban": "DE89370400440532013000"}`))
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(sealed))
+	require.NotContains(t, string(sealed), "DE89")
+	again, err := enc.Seal(ctx, []byte(`{"iban": "DE89370400440532013000"}`))
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again, "fresh nonce per record")
+
+	plain, err := enc.Open(ctx, sealed)
+	require.NoError(t, err)
+	require.Equal(t, `{"iban": "DE89370400440532013000"}`, string(plain))
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, err = enc.Open(ctx, tampered)
+	require.ErrorIs(t, err, ErrDecrypt)
+	_, err = enc.Open(ctx, []byte(`{"plain": true}`))
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	// Rotation: der alte Schlüssel bleibt lesbar, neue Daten nutzen den neuen
+	setTestKey(t, "TEST_ENCRYPTION_KEY_NEW", 2)
+	rotated := NewEncryption(EnvKeyProvider{Current: "TEST_ENCRYPTION_KEY_NEW", Previous: []string{"TEST_ENCRYPTION_KEY"}})
+	plain, err = rotated.Open(ctx, sealed)
+	require.NoError(t, err)
+	require.Contains(t, string(plain), "DE89")
+	newer, err := rotated.Seal(ctx, plain)
+	require.NoError(t, err)
+	_, err = enc.Open(ctx, newer)
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	t.Setenv("TEST_ENCRYPTION_KEY_SHORT", base64.StdEncoding.EncodeToString([]byte("short")))
+	_, err = NewEncryption(EnvKeyProvider{Current: "TEST_ENCRYPTION_KEY_SHORT"}).Seal(ctx, plain)
+	require.ErrorContains(t, err, "16, 24 or 32 bytes")
+	_, err = NewEncryption(EnvKeyProvider{Current: "TEST_ENCRYPTION_KEY_MISSING"}).Seal(ctx, plain)
+	require.ErrorContains(t, err, "is not set")
+}
+
+func TestFileResultCache_Encryption(t *testing.T) {
+	cache, err := NewFileResultCache(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("plain", `{"name": "Max Muster"}`))
+	cache.Encryption = newTestEncryption(t)
+
+	_, ok, err := cache.Get("plain")
+	require.NoError(t, err)
+	require.False(t, ok, "plaintext entries are ignored once encryption is on")
+
+	require.NoError(t, cache.Set("secret", `{"name": "Max Muster"}`))
+	raw, err := os.ReadFile(cache.path("secret"))
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "Muster")
+	content, ok, err := cache.Get("secret")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `{"name": "Max Muster"}`, content)
+
+	cache.Encryption = nil
+	_, _, err = cache.Get("secret")
+	require.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestEncryptedAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	plain, err := OpenAuditLog(path)
+	require.NoError(t, err)
+	require.NoError(t, plain.Record(AuditRecord{Time: time.Unix(100, 0).UTC(), Model: "old", User: "u1"}))
+	require.NoError(t, plain.Close())
+
+	enc := newTestEncryption(t)
+	a, err := OpenEncryptedAuditLog(path, enc)
+	require.NoError(t, err)
+	require.NoError(t, a.Record(AuditRecord{Time: time.Unix(200, 0).UTC(), Model: "gpt-4o", User: "secret-user"}))
+	require.NoError(t, a.Close())
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "secret-user")
+	require.Len(t, strings.Split(strings.TrimSpace(string(raw)), "\n"), 2)
+
+	records, err := ReadEncryptedAuditLog(path, enc)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, "old", records[0].Model)
+	require.Equal(t, "secret-user", records[1].User)
+
+	_, err = ReadAuditLog(path)
+	require.ErrorContains(t, err, "is encrypted")
+	_, err = OpenEncryptedAuditLog(path, nil)
+	require.Error(t, err)
+}
+
+func TestFileConversationStore(t *testing.T) {
+	ai, srv := newMockService(t)
+	srv.Enqueue(
+		mockserver.Response{Content: "Hallo!", PromptTokens: 50, CompletionTokens: 10},
+		mockserver.Response{Content: "Gut, danke.", PromptTokens: 80, CompletionTokens: 20},
+	)
+	ctx := context.Background()
+	store, err := NewFileConversationStore(filepath.Join(t.TempDir(), "conversations"))
+	require.NoError(t, err)
+	store.Encryption = newTestEncryption(t)
+
+	conv := NewConversation(ai, "Du bist freundlich.")
+	_, err = conv.Send(ctx, "Hallo, ich bin Max Muster")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, "chat-1", conv))
+
+	raw, err := os.ReadFile(filepath.Join(store.Dir, "chat-1.json"))
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "Muster")
+	info, err := os.Stat(filepath.Join(store.Dir, "chat-1.json"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := store.Load(ctx, "chat-1", ai)
+	require.NoError(t, err)
+	require.Equal(t, conv.Turns(), loaded.Turns())
+	require.EqualValues(t, 60, loaded.ContextTokens())
+
+	_, err = loaded.Send(ctx, "Wie geht es?")
+	require.NoError(t, err)
+	var body struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	requests := srv.Requests()
+	require.NoError(t, json.Unmarshal(requests[len(requests)-1].Body, &body))
+	require.Len(t, body.Messages, 4)
+	require.Equal(t, "Hallo, ich bin Max Muster", body.Messages[1].Content)
+	require.Equal(t, "Hallo!", body.Messages[2].Content)
+
+	_, err = store.Load(ctx, "missing", ai)
+	require.ErrorIs(t, err, ErrConversationNotFound)
+	_, err = store.Load(ctx, "../etc/passwd", ai)
+	require.ErrorContains(t, err, "invalid conversation id")
+
+	store.Encryption = nil
+	_, err = store.Load(ctx, "chat-1", ai)
+	require.ErrorIs(t, err, ErrDecrypt)
+	require.NoError(t, store.Delete("chat-1"))
+	require.NoError(t, store.Delete("chat-1"))
+}
@@ -0,0 +1,78 @@
+package openai
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// IsTimeout meldet Zeitüberschreitungen: abgelaufene Deadlines des Kontexts, Timeouts
+// der Verbindung und 408/504-Antworten der API.
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var oe *OpenAIError
+	if errors.As(err, &oe) {
+		return oe.IsTimeout()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsNetwork meldet Fehler auf dem Weg zur API (DNS, Verbindungsaufbau, abgebrochene
+// Verbindungen, TLS), bei denen also keine Antwort der API vorliegt.
+func IsNetwork(err error) bool {
+	if err == nil {
+		return false
+	}
+	var oe *OpenAIError
+	if errors.As(err, &oe) {
+		return false
+	}
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+	return errors.As(err, &opErr) ||
+		errors.As(err, &dnsErr) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		isTLSError(err)
+}
+
+// isTLSError meldet Fehler beim TLS-Handshake und bei der Zertifikatsprüfung.
+func isTLSError(err error) bool {
+	var (
+		headerErr tls.RecordHeaderError
+		alertErr  tls.AlertError
+		verifyErr *tls.CertificateVerificationError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		certErr   x509.CertificateInvalidError
+	)
+	return errors.As(err, &headerErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) ||
+		errors.As(err, &authErr) ||
+		errors.As(err, &hostErr) ||
+		errors.As(err, &certErr)
+}
+
+// isTransientNetworkError meldet Verbindungsfehler, bei denen sich ein neuer Versuch lohnt.
+// Abbruch und Deadline des Kontexts sowie TLS-Fehler zählen nicht dazu.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return IsNetwork(err) && !isTLSError(err)
+}
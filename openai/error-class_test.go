@@ -0,0 +1,51 @@
+package openai
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorClassification(t *testing.T) {
+	reset := &url.Error{Op: "Post", URL: "https://api.openai.com/v1/chat/completions", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+	dialTimeout := &url.Error{Op: "Post", URL: "https://api.openai.com/v1/chat/completions", Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}}
+	tlsErr := &url.Error{Op: "Post", URL: "https://api.openai.com/v1/chat/completions", Err: x509.UnknownAuthorityError{}}
+
+	cases := []struct {
+		name      string
+		err       error
+		timeout   bool
+		network   bool
+		transient bool
+	}{
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), true, false, false},
+		{"canceled", context.Canceled, false, false, false},
+		{"reset", reset, false, true, true},
+		{"dial timeout", dialTimeout, true, true, true},
+		{"tls", tlsErr, false, true, false},
+		{"dns", &net.DNSError{Err: "no such host", Name: "api.openai.com"}, false, true, true},
+		{"api 504", &OpenAIError{Status: 504}, true, false, false},
+		{"api 500", &OpenAIError{Status: 500}, false, false, false},
+		{"other", errors.New("boom"), false, false, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.timeout, IsTimeout(c.err))
+			require.Equal(t, c.network, IsNetwork(c.err))
+			require.Equal(t, c.transient, isTransientNetworkError(c.err))
+		})
+	}
+}
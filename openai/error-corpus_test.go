@@ -0,0 +1,128 @@
+package openai
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testdata/openai-errors enthält echte Fehlerstrings der API (<name>.txt) und die erwartete
+// Auswertung (<name>.golden.json). Ändert OpenAI den Wortlaut, kommt ein neuer Fall dazu;
+// die Golden-Dateien werden mit -update neu geschrieben und vor dem Commit von Hand geprüft:
+//
+//	go test ./openai -run TestErrorCorpus -update
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+const errorCorpusDir = "testdata/openai-errors"
+
+// corpusResult ist die erwartete Auswertung eines Fehlerstrings.
+type corpusResult struct {
+	Parser      string          `json:"parser"` // "json", "plain" oder "none"
+	Error       json.RawMessage `json:"error,omitempty"`
+	RateLimit   bool            `json:"rateLimit"`
+	Auth        bool            `json:"auth"`
+	ServerError bool            `json:"serverError"`
+	Timeout     bool            `json:"timeout"`
+	Retryable   bool            `json:"retryable"`
+}
+
+// parseCorpusError wertet raw wie der Service aus: erst das JSON-Format, dann das Textformat.
+func parseCorpusError(t *testing.T, raw string) corpusResult {
+	t.Helper()
+	parser := "json"
+	e, err := ParseOpenAIJsonError(raw)
+	if err != nil {
+		parser = "plain"
+		e, err = ParseOpenAIPlainError(raw)
+	}
+	if err != nil {
+		return corpusResult{Parser: "none"}
+	}
+	data, err := json.MarshalIndent(e, "  ", "  ")
+	require.NoError(t, err)
+	return corpusResult{
+		Parser:      parser,
+		Error:       data,
+		RateLimit:   e.IsRateLimit(),
+		Auth:        e.IsAuth(),
+		ServerError: e.IsServerError(),
+		Timeout:     e.IsTimeout(),
+		Retryable:   e.isRetryable(),
+	}
+}
+
+func errorCorpus(tb testing.TB) []string {
+	tb.Helper()
+	files, err := filepath.Glob(filepath.Join(errorCorpusDir, "*.txt"))
+	require.NoError(tb, err)
+	require.NotEmpty(tb, files)
+	return files
+}
+
+func readCorpusError(tb testing.TB, file string) string {
+	tb.Helper()
+	data, err := os.ReadFile(file)
+	require.NoError(tb, err)
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+func TestErrorCorpus(t *testing.T) {
+	for _, file := range errorCorpus(t) {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		t.Run(name, func(t *testing.T) {
+			got := parseCorpusError(t, readCorpusError(t, file))
+			data, err := json.MarshalIndent(got, "", "  ")
+			require.NoError(t, err)
+			data = append(data, '\n')
+
+			golden := strings.TrimSuffix(file, ".txt") + ".golden.json"
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, data, 0644))
+				return
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err, "missing golden file, run with -update")
+			require.JSONEq(t, string(want), string(data))
+		})
+	}
+}
+
+// TestErrorCorpus_Invariants prüft Eigenschaften, die für jeden Fehlerstring gelten müssen,
+// unabhängig vom Inhalt der Golden-Dateien.
+func TestErrorCorpus_Invariants(t *testing.T) {
+	for _, file := range errorCorpus(t) {
+		raw := readCorpusError(t, file)
+		name := filepath.Base(file)
+
+		e, err := ParseOpenAIJsonError(raw)
+		if err != nil {
+			e, err = ParseOpenAIPlainError(raw)
+		}
+		require.NoError(t, err, name)
+		require.NotZero(t, e.Status, name)
+		require.NotEmpty(t, e.Reason, name)
+		require.True(t, strings.HasPrefix(e.URL, "https://api.openai.com/v1/"), name)
+
+		if strings.Contains(raw, "Rate limit reached") {
+			require.NotNil(t, e.RateInfo, "%s: rate limit details not recognized", name)
+			require.Positive(t, e.RateInfo.RetryAfter, name)
+			require.Positive(t, e.RateInfo.Limit, name)
+			require.True(t, e.isRetryable(), name)
+		}
+		if e.Code == "insufficient_quota" {
+			require.False(t, e.isRetryable(), name)
+		}
+
+		// die stabile JSON-Form muss sich verlustfrei wieder einlesen lassen
+		data, err := json.Marshal(e)
+		require.NoError(t, err, name)
+		var back openAIErrorJSON
+		require.NoError(t, json.Unmarshal(data, &back), name)
+		require.Equal(t, e.toJSON(), back, name)
+	}
+}
@@ -3,8 +3,7 @@ package openai
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
-	"math"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,6 +21,8 @@ type OpenAIError struct {
 	Param    *string         // i.d.R. nil
 	Code     string          // z.B. "rate_limit_exceeded"
 	RateInfo *OpenAIRateInfo // Parse aus message (nur wenn erkannt)
+
+	receivedAt time.Time // Zeitpunkt der Antwort, Basis für RetryAfter
 }
 
 func (e *OpenAIError) Error() string {
@@ -70,6 +71,151 @@ func (e *OpenAIError) IsServerError() bool {
 	return e.Status >= 500 && e.Status <= 599
 }
 
+// IsTimeout meldet true, wenn die API selbst eine Zeitüberschreitung meldet (408, 504).
+func (e *OpenAIError) IsTimeout() bool {
+	if e == nil {
+		return false
+	}
+	return e.Status == 408 || e.Status == 504
+}
+
+// isRetryable meldet Fehler, bei denen ein späterer Versuch Erfolg verspricht: Rate-Limits
+// und 5xx. insufficient_quota kommt ebenfalls als 429, ein neuer Versuch hilft dort aber nicht.
+func (e *OpenAIError) isRetryable() bool {
+	if e == nil {
+		return false
+	}
+	return (e.IsRateLimit() && e.Code != "insufficient_quota") || e.IsServerError()
+}
+
+// retryAfter liefert die empfohlene Wartezeit aus RateInfo, sonst 0.
+func (e *OpenAIError) retryAfter() time.Duration {
+	if e == nil || e.RateInfo == nil {
+		return 0
+	}
+	return e.RateInfo.RetryAfter
+}
+
+// openAIErrorJSON ist die stabile JSON-Form von OpenAIError.
+type openAIErrorJSON struct {
+	Method   string              `json:"method,omitempty"`
+	URL      string              `json:"url,omitempty"`
+	Status   int                 `json:"status"`
+	Reason   string              `json:"reason,omitempty"`
+	Message  string              `json:"message,omitempty"`
+	Type     string              `json:"type,omitempty"`
+	Param    *string             `json:"param,omitempty"`
+	Code     string              `json:"code,omitempty"`
+	RateInfo *openAIRateInfoJSON `json:"rateInfo,omitempty"`
+}
+
+type openAIRateInfoJSON struct {
+	Model        string `json:"model,omitempty"`
+	ScopeType    string `json:"scopeType,omitempty"`
+	ScopeID      string `json:"scopeId,omitempty"`
+	Metric       string `json:"metric,omitempty"`
+	Limit        int    `json:"limit"`
+	Used         int    `json:"used"`
+	Requested    int    `json:"requested"`
+	RetryAfterMs int64  `json:"retryAfterMs"`
+	DocsURL      string `json:"docsUrl,omitempty"`
+}
+
+func (e *OpenAIError) toJSON() openAIErrorJSON {
+	j := openAIErrorJSON{
+		Method:  e.Method,
+		URL:     e.URL,
+		Status:  e.Status,
+		Reason:  e.Reason,
+		Message: e.Message,
+		Type:    e.Type,
+		Param:   e.Param,
+		Code:    e.Code,
+	}
+	if ri := e.RateInfo; ri != nil {
+		j.RateInfo = &openAIRateInfoJSON{
+			Model:        ri.Model,
+			ScopeType:    ri.ScopeType,
+			ScopeID:      ri.ScopeID,
+			Metric:       ri.Metric,
+			Limit:        ri.Limit,
+			Used:         ri.Used,
+			Requested:    ri.Requested,
+			RetryAfterMs: ri.RetryAfter.Milliseconds(),
+			DocsURL:      ri.DocsURL,
+		}
+	}
+	return j
+}
+
+// MarshalJSON liefert eine stabile, maschinenlesbare Form inkl. RateInfo
+// (Wartezeit in Millisekunden), z.B. für Log-Pipelines.
+func (e *OpenAIError) MarshalJSON() ([]byte, error) {
+	if e == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(e.toJSON())
+}
+
+// ToMap liefert dieselben Felder wie MarshalJSON als Map, etwa für strukturierte Log-Felder.
+// RateInfo steht als verschachtelte Map unter "rateInfo".
+func (e *OpenAIError) ToMap() map[string]any {
+	if e == nil {
+		return nil
+	}
+	j := e.toJSON()
+	m := map[string]any{"status": j.Status}
+	putString := func(key, value string) {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	putString("method", j.Method)
+	putString("url", j.URL)
+	putString("reason", j.Reason)
+	putString("message", j.Message)
+	putString("type", j.Type)
+	putString("code", j.Code)
+	if j.Param != nil {
+		m["param"] = *j.Param
+	}
+	if ri := j.RateInfo; ri != nil {
+		rm := map[string]any{
+			"limit":        ri.Limit,
+			"used":         ri.Used,
+			"requested":    ri.Requested,
+			"retryAfterMs": ri.RetryAfterMs,
+		}
+		for key, value := range map[string]string{
+			"model":     ri.Model,
+			"scopeType": ri.ScopeType,
+			"scopeId":   ri.ScopeID,
+			"metric":    ri.Metric,
+			"docsUrl":   ri.DocsURL,
+		} {
+			if value != "" {
+				rm[key] = value
+			}
+		}
+		m["rateInfo"] = rm
+	}
+	return m
+}
+
+// RetryAfter liefert die verbleibende empfohlene Wartezeit bis zum nächsten Versuch.
+// ok ist false, wenn die Antwort keine Wartezeit enthielt. Bei Fehlern, die der Service
+// zurückgibt, ist die seit der Antwort vergangene Zeit bereits abgezogen.
+func (e *OpenAIError) RetryAfter() (wait time.Duration, ok bool) {
+	if e == nil || e.RateInfo == nil || e.RateInfo.RetryAfter <= 0 {
+		return 0, false
+	}
+	wait = e.RateInfo.RetryAfter
+	if !e.receivedAt.IsZero() {
+		wait -= time.Since(e.receivedAt)
+	}
+	return max(wait, 0), true
+}
+
 // OpenAIRateInfo enthält feingranulare Rate-Limit-Daten,
 // die aus der Message extrahiert werden (falls vorhanden).
 type OpenAIRateInfo struct {
@@ -84,14 +230,94 @@ type OpenAIRateInfo struct {
 	DocsURL    string        // Rate-limit Doku URL
 }
 
+// Die Ausdrücke werden einmal kompiliert, weil die Parser in Retry-Schleifen bei jedem
+// fehlgeschlagenen Request laufen.
+var (
+	jsonHeadRe         = regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE)\s+"([^"]+)"\s*:\s*(\d{3})\b([^{\n]*)`)
+	plainHeadRe        = regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE)\s+(\S+):\s+(\d{3})\b(.*?)\s+-\s+(.*)$`)
+	truncatedMessageRe = regexp.MustCompile(`"message"\s*:\s*"((?:[^"\\]|\\.)*)`)
+	rateInfoRe         = regexp.MustCompile(
+		`Rate limit reached for ([\w\-.]+) in (organization|project) ([\w-]+) on ([^:]+): Limit (\d+), Used (\d+), Requested (\d+)\. Please try again in ((?:[0-9.]+(?:ms|h|m|s))+)\. Visit (\S+)`,
+	)
+)
+
+var (
+	errUnrecognizedHeader = errors.New("unrecognized header format")
+	errUnrecognizedFormat = errors.New("unrecognized error format")
+)
+
+// statusReason liefert den Status-Text aus dem Header oder, falls er fehlt,
+// den Standardtext zum Statuscode.
+func statusReason(status int, reason string) string {
+	if reason = strings.TrimSpace(reason); reason != "" {
+		return reason
+	}
+	return http.StatusText(status)
+}
+
+// splitReason trennt den Status-Text vom Rest der Zeile, etwa bei einem Body ohne JSON.
+func splitReason(status int, rest string) (reason, message string) {
+	rest = strings.TrimSpace(rest)
+	if text := http.StatusText(status); text != "" && strings.HasPrefix(rest, text) {
+		return text, strings.TrimSpace(rest[len(text):])
+	}
+	reason, message, _ = strings.Cut(rest, "\n")
+	return statusReason(status, reason), strings.TrimSpace(message)
+}
+
+// truncatedMessage liefert aus einem abgeschnittenen JSON-Body den lesbaren Teil der
+// Message; ohne erkennbares Feld den Body selbst.
+func truncatedMessage(body string) string {
+	if m := truncatedMessageRe.FindStringSubmatch(body); m != nil {
+		if msg, err := strconv.Unquote(`"` + m[1] + `"`); err == nil {
+			return msg
+		}
+		return m[1]
+	}
+	return strings.TrimSpace(body)
+}
+
+var escapedWhitespace = strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+
+// parseRateInfo zieht die Rate-Limit-Details aus der Message; roundRetry rundet die Wartezeit
+// auf ganze Sekunden, kürzere Wartezeiten auf eine Sekunde. Die API schreibt die Wartezeit
+// als Go-Dauer ("3.482s", "120ms", "6m0s"). Ohne passenden Text liefert die Funktion nil.
+func parseRateInfo(msg string, roundRetry bool) *OpenAIRateInfo {
+	// Schneller Ausstieg, damit der Regex nur bei Kandidaten läuft
+	if !strings.Contains(msg, "Rate limit reached") {
+		return nil
+	}
+	rm := rateInfoRe.FindStringSubmatch(msg)
+	if len(rm) != 10 {
+		return nil
+	}
+	limit, _ := strconv.Atoi(rm[5])
+	used, _ := strconv.Atoi(rm[6])
+	req, _ := strconv.Atoi(rm[7])
+	retry, _ := time.ParseDuration(rm[8])
+	if roundRetry && retry > 0 {
+		retry = max(retry.Round(time.Second), time.Second)
+	}
+	return &OpenAIRateInfo{
+		Model:      rm[1],
+		ScopeType:  rm[2],
+		ScopeID:    rm[3],
+		Metric:     strings.TrimSpace(rm[4]),
+		Limit:      limit,
+		Used:       used,
+		Requested:  req,
+		RetryAfter: retry,
+		DocsURL:    rm[9],
+	}
+}
+
 // ParseOpenAIError parst Fehlermeldungen wie:
 // POST "https://api.openai.com/v1/chat/completions": 429 Too Many Requests { "message": "...", "type": "...", "param": null, "code": "..." }
 func ParseOpenAIJsonError(raw string) (*OpenAIError, error) {
 	raw = strings.TrimSpace(raw)
 
 	// 1) Kopf extrahieren
-	headRe := regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE)\s+"([^"]+)"\s*:\s*(\d{3})\s+([A-Za-z ]+)\s+`)
-	m := headRe.FindStringSubmatch(raw)
+	m := jsonHeadRe.FindStringSubmatch(raw)
 	e := &OpenAIError{}
 	if len(m) == 5 {
 		e.Method = m[1]
@@ -99,20 +325,23 @@ func ParseOpenAIJsonError(raw string) (*OpenAIError, error) {
 		if s, err := strconv.Atoi(m[3]); err == nil {
 			e.Status = s
 		}
-		e.Reason = strings.TrimSpace(m[4])
+		e.Reason = statusReason(e.Status, m[4])
 	} else {
-		return nil, errors.New("unrecognized header format")
+		return nil, errUnrecognizedHeader
 	}
 
 	// 2) JSON-Body finden (ab erster '{')
 	i := strings.Index(raw, "{")
 	if i == -1 {
-		return e, nil // kein JSON-Body – geben wir nur Header zurück
+		// kein JSON-Body, z.B. die HTML-Seite eines Gateways: Text als Message durchreichen
+		e.Reason, e.Message = splitReason(e.Status, raw[len(m[0])-len(m[4]):])
+		return e, nil
 	}
 	jsonPart := strings.TrimSpace(raw[i:])
 	// evtl. escaped \n/\t in echte Whitespace wandeln
-	jsonPart = strings.ReplaceAll(jsonPart, `\n`, "\n")
-	jsonPart = strings.ReplaceAll(jsonPart, `\t`, "\t")
+	if strings.Contains(jsonPart, `\`) {
+		jsonPart = escapedWhitespace.Replace(jsonPart)
+	}
 
 	// 3) Body unmarshalen – unterstützt beide Varianten:
 	//    a) {"error": {...}}
@@ -133,12 +362,11 @@ func ParseOpenAIJsonError(raw string) (*OpenAIError, error) {
 		// ggf. abgeschnittene } tolerieren
 		if last := strings.LastIndex(jsonPart, "}"); last > 0 {
 			if err2 := json.Unmarshal([]byte(jsonPart[:last+1]), &shell); err2 != nil {
-				// Body als Text durchreichen
-				e.Message = strings.TrimSpace(jsonPart)
+				e.Message = truncatedMessage(jsonPart)
 				return e, nil
 			}
 		} else {
-			e.Message = strings.TrimSpace(jsonPart)
+			e.Message = truncatedMessage(jsonPart)
 			return e, nil
 		}
 	}
@@ -156,28 +384,7 @@ func ParseOpenAIJsonError(raw string) (*OpenAIError, error) {
 	}
 
 	// 4) Rate-Limit-Details aus der Message ziehen
-	rateRe := regexp.MustCompile(
-		`Rate limit reached for ([\w\-.]+) in (organization|project) ([\w-]+) on ([^:]+): Limit (\d+), Used (\d+), Requested (\d+)\. Please try again in ([0-9.]+)s\. Visit (\S+)`,
-	)
-	rm := rateRe.FindStringSubmatch(e.Message)
-	if len(rm) == 10 {
-		limit, _ := strconv.Atoi(rm[5])
-		used, _ := strconv.Atoi(rm[6])
-		req, _ := strconv.Atoi(rm[7])
-		sec, _ := strconv.ParseFloat(rm[8], 64)
-
-		e.RateInfo = &OpenAIRateInfo{
-			Model:      rm[1],
-			ScopeType:  rm[2],
-			ScopeID:    rm[3],
-			Metric:     strings.TrimSpace(rm[4]),
-			Limit:      limit,
-			Used:       used,
-			Requested:  req,
-			RetryAfter: time.Duration(sec * float64(time.Second)),
-			DocsURL:    rm[9],
-		}
-	}
+	e.RateInfo = parseRateInfo(e.Message, false)
 
 	// Sinnvoller Default-Code bei 429+Rate-Limit
 	if e.Code == "" && e.Status == 429 && strings.Contains(strings.ToLower(e.Message), "rate limit") {
@@ -192,10 +399,9 @@ func ParseOpenAIPlainError(raw string) (*OpenAIError, error) {
 	raw = strings.TrimSpace(raw)
 
 	// Kopf: METHOD URL: STATUS REASON - MESSAGE
-	headRe := regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE)\s+(\S+):\s+(\d{3})\s+([A-Za-z ]+)\s+-\s+(.*)$`)
-	m := headRe.FindStringSubmatch(raw)
+	m := plainHeadRe.FindStringSubmatch(raw)
 	if len(m) != 6 {
-		return nil, fmt.Errorf("unrecognized error format")
+		return nil, errUnrecognizedFormat
 	}
 
 	status, _ := strconv.Atoi(m[3])
@@ -203,36 +409,13 @@ func ParseOpenAIPlainError(raw string) (*OpenAIError, error) {
 		Method:  m[1],
 		URL:     m[2],
 		Status:  status,
-		Reason:  strings.TrimSpace(m[4]),
+		Reason:  statusReason(status, m[4]),
 		Message: strings.TrimSpace(m[5]),
 	}
 
 	// Rate-Limit-Details aus der Message ziehen
-	rateRe := regexp.MustCompile(
-		`Rate limit reached for ([\w\-.]+) in (organization|project) ([\w-]+) on ([^:]+): Limit (\d+), Used (\d+), Requested (\d+)\. Please try again in ([0-9.]+)s\. Visit (\S+)`,
-	)
-	rm := rateRe.FindStringSubmatch(e.Message)
-	if len(rm) == 10 {
-		limit, _ := strconv.Atoi(rm[5])
-		used, _ := strconv.Atoi(rm[6])
-		req, _ := strconv.Atoi(rm[7])
-		sec, _ := strconv.ParseFloat(rm[8], 64)
-		if sec > 0 {
-			sec = math.Round(sec)
-		}
-
-		e.RateInfo = &OpenAIRateInfo{
-			Model:      rm[1],
-			ScopeType:  rm[2],
-			ScopeID:    rm[3],
-			Metric:     strings.TrimSpace(rm[4]),
-			Limit:      limit,
-			Used:       used,
-			Requested:  req,
-			RetryAfter: time.Duration(sec * float64(time.Second)),
-			DocsURL:    rm[9],
-		}
-
+	e.RateInfo = parseRateInfo(e.Message, true)
+	if e.RateInfo != nil {
 		// Type heuristisch aus Metric ableiten
 		metricLower := strings.ToLower(e.RateInfo.Metric)
 		switch {
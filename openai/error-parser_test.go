@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -98,3 +99,170 @@ func TestClassifier_IsServerError(t *testing.T) {
 		t.Errorf("classifier mismatch (server): server=%v auth=%v rate=%v", e.IsServerError(), e.IsAuth(), e.IsRateLimit())
 	}
 }
+
+const (
+	benchJsonError = `POST "https://api.openai.com/v1/chat/completions": 429 Too Many Requests {
+        "message": "Rate limit reached for gpt-4.1 in organization org-YvWUPqaYaDO3IEven3giqHwj on tokens per min (TPM): Limit 30000, Used 30000, Requested 1741. Please try again in 3.482s. Visit https://platform.openai.com/account/rate-limits to learn more.",
+        "type": "tokens",
+        "param": null,
+        "code": "rate_limit_exceeded"
+    }`
+	benchPlainError = `POST https://api.openai.com/v1/chat/completions: 429 Too Many Requests - Rate limit reached for gpt-4.1 in organization org-YvWUPqaYaDO3IEven3giqHwj on tokens per min (TPM): Limit 30000, Used 30000, Requested 1895. Please try again in 3.789s. Visit https://platform.openai.com/account/rate-limits to learn more.`
+)
+
+func BenchmarkParseOpenAIJsonError(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ParseOpenAIJsonError(benchJsonError); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseOpenAIPlainError(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ParseOpenAIPlainError(benchPlainError); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseOpenAIJsonError_Unrecognized(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = ParseOpenAIJsonError(benchPlainError)
+	}
+}
+
+func fuzzSeeds(f *testing.F) {
+	f.Add(benchJsonError)
+	f.Add(benchPlainError)
+	f.Add(`POST "https://api.openai.com/v1/chat/completions": 500 Internal Server Error {"error": {"message": "boom", "code": null}}`)
+	f.Add(`POST "https://x": 400 Bad Request {"message": "unterminated`)
+	f.Add(`GET https://x: 401 Unauthorized - `)
+	f.Add("")
+	f.Add("{}")
+	for _, file := range errorCorpus(f) {
+		f.Add(readCorpusError(f, file))
+	}
+}
+
+// checkParseResult stellt sicher, dass genau eins von Ergebnis und Fehler gesetzt ist.
+func checkParseResult(t *testing.T, e *OpenAIError, err error) {
+	if (e == nil) == (err == nil) {
+		t.Fatalf("expected either result or error, got %+v / %v", e, err)
+	}
+	if e != nil {
+		_ = e.Error()
+		_ = e.IsRateLimit()
+		_ = e.IsAuth()
+		_ = e.IsServerError()
+	}
+}
+
+func FuzzParseOpenAIJsonError(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, raw string) {
+		e, err := ParseOpenAIJsonError(raw)
+		checkParseResult(t, e, err)
+	})
+}
+
+func FuzzParseOpenAIPlainError(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, raw string) {
+		e, err := ParseOpenAIPlainError(raw)
+		checkParseResult(t, e, err)
+	})
+}
+
+func TestParseOpenAIError_StatusReason(t *testing.T) {
+	cases := []struct {
+		raw    string
+		plain  bool
+		reason string
+	}{
+		{`POST "https://x/v1/files": 418 I'm a teapot {"message": "no"}`, false, "I'm a teapot"},
+		{`POST "https://x/v1/files": 203 Non-Authoritative Information {"message": "no"}`, false, "Non-Authoritative Information"},
+		{`POST "https://x/v1/files": 503 {"message": "no"}`, false, "Service Unavailable"},
+		{`POST https://x/v1/files: 418 I'm a teapot - no`, true, "I'm a teapot"},
+		{`POST https://x/v1/files: 203 Non-Authoritative Information - no - really`, true, "Non-Authoritative Information"},
+		{`POST https://x/v1/files: 502 - no`, true, "Bad Gateway"},
+	}
+	for _, c := range cases {
+		var (
+			e   *OpenAIError
+			err error
+		)
+		if c.plain {
+			e, err = ParseOpenAIPlainError(c.raw)
+		} else {
+			e, err = ParseOpenAIJsonError(c.raw)
+		}
+		require.NoError(t, err, c.raw)
+		require.Equal(t, c.reason, e.Reason, c.raw)
+		require.Equal(t, "no", e.Message[:2], c.raw)
+	}
+}
+
+func TestOpenAIError_MarshalJSON(t *testing.T) {
+	e, err := ParseOpenAIJsonError(benchJsonError)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"method": "POST",
+		"url": "https://api.openai.com/v1/chat/completions",
+		"status": 429,
+		"reason": "Too Many Requests",
+		"message": "Rate limit reached for gpt-4.1 in organization org-YvWUPqaYaDO3IEven3giqHwj on tokens per min (TPM): Limit 30000, Used 30000, Requested 1741. Please try again in 3.482s. Visit https://platform.openai.com/account/rate-limits to learn more.",
+		"type": "tokens",
+		"code": "rate_limit_exceeded",
+		"rateInfo": {
+			"model": "gpt-4.1",
+			"scopeType": "organization",
+			"scopeId": "org-YvWUPqaYaDO3IEven3giqHwj",
+			"metric": "tokens per min (TPM)",
+			"limit": 30000,
+			"used": 30000,
+			"requested": 1741,
+			"retryAfterMs": 3482,
+			"docsUrl": "https://platform.openai.com/account/rate-limits"
+		}
+	}`, string(data))
+
+	// ToMap entspricht der JSON-Form
+	fromMap, err := json.Marshal(e.ToMap())
+	require.NoError(t, err)
+	require.JSONEq(t, string(data), string(fromMap))
+
+	var nilErr *OpenAIError
+	data, err = json.Marshal(nilErr)
+	require.NoError(t, err)
+	require.Equal(t, "null", string(data))
+}
+
+func TestOpenAIError_RetryAfter(t *testing.T) {
+	e, err := ParseOpenAIJsonError(benchJsonError)
+	require.NoError(t, err)
+
+	wait, ok := e.RetryAfter()
+	require.True(t, ok)
+	require.Equal(t, 3482*time.Millisecond, wait)
+
+	// vergangene Zeit seit der Antwort wird abgezogen
+	e.receivedAt = time.Now().Add(-3 * time.Second)
+	wait, ok = e.RetryAfter()
+	require.True(t, ok)
+	require.LessOrEqual(t, wait, 482*time.Millisecond)
+
+	e.receivedAt = time.Now().Add(-time.Minute)
+	wait, ok = e.RetryAfter()
+	require.True(t, ok)
+	require.Zero(t, wait)
+
+	_, ok = (&OpenAIError{Status: 500}).RetryAfter()
+	require.False(t, ok)
+}
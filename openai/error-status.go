@@ -0,0 +1,87 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// HTTPStatusFor bildet einen Fehler der Bibliothek auf den Status ab, den ein einbettender
+// HTTP-Dienst an seine Aufrufer weitergeben sollte. Fehler von OpenAI selbst (außer Rate-Limits)
+// sind aus Sicht des Aufrufers Fehler eines Upstreams und werden zu 502.
+func HTTPStatusFor(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var oe *OpenAIError
+	switch {
+	case errors.As(err, &oe):
+		if oe.IsRateLimit() {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusBadGateway
+	case errors.Is(err, ErrContentFiltered), errors.Is(err, ErrRefused), errors.Is(err, ErrMaxLength),
+		errors.Is(err, ErrModerationBlocked):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrBadInput):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrBudgetExceeded):
+		return http.StatusPaymentRequired
+	case errors.Is(err, ErrLocked):
+		return http.StatusConflict
+	case IsTimeout(err):
+		return http.StatusGatewayTimeout
+	case IsNetwork(err):
+		return http.StatusBadGateway
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Problem ist ein Fehlerobjekt nach RFC 9457 (application/problem+json).
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// NewProblem erzeugt das Problem-Objekt zu err.
+func NewProblem(err error) Problem {
+	status := HTTPStatusFor(err)
+	p := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if err != nil {
+		p.Detail = err.Error()
+	}
+	var oe *OpenAIError
+	if errors.As(err, &oe) {
+		p.Detail = oe.Message
+		p.Code = oe.Code
+	}
+	return p
+}
+
+// WriteProblem schreibt err als application/problem+json. Bei Rate-Limits wird die
+// empfohlene Wartezeit als Retry-After-Header (in ganzen Sekunden) mitgeschickt.
+func WriteProblem(w http.ResponseWriter, err error) {
+	p := NewProblem(err)
+	var oe *OpenAIError
+	if p.Status == http.StatusTooManyRequests && errors.As(err, &oe) {
+		if wait, ok := oe.RetryAfter(); ok && wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,72 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPStatusFor(t *testing.T) {
+	rateLimit, err := ParseOpenAIJsonError(benchJsonError)
+	require.NoError(t, err)
+
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{nil, http.StatusOK},
+		{rateLimit, http.StatusTooManyRequests},
+		{&OpenAIError{Status: 401, Code: "invalid_api_key"}, http.StatusBadGateway},
+		{&OpenAIError{Status: 500}, http.StatusBadGateway},
+		{fmt.Errorf("convert: %w", ErrContentFiltered), http.StatusUnprocessableEntity},
+		{ErrBudgetExceeded, http.StatusPaymentRequired},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.status, HTTPStatusFor(c.err), "%v", c.err)
+	}
+}
+
+func TestWriteProblem_RateLimit(t *testing.T) {
+	rateLimit, err := ParseOpenAIJsonError(benchJsonError)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, rateLimit)
+
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	require.Equal(t, "4", rec.Header().Get("Retry-After"))
+
+	var p Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	require.Equal(t, http.StatusTooManyRequests, p.Status)
+	require.Equal(t, "Too Many Requests", p.Title)
+	require.Equal(t, "rate_limit_exceeded", p.Code)
+}
+
+func TestGenerateContent_TypedAPIError(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error", "param": null, "code": "invalid_api_key"}}`))
+	})
+
+	start := time.Now()
+	_, err := ai.GenerateContent("system")
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+
+	var oe *OpenAIError
+	require.ErrorAs(t, err, &oe)
+	require.True(t, oe.IsAuth())
+	require.Equal(t, http.StatusBadGateway, HTTPStatusFor(err))
+}
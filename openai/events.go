@@ -0,0 +1,123 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// EventType bezeichnet die Art eines Ereignisses.
+type EventType string
+
+const (
+	EventJobStarted  EventType = "job.started"
+	EventJobFinished EventType = "job.finished"
+	EventJobFailed   EventType = "job.failed"
+	EventJobSkipped  EventType = "job.skipped" // z.B. weil eine andere Instanz den Job hält
+
+	// EventCompletion meldet jede Antwort der API mit Modell, Finish-Reason, Verweigerung
+	// und gefilterten Kategorien, z.B. um Content-Policy-Probleme je Prompt-Version zu verfolgen.
+	EventCompletion EventType = "completion.finished"
+)
+
+// Event wird an die registrierten Hooks gemeldet, z.B. für Benachrichtigungen oder Metriken.
+type Event struct {
+	Type   EventType      `json:"type"`
+	Time   time.Time      `json:"time"`
+	Job    string         `json:"job,omitempty"`
+	Err    error          `json:"-"`
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// EventHook empfängt Ereignisse. Hooks werden synchron aufgerufen und sollten nicht blockieren.
+type EventHook func(Event)
+
+// emitEvent ruft alle Hooks auf; ein fehlerhafter Hook bringt den Aufrufer nicht zu Fall.
+func emitEvent(hooks []EventHook, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("event hook panicked on %s: %v", ev.Type, r)
+				}
+			}()
+			hook(ev)
+		}()
+	}
+}
+
+// LogEventHook protokolliert Ereignisse über das Standard-Log.
+func LogEventHook(ev Event) {
+	msg := fmt.Sprintf("%s %s", ev.Type, ev.Job)
+	if len(ev.Fields) > 0 {
+		keys := slices.Sorted(maps.Keys(ev.Fields))
+		for _, key := range keys {
+			msg += fmt.Sprintf(" %s=%v", key, ev.Fields[key])
+		}
+	}
+	if ev.Err != nil {
+		log.Warn("%s: %v", msg, ev.Err)
+		return
+	}
+	log.Info("%s", msg)
+}
+
+// completionEvent beschreibt eine Antwort der API.
+func (ai *AiCommunicationService) completionEvent(systemMessage string, cfg requestConfig, completion *openai.ChatCompletion) Event {
+	choice := completion.Choices[0]
+	fields := map[string]any{
+		"model":            completion.Model,
+		"finishReason":     choice.FinishReason,
+		"promptVersion":    ai.promptVersion(systemMessage, cfg),
+		"promptTokens":     completion.Usage.PromptTokens,
+		"completionTokens": completion.Usage.CompletionTokens,
+		"refused":          choice.Message.Refusal != "",
+	}
+	if choice.Message.Refusal != "" {
+		fields["refusal"] = choice.Message.Refusal
+	}
+	if categories := filteredCategories(choice); len(categories) > 0 {
+		fields["filteredCategories"] = categories
+	}
+	for key, value := range map[string]string{
+		"task":         cfg.task,
+		"documentType": cfg.documentType,
+		"variant":      cfg.variant,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return Event{Type: EventCompletion, Fields: fields}
+}
+
+// filteredCategories liefert die Kategorien, die laut content_filter_results gefiltert
+// wurden. OpenAI selbst liefert das Feld nicht, Azure OpenAI schon.
+func filteredCategories(choice openai.ChatCompletionChoice) []string {
+	field, ok := choice.JSON.ExtraFields["content_filter_results"]
+	if !ok {
+		return nil
+	}
+	var results map[string]struct {
+		Filtered bool `json:"filtered"`
+	}
+	if err := json.Unmarshal([]byte(field.Raw()), &results); err != nil {
+		return nil
+	}
+	var categories []string
+	for category, result := range results {
+		if result.Filtered {
+			categories = append(categories, category)
+		}
+	}
+	slices.Sort(categories)
+	return categories
+}
@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CostCurrency ist die Währung, in der die OpenAI-Preise und damit alle Kosten erfasst werden.
+const CostCurrency = "USD"
+
+// ExchangeRateProvider liefert den Kurs, mit dem ein Betrag in from nach to umgerechnet wird.
+type ExchangeRateProvider interface {
+	Rate(from, to string) (float64, error)
+}
+
+// StaticExchangeRates enthält feste Kurse als Einheiten der Währung pro 1 USD,
+// z.B. StaticExchangeRates{"EUR": 0.92}.
+type StaticExchangeRates map[string]float64
+
+func (r StaticExchangeRates) Rate(from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	fromRate, err := r.perUSD(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := r.perUSD(to)
+	if err != nil {
+		return 0, err
+	}
+	return toRate / fromRate, nil
+}
+
+func (r StaticExchangeRates) perUSD(currency string) (float64, error) {
+	if currency == CostCurrency {
+		return 1, nil
+	}
+	rate, ok := r[currency]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return rate, nil
+}
+
+// TotalCostsIn liefert die Gesamtkosten umgerechnet in die angegebene Währung.
+func (ai *AiCommunicationService) TotalCostsIn(currency string) (float64, error) {
+	total := ai.TotalCosts()
+	if strings.EqualFold(currency, CostCurrency) {
+		return total, nil
+	}
+	if ai.ExchangeRates == nil {
+		return 0, fmt.Errorf("no exchange rate provider configured for %s", currency)
+	}
+	rate, err := ai.ExchangeRates.Rate(CostCurrency, currency)
+	if err != nil {
+		return 0, err
+	}
+	return total * rate, nil
+}
@@ -0,0 +1,29 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTotalCostsIn(t *testing.T) {
+	ai := AiCommunicationService{
+		Costs:         []chatCosts{{TotalCost: 1.5}, {TotalCost: 0.5}},
+		ExchangeRates: StaticExchangeRates{"EUR": 0.9, "CHF": 0.8},
+	}
+
+	usd, err := ai.TotalCostsIn("USD")
+	require.NoError(t, err)
+	require.InDelta(t, 2.0, usd, 1e-9)
+
+	eur, err := ai.TotalCostsIn("eur")
+	require.NoError(t, err)
+	require.InDelta(t, 1.8, eur, 1e-9)
+
+	_, err = ai.TotalCostsIn("GBP")
+	require.Error(t, err)
+
+	rate, err := StaticExchangeRates{"EUR": 0.9, "CHF": 0.8}.Rate("EUR", "CHF")
+	require.NoError(t, err)
+	require.InDelta(t, 0.8/0.9, rate, 1e-9)
+}
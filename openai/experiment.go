@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+)
+
+// Variant ist eine Variante eines Experiments. Die Route legt Prompt und Modell fest.
+type Variant struct {
+	ID     string
+	Weight float64 // Anteil am Traffic in Prozent (wird auf die Summe aller Varianten normiert)
+	Route
+}
+
+// VariantStats fasst Kosten und Qualität einer Variante zusammen.
+type VariantStats struct {
+	Requests  int
+	Failures  int
+	TotalCost float64 // USD
+	ScoreSum  float64
+	Scores    int
+}
+
+// AvgCost liefert die durchschnittlichen Kosten je erfolgreichem Request.
+func (s VariantStats) AvgCost() float64 {
+	if ok := s.Requests - s.Failures; ok > 0 {
+		return s.TotalCost / float64(ok)
+	}
+	return 0
+}
+
+// AvgScore liefert die durchschnittliche Bewertung aus RecordScore.
+func (s VariantStats) AvgScore() float64 {
+	if s.Scores == 0 {
+		return 0
+	}
+	return s.ScoreSum / float64(s.Scores)
+}
+
+// Experiment verteilt Aufrufe nach Gewicht auf Prompt-/Modell-Varianten und sammelt je
+// Variante Kosten und Fehler. Qualitätswerte, z.B. aus einer Evaluierung, kommen über
+// RecordScore hinzu, damit sich Qualität und Kosten der Varianten vergleichen lassen.
+type Experiment struct {
+	Name     string
+	Variants []Variant
+
+	mu    sync.Mutex
+	stats map[string]*VariantStats
+}
+
+func NewExperiment(name string, variants ...Variant) *Experiment {
+	return &Experiment{Name: name, Variants: variants}
+}
+
+// WithExperiment ordnet den Aufruf einer Variante des Experiments zu. Mit gleichem key
+// (z.B. Dateiname oder Kunden-ID) landet ein Aufruf immer in derselben Variante, ohne key
+// wird zufällig verteilt. Weitere Optionen des Aufrufs haben Vorrang vor der Variante.
+func WithExperiment(exp *Experiment, key string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.experiment = exp
+		cfg.experimentKey = key
+	}
+}
+
+// Assign wählt die Variante für key.
+func (e *Experiment) Assign(key string) Variant {
+	total := 0.0
+	for _, v := range e.Variants {
+		total += max(v.Weight, 0)
+	}
+	if total == 0 {
+		return Variant{}
+	}
+
+	var p float64
+	if key == "" {
+		p = rand.Float64()
+	} else {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(e.Name + "\x00" + key))
+		p = float64(h.Sum64()>>11) / (1 << 53)
+	}
+
+	threshold := p * total
+	for _, v := range e.Variants {
+		threshold -= max(v.Weight, 0)
+		if threshold < 0 {
+			return v
+		}
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// RecordScore ergänzt eine Qualitätsbewertung für die Variante.
+func (e *Experiment) RecordScore(variantID string, score float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.variantStats(variantID)
+	s.ScoreSum += score
+	s.Scores++
+}
+
+// Stats liefert eine Kopie der Statistik je Varianten-ID.
+func (e *Experiment) Stats() map[string]VariantStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	result := make(map[string]VariantStats, len(e.stats))
+	for id, s := range e.stats {
+		result[id] = *s
+	}
+	return result
+}
+
+// observe erfasst einen Request der Variante.
+func (e *Experiment) observe(variantID string, cost float64, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.variantStats(variantID)
+	s.Requests++
+	s.TotalCost += cost
+	if err != nil {
+		s.Failures++
+	}
+}
+
+func (e *Experiment) variantStats(variantID string) *VariantStats {
+	if e.stats == nil {
+		e.stats = map[string]*VariantStats{}
+	}
+	s, ok := e.stats[variantID]
+	if !ok {
+		s = &VariantStats{}
+		e.stats[variantID] = s
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package openai
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperiment_Assign(t *testing.T) {
+	exp := NewExperiment("prompt-v2",
+		Variant{ID: "control", Weight: 80},
+		Variant{ID: "candidate", Weight: 20},
+	)
+
+	require.Equal(t, exp.Assign("invoice-1.pdf").ID, exp.Assign("invoice-1.pdf").ID)
+
+	counts := map[string]int{}
+	for i := range 10000 {
+		counts[exp.Assign(fmt.Sprintf("doc-%d", i)).ID]++
+	}
+	require.InDelta(t, 8000, counts["control"], 300)
+	require.InDelta(t, 2000, counts["candidate"], 300)
+
+	require.Empty(t, NewExperiment("empty").Assign("x").ID)
+}
+
+func TestGenerateContent_WithExperiment(t *testing.T) {
+	ai, models := newModelTestService(t, map[string]string{
+		string(openai.ChatModelGPT4_1Mini): `{"variant": "candidate"}`,
+	})
+	exp := NewExperiment("model-switch",
+		Variant{ID: "candidate", Weight: 100, Route: Route{Model: openai.ChatModelGPT4_1Mini}},
+	)
+
+	content, err := ai.GenerateContent("system", WithExperiment(exp, "customer-1"))
+	require.NoError(t, err)
+	require.Equal(t, `{"variant": "candidate"}`, content)
+	require.Equal(t, []string{string(openai.ChatModelGPT4_1Mini)}, *models)
+	require.Equal(t, "candidate", ai.Costs[0].Variant)
+
+	exp.RecordScore("candidate", 0.9)
+	stats := exp.Stats()["candidate"]
+	require.Equal(t, 1, stats.Requests)
+	require.Zero(t, stats.Failures)
+	require.InDelta(t, ai.TotalCosts(), stats.AvgCost(), 1e-9)
+	require.Equal(t, 0.9, stats.AvgScore())
+}
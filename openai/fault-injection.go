@@ -0,0 +1,171 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// FaultInjector stört Requests an die API zufällig mit 429, 500, Timeouts und langsamen
+// Antworten. Damit lässt sich in Tests und auf Staging prüfen, wie sich Wiederholungen,
+// Rate-Limiter und Budgets unter anhaltenden Fehlern verhalten. Nicht für Produktion.
+type FaultInjector struct {
+	// Wahrscheinlichkeiten je Request (0..1); sie werden in dieser Reihenfolge geprüft.
+	RateLimit   float64 // 429 mit Retry-After
+	ServerError float64 // 500
+	Timeout     float64 // keine Antwort bis TimeoutAfter, dann Netzwerk-Timeout
+	Slow        float64 // Antwort erst nach SlowDelay, danach normal
+
+	RetryAfter   time.Duration // für 429, Default: 1s
+	TimeoutAfter time.Duration // Default: 5s; endet früher mit dem Kontext des Requests
+	SlowDelay    time.Duration // Default: 2s
+
+	// Rand liefert Zufallszahlen in [0, 1); Default: math/rand/v2. Für reproduzierbare Tests setzen.
+	Rand func() float64
+
+	mu    sync.Mutex
+	stats FaultStats
+}
+
+// FaultStats zählt die Requests und die eingestreuten Fehler.
+type FaultStats struct {
+	Requests     int
+	RateLimited  int
+	ServerErrors int
+	Timeouts     int
+	Slow         int
+}
+
+// ParseFaultInjector liest eine Angabe wie "429=0.1,500=0.05,timeout=0.01,slow=0.2", z.B.
+// aus der Konfiguration einer Staging-Umgebung.
+func ParseFaultInjector(spec string) (*FaultInjector, error) {
+	f := &FaultInjector{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		p, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid fault %q: expected <kind>=<probability between 0 and 1>", item)
+		}
+		switch strings.TrimSpace(name) {
+		case "429":
+			f.RateLimit = p
+		case "500":
+			f.ServerError = p
+		case "timeout":
+			f.Timeout = p
+		case "slow":
+			f.Slow = p
+		default:
+			return nil, fmt.Errorf("unknown fault %q: expected 429, 500, timeout or slow", name)
+		}
+	}
+	return f, nil
+}
+
+// Stats liefert die bisherigen Zählerstände.
+func (f *FaultInjector) Stats() FaultStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// middleware hängt sich in den openai.Client, siehe AiCommunicationService.Faults.
+func (f *FaultInjector) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	f.mu.Lock()
+	f.stats.Requests++
+	r := f.random()
+	fault := ""
+	for _, c := range []struct {
+		name string
+		p    float64
+		n    *int
+	}{
+		{"429", f.RateLimit, &f.stats.RateLimited},
+		{"500", f.ServerError, &f.stats.ServerErrors},
+		{"timeout", f.Timeout, &f.stats.Timeouts},
+		{"slow", f.Slow, &f.stats.Slow},
+	} {
+		if r < c.p {
+			fault = c.name
+			*c.n++
+			break
+		}
+		r -= c.p
+	}
+	f.mu.Unlock()
+
+	switch fault {
+	case "429":
+		retryAfter := orDefault(f.RetryAfter, time.Second)
+		resp := faultResponse(req, http.StatusTooManyRequests, "rate_limit_exceeded", fmt.Sprintf(
+			"Rate limit reached for requests (injected fault). Please try again in %gs.", retryAfter.Seconds()))
+		resp.Header.Set("Retry-After-Ms", strconv.FormatInt(retryAfter.Milliseconds(), 10))
+		return resp, nil
+	case "500":
+		return faultResponse(req, http.StatusInternalServerError, "server_error", "The server had an error while processing your request (injected fault)."), nil
+	case "timeout":
+		select {
+		case <-time.After(orDefault(f.TimeoutAfter, 5*time.Second)):
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: faultTimeoutError{}}
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	case "slow":
+		select {
+		case <-time.After(orDefault(f.SlowDelay, 2*time.Second)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return next(req)
+}
+
+// random liefert die nächste Zufallszahl; f.mu muss gehalten werden.
+func (f *FaultInjector) random() float64 {
+	if f.Rand != nil {
+		return f.Rand()
+	}
+	return rand.Float64()
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// faultResponse baut eine Fehlerantwort im Format der API.
+func faultResponse(req *http.Request, status int, code, message string) *http.Response {
+	body := fmt.Sprintf(`{"error": {"message": %q, "type": "injected_fault", "param": null, "code": %q}}`, message, code)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type faultTimeoutError struct{}
+
+func (faultTimeoutError) Error() string   { return "i/o timeout (injected fault)" }
+func (faultTimeoutError) Timeout() bool   { return true }
+func (faultTimeoutError) Temporary() bool { return true }
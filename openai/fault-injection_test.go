@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFaultInjector(t *testing.T) {
+	f, err := ParseFaultInjector("429=0.1, 500=0.05,timeout=0.01,slow=0.2")
+	require.NoError(t, err)
+	require.Equal(t, 0.1, f.RateLimit)
+	require.Equal(t, 0.05, f.ServerError)
+	require.Equal(t, 0.01, f.Timeout)
+	require.Equal(t, 0.2, f.Slow)
+
+	_, err = ParseFaultInjector("503=0.1")
+	require.ErrorContains(t, err, "unknown fault")
+	_, err = ParseFaultInjector("429=2")
+	require.ErrorContains(t, err, "invalid fault")
+}
+
+func TestFaultInjector_SustainedFailures(t *testing.T) {
+	ai, srv := newMockService(t)
+	ai.MaxAttempts = 20
+	ai.Faults = &FaultInjector{
+		RateLimit: 0.3, ServerError: 0.2, Timeout: 0.1, Slow: 0.1,
+		RetryAfter: 5 * time.Millisecond, TimeoutAfter: 5 * time.Millisecond, SlowDelay: 5 * time.Millisecond,
+		Rand: rand.New(rand.NewPCG(1, 2)).Float64,
+	}
+
+	const calls = 20
+	for range calls {
+		content, err := ai.GenerateContent("system")
+		require.NoError(t, err)
+		require.Equal(t, `{"ok": true}`, content)
+	}
+
+	stats := ai.Faults.Stats()
+	require.Greater(t, stats.RateLimited, 0)
+	require.Greater(t, stats.ServerErrors, 0)
+	require.Greater(t, stats.Timeouts, 0)
+	require.Greater(t, stats.Slow, 0)
+	// gestörte Requests erreichen den Server nicht und kosten nichts
+	require.Equal(t, calls, srv.ChatRequests())
+	require.Equal(t, stats.Requests-stats.RateLimited-stats.ServerErrors-stats.Timeouts, srv.ChatRequests())
+	require.InDelta(t, calls*costOf(100, 20), ai.TotalCosts(), 1e-9)
+}
+
+func TestFaultInjector_GivesUp(t *testing.T) {
+	ai, srv := newMockService(t)
+	ai.MaxAttempts = 3
+	ai.Faults = &FaultInjector{ServerError: 1}
+
+	_, err := ai.GenerateContent("system")
+	var oe *OpenAIError
+	require.ErrorAs(t, err, &oe)
+	require.EqualValues(t, 500, oe.Status)
+	require.Equal(t, 3, ai.Faults.Stats().Requests)
+	require.Zero(t, srv.ChatRequests())
+	require.Zero(t, ai.TotalCosts())
+
+	ai, _ = newMockService(t)
+	ai.MaxAttempts = 1
+	ai.Faults = &FaultInjector{Timeout: 1, TimeoutAfter: 10 * time.Millisecond}
+	_, err = ai.GenerateContent("system")
+	require.True(t, IsTimeout(err), err)
+}
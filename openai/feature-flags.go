@@ -0,0 +1,98 @@
+package openai
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// Feature ist ein experimentelles Verhalten, das erst nach Freischaltung aktiv ist. So
+// lassen sich neue Funktionen schrittweise einführen, ohne bestehende Aufrufer zu ändern.
+type Feature string
+
+const (
+	// FeatureJSONRepair repariert Antworten, die mit { oder [ beginnen, aber kein gültiges
+	// JSON sind (fehlende schließende Klammern, Kommas vor } oder ]), statt sie weiterzugeben.
+	FeatureJSONRepair Feature = "json-repair"
+	// FeatureAutoContinue fordert bei finish_reason "length" die Fortsetzung der Antwort an,
+	// statt ErrMaxLength zu melden, höchstens maxContinuations Mal.
+	FeatureAutoContinue Feature = "auto-continue"
+)
+
+var knownFeatures = []Feature{FeatureJSONRepair, FeatureAutoContinue}
+
+const (
+	maxContinuations = 3
+	continuePrompt   = "Setze deine Antwort genau an der Stelle fort, an der sie abgebrochen ist. Wiederhole nichts und füge nichts hinzu."
+)
+
+// FeaturesEnv nennt die Env-Variable mit kommagetrennten Features, z.B. "json-repair,-auto-continue";
+// ein vorangestelltes "-" schaltet ab. Die Angaben am Service und je Aufruf haben Vorrang.
+const FeaturesEnv = "MYAILIB_FEATURES"
+
+// Features schaltet Features ein (true) oder ausdrücklich ab (false).
+type Features map[Feature]bool
+
+// ParseFeatures liest eine Liste im Format von FeaturesEnv.
+func ParseFeatures(s string) (Features, error) {
+	features := Features{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		on := !strings.HasPrefix(name, "-")
+		f := Feature(strings.TrimPrefix(name, "-"))
+		if !slices.Contains(knownFeatures, f) {
+			return nil, fmt.Errorf("unknown feature %q", f)
+		}
+		features[f] = on
+	}
+	return features, nil
+}
+
+// Enabled meldet, ob f eingeschaltet ist.
+func (f Features) Enabled(feature Feature) bool {
+	return f[feature]
+}
+
+// with liefert eine Kopie mit den Angaben aus other.
+func (f Features) with(other Features) Features {
+	if len(other) == 0 {
+		return f
+	}
+	merged := make(Features, len(f)+len(other))
+	for k, v := range f {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// envFeatures liest FeaturesEnv einmal; unbekannte Namen werden ignoriert, damit eine
+// gemeinsame Konfiguration auch ältere Versionen der Bibliothek nicht stört.
+var envFeatures = sync.OnceValue(func() Features {
+	features := Features{}
+	for _, name := range strings.Split(os.Getenv(FeaturesEnv), ",") {
+		f, err := ParseFeatures(name)
+		if err != nil {
+			log.Warn("%s: %v", FeaturesEnv, err)
+			continue
+		}
+		features = features.with(f)
+	}
+	return features
+})
+
+// WithFeature schaltet ein Feature für diesen Aufruf ein oder ab.
+func WithFeature(feature Feature, on bool) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.features = cfg.features.with(Features{feature: on})
+	}
+}
@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeatures(t *testing.T) {
+	features, err := ParseFeatures(" json-repair, -auto-continue,")
+	require.NoError(t, err)
+	require.Equal(t, Features{FeatureJSONRepair: true, FeatureAutoContinue: false}, features)
+	require.True(t, features.Enabled(FeatureJSONRepair))
+	require.False(t, features.Enabled(FeatureAutoContinue))
+
+	_, err = ParseFeatures("json-repair,responses-api")
+	require.ErrorContains(t, err, "responses-api")
+}
+
+func TestGenerateContent_FeatureAutoContinue(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) == 1 {
+			body := strings.Replace(testChatCompletion, `"{\"ok\": true}"`, `"{\"ok\": "`, 1)
+			_, _ = w.Write([]byte(strings.Replace(body, `"stop"`, `"length"`, 1)))
+			return
+		}
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, `"true}"`, 1)))
+	})
+
+	_, err := ai.GenerateContent("system")
+	require.ErrorIs(t, err, ErrMaxLength, "off by default")
+
+	calls.Store(0)
+	content, err := ai.GenerateContent("system", WithFeature(FeatureAutoContinue, true))
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.EqualValues(t, 2, calls.Load())
+	require.InDelta(t, costOf(200, 40), ai.Costs[len(ai.Costs)-1].TotalCost, 1e-9)
+}
+
+func TestGenerateContent_FeatureJSONRepair(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, `"{\"items\": [1, 2,], \"ok\": true"`, 1)))
+	})
+
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"items": [1, 2,], "ok": true`, content)
+
+	ai.Features = Features{FeatureJSONRepair: true}
+	content, err = ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"items": [1, 2], "ok": true}`, content)
+
+	content, err = ai.GenerateContent("system", WithFeature(FeatureJSONRepair, false))
+	require.NoError(t, err)
+	require.Equal(t, `{"items": [1, 2,], "ok": true`, content)
+}
@@ -0,0 +1,170 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidConfidence meldet eine Antwort, deren Konfidenzangaben nicht zum Ergebnis passen.
+	ErrInvalidConfidence = errors.New("invalid field confidence")
+	// ErrInvalidCitation meldet eine Antwort mit ungültigen Fundstellen.
+	ErrInvalidCitation = errors.New("invalid field citation")
+)
+
+// extractionInstruction wird an die System-Message angehängt, wenn WithFieldConfidence
+// oder WithCitations gesetzt ist.
+func extractionInstruction(cfg requestConfig) string {
+	parts, wants := []string{}, []string{}
+	if cfg.fieldConfidence {
+		parts = append(parts, `"confidence": {"<Feldpfad>": {"score": <0.0 bis 1.0>, "pages": [<Seitennummern ab 1>]}}`)
+		wants = append(wants, "wie sicher du dir bist und auf welchen Seiten des Dokuments es steht")
+	}
+	if cfg.citations {
+		parts = append(parts, `"citations": {"<Feldpfad>": [{"page": <Seitennummer ab 1>, "quote": "<wörtlicher Ausschnitt aus dem Dokument>"}]}`)
+		wants = append(wants, "die Fundstelle mit Seite und wörtlichem Zitat")
+	}
+	return "\n\nGib zu jedem extrahierten Feld " + strings.Join(wants, " sowie ") + " an. " +
+		`Antworte mit einem JSON-Objekt der Form {"data": <Ergebnis>, ` + strings.Join(parts, ", ") + `}. ` +
+		`Feldpfade schreibst du wie "items[0].total".`
+}
+
+// extraction meldet, ob die Antwort die Form von Extraction hat.
+func (cfg requestConfig) extraction() bool {
+	return cfg.fieldConfidence || cfg.citations
+}
+
+// FieldConfidence ist die Einschätzung des Modells zu einem extrahierten Feld.
+type FieldConfidence struct {
+	Score float64 `json:"score"`           // 0.0 bis 1.0
+	Pages []int   `json:"pages,omitempty"` // Seiten des Dokuments, ab 1
+}
+
+// Citation ist eine Fundstelle im Dokument.
+type Citation struct {
+	Page  int    `json:"page"`  // ab 1
+	Quote string `json:"quote"` // wörtlicher Ausschnitt
+}
+
+// maxCitationQuote begrenzt die Länge eines Zitats; längere deuten auf kopierte Seiten hin.
+const maxCitationQuote = 500
+
+// Extraction ist eine mit WithFieldConfidence bzw. WithCitations angeforderte Antwort:
+// das eigentliche Ergebnis und je Feldpfad (z.B. "items[0].total") Konfidenz und Fundstellen.
+type Extraction struct {
+	Data       json.RawMessage            `json:"data"`
+	Confidence map[string]FieldConfidence `json:"confidence,omitempty"`
+	Citations  map[string][]Citation      `json:"citations,omitempty"`
+}
+
+// WithFieldConfidence fordert zu jedem Feld Konfidenz und Seitenangaben an. Die Antwort hat
+// dann die Form von Extraction und wird vor der Rückgabe mit ParseExtraction geprüft.
+func WithFieldConfidence() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.fieldConfidence = true
+	}
+}
+
+// WithCitations fordert zu jedem Feld Fundstellen (Seite und Zitat) an, etwa damit eine
+// Oberfläche die Herkunft eines Wertes markieren kann. Die Antwort hat dann die Form von
+// Extraction und wird vor der Rückgabe mit ParseExtraction geprüft.
+func WithCitations() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.citations = true
+	}
+}
+
+// ParseExtraction liest eine mit WithFieldConfidence bzw. WithCitations angeforderte Antwort
+// und prüft, dass jede Konfidenz zwischen 0 und 1 liegt, Seiten ab 1 zählen, Zitate nicht leer
+// und nicht übermäßig lang sind und jeder Pfad im Ergebnis existiert.
+func ParseExtraction(content string) (*Extraction, error) {
+	e := &Extraction{}
+	if err := json.Unmarshal([]byte(content), e); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfidence, err)
+	}
+	if len(e.Data) == 0 {
+		return nil, fmt.Errorf("%w: missing data", ErrInvalidConfidence)
+	}
+	var data any
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfidence, err)
+	}
+	for path, c := range e.Confidence {
+		if c.Score < 0 || c.Score > 1 {
+			return nil, fmt.Errorf("%w: score %g of %s out of range", ErrInvalidConfidence, c.Score, path)
+		}
+		for _, page := range c.Pages {
+			if page < 1 {
+				return nil, fmt.Errorf("%w: invalid page %d for %s", ErrInvalidConfidence, page, path)
+			}
+		}
+		if _, ok := lookupPath(data, path); !ok {
+			return nil, fmt.Errorf("%w: unknown field %s", ErrInvalidConfidence, path)
+		}
+	}
+	for path, citations := range e.Citations {
+		if _, ok := lookupPath(data, path); !ok {
+			return nil, fmt.Errorf("%w: citation for unknown field %s", ErrInvalidCitation, path)
+		}
+		for _, c := range citations {
+			switch {
+			case c.Page < 1:
+				return nil, fmt.Errorf("%w: invalid page %d for %s", ErrInvalidCitation, c.Page, path)
+			case strings.TrimSpace(c.Quote) == "":
+				return nil, fmt.Errorf("%w: empty quote for %s", ErrInvalidCitation, path)
+			case len([]rune(c.Quote)) > maxCitationQuote:
+				return nil, fmt.Errorf("%w: quote for %s longer than %d characters", ErrInvalidCitation, path, maxCitationQuote)
+			}
+		}
+	}
+	return e, nil
+}
+
+// LowConfidence liefert die Feldpfade mit einer Konfidenz unter minScore, sortiert.
+func (e *Extraction) LowConfidence(minScore float64) []string {
+	low := []string{}
+	for _, path := range slices.Sorted(maps.Keys(e.Confidence)) {
+		if e.Confidence[path].Score < minScore {
+			low = append(low, path)
+		}
+	}
+	return low
+}
+
+// lookupPath folgt einem Pfad wie "items[0].total" in dekodiertem JSON.
+func lookupPath(v any, path string) (any, bool) {
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[name]; !ok {
+				return nil, false
+			}
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, false
+			}
+			i, err := strconv.Atoi(idx)
+			arr, isArr := v.([]any)
+			if err != nil || !isArr || i < 0 || i >= len(arr) {
+				return nil, false
+			}
+			v = arr[i]
+			rest = strings.TrimPrefix(after, "[")
+			if rest == after && after != "" {
+				return nil, false
+			}
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtraction(t *testing.T) {
+	e, err := ParseExtraction(`{
+		"data": {"total": 12.5, "items": [{"sku": "A"}, {"sku": "B"}]},
+		"confidence": {"total": {"score": 0.95, "pages": [2]}, "items[1].sku": {"score": 0.4, "pages": [1, 2]}}
+	}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"total": 12.5, "items": [{"sku": "A"}, {"sku": "B"}]}`, string(e.Data))
+	require.Equal(t, []int{2}, e.Confidence["total"].Pages)
+	require.Equal(t, []string{"items[1].sku"}, e.LowConfidence(0.8))
+
+	for _, content := range []string{
+		`{"data": {"total": 1}, "confidence": {"total": {"score": 1.5}}}`,
+		`{"data": {"total": 1}, "confidence": {"total": {"score": 0.5, "pages": [0]}}}`,
+		`{"data": {"total": 1}, "confidence": {"sum": {"score": 0.5}}}`,
+		`{"data": {"items": []}, "confidence": {"items[0]": {"score": 0.5}}}`,
+		`{"confidence": {}}`,
+		`kein JSON`,
+	} {
+		_, err := ParseExtraction(content)
+		require.ErrorIs(t, err, ErrInvalidConfidence, content)
+	}
+}
+
+func TestBatchConverter_FieldConfidence(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+
+	ai := newBatchTestService(t, func() string {
+		return `{"data": {"total": 12.5}, "confidence": {"total": {"score": 0.6, "pages": [1]}}}`
+	})
+	bc := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out"))
+	bc.FieldConfidence = true
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	doc := result.Documents[0]
+	require.Equal(t, FieldConfidence{Score: 0.6, Pages: []int{1}}, doc.Confidence["total"])
+	data, err := os.ReadFile(doc.OutputFile)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"total": 12.5}`, string(data))
+}
+
+func TestParseExtraction_Citations(t *testing.T) {
+	e, err := ParseExtraction(`{
+		"data": {"total": 12.5, "items": [{"sku": "A"}]},
+		"citations": {"total": [{"page": 2, "quote": "Gesamt: 12,50 EUR"}], "items[0].sku": [{"page": 1, "quote": "Art.-Nr. A"}]}
+	}`)
+	require.NoError(t, err)
+	require.Equal(t, []Citation{{Page: 2, Quote: "Gesamt: 12,50 EUR"}}, e.Citations["total"])
+	require.Empty(t, e.Confidence)
+
+	for _, content := range []string{
+		`{"data": {"total": 1}, "citations": {"total": [{"page": 0, "quote": "1"}]}}`,
+		`{"data": {"total": 1}, "citations": {"total": [{"page": 1, "quote": " "}]}}`,
+		`{"data": {"total": 1}, "citations": {"sum": [{"page": 1, "quote": "1"}]}}`,
+		`{"data": {"total": 1}, "citations": {"total": [{"page": 1, "quote": "` + strings.Repeat("x", maxCitationQuote+1) + `"}]}}`,
+	} {
+		_, err := ParseExtraction(content)
+		require.ErrorIs(t, err, ErrInvalidCitation)
+	}
+}
+
+func TestExtractionInstruction(t *testing.T) {
+	both := extractionInstruction(requestConfig{fieldConfidence: true, citations: true})
+	require.Contains(t, both, `"confidence"`)
+	require.Contains(t, both, `"citations"`)
+	require.True(t, strings.HasPrefix(both, "\n\n"))
+	require.NotContains(t, extractionInstruction(requestConfig{citations: true}), `"confidence"`)
+}
+
+func TestBatchConverter_Citations(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+
+	ai := newBatchTestService(t, func() string {
+		return `{"data": {"total": 12.5}, "citations": {"total": [{"page": 1, "quote": "Summe 12,50"}]}}`
+	})
+	bc := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out"))
+	bc.Citations = true
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	doc := result.Documents[0]
+	require.Equal(t, []Citation{{Page: 1, Quote: "Summe 12,50"}}, doc.Citations["total"])
+	require.JSONEq(t, `{"total": 12.5}`, doc.Content)
+}
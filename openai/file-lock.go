@@ -0,0 +1,142 @@
+package openai
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked meldet, dass eine andere Instanz die Sperre hält.
+var ErrLocked = errors.New("locked by another instance")
+
+// fileLock ist eine gehaltene Sperrdatei. Sie enthält ein zufälliges Token, an dem die
+// Instanz erkennt, ob die Sperre noch ihr gehört.
+type fileLock struct {
+	path  string
+	token []byte
+}
+
+// acquireFileLock legt die Sperrdatei exklusiv an. Eine Sperre, die älter als ttl ist,
+// gilt als verwaist (abgestürzte Instanz) und wird übernommen. ttl <= 0 übernimmt nie.
+func acquireFileLock(path string, ttl time.Duration) (*fileLock, error) {
+	l, err := newFileLock(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.Write(l.token)
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+		if err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		return l, nil
+	}
+	if !os.IsExist(err) {
+		return nil, err
+	}
+	if !lockExpired(path, ttl) {
+		return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+	}
+	return l, l.takeOver(ttl)
+}
+
+func newFileLock(path string) (*fileLock, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	token := fmt.Sprintf("pid=%d host=%s since=%s token=%s\n", os.Getpid(), host, time.Now().Format(time.RFC3339), hex.EncodeToString(nonce))
+	return &fileLock{path: path, token: []byte(token)}, nil
+}
+
+// lockExpired meldet, ob die Sperrdatei älter als ttl ist.
+func lockExpired(path string, ttl time.Duration) bool {
+	info, err := os.Stat(path)
+	return err == nil && ttl > 0 && time.Since(info.ModTime()) >= ttl
+}
+
+// takeOver übernimmt eine verwaiste Sperre. Nur wer die Hilfssperre <path>.takeover anlegt,
+// darf sie ersetzen; das Token wird per Rename eingesetzt und danach geprüft. So können zwei
+// Instanzen, die die Sperre gleichzeitig für verwaist halten, sie nicht beide übernehmen.
+func (l *fileLock) takeOver(ttl time.Duration) error {
+	guard := l.path + ".takeover"
+	g, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		// eine beim Übernehmen abgestürzte Instanz hinterlässt die Hilfssperre
+		if os.IsExist(err) && lockExpired(guard, ttl) {
+			os.Remove(guard)
+		}
+		return fmt.Errorf("%w: %s", ErrLocked, l.path)
+	}
+	g.Close()
+	defer os.Remove(guard)
+
+	// erneut prüfen: eine andere Instanz kann die Sperre inzwischen übernommen haben
+	if !lockExpired(l.path, ttl) {
+		return fmt.Errorf("%w: %s", ErrLocked, l.path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(l.token)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if !l.held() {
+		return fmt.Errorf("%w: %s", ErrLocked, l.path)
+	}
+	return nil
+}
+
+// held meldet, ob die Sperrdatei noch das eigene Token enthält.
+func (l *fileLock) held() bool {
+	data, err := os.ReadFile(l.path)
+	return err == nil && bytes.Equal(data, l.token)
+}
+
+// release gibt die Sperre frei, sofern sie noch der Instanz gehört.
+func (l *fileLock) release() {
+	if l.held() {
+		os.Remove(l.path)
+	}
+}
+
+// refresh aktualisiert die Änderungszeit der Sperrdatei alle interval, damit lange Läufe
+// nicht als verwaist gelten. Eine von einer anderen Instanz übernommene Sperre bleibt
+// unberührt. stop beendet die Aktualisierung.
+func (l *fileLock) refresh(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if l.held() {
+					os.Chtimes(l.path, now, now)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
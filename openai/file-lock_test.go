@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLock_ConcurrentTakeOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	for range 20 {
+		require.NoError(t, os.WriteFile(path, []byte("pid=1"), 0644))
+		old := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(path, old, old))
+
+		var acquired atomic.Int32
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := acquireFileLock(path, time.Minute); err == nil {
+					acquired.Add(1)
+				} else {
+					require.ErrorIs(t, err, ErrLocked)
+				}
+			}()
+		}
+		wg.Wait()
+		require.EqualValues(t, 1, acquired.Load())
+		require.NoError(t, os.Remove(path))
+	}
+}
+
+func TestFileLock_ReleaseKeepsForeignLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	first, err := acquireFileLock(path, time.Minute)
+	require.NoError(t, err)
+	_, err = acquireFileLock(path, time.Minute)
+	require.ErrorIs(t, err, ErrLocked)
+
+	// die erste Instanz hängt, eine zweite übernimmt die verwaiste Sperre
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+	second, err := acquireFileLock(path, time.Minute)
+	require.NoError(t, err)
+	require.NoFileExists(t, path+".takeover")
+
+	first.release()
+	require.FileExists(t, path)
+	second.release()
+	require.NoFileExists(t, path)
+}
@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"context"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// HedgePolicy beschreibt Hedging für latenzkritische Aufrufe: antwortet der erste Request
+// nicht innerhalb von After, geht ein zweiter an das Fallback-Modell bzw. den Fallback-Service.
+// Es gewinnt die erste erfolgreiche Antwort, der andere Request wird abgebrochen.
+type HedgePolicy struct {
+	After   time.Duration           // Wartezeit bis zum zweiten Request
+	Model   openai.ChatModel        // Modell des zweiten Requests; leer = Modell des Aufrufs bzw. des Fallback-Services
+	Service *AiCommunicationService // anderer Service (z.B. anderer Endpoint/Key) für den zweiten Request, optional
+}
+
+// WithHedging aktiviert für diesen Aufruf Hedging und ersetzt die Policy des Services.
+func WithHedging(p HedgePolicy) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.hedge = &p
+	}
+}
+
+// hedgeConfig liefert Service und Konfiguration für den zweiten Request.
+func (ai *AiCommunicationService) hedgeConfig(cfg requestConfig) (*AiCommunicationService, requestConfig) {
+	p := cfg.hedge
+	target := ai
+	hedgeCfg := cfg
+	hedgeCfg.hedge = nil
+	// der zweite Request ist bewusst ein eigener, nicht derselbe Vorgang
+	hedgeCfg.idempotencyKey = ""
+	if p.Service != nil {
+		target = p.Service
+		target.init()
+		hedgeCfg.model = p.Service.Model
+	}
+	if p.Model != "" {
+		hedgeCfg.model = p.Model
+	}
+	return target, hedgeCfg
+}
+
+// hedgedRequest führt den Aufruf mit Hedging aus.
+func (ai *AiCommunicationService) hedgedRequest(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		content string
+		err     error
+	}
+	results := make(chan result, 2)
+	run := func(target *AiCommunicationService, cfg requestConfig) {
+		content, err := target.requestJsonContent(ctx, systemMessage, f, cfg)
+		results <- result{content, err}
+	}
+
+	primaryCfg := cfg
+	primaryCfg.hedge = nil
+	go run(ai, primaryCfg)
+
+	timer := time.NewTimer(cfg.hedge.After)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.content, r.err
+	case <-timer.C:
+	}
+
+	target, hedgeCfg := ai.hedgeConfig(cfg)
+	log.Debug("No response after %s, hedging with model %s", cfg.hedge.After, hedgeCfg.model)
+	go run(target, hedgeCfg)
+
+	var firstErr error
+	for range 2 {
+		r := <-results
+		if r.err == nil {
+			return r.content, nil
+		}
+		if firstErr == nil {
+			firstErr = r.err
+		}
+	}
+	return "", firstErr
+}
@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateContent_Hedging(t *testing.T) {
+	primaryCancelled := make(chan struct{})
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body.Model == string(openai.ChatModelGPT4_1) {
+			select {
+			case <-r.Context().Done():
+				close(primaryCancelled)
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `{\"ok\": true}`, `{\"hedged\": true}`, 1)))
+	})
+
+	start := time.Now()
+	content, err := ai.GenerateContent("system", WithHedging(HedgePolicy{
+		After: 50 * time.Millisecond,
+		Model: openai.ChatModelGPT4_1Mini,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, `{"hedged": true}`, content)
+	require.Less(t, time.Since(start), time.Second)
+
+	select {
+	case <-primaryCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("primary request was not cancelled")
+	}
+}
+
+func TestGenerateContent_HedgingNotNeeded(t *testing.T) {
+	var models []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		models = append(models, body.Model)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	ai.Hedging = &HedgePolicy{After: time.Second, Model: openai.ChatModelGPT4_1Mini}
+
+	_, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, []string{string(openai.ChatModelGPT4_1)}, models)
+}
@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyTTL ist die Dauer, für die ein Ergebnis zu einem Idempotency-Key gemerkt wird.
+const DefaultIdempotencyTTL = 10 * time.Minute
+
+// idempotencyCache merkt sich Ergebnisse je Idempotency-Key und fasst gleichzeitige
+// Aufrufe mit demselben Key zu einem einzigen API-Aufruf zusammen.
+type idempotencyCache struct {
+	mu       sync.Mutex
+	results  map[string]idempotentResult
+	inflight map[string]*idempotentCall
+	now      func() time.Time
+}
+
+type idempotentResult struct {
+	content string
+	expires time.Time
+}
+
+type idempotentCall struct {
+	done    chan struct{}
+	content string
+	err     error
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		results:  map[string]idempotentResult{},
+		inflight: map[string]*idempotentCall{},
+		now:      time.Now,
+	}
+}
+
+// do führt fn höchstens einmal je key und TTL aus. Fehler werden nicht gemerkt,
+// damit ein späterer Versuch mit demselben Key erneut ausgeführt wird. Wer auf einen
+// laufenden Aufruf wartet, gibt mit ctx auf; bricht der laufende Aufruf wegen seines
+// eigenen Kontexts ab, übernimmt ein Wartender.
+func (c *idempotencyCache) do(ctx context.Context, key string, ttl time.Duration, fn func() (string, error)) (string, error) {
+	for {
+		c.mu.Lock()
+		now := c.now()
+		for k, r := range c.results {
+			if !now.Before(r.expires) {
+				delete(c.results, k)
+			}
+		}
+		if r, ok := c.results[key]; ok {
+			c.mu.Unlock()
+			return r.content, nil
+		}
+		call, ok := c.inflight[key]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		cancelled := errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)
+		if !cancelled || ctx.Err() != nil {
+			return call.content, call.err
+		}
+	}
+	call := &idempotentCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.content, call.err = fn()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.results[key] = idempotentResult{content: call.content, expires: c.now().Add(ttl)}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.content, call.err
+}
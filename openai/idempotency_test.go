@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newIdempotencyCache()
+	c.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	fn := func() (string, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "result", nil
+	}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := c.do(context.Background(), "key", time.Minute, fn)
+			require.NoError(t, err)
+			require.Equal(t, "result", content)
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 1, calls.Load())
+
+	_, err := c.do(context.Background(), "key", time.Minute, fn)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, calls.Load())
+
+	now = now.Add(2 * time.Minute)
+	_, err = c.do(context.Background(), "key", time.Minute, fn)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestIdempotencyCache_ErrorsAreNotCached(t *testing.T) {
+	c := newIdempotencyCache()
+	_, err := c.do(context.Background(), "key", time.Minute, func() (string, error) { return "", errors.New("boom") })
+	require.Error(t, err)
+
+	content, err := c.do(context.Background(), "key", time.Minute, func() (string, error) { return "ok", nil })
+	require.NoError(t, err)
+	require.Equal(t, "ok", content)
+}
+
+func TestIdempotencyCache_WaiterHonoursContext(t *testing.T) {
+	c := newIdempotencyCache()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	first := make(chan error, 1)
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	go func() {
+		_, err := c.do(firstCtx, "key", time.Minute, func() (string, error) {
+			close(started)
+			select {
+			case <-release:
+				return "first", nil
+			case <-firstCtx.Done():
+				return "", firstCtx.Err()
+			}
+		})
+		first <- err
+	}()
+	<-started
+
+	// wartender Aufruf gibt mit seinem eigenen Kontext auf
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.do(ctx, "key", time.Minute, func() (string, error) { return "second", nil })
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+
+	// bricht der laufende Aufruf ab, übernimmt ein Wartender
+	waiter := make(chan string, 1)
+	go func() {
+		content, err := c.do(context.Background(), "key", time.Minute, func() (string, error) { return "second", nil })
+		require.NoError(t, err)
+		waiter <- content
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancelFirst()
+	require.ErrorIs(t, <-first, context.Canceled)
+	require.Equal(t, "second", <-waiter)
+	close(release)
+}
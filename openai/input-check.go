@@ -0,0 +1,57 @@
+package openai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// ErrBadInput meldet ein leeres oder beschädigtes Dokument, das vor dem Upload abgelehnt wird.
+var ErrBadInput = errors.New("bad input document")
+
+// pdfCheckWindow ist der Bereich am Anfang und Ende der Datei, in dem Kopf und %%EOF stehen müssen.
+const pdfCheckWindow = 1024
+
+// CheckInputFile prüft ein Dokument vor dem Upload: leere Dateien und PDFs ohne gültigen
+// Kopf oder ohne %%EOF am Ende (abgeschnitten) liefern ErrBadInput. Als PDF gilt eine Datei
+// mit Endung .pdf oder PDF-Kopf.
+func CheckInputFile(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return log.WrapError(err)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%w: %s is empty", ErrBadInput, filepath.Base(fileName))
+	}
+
+	window := min(info.Size(), pdfCheckWindow)
+	head := make([]byte, window)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return log.WrapError(err)
+	}
+	isPDF := bytes.Contains(head, []byte("%PDF-"))
+	if !isPDF && !strings.EqualFold(filepath.Ext(fileName), ".pdf") {
+		return nil
+	}
+	if !isPDF {
+		return fmt.Errorf("%w: %s has no PDF header", ErrBadInput, filepath.Base(fileName))
+	}
+	tail := make([]byte, window)
+	if _, err := f.ReadAt(tail, info.Size()-window); err != nil {
+		return log.WrapError(err)
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return fmt.Errorf("%w: %s is truncated (no %%%%EOF marker)", ErrBadInput, filepath.Base(fileName))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInputFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ok.pdf":        testPDF,
+		"padded.pdf":    "%PDF-1.7\n" + strings.Repeat("x", 4096) + "\n%%EOF",
+		"empty.pdf":     "",
+		"noheader.pdf":  "hello\n%%EOF\n",
+		"truncated.pdf": "%PDF-1.7\n" + strings.Repeat("x", 4096),
+		"notes.txt":     "hello",
+		"empty.txt":     "",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	for _, name := range []string{"ok.pdf", "padded.pdf", "notes.txt"} {
+		require.NoError(t, CheckInputFile(filepath.Join(dir, name)), name)
+	}
+	for _, name := range []string{"empty.pdf", "noheader.pdf", "truncated.pdf", "empty.txt"} {
+		require.ErrorIs(t, CheckInputFile(filepath.Join(dir, name)), ErrBadInput, name)
+	}
+	require.Equal(t, http.StatusBadRequest, HTTPStatusFor(CheckInputFile(filepath.Join(dir, "empty.pdf"))))
+}
+
+func TestBatchConverter_BadInput(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte("%PDF-1.4\n"), 0644))
+
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	bc := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out"))
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.BadInput)
+	require.Equal(t, DocumentBadInput, result.Documents[0].Status)
+	require.Contains(t, result.Documents[0].Error, "truncated")
+}
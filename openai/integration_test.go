@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dchaykin/myailib/openai/internal/mockserver"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+// Die Tests hier durchlaufen den vollständigen Weg über generateJsonContent gegen den
+// simulierten OpenAI-Server, ohne einzelne Handler je Test.
+
+func newMockService(t *testing.T) (*AiCommunicationService, *mockserver.Server) {
+	t.Helper()
+	srv := mockserver.New()
+	t.Cleanup(srv.Close)
+
+	ai := NewAiCommunicationService("prompt")
+	ai.config.AuthData["apiKey"] = "test-key"
+	ai.ClientOptions = []option.RequestOption{option.WithBaseURL(srv.URL)}
+	ai.Backoff = &BackoffPolicy{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+	return ai, srv
+}
+
+func TestIntegration_Completion(t *testing.T) {
+	ai, srv := newMockService(t)
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+	srv.Enqueue(mockserver.Response{Content: "Hier das Ergebnis:\n```json\n{\"betrag\": \"1.234,50\"}\n```", PromptTokens: 1000, CompletionTokens: 200})
+
+	result, err := ai.Generate(context.Background(), Request{SystemMessage: "system"}, WithPostProcessors(PostProcessGermanNumbers))
+	require.NoError(t, err)
+	require.Equal(t, `{"betrag": 1234.5}`, result.Content)
+	require.EqualValues(t, 1000, result.PromptTokens)
+	require.InDelta(t, costOf(1000, 200), ai.TotalCosts(), 1e-9)
+	require.Len(t, audit.records, 1)
+
+	requests := srv.Requests()
+	require.Len(t, requests, 1)
+	require.Equal(t, UserAgent(), requests[0].Header.Get("User-Agent"))
+	require.Equal(t, "Bearer test-key", requests[0].Header.Get("Authorization"))
+	require.Contains(t, string(requests[0].Body), `"prompt"`)
+}
+
+func TestIntegration_UploadsPDF(t *testing.T) {
+	ai, srv := newMockService(t)
+	fileName := filepath.Join(t.TempDir(), "a.pdf")
+	pdf := "%PDF-1.7\n" + strings.Repeat("x", 50_000) + "\n%%EOF\n"
+	require.NoError(t, os.WriteFile(fileName, []byte(pdf), 0644))
+
+	result, err := ai.Generate(context.Background(), Request{SystemMessage: "system", FileName: fileName})
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, result.Content)
+
+	files := srv.Files()
+	require.Len(t, files, 1)
+	for id, size := range files {
+		require.EqualValues(t, len(pdf), size)
+		requests := srv.Requests()
+		require.Equal(t, "/chat/completions", requests[len(requests)-1].Path)
+		require.Contains(t, string(requests[len(requests)-1].Body), id)
+	}
+}
+
+func TestIntegration_RateLimitWithHeaders(t *testing.T) {
+	ai, srv := newMockService(t)
+	ai.Forecaster = NewQuotaForecaster()
+	srv.Enqueue(mockserver.RateLimited(150 * time.Millisecond))
+
+	start := time.Now()
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	require.Equal(t, 2, srv.ChatRequests())
+	require.Len(t, ai.Forecaster.Samples(), 1)
+
+	ai.MaxAttempts = 1
+	srv.Enqueue(mockserver.RateLimited(2 * time.Second))
+	_, err = ai.GenerateContent("system")
+	var oe *OpenAIError
+	require.ErrorAs(t, err, &oe)
+	require.True(t, oe.IsRateLimit())
+}
+
+func TestIntegration_TransientFailures(t *testing.T) {
+	ai, srv := newMockService(t)
+	srv.Enqueue(mockserver.ServerError(), mockserver.Response{Content: `{"ok": true}`, Truncate: true})
+
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.Equal(t, 3, srv.ChatRequests())
+	require.InDelta(t, costOf(100, 20), ai.TotalCosts(), 1e-9, "only the successful attempt is billed")
+}
+
+func TestIntegration_FinishReasons(t *testing.T) {
+	ai, srv := newMockService(t)
+	srv.Enqueue(
+		mockserver.Response{Content: `{"ok": `, FinishReason: "length"},
+		mockserver.Response{FinishReason: "content_filter"},
+	)
+
+	_, err := ai.GenerateContent("system")
+	require.ErrorIs(t, err, ErrMaxLength)
+	_, err = ai.GenerateContent("system")
+	require.ErrorIs(t, err, ErrContentFiltered)
+}
+
+func TestIntegration_Streaming(t *testing.T) {
+	// geprüft wird, dass der Server Streams wie die API liefert; den Service deckt streaming_test.go ab
+	ai, srv := newMockService(t)
+	ai.init()
+	srv.Enqueue(mockserver.Response{Content: `{"text": "ein etwas längerer Text"}`})
+
+	stream := ai.client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Messages:      []openai.ChatCompletionMessageParamUnion{openai.UserMessage("x")},
+		Model:         openai.ChatModelGPT4_1,
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)},
+	})
+	acc := openai.ChatCompletionAccumulator{}
+	chunks := 0
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+		chunks++
+	}
+	require.NoError(t, stream.Err())
+	require.Greater(t, chunks, 3)
+	require.Equal(t, `{"text": "ein etwas längerer Text"}`, acc.Choices[0].Message.Content)
+	require.EqualValues(t, 120, acc.Usage.TotalTokens)
+}
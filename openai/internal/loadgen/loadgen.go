@@ -0,0 +1,139 @@
+// Package loadgen erzeugt gleichmäßige Last mit fester Rate für Soak-Tests, z.B. gegen den
+// simulierten Server aus mockserver, und prüft die beobachtete Taktung gegen ein Limit.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config beschreibt die Last.
+type Config struct {
+	RPS         float64       // angebotene Aufrufe je Sekunde
+	Duration    time.Duration // so lange werden Aufrufe gestartet
+	Concurrency int           // höchstens so viele Aufrufe gleichzeitig; Default: unbegrenzt
+}
+
+// Report fasst einen Lauf zusammen.
+type Report struct {
+	Offered   int // gestartete Aufrufe
+	Completed int
+	Failed    int
+	Dropped   int // wegen Concurrency nicht gestartet
+	Errors    []error
+	Latencies []time.Duration // der erfolgreichen Aufrufe, aufsteigend
+	Elapsed   time.Duration
+}
+
+// Percentile liefert die Latenz zum Anteil p (0..1).
+func (r *Report) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(r.Latencies)-1))
+	return r.Latencies[i]
+}
+
+// Run startet call mit der Rate aus cfg, bis Duration abgelaufen ist, und wartet auf alle
+// laufenden Aufrufe. Endet ctx, bricht der Lauf ab; call erhält dann einen beendeten Kontext.
+func Run(ctx context.Context, cfg Config, call func(ctx context.Context) error) *Report {
+	report := &Report{}
+	if cfg.RPS <= 0 || cfg.Duration <= 0 {
+		return report
+	}
+	var sem chan struct{}
+	if cfg.Concurrency > 0 {
+		sem = make(chan struct{}, cfg.Concurrency)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	interval := time.Duration(float64(time.Second) / cfg.RPS)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	deadline := time.NewTimer(cfg.Duration)
+	defer deadline.Stop()
+
+	launch := func() {
+		report.Offered++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			t := time.Now()
+			err := call(ctx)
+			latency := time.Since(t)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Failed++
+				report.Errors = append(report.Errors, err)
+				return
+			}
+			report.Completed++
+			report.Latencies = append(report.Latencies, latency)
+		}()
+	}
+
+loop:
+	for {
+		if sem == nil {
+			launch()
+		} else {
+			select {
+			case sem <- struct{}{}:
+				launch()
+			default:
+				report.Dropped++
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	sort.Slice(report.Latencies, func(i, j int) bool { return report.Latencies[i] < report.Latencies[j] })
+	return report
+}
+
+// CheckPacing prüft, ob die Zeitpunkte times mit den Gewichten weights (z.B. Tokens je
+// Request, nil = je 1) ein Limit von perMinute einhalten, das wie ein Token-Bucket mit
+// einer vollen Minute als Startguthaben arbeitet: Bis zum Zeitpunkt t nach dem ersten
+// Request dürfen höchstens perMinute·(1 + t/1min) + slack Einheiten angefallen sein.
+func CheckPacing(times []time.Time, weights []int, perMinute int, slack float64) error {
+	if len(times) == 0 || perMinute <= 0 {
+		return nil
+	}
+	idx := make([]int, len(times))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return times[idx[a]].Before(times[idx[b]]) })
+
+	first := times[idx[0]]
+	used := 0.0
+	for _, i := range idx {
+		w := 1.0
+		if weights != nil {
+			w = float64(weights[i])
+		}
+		used += w
+		elapsed := times[i].Sub(first)
+		allowed := float64(perMinute)*(1+elapsed.Minutes()) + slack
+		if used > allowed {
+			return fmt.Errorf("pacing exceeded after %s: %.0f used, %.0f allowed at %d/min", elapsed.Round(time.Millisecond), used, allowed, perMinute)
+		}
+	}
+	return nil
+}
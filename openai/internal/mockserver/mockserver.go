@@ -0,0 +1,333 @@
+// Package mockserver simuliert die OpenAI-API mit httptest für Tests ohne Netzzugriff:
+// Chat-Completions (auch gestreamt), Datei-Uploads, 429 mit Rate-Limit-Headern und
+// abgebrochene Antworten.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response beschreibt die Antwort auf einen Chat-Request.
+type Response struct {
+	Content          string
+	FinishReason     string // Default: "stop"
+	Model            string // Default: Modell aus dem Request
+	PromptTokens     int64  // Default: 100
+	CompletionTokens int64  // Default: 20
+
+	// Status != 0 liefert einen Fehler mit Message statt einer Completion.
+	Status  int
+	Message string
+	Code    string
+	Headers map[string]string // zusätzliche Header, z.B. x-ratelimit-*
+
+	Delay    time.Duration // Wartezeit vor der Antwort; endet früher, wenn der Client abbricht
+	Truncate bool          // Body nach der Hälfte abbrechen (Verbindung wird geschlossen)
+}
+
+// RateLimited ist eine 429-Antwort wie von OpenAI bei erschöpftem Token-Limit, mit
+// Retry-After in Text und Headern.
+func RateLimited(retryAfter time.Duration) Response {
+	seconds := retryAfter.Seconds()
+	return Response{
+		Status: http.StatusTooManyRequests,
+		Message: fmt.Sprintf("Rate limit reached for gpt-4.1 in organization org-mock on tokens per min (TPM): "+
+			"Limit 30000, Used 30000, Requested 1000. Please try again in %gs. "+
+			"Visit https://platform.openai.com/account/rate-limits to learn more.", seconds),
+		Code: "rate_limit_exceeded",
+		Headers: map[string]string{
+			"retry-after-ms":                 strconv.FormatInt(retryAfter.Milliseconds(), 10),
+			"x-ratelimit-limit-tokens":       "30000",
+			"x-ratelimit-remaining-tokens":   "0",
+			"x-ratelimit-reset-tokens":       retryAfter.String(),
+			"x-ratelimit-limit-requests":     "500",
+			"x-ratelimit-remaining-requests": "499",
+		},
+	}
+}
+
+// ServerError ist eine 500-Antwort.
+func ServerError() Response {
+	return Response{Status: http.StatusInternalServerError, Message: "The server had an error while processing your request.", Code: "server_error"}
+}
+
+// Request ist ein aufgezeichneter Request.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte // bei Uploads leer
+	At     time.Time
+}
+
+// Server ist der simulierte OpenAI-Endpunkt. Die Antworten auf Chat-Requests kommen der
+// Reihe nach aus der Warteschlange (Enqueue), danach gilt Default.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	queue    []Response
+	fallback Response
+	requests []Request
+	files    map[string]int64 // ID -> Größe
+}
+
+// New startet den Server; Close beendet ihn.
+func New() *Server {
+	s := &Server{fallback: Response{Content: `{"ok": true}`}, files: map[string]int64{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetDefault legt die Antwort fest, wenn die Warteschlange leer ist.
+func (s *Server) SetDefault(r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = r
+}
+
+// Enqueue stellt Antworten für die nächsten Chat-Requests bereit.
+func (s *Server) Enqueue(r ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, r...)
+}
+
+// Requests liefert alle bisherigen Requests.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ChatRequests zählt die Chat-Requests.
+func (s *Server) ChatRequests() int {
+	n := 0
+	for _, r := range s.Requests() {
+		if r.Path == "/chat/completions" {
+			n++
+		}
+	}
+	return n
+}
+
+// Files liefert die Größen der hochgeladenen, nicht gelöschten Dateien je ID.
+func (s *Server) Files() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make(map[string]int64, len(s.files))
+	for id, size := range s.files {
+		files[id] = size
+	}
+	return files
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	rec := Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), At: time.Now()}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/files":
+		s.record(rec)
+		s.upload(w, r)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/files/"):
+		s.record(rec)
+		id := strings.TrimPrefix(r.URL.Path, "/files/")
+		s.mu.Lock()
+		delete(s.files, id)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "object": "file", "deleted": true})
+	case r.Method == http.MethodPost && r.URL.Path == "/chat/completions":
+		rec.Body, _ = io.ReadAll(r.Body)
+		s.record(rec)
+		s.chat(w, r, rec.Body)
+	default:
+		s.record(rec)
+		writeError(w, Response{Status: http.StatusNotFound, Message: "Unknown request URL: " + r.Method + " " + r.URL.Path, Code: "unknown_url"})
+	}
+}
+
+func (s *Server) record(r Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+}
+
+func (s *Server) next() Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return s.fallback
+	}
+	r := s.queue[0]
+	s.queue = s.queue[1:]
+	return r
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, Response{Status: http.StatusBadRequest, Message: err.Error(), Code: "invalid_request"})
+		return
+	}
+	var size int64
+	name := ""
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, Response{Status: http.StatusBadRequest, Message: err.Error(), Code: "invalid_request"})
+			return
+		}
+		if part.FormName() == "file" {
+			name = part.FileName()
+			size, _ = io.Copy(io.Discard, part)
+		}
+	}
+	s.mu.Lock()
+	id := fmt.Sprintf("file-%d", len(s.requests))
+	s.files[id] = size
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id": id, "object": "file", "bytes": size, "created_at": time.Now().Unix(),
+		"filename": name, "purpose": "user_data", "status": "processed",
+	})
+}
+
+func (s *Server) chat(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, Response{Status: http.StatusBadRequest, Message: "invalid JSON body", Code: "invalid_request"})
+		return
+	}
+	resp := s.next()
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	if resp.Status != 0 {
+		writeError(w, resp)
+		return
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	if resp.FinishReason == "" {
+		resp.FinishReason = "stop"
+	}
+	if resp.PromptTokens == 0 && resp.CompletionTokens == 0 {
+		resp.PromptTokens, resp.CompletionTokens = 100, 20
+	}
+	if req.Stream {
+		stream(w, resp)
+		return
+	}
+
+	data, _ := json.Marshal(map[string]any{
+		"id":      "chatcmpl-mock",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   resp.Model,
+		"choices": []any{map[string]any{
+			"index":         0,
+			"finish_reason": resp.FinishReason,
+			"message":       map[string]any{"role": "assistant", "content": resp.Content},
+		}},
+		"usage": usage(resp),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Truncate {
+		truncate(w, data)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// stream sendet den Inhalt als Server-Sent Events in Stücken zu wenigen Zeichen; der
+// letzte Chunk enthält die Usage.
+func stream(w http.ResponseWriter, resp Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	chunk := func(delta map[string]any, finishReason any, u any) {
+		data, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-mock",
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   resp.Model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finishReason}},
+			"usage":   u,
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	runes := []rune(resp.Content)
+	if resp.Truncate {
+		runes = runes[:len(runes)/2]
+	}
+	chunk(map[string]any{"role": "assistant", "content": ""}, nil, nil)
+	for i := 0; i < len(runes); i += 8 {
+		chunk(map[string]any{"content": string(runes[i:min(i+8, len(runes))])}, nil, nil)
+	}
+	if resp.Truncate {
+		return
+	}
+	chunk(map[string]any{}, resp.FinishReason, usage(resp))
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// truncate kündigt den ganzen Body an, schreibt aber nur die Hälfte und schließt die Verbindung.
+func truncate(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data[:len(data)/2])
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	if hijacker, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func usage(resp Response) map[string]any {
+	return map[string]any{
+		"prompt_tokens":     resp.PromptTokens,
+		"completion_tokens": resp.CompletionTokens,
+		"total_tokens":      resp.PromptTokens + resp.CompletionTokens,
+	}
+}
+
+func writeError(w http.ResponseWriter, resp Response) {
+	writeJSON(w, resp.Status, map[string]any{"error": map[string]any{
+		"message": resp.Message, "type": "mock_error", "param": nil, "code": resp.Code,
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
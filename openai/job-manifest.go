@@ -0,0 +1,494 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrBudgetExceeded meldet, dass ein Job seine Kostenobergrenze erreicht hat.
+var ErrBudgetExceeded = errors.New("job budget exceeded")
+
+// JobManifest beschreibt einen wiederkehrenden Konvertierungsjob deklarativ (YAML oder JSON).
+// Relative Pfade werden relativ zum Verzeichnis der Manifest-Datei aufgelöst.
+type JobManifest struct {
+	Name              string           `json:"name" yaml:"name"`
+	Schedule          string           `json:"schedule,omitempty" yaml:"schedule,omitempty"` // für JobScheduler, siehe ParseSchedule
+	Input             JobInput         `json:"input" yaml:"input"`
+	Output            JobOutput        `json:"output" yaml:"output"`
+	Model             string           `json:"model,omitempty" yaml:"model,omitempty"`
+	Temperature       *float64         `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	PromptVersion     string           `json:"promptVersion,omitempty" yaml:"promptVersion,omitempty"`
+	DocumentType      string           `json:"documentType,omitempty" yaml:"documentType,omitempty"`
+	SystemMessage     string           `json:"systemMessage,omitempty" yaml:"systemMessage,omitempty"`
+	SystemMessageFile string           `json:"systemMessageFile,omitempty" yaml:"systemMessageFile,omitempty"`
+	Prompt            string           `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	PromptFile        string           `json:"promptFile,omitempty" yaml:"promptFile,omitempty"`
+	SchemaFile        string           `json:"schemaFile,omitempty" yaml:"schemaFile,omitempty"`         // JSON-Schema der Antwort
+	ValidateSchema    bool             `json:"validateSchema,omitempty" yaml:"validateSchema,omitempty"` // Antworten gegen das Schema prüfen
+	PostProcessors    []string         `json:"postProcessors,omitempty" yaml:"postProcessors,omitempty"`
+	ContinueOnError   bool             `json:"continueOnError,omitempty" yaml:"continueOnError,omitempty"` // siehe BatchConverter.ContinueOnError
+	Budget            JobBudget        `json:"budget,omitempty" yaml:"budget,omitempty"`
+	RateLimit         JobRateSpec      `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+	Safety            *SafetySettings  `json:"safety,omitempty" yaml:"safety,omitempty"`
+	FieldConfidence   bool             `json:"fieldConfidence,omitempty" yaml:"fieldConfidence,omitempty"` // Konfidenz je Feld anfordern
+	Citations         bool             `json:"citations,omitempty" yaml:"citations,omitempty"`             // Fundstellen je Feld anfordern
+	Language          string           `json:"language,omitempty" yaml:"language,omitempty"`               // Sprache der Antwort prüfen, z.B. "de"
+	Residency         *ResidencyPolicy `json:"residency,omitempty" yaml:"residency,omitempty"`             // erlaubte Endpoints, z.B. baseURL: https://eu.api.openai.com/v1
+	// Schemas legt je Dokumenttyp ein eigenes Schema fest; ein Klassifizierungsschritt wählt es
+	// je Dokument (siehe DocumentProfiler). Dokumente anderer Typen nutzen die Angaben oben.
+	Schemas         []JobSchema `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	ClassifierModel string      `json:"classifierModel,omitempty" yaml:"classifierModel,omitempty"` // Modell der Klassifizierung; leer = model
+
+	baseDir string
+}
+
+// JobSchema beschreibt die Extraktion für einen Dokumenttyp. Leere Angaben übernehmen die
+// Werte des Manifests.
+type JobSchema struct {
+	Type              string `json:"type" yaml:"type"` // z.B. "invoice", "bank-statement"
+	SchemaFile        string `json:"schemaFile,omitempty" yaml:"schemaFile,omitempty"`
+	SystemMessage     string `json:"systemMessage,omitempty" yaml:"systemMessage,omitempty"`
+	SystemMessageFile string `json:"systemMessageFile,omitempty" yaml:"systemMessageFile,omitempty"`
+	Prompt            string `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	PromptFile        string `json:"promptFile,omitempty" yaml:"promptFile,omitempty"`
+	Model             string `json:"model,omitempty" yaml:"model,omitempty"`
+	Folder            string `json:"folder,omitempty" yaml:"folder,omitempty"` // relativ zu output.folder; Default: type
+}
+
+type JobInput struct {
+	Folder  string `json:"folder" yaml:"folder"`
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"` // z.B. "*.pdf"
+	// siehe BatchConverter.FollowSymlinks, SkipHidden und MaxFileSize
+	SkipSymlinks bool  `json:"skipSymlinks,omitempty" yaml:"skipSymlinks,omitempty"`
+	SkipHidden   bool  `json:"skipHidden,omitempty" yaml:"skipHidden,omitempty"`
+	MaxFileSize  int64 `json:"maxFileSize,omitempty" yaml:"maxFileSize,omitempty"` // Bytes
+}
+
+type JobOutput struct {
+	Folder  string            `json:"folder" yaml:"folder"`
+	Webhook *JobWebhookOutput `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	SQL     *JobSQLOutput     `json:"sql,omitempty" yaml:"sql,omitempty"`
+	// Provenance legt neben jedem Ergebnis eine Herkunftsdatei ab, siehe BatchConverter.WriteProvenance.
+	Provenance *JobProvenanceOutput `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+	Review     *JobReviewOutput     `json:"review,omitempty" yaml:"review,omitempty"`
+	// FileMode und DirMode sind oktal, z.B. "0640"; siehe BatchConverter.Permissions.
+	FileMode string    `json:"fileMode,omitempty" yaml:"fileMode,omitempty"`
+	DirMode  string    `json:"dirMode,omitempty" yaml:"dirMode,omitempty"`
+	Owner    *JobOwner `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// Compression speichert die Ergebnisse komprimiert, z.B. "gzip"; siehe BatchConverter.Compression.
+	Compression string `json:"compression,omitempty" yaml:"compression,omitempty"`
+	// Naming "content" legt Ergebnisse unter ihrem Hash in ObjectStore ab, siehe OutputNamingContent.
+	Naming      OutputNaming `json:"naming,omitempty" yaml:"naming,omitempty"`
+	ObjectStore string       `json:"objectStore,omitempty" yaml:"objectStore,omitempty"`
+	// Anonymize ersetzt personenbezogene Daten durch Platzhalter, siehe BatchConverter.Anonymizer.
+	Anonymize *JobAnonymizeOutput `json:"anonymize,omitempty" yaml:"anonymize,omitempty"`
+}
+
+// JobAnonymizeOutput beschreibt die Anonymisierung, siehe Anonymizer.
+type JobAnonymizeOutput struct {
+	MappingFile string            `json:"mappingFile" yaml:"mappingFile"`               // Zuordnung Platzhalter -> Wert, außerhalb von output.folder
+	Fields      map[string]string `json:"fields,omitempty" yaml:"fields,omitempty"`     // Feld oder Pfad -> Kategorie, z.B. name: PERSON
+	Patterns    bool              `json:"patterns,omitempty" yaml:"patterns,omitempty"` // E-Mail, IBAN und Telefon in allen Texten
+}
+
+// JobOwner ist der Eigentümer der Ergebnisse (nur Unix); fehlt uid oder gid, bleibt sie unverändert.
+type JobOwner struct {
+	UID *int `json:"uid,omitempty" yaml:"uid,omitempty"`
+	GID *int `json:"gid,omitempty" yaml:"gid,omitempty"`
+}
+
+// JobReviewOutput legt Ergebnisse zur Prüfung vor, siehe BatchConverter.Review. Webhook und
+// SQL aus Output erhalten die Einträge zusätzlich, wenn sie hier aktiviert sind.
+type JobReviewOutput struct {
+	Folder        string  `json:"folder,omitempty" yaml:"folder,omitempty"`
+	Webhook       bool    `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	SQL           bool    `json:"sql,omitempty" yaml:"sql,omitempty"`
+	MinConfidence float64 `json:"minConfidence,omitempty" yaml:"minConfidence,omitempty"`
+}
+
+type JobProvenanceOutput struct {
+	SecretEnv string `json:"secretEnv,omitempty" yaml:"secretEnv,omitempty"` // Name der Env-Variable mit dem HMAC-Schlüssel
+}
+
+type JobWebhookOutput struct {
+	URL       string `json:"url" yaml:"url"`
+	SecretEnv string `json:"secretEnv,omitempty" yaml:"secretEnv,omitempty"` // Name der Env-Variable mit dem HMAC-Secret
+}
+
+// JobSQLOutput schreibt Ergebnisse in eine Datenbank. Der Treiber muss vom Programm
+// registriert sein (Blank-Import).
+type JobSQLOutput struct {
+	Driver  string     `json:"driver" yaml:"driver"`
+	DSNEnv  string     `json:"dsnEnv" yaml:"dsnEnv"` // Name der Env-Variable mit dem DSN
+	Dialect SQLDialect `json:"dialect" yaml:"dialect"`
+	Table   string     `json:"table,omitempty" yaml:"table,omitempty"`
+}
+
+type JobBudget struct {
+	// MaxCost ist die Kostenobergrenze (USD) je Lauf, 0 = unbegrenzt. Ist sie erreicht, stoppt
+	// der Lauf, die restlichen Dateien werden übersprungen und das nötige Budget geschätzt.
+	MaxCost float64 `json:"maxCost,omitempty" yaml:"maxCost,omitempty"`
+}
+
+type JobRateSpec struct {
+	RPM int `json:"rpm,omitempty" yaml:"rpm,omitempty"`
+	TPM int `json:"tpm,omitempty" yaml:"tpm,omitempty"`
+}
+
+// LoadJobManifest liest ein Manifest; .json wird als JSON, alles andere als YAML gelesen.
+func LoadJobManifest(path string) (*JobManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	m := &JobManifest{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(m)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid job manifest %s: %w", path, err)
+	}
+	m.baseDir = filepath.Dir(path)
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate prüft die Pflichtangaben.
+func (m *JobManifest) Validate() error {
+	switch {
+	case m.Name == "":
+		return fmt.Errorf("job manifest: name is required")
+	case m.Input.Folder == "":
+		return fmt.Errorf("job manifest %s: input.folder is required", m.Name)
+	case m.Output.Folder == "":
+		return fmt.Errorf("job manifest %s: output.folder is required", m.Name)
+	case m.SystemMessage != "" && m.SystemMessageFile != "":
+		return fmt.Errorf("job manifest %s: systemMessage and systemMessageFile are mutually exclusive", m.Name)
+	case m.Prompt != "" && m.PromptFile != "":
+		return fmt.Errorf("job manifest %s: prompt and promptFile are mutually exclusive", m.Name)
+	case m.Output.Review != nil && (m.Output.Review.MinConfidence < 0 || m.Output.Review.MinConfidence > 1):
+		return fmt.Errorf("job manifest %s: output.review.minConfidence must be between 0 and 1", m.Name)
+	case m.Budget.MaxCost < 0:
+		return fmt.Errorf("job manifest %s: budget.maxCost must not be negative", m.Name)
+	case m.Input.MaxFileSize < 0:
+		return fmt.Errorf("job manifest %s: input.maxFileSize must not be negative", m.Name)
+	case m.ValidateSchema && m.SchemaFile == "" && !slices.ContainsFunc(m.Schemas, func(s JobSchema) bool { return s.SchemaFile != "" }):
+		return fmt.Errorf("job manifest %s: validateSchema requires schemaFile", m.Name)
+	}
+	if _, err := m.Output.permissions(); err != nil {
+		return fmt.Errorf("job manifest %s: %w", m.Name, err)
+	}
+	if m.Residency != nil {
+		if err := m.Residency.Validate(); err != nil {
+			return fmt.Errorf("job manifest %s: residency: %w", m.Name, err)
+		}
+	}
+	if _, err := lookupCompression(m.Output.Compression); err != nil {
+		return fmt.Errorf("job manifest %s: output.compression: %w", m.Name, err)
+	}
+	if err := validOutputNaming(m.Output.Naming); err != nil {
+		return fmt.Errorf("job manifest %s: output.naming: %w", m.Name, err)
+	}
+	if a := m.Output.Anonymize; a != nil {
+		switch {
+		case a.MappingFile == "":
+			return fmt.Errorf("job manifest %s: output.anonymize.mappingFile is required", m.Name)
+		case len(a.Fields) == 0 && !a.Patterns:
+			return fmt.Errorf("job manifest %s: output.anonymize needs fields or patterns", m.Name)
+		case isWithin(m.path(a.MappingFile), m.path(m.Output.Folder)):
+			return fmt.Errorf("job manifest %s: output.anonymize.mappingFile must be outside output.folder", m.Name)
+		}
+		for field, category := range a.Fields {
+			if !piiCategoryPattern.MatchString(category) {
+				return fmt.Errorf("job manifest %s: output.anonymize.fields: invalid category %q for %s", m.Name, category, field)
+			}
+		}
+	}
+	types := map[string]bool{}
+	for _, s := range m.Schemas {
+		switch {
+		case s.Type == "":
+			return fmt.Errorf("job manifest %s: schemas[].type is required", m.Name)
+		case types[s.Type]:
+			return fmt.Errorf("job manifest %s: duplicate schema type %q", m.Name, s.Type)
+		case s.SystemMessage != "" && s.SystemMessageFile != "":
+			return fmt.Errorf("job manifest %s: schema %s: systemMessage and systemMessageFile are mutually exclusive", m.Name, s.Type)
+		case s.Prompt != "" && s.PromptFile != "":
+			return fmt.Errorf("job manifest %s: schema %s: prompt and promptFile are mutually exclusive", m.Name, s.Type)
+		}
+		types[s.Type] = true
+	}
+	if m.Input.Pattern != "" {
+		if _, err := filepath.Match(m.Input.Pattern, ""); err != nil {
+			return fmt.Errorf("job manifest %s: invalid input.pattern: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *JobManifest) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(m.baseDir, p)
+}
+
+func (m *JobManifest) readText(inline, file string) (string, error) {
+	if file == "" {
+		return inline, nil
+	}
+	data, err := os.ReadFile(m.path(file))
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	return string(data), nil
+}
+
+// NewBatchConverter baut aus dem Manifest Service und BatchConverter.
+func (m *JobManifest) NewBatchConverter(ctx context.Context) (*BatchConverter, error) {
+	systemMessage, err := m.readText(m.SystemMessage, m.SystemMessageFile)
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := m.readText(m.Prompt, m.PromptFile)
+	if err != nil {
+		return nil, err
+	}
+	systemMessage, err = m.withSchema(systemMessage, m.SchemaFile)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := m.loadSchema(m.SchemaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	service := NewAiCommunicationService(prompt)
+	if m.Model != "" {
+		service.Model = openai.ChatModel(m.Model)
+	}
+	if m.Temperature != nil {
+		service.Temperature = *m.Temperature
+	}
+	service.PromptVersion = m.PromptVersion
+	service.PostProcessors = m.PostProcessors
+	service.RateLimiter = NewRateLimiter(m.RateLimit.RPM, m.RateLimit.TPM)
+	service.Safety = m.Safety
+	if m.Language != "" {
+		service.Language = &LanguagePolicy{Language: m.Language}
+	}
+	service.Residency = m.Residency
+
+	bc := NewBatchConverter(service, systemMessage, m.path(m.Input.Folder), m.path(m.Output.Folder))
+	bc.Pattern = m.Input.Pattern
+	bc.FollowSymlinks = !m.Input.SkipSymlinks
+	bc.SkipHidden = m.Input.SkipHidden
+	bc.MaxFileSize = m.Input.MaxFileSize
+	bc.MaxCost = m.Budget.MaxCost
+	bc.Job = m.Name
+	bc.DocumentType = m.DocumentType
+	bc.FieldConfidence = m.FieldConfidence
+	bc.Citations = m.Citations
+	bc.Schema = schema
+	service.Estimator = NewTokenEstimator()
+	bc.ContinueOnError = m.ContinueOnError
+	if len(m.Schemas) > 0 {
+		if bc.Profiler, err = m.profiler(); err != nil {
+			return nil, err
+		}
+	}
+
+	if bc.Permissions, err = m.Output.permissions(); err != nil {
+		return nil, err
+	}
+	bc.Compression = m.Output.Compression
+	bc.Naming = m.Output.Naming
+	if m.Output.ObjectStore != "" {
+		bc.ObjectStore = m.path(m.Output.ObjectStore)
+	}
+	if a := m.Output.Anonymize; a != nil {
+		if bc.Anonymizer, err = NewAnonymizer(m.path(a.MappingFile), a.Fields); err != nil {
+			return nil, err
+		}
+		bc.Anonymizer.Patterns = a.Patterns
+	}
+	review := m.Output.Review
+	if review != nil {
+		bc.MinConfidence = review.MinConfidence
+		if review.Folder != "" {
+			sink, err := NewFolderReviewSink(m.path(review.Folder))
+			if err != nil {
+				return nil, err
+			}
+			bc.Review = append(bc.Review, sink)
+		}
+	}
+	if wh := m.Output.Webhook; wh != nil {
+		sink := NewWebhookSink(wh.URL, []byte(os.Getenv(wh.SecretEnv)))
+		bc.Sinks = append(bc.Sinks, sink)
+		if review != nil && review.Webhook {
+			bc.Review = append(bc.Review, sink)
+		}
+	}
+	if p := m.Output.Provenance; p != nil {
+		bc.WriteProvenance = true
+		bc.ProvenanceKey = []byte(os.Getenv(p.SecretEnv))
+	}
+	if out := m.Output.SQL; out != nil {
+		db, err := sql.Open(out.Driver, os.Getenv(out.DSNEnv))
+		if err != nil {
+			return nil, log.WrapError(err)
+		}
+		sink, err := NewSQLSink(ctx, db, out.Dialect, out.Table)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		sink.ownsDB = true
+		bc.Sinks = append(bc.Sinks, sink)
+		if review != nil && review.SQL {
+			bc.Review = append(bc.Review, sink)
+		}
+	}
+	return bc, nil
+}
+
+// permissions liefert Rechte und Eigentümer der Ergebnisse.
+func (o JobOutput) permissions() (OutputPermissions, error) {
+	var p OutputPermissions
+	var err error
+	if p.FileMode, err = parseFileMode(o.FileMode); err != nil {
+		return p, fmt.Errorf("invalid output.fileMode: %w", err)
+	}
+	if p.DirMode, err = parseFileMode(o.DirMode); err != nil {
+		return p, fmt.Errorf("invalid output.dirMode: %w", err)
+	}
+	if o.Owner != nil {
+		p.Owner = &FileOwner{UID: -1, GID: -1}
+		if o.Owner.UID != nil {
+			p.Owner.UID = *o.Owner.UID
+		}
+		if o.Owner.GID != nil {
+			p.Owner.GID = *o.Owner.GID
+		}
+	}
+	return p, nil
+}
+
+// parseFileMode liest einen oktalen Modus wie "0640" oder "0o640"; leer = 0.
+func parseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission like 0640", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// isWithin meldet, ob path in dir oder einem Unterordner davon liegt.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// withSchema hängt die Anweisung an, gemäß dem JSON-Schema aus schemaFile zu antworten.
+func (m *JobManifest) withSchema(systemMessage, schemaFile string) (string, error) {
+	if schemaFile == "" {
+		return systemMessage, nil
+	}
+	schema, err := m.readText("", schemaFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(systemMessage + "\n\nAntworte ausschließlich mit JSON gemäß folgendem JSON-Schema:\n" + schema), nil
+}
+
+// loadSchema lädt bei ValidateSchema das Schema zum Prüfen der Antworten; sonst nil.
+func (m *JobManifest) loadSchema(schemaFile string) (*JSONSchema, error) {
+	if !m.ValidateSchema || schemaFile == "" {
+		return nil, nil
+	}
+	return LoadJSONSchema(m.path(schemaFile))
+}
+
+// profiler baut aus Schemas die Klassifizierung.
+func (m *JobManifest) profiler() (*DocumentProfiler, error) {
+	baseMessage, err := m.readText(m.SystemMessage, m.SystemMessageFile)
+	if err != nil {
+		return nil, err
+	}
+	p := &DocumentProfiler{Model: openai.ChatModel(m.ClassifierModel), Routes: map[string]ProfileRoute{}}
+	for _, s := range m.Schemas {
+		route := ProfileRoute{Route: Route{Model: openai.ChatModel(s.Model)}, Folder: s.Folder}
+		if route.Folder == "" {
+			route.Folder = s.Type
+		}
+		if route.Prompt, err = m.readText(s.Prompt, s.PromptFile); err != nil {
+			return nil, err
+		}
+		message := baseMessage
+		if s.SystemMessage != "" || s.SystemMessageFile != "" {
+			if message, err = m.readText(s.SystemMessage, s.SystemMessageFile); err != nil {
+				return nil, err
+			}
+		}
+		schemaFile := s.SchemaFile
+		if schemaFile == "" {
+			schemaFile = m.SchemaFile
+		}
+		if route.SystemMessage, err = m.withSchema(message, schemaFile); err != nil {
+			return nil, err
+		}
+		if route.Schema, err = m.loadSchema(schemaFile); err != nil {
+			return nil, err
+		}
+		p.Routes[s.Type] = route
+	}
+	return p, nil
+}
+
+// RunJob führt den im Manifest beschriebenen Job aus.
+func RunJob(manifest *JobManifest) (*BatchResult, error) {
+	return RunJobContext(context.Background(), manifest)
+}
+
+// RunJobContext wie RunJob, aber abbrechbar über ctx.
+func RunJobContext(ctx context.Context, manifest *JobManifest) (*BatchResult, error) {
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	bc, err := manifest.NewBatchConverter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := bc.Close(); err != nil {
+			log.Warn("failed to close outputs of job %s: %v", manifest.Name, err)
+		}
+	}()
+	log.Info("Running job %s", manifest.Name)
+	return bc.RunContext(ctx)
+}
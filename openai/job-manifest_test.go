@@ -0,0 +1,168 @@
+package openai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadJobManifest(t *testing.T) {
+	m, err := LoadJobManifest("testdata/job.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "invoices", m.Name)
+	require.Equal(t, "*.pdf", m.Input.Pattern)
+	require.EqualValues(t, 12.5, m.Budget.MaxCost)
+	require.NotNil(t, m.Output.Webhook)
+
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("testdata", "in"), bc.SrcFolder)
+	require.Equal(t, filepath.Join("testdata", "out"), bc.DestFolder)
+	require.EqualValues(t, "gpt-4.1-mini", bc.Service.Model)
+	require.InDelta(t, 0.1, bc.Service.Temperature, 1e-9)
+	require.Equal(t, "invoice-v3", bc.Service.PromptVersion)
+	require.Equal(t, 60, bc.Service.RateLimiter.RPM)
+	require.Len(t, bc.Sinks, 1)
+	require.False(t, bc.ContinueOnError)
+}
+
+func TestLoadJobManifest_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "job.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"name":"x","input":{"folder":"in"}}`), 0644))
+	_, err := LoadJobManifest(path)
+	require.ErrorContains(t, err, "output.folder")
+
+	path = filepath.Join(dir, "job.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: x\nunknown: 1\n"), 0644))
+	_, err = LoadJobManifest(path)
+	require.Error(t, err)
+}
+
+func TestLoadJobManifest_Schemas(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invoice.json"), []byte(`{"type": "object"}`), 0644))
+	manifest := `name: mixed
+input:
+  folder: in
+output:
+  folder: out
+systemMessage: Du extrahierst Daten.
+classifierModel: gpt-4.1-mini
+schemas:
+  - type: invoice
+    schemaFile: invoice.json
+  - type: bank-statement
+    systemMessage: Du extrahierst Kontobewegungen.
+    model: gpt-4.1
+    folder: statements
+`
+	path := filepath.Join(dir, "job.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, bc.Profiler)
+	require.EqualValues(t, "gpt-4.1-mini", bc.Profiler.Model)
+	require.Equal(t, []string{"bank-statement", "invoice"}, bc.Profiler.types())
+
+	invoice := bc.Profiler.Routes["invoice"]
+	require.Equal(t, "invoice", invoice.Folder)
+	require.Contains(t, invoice.SystemMessage, "Du extrahierst Daten.")
+	require.Contains(t, invoice.SystemMessage, `{"type": "object"}`)
+
+	statement := bc.Profiler.Routes["bank-statement"]
+	require.Equal(t, "statements", statement.Folder)
+	require.Equal(t, "Du extrahierst Kontobewegungen.", statement.SystemMessage)
+	require.EqualValues(t, "gpt-4.1", statement.Model)
+
+	m.Schemas = append(m.Schemas, JobSchema{Type: "invoice"})
+	require.ErrorContains(t, m.Validate(), "duplicate schema type")
+}
+
+func TestLoadJobManifest_Permissions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "job.yaml")
+	manifest := "name: x\ninput:\n  folder: in\noutput:\n  folder: out\n  fileMode: \"0640\"\n  dirMode: 0o750\n  owner:\n    gid: 1001\n"
+	require.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, OutputPermissions{FileMode: 0640, DirMode: 0750, Owner: &FileOwner{UID: -1, GID: 1001}}, bc.Permissions)
+
+	require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\n  fileMode: \"0999\"\n"), 0644))
+	_, err = LoadJobManifest(path)
+	require.ErrorContains(t, err, "output.fileMode")
+}
+
+func TestLoadJobManifest_Compression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\n  compression: gzip\n"), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, CompressionGzip, bc.Compression)
+
+	require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\n  compression: rar\n"), 0644))
+	_, err = LoadJobManifest(path)
+	require.ErrorContains(t, err, "output.compression")
+}
+
+func TestLoadJobManifest_ContinueOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\ncontinueOnError: true\n"), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.True(t, bc.ContinueOnError)
+}
+
+func TestLoadJobManifest_Residency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.yaml")
+	manifest := "name: x\ninput:\n  folder: in\noutput:\n  folder: out\nresidency:\n  baseURL: https://eu.api.openai.com/v1\n"
+	require.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, EUResidency(), bc.Service.Residency)
+
+	require.NoError(t, os.WriteFile(path, []byte(manifest+"  allowedBaseURLs: [proxy.internal]\n"), 0644))
+	_, err = LoadJobManifest(path)
+	require.ErrorContains(t, err, "residency: invalid residency endpoint")
+}
+
+func TestLoadJobManifest_Anonymize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "job.yaml")
+	manifest := "name: x\ninput:\n  folder: in\noutput:\n  folder: out\n  anonymize:\n    mappingFile: private/mapping.json\n    fields:\n      name: PERSON\n    patterns: true\n"
+	require.NoError(t, os.WriteFile(path, []byte(manifest), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, bc.Anonymizer)
+	require.Equal(t, filepath.Join(dir, "private", "mapping.json"), bc.Anonymizer.MappingFile)
+	require.True(t, bc.Anonymizer.Patterns)
+	require.Equal(t, map[string]string{"name": PIIPerson}, bc.Anonymizer.Fields)
+
+	for manifest, msg := range map[string]string{
+		"    mappingFile: out/mapping.json\n    patterns: true\n":          "must be outside output.folder",
+		"    mappingFile: mapping.json\n":                                  "needs fields or patterns",
+		"    patterns: true\n":                                             "mappingFile is required",
+		"    mappingFile: mapping.json\n    fields:\n      name: person\n": `invalid category "person"`,
+	} {
+		require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\n  anonymize:\n"+manifest), 0644))
+		_, err = LoadJobManifest(path)
+		require.ErrorContains(t, err, msg)
+	}
+}
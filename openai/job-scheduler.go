@@ -0,0 +1,225 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+const defaultJobLockTTL = 6 * time.Hour
+
+var unsafeFileNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// JobStatus ist der Stand eines geplanten Jobs.
+type JobStatus struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	Running   bool      `json:"running"`
+	LastStart time.Time `json:"lastStart,omitempty"`
+	LastEnd   time.Time `json:"lastEnd,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+	NextRun   time.Time `json:"nextRun"`
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+}
+
+// JobScheduler führt Job-Manifeste nach Zeitplan aus. Über Sperrdateien in LockDir wird
+// verhindert, dass mehrere Instanzen denselben Job gleichzeitig ausführen; LockDir muss
+// dazu für alle Instanzen erreichbar sein (z.B. gemeinsames Volume).
+type JobScheduler struct {
+	LockDir string
+	LockTTL time.Duration // Sperren älter als das gelten als verwaist, Default: 6h
+	Hooks   []EventHook   // z.B. für Benachrichtigungen bei EventJobFailed
+
+	runJob func(context.Context, *JobManifest) (*BatchResult, error)
+	now    func() time.Time
+
+	mu   sync.Mutex
+	jobs []*scheduledJob
+	wg   sync.WaitGroup
+}
+
+type scheduledJob struct {
+	name     string
+	run      func(ctx context.Context) (*BatchResult, error)
+	schedule Schedule
+	status   JobStatus
+}
+
+func NewJobScheduler(lockDir string) *JobScheduler {
+	return &JobScheduler{
+		LockDir: lockDir,
+		LockTTL: defaultJobLockTTL,
+		Hooks:   []EventHook{LogEventHook},
+		runJob:  RunJobContext,
+		now:     time.Now,
+	}
+}
+
+// Add plant einen Job nach seinem Feld Schedule ein.
+func (s *JobScheduler) Add(manifest *JobManifest) error {
+	if manifest.Schedule == "" {
+		return fmt.Errorf("job %s has no schedule", manifest.Name)
+	}
+	return s.add(manifest.Name, manifest.Schedule, func(ctx context.Context) (*BatchResult, error) {
+		return s.runJob(ctx, manifest)
+	})
+}
+
+// AddTask plant eine Aufgabe ohne Manifest ein, z.B. Wartung. Sperre, Status und Events
+// funktionieren wie bei Jobs.
+func (s *JobScheduler) AddTask(name, schedule string, task func() error) error {
+	return s.add(name, schedule, func(context.Context) (*BatchResult, error) {
+		return nil, task()
+	})
+}
+
+func (s *JobScheduler) add(name, spec string, run func(ctx context.Context) (*BatchResult, error)) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.name == name {
+			return fmt.Errorf("job %s already scheduled", name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{
+		name:     name,
+		run:      run,
+		schedule: schedule,
+		status: JobStatus{
+			Name:     name,
+			Schedule: spec,
+			NextRun:  schedule.Next(s.now()),
+		},
+	})
+	return nil
+}
+
+// Status liefert den Stand aller geplanten Jobs.
+func (s *JobScheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		result = append(result, job.status)
+	}
+	return result
+}
+
+// Start führt fällige Jobs aus, bis ctx endet. Laufende Jobs werden dann abgebrochen;
+// Start wartet, bis sie beendet sind.
+func (s *JobScheduler) Start(ctx context.Context) {
+	defer s.wg.Wait()
+	for {
+		wait := s.runDue(ctx)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// runDue startet alle fälligen Jobs und liefert die Zeit bis zum nächsten Termin.
+func (s *JobScheduler) runDue(ctx context.Context) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	wait := time.Minute
+	for _, job := range s.jobs {
+		if job.status.NextRun.IsZero() {
+			continue // Zeitplan ohne weiteren Termin
+		}
+		if !job.status.NextRun.After(now) {
+			job.status.NextRun = job.schedule.Next(now)
+			if job.status.Running {
+				emitEvent(s.Hooks, Event{Type: EventJobSkipped, Job: job.name, Fields: map[string]any{"reason": "still running"}})
+			} else {
+				job.status.Running = true
+				s.wg.Add(1)
+				go s.execute(ctx, job)
+			}
+		}
+		if d := job.status.NextRun.Sub(now); !job.status.NextRun.IsZero() && d < wait {
+			wait = d
+		}
+	}
+	return max(wait, time.Second)
+}
+
+func (s *JobScheduler) execute(ctx context.Context, job *scheduledJob) {
+	defer s.wg.Done()
+	name := job.name
+
+	lockPath := filepath.Join(s.LockDir, unsafeFileNameRe.ReplaceAllString(name, "_")+".lock")
+	lock, err := acquireFileLock(lockPath, s.LockTTL)
+	if err != nil {
+		s.mu.Lock()
+		job.status.Running = false
+		s.mu.Unlock()
+		emitEvent(s.Hooks, Event{Type: EventJobSkipped, Job: name, Err: err})
+		return
+	}
+	defer lock.release()
+	if s.LockTTL > 0 {
+		// Jobs dürfen länger laufen als LockTTL, ohne dass eine andere Instanz übernimmt
+		defer lock.refresh(s.LockTTL / 3)()
+	}
+
+	s.mu.Lock()
+	job.status.LastStart = s.now()
+	s.mu.Unlock()
+	emitEvent(s.Hooks, Event{Type: EventJobStarted, Job: name})
+
+	result, err := s.safeRun(ctx, job)
+
+	s.mu.Lock()
+	job.status.Running = false
+	job.status.LastEnd = s.now()
+	job.status.Runs++
+	job.status.LastError = ""
+	if err != nil {
+		job.status.Failures++
+		job.status.LastError = err.Error()
+	}
+	duration := job.status.LastEnd.Sub(job.status.LastStart)
+	s.mu.Unlock()
+
+	fields := map[string]any{"duration": duration.String()}
+	if result != nil {
+		fields["converted"] = result.Converted
+		fields["failed"] = result.Failed
+		fields["skipped"] = result.Skipped
+		fields["cost"] = result.TotalCost
+	}
+	if err == nil && result != nil && result.Failed > 0 {
+		err = fmt.Errorf("%d of %d documents failed", result.Failed, len(result.Documents))
+	}
+	if err != nil {
+		emitEvent(s.Hooks, Event{Type: EventJobFailed, Job: name, Err: err, Fields: fields})
+		return
+	}
+	emitEvent(s.Hooks, Event{Type: EventJobFinished, Job: name, Fields: fields})
+}
+
+func (s *JobScheduler) safeRun(ctx context.Context, job *scheduledJob) (result *BatchResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = log.WrapError(fmt.Errorf("job %s panicked: %v", job.name, r))
+		}
+	}()
+	return job.run(ctx)
+}
@@ -0,0 +1,157 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2025, 3, 14, 10, 17, 30, 0, time.UTC) // Freitag
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2025, 3, 15, 2, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2025, 3, 17, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2025, 3, 16, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		require.NoError(t, err, tt.spec)
+		require.Equal(t, tt.next, s.Next(base), tt.spec)
+	}
+
+	for _, spec := range []string{"", "* * *", "60 * * * *", "*/0 * * * *", "@every nope", "0 0 30 2 *"} {
+		_, err := ParseSchedule(spec)
+		require.Error(t, err, spec)
+	}
+	_, err := ParseSchedule("0 0 29 2 *")
+	require.NoError(t, err)
+}
+
+// neverSchedule hat keinen weiteren Termin.
+type neverSchedule struct{}
+
+func (neverSchedule) Next(time.Time) time.Time { return time.Time{} }
+
+func TestJobScheduler_NeverRestartsWithoutNextRun(t *testing.T) {
+	s := NewJobScheduler(t.TempDir())
+	s.Hooks = nil
+	var runs atomic.Int32
+	s.jobs = []*scheduledJob{{
+		name:     "once",
+		schedule: neverSchedule{},
+		run: func(context.Context) (*BatchResult, error) {
+			runs.Add(1)
+			return nil, nil
+		},
+		status: JobStatus{Name: "once", NextRun: s.now()},
+	}}
+	for range 3 {
+		require.Equal(t, time.Minute, s.runDue(context.Background()))
+		s.wg.Wait()
+	}
+	require.EqualValues(t, 1, runs.Load())
+	require.True(t, s.Status()[0].NextRun.IsZero())
+}
+
+func TestJobScheduler_RunsDueJobsWithLock(t *testing.T) {
+	lockDir := t.TempDir()
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	var events []EventType
+	s := NewJobScheduler(lockDir)
+	s.now = func() time.Time { return now }
+	s.Hooks = []EventHook{func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev.Type)
+	}}
+	s.runJob = func(ctx context.Context, m *JobManifest) (*BatchResult, error) {
+		if m.Name == "failing" {
+			return nil, errors.New("boom")
+		}
+		return &BatchResult{}, nil
+	}
+
+	require.NoError(t, s.Add(&JobManifest{Name: "ok", Schedule: "@every 1m"}))
+	require.NoError(t, s.Add(&JobManifest{Name: "failing", Schedule: "@every 1m"}))
+	require.NoError(t, s.Add(&JobManifest{Name: "locked", Schedule: "@every 1m"}))
+	require.Error(t, s.Add(&JobManifest{Name: "ok", Schedule: "@every 1m"}))
+
+	// andere Instanz hält den Job "locked"
+	require.NoError(t, os.WriteFile(filepath.Join(lockDir, "locked.lock"), nil, 0644))
+
+	now = now.Add(time.Minute)
+	s.runDue(context.Background())
+	s.wg.Wait()
+
+	status := map[string]JobStatus{}
+	for _, st := range s.Status() {
+		status[st.Name] = st
+	}
+	require.Equal(t, 1, status["ok"].Runs)
+	require.Equal(t, 1, status["failing"].Failures)
+	require.Equal(t, "boom", status["failing"].LastError)
+	require.Zero(t, status["locked"].Runs)
+	require.Equal(t, now.Add(time.Minute), status["ok"].NextRun)
+
+	require.ElementsMatch(t, []EventType{
+		EventJobStarted, EventJobFinished,
+		EventJobStarted, EventJobFailed,
+		EventJobSkipped,
+	}, events)
+	require.NoFileExists(t, filepath.Join(lockDir, "ok.lock"))
+}
+
+func TestJobScheduler_CancelsRunningJobsAndKeepsLock(t *testing.T) {
+	lockDir := t.TempDir()
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	s := NewJobScheduler(lockDir)
+	s.LockTTL = 60 * time.Millisecond
+	s.now = func() time.Time { return now }
+	started := make(chan struct{})
+	s.runJob = func(ctx context.Context, m *JobManifest) (*BatchResult, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	require.NoError(t, s.Add(&JobManifest{Name: "long", Schedule: "@every 1m"}))
+	now = now.Add(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	<-started
+
+	// länger als LockTTL: die Sperre wird erneuert und nicht übernommen
+	time.Sleep(3 * s.LockTTL)
+	_, err := acquireFileLock(filepath.Join(lockDir, "long.lock"), s.LockTTL)
+	require.ErrorIs(t, err, ErrLocked)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after cancel")
+	}
+	require.Equal(t, context.Canceled.Error(), s.Status()[0].LastError)
+	require.NoFileExists(t, filepath.Join(lockDir, "long.lock"))
+}
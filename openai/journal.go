@@ -0,0 +1,235 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// JournalStatus ist der Bearbeitungsstand eines vorgemerkten Aufrufs.
+type JournalStatus string
+
+const (
+	JournalQueued JournalStatus = "queued"
+	JournalDone   JournalStatus = "done"
+	JournalFailed JournalStatus = "failed"
+)
+
+// JournalEntry beschreibt einen vorgemerkten Aufruf mit allem, was zur Wiederholung nötig ist.
+type JournalEntry struct {
+	ID            string           `json:"id"`
+	SystemMessage string           `json:"systemMessage,omitempty"`
+	Prompt        string           `json:"prompt,omitempty"`
+	FileName      string           `json:"fileName,omitempty"`  // PDF, falls GenerateContentWithPDF
+	InputHash     string           `json:"inputHash,omitempty"` // Eingaben, aus denen Result entstand, siehe Enqueue
+	Profile       *DocumentProfile `json:"profile,omitempty"`   // Ergebnis des Vorlaufs, siehe DocumentProfiler
+	Status        JournalStatus    `json:"status"`
+	Result        string           `json:"result,omitempty"` // Antwort, sobald Status done
+	Error         string           `json:"error,omitempty"`
+	UpdatedAt     time.Time        `json:"updatedAt"`
+}
+
+// Journal protokolliert vorgemerkte Aufrufe und deren Ergebnisse als JSON-Lines-Datei.
+// Jede Änderung wird angehängt und sofort auf die Platte geschrieben; beim Öffnen gilt
+// der jeweils letzte Eintrag einer ID. So kann ein abgestürzter Worker genau dort
+// weitermachen, wo er aufgehört hat, ohne erledigte Aufrufe erneut zu bezahlen.
+type Journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]*JournalEntry
+	order   []string
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{entries: map[string]*JournalEntry{}}
+
+	if data, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(data)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var entry JournalEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				// abgeschnittene letzte Zeile nach Absturz ignorieren
+				log.Warn("skipping corrupt journal line in %s: %v", path, err)
+				continue
+			}
+			j.apply(entry)
+		}
+		data.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, log.WrapError(err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, log.WrapError(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	terminateLastLine(file)
+	j.file = file
+	return j, nil
+}
+
+// Enqueue merkt einen Aufruf vor. Ist die ID bereits bekannt, bleibt der vorhandene Eintrag
+// unverändert und wird zurückgegeben. Unterscheidet sich InputHash, z.B. weil Datei oder
+// Prompt geändert wurden, ersetzt entry den alten Eintrag samt Ergebnis.
+func (j *Journal) Enqueue(entry JournalEntry) (JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if existing, ok := j.entries[entry.ID]; ok {
+		if entry.InputHash == "" || existing.InputHash == entry.InputHash {
+			return *existing, nil
+		}
+		log.Info("inputs of journal entry %s changed, converting again", entry.ID)
+	}
+	entry.Status = JournalQueued
+	entry.Result = ""
+	entry.Error = ""
+	return entry, j.write(entry)
+}
+
+// MarkDone speichert das Ergebnis eines Aufrufs.
+func (j *Journal) MarkDone(id, result string) error {
+	return j.update(id, func(entry *JournalEntry) {
+		entry.Status = JournalDone
+		entry.Result = result
+		entry.Error = ""
+	})
+}
+
+// SetProfile speichert das Profil des Dokuments, damit ein fortgesetzter Lauf den Vorlauf
+// nicht erneut bezahlt.
+func (j *Journal) SetProfile(id string, profile DocumentProfile) error {
+	return j.update(id, func(entry *JournalEntry) {
+		entry.Profile = &profile
+	})
+}
+
+// MarkFailed vermerkt einen Fehler; der Eintrag gilt weiterhin als offen.
+func (j *Journal) MarkFailed(id string, cause error) error {
+	return j.update(id, func(entry *JournalEntry) {
+		entry.Status = JournalFailed
+		if cause != nil {
+			entry.Error = cause.Error()
+		}
+	})
+}
+
+// Get liefert den aktuellen Stand eines Eintrags.
+func (j *Journal) Get(id string) (JournalEntry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry, ok := j.entries[id]
+	if !ok {
+		return JournalEntry{}, false
+	}
+	return *entry, true
+}
+
+// Pending liefert alle noch nicht erledigten Einträge in der Reihenfolge ihrer Erfassung.
+func (j *Journal) Pending() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	result := []JournalEntry{}
+	for _, id := range j.order {
+		if entry := j.entries[id]; entry.Status != JournalDone {
+			result = append(result, *entry)
+		}
+	}
+	return result
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+func (j *Journal) update(id string, fn func(entry *JournalEntry)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	existing, ok := j.entries[id]
+	if !ok {
+		return fmt.Errorf("unknown journal entry: %s", id)
+	}
+	entry := *existing
+	fn(&entry)
+	return j.write(entry)
+}
+
+// write hängt den Eintrag an die Datei an. Aufruf nur mit gehaltenem Lock.
+func (j *Journal) write(entry JournalEntry) error {
+	if j.file == nil {
+		return fmt.Errorf("journal is closed")
+	}
+	entry.UpdatedAt = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return log.WrapError(err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return log.WrapError(err)
+	}
+	j.apply(entry)
+	return nil
+}
+
+func (j *Journal) apply(entry JournalEntry) {
+	if _, ok := j.entries[entry.ID]; !ok {
+		j.order = append(j.order, entry.ID)
+	}
+	j.entries[entry.ID] = &entry
+}
+
+// ResumeJournal arbeitet alle offenen Einträge des Journals ab.
+func (ai *AiCommunicationService) ResumeJournal(j *Journal, opts ...RequestOption) error {
+	return ai.ResumeJournalContext(context.Background(), j, opts...)
+}
+
+// ResumeJournalContext wie ResumeJournal, aber abbrechbar über ctx; offene Einträge bleiben
+// dann für den nächsten Aufruf stehen.
+func (ai *AiCommunicationService) ResumeJournalContext(ctx context.Context, j *Journal, opts ...RequestOption) error {
+	for _, entry := range j.Pending() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entryOpts := append([]RequestOption{WithPrompt(entry.Prompt)}, opts...)
+		var content string
+		var err error
+		if entry.FileName != "" {
+			content, err = ai.GenerateContentWithPDFContext(ctx, entry.SystemMessage, entry.FileName, entryOpts...)
+		} else {
+			content, err = ai.GenerateContentContext(ctx, entry.SystemMessage, entryOpts...)
+		}
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			if err1 := j.MarkFailed(entry.ID, err); err1 != nil {
+				return log.WrapError(err1)
+			}
+			return log.WrapError(err)
+		}
+		if err := j.MarkDone(entry.ID, content); err != nil {
+			return log.WrapError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	_, err = j.Enqueue(JournalEntry{ID: "a.pdf", FileName: "in/a.pdf"})
+	require.NoError(t, err)
+	_, err = j.Enqueue(JournalEntry{ID: "b.pdf", FileName: "in/b.pdf"})
+	require.NoError(t, err)
+	require.NoError(t, j.MarkDone("a.pdf", `{"ok":true}`))
+	require.NoError(t, j.MarkFailed("b.pdf", errors.New("boom")))
+	require.NoError(t, j.Close())
+
+	// abgeschnittene Zeile wie nach einem Absturz
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	f.WriteString(`{"id":"c.pdf","sta`)
+	f.Close()
+
+	j, err = OpenJournal(path)
+	require.NoError(t, err)
+
+	a, ok := j.Get("a.pdf")
+	require.True(t, ok)
+	require.Equal(t, JournalDone, a.Status)
+	require.Equal(t, `{"ok":true}`, a.Result)
+
+	pending := j.Pending()
+	require.Len(t, pending, 1)
+	require.Equal(t, "b.pdf", pending[0].ID)
+	require.Equal(t, "boom", pending[0].Error)
+
+	entry, err := j.Enqueue(JournalEntry{ID: "a.pdf"})
+	require.NoError(t, err)
+	require.Equal(t, JournalDone, entry.Status)
+
+	// Einträge nach der abgeschnittenen Zeile überstehen das nächste Öffnen
+	require.NoError(t, j.MarkDone("b.pdf", `{"b":true}`))
+	require.NoError(t, j.Close())
+	j, err = OpenJournal(path)
+	require.NoError(t, err)
+	b, ok := j.Get("b.pdf")
+	require.True(t, ok)
+	require.Equal(t, JournalDone, b.Status)
+	require.Empty(t, j.Pending())
+	require.NoError(t, j.Close())
+}
+
+func TestResumeJournalContext(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	require.NoError(t, err)
+	defer j.Close()
+	_, err = j.Enqueue(JournalEntry{ID: "a", SystemMessage: "system"})
+	require.NoError(t, err)
+	_, err = j.Enqueue(JournalEntry{ID: "b", SystemMessage: "system"})
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+
+	// abgebrochen: nichts wird gesendet, die Einträge bleiben offen statt fehlgeschlagen
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, ai.ResumeJournalContext(ctx, j), context.Canceled)
+	require.Zero(t, calls.Load())
+	pending := j.Pending()
+	require.Len(t, pending, 2)
+	require.Empty(t, pending[0].Error)
+
+	require.NoError(t, ai.ResumeJournalContext(context.Background(), j))
+	require.EqualValues(t, 2, calls.Load())
+	require.Empty(t, j.Pending())
+}
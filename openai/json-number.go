@@ -0,0 +1,327 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// NumberMode legt fest, wie DecodeJSON Zahlen in untypisierten Werten (any, map[string]any,
+// []any) ablegt. Felder vom Typ Decimal oder json.Number werden in jedem Modus exakt gelesen.
+type NumberMode string
+
+const (
+	// NumberFloat64 liest Zahlen als float64 wie encoding/json (Default). Ganzzahlen über
+	// 2^53 und viele Geldbeträge sind dann nicht mehr exakt.
+	NumberFloat64 NumberMode = "float64"
+	// NumberJSON liest Zahlen als json.Number, also unverändert als Text.
+	NumberJSON NumberMode = "json-number"
+	// NumberDecimal liest Zahlen als Decimal, mit denen exakt gerechnet werden kann.
+	NumberDecimal NumberMode = "decimal"
+)
+
+// ErrDivisionByZero meldet eine Division durch null mit Decimal.Div.
+var ErrDivisionByZero = errors.New("decimal division by zero")
+
+// maxDecimalExponent begrenzt Exponenten wie 1e999999, die sonst riesige Zahlen erzeugen.
+const maxDecimalExponent = 1000
+
+// DecodeJSON liest data nach v und behandelt Zahlen in untypisierten Werten gemäß mode
+// (leer = NumberFloat64). Anders als json.Unmarshal werden Daten nach dem ersten Wert
+// abgelehnt.
+func DecodeJSON(data []byte, v any, mode NumberMode) error {
+	switch mode {
+	case "", NumberFloat64, NumberJSON, NumberDecimal:
+	default:
+		return fmt.Errorf("unknown number mode %q", mode)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if mode == NumberJSON || mode == NumberDecimal {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if dec.More() {
+		return fmt.Errorf("invalid JSON: trailing data")
+	}
+	if mode == NumberDecimal {
+		if err := decimalValues(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeData liest das Ergebnis einer Extraction nach v, Zahlen gemäß mode.
+func (e *Extraction) DecodeData(v any, mode NumberMode) error {
+	return DecodeJSON(e.Data, v, mode)
+}
+
+// decimalValues ersetzt in untypisierten Zielen von DecodeJSON alle json.Number durch Decimal.
+func decimalValues(v any) error {
+	switch target := v.(type) {
+	case *any:
+		converted, err := toDecimals(*target)
+		*target = converted
+		return err
+	case *map[string]any:
+		_, err := toDecimals(*target)
+		return err
+	case *[]any:
+		_, err := toDecimals(*target)
+		return err
+	}
+	return nil
+}
+
+func toDecimals(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			converted, err := toDecimals(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = converted
+		}
+	case []any:
+		for i, item := range v {
+			converted, err := toDecimals(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+	case json.Number:
+		return ParseDecimal(v.String())
+	}
+	return v, nil
+}
+
+// Decimal ist eine exakte Dezimalzahl für Geldbeträge und große Ganzzahlen aus Extraktionen.
+// Der Nullwert ist 0. Decimal wird als JSON-Zahl geschrieben und liest Zahlen sowie Zahlen in
+// Anführungszeichen ("1234.56"), wie Modelle sie gelegentlich liefern. Die Nachkommastellen
+// bleiben erhalten: 12.50 bleibt 12.50.
+type Decimal struct {
+	unscaled *big.Int // nil = 0
+	scale    int32    // Anzahl Nachkommastellen, >= 0
+}
+
+// NewDecimal liefert value * 10^-scale, z.B. NewDecimal(1250, 2) = 12.50.
+func NewDecimal(value int64, scale int32) Decimal {
+	d := Decimal{unscaled: big.NewInt(value)}
+	if scale < 0 {
+		d.unscaled.Mul(d.unscaled, pow10(-scale))
+		return d
+	}
+	d.scale = scale
+	return d
+}
+
+// ParseDecimal liest eine Zahl in JSON-Schreibweise, z.B. "-1234.56" oder "1.5e3".
+// Tausendertrennzeichen und andere Formate wandelt NormalizeDecimal um.
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "+"))
+		if err != nil || e > maxDecimalExponent || e < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		mantissa, exp = s[:i], e
+	}
+	negative := strings.HasPrefix(mantissa, "-")
+	mantissa = strings.TrimPrefix(strings.TrimPrefix(mantissa, "-"), "+")
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	scale := len(fracPart) - exp
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}, nil
+}
+
+// MustDecimal wie ParseDecimal, löst aber bei ungültiger Eingabe einen panic aus; für Konstanten.
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// rescale liefert den unskalierten Wert für scale >= d.scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	v := new(big.Int).Set(d.int())
+	if scale > d.scale {
+		v.Mul(v, pow10(scale-d.scale))
+	}
+	return v
+}
+
+// Add liefert d + o.
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := max(d.scale, o.scale)
+	return Decimal{unscaled: new(big.Int).Add(d.rescale(scale), o.rescale(scale)), scale: scale}
+}
+
+// Sub liefert d - o.
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d.Add(o.Neg())
+}
+
+// Mul liefert d * o; die Nachkommastellen addieren sich.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), o.int()), scale: d.scale + o.scale}
+}
+
+// Div liefert d / o, kaufmännisch gerundet auf places Nachkommastellen.
+func (d Decimal) Div(o Decimal, places int32) (Decimal, error) {
+	if o.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+	// d/o = (d.int * 10^(places+1+o.scale)) / (o.int * 10^d.scale), eine Stelle mehr zum Runden
+	num := new(big.Int).Mul(d.int(), pow10(places+1+o.scale))
+	den := new(big.Int).Mul(o.int(), pow10(d.scale))
+	q := new(big.Int).Quo(num, den)
+	return Decimal{unscaled: q, scale: places + 1}.Round(places), nil
+}
+
+// Neg liefert -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Round rundet kaufmännisch (ab 5 vom Nullpunkt weg) auf places Nachkommastellen.
+// Hat d weniger Stellen, werden Nullen ergänzt.
+func (d Decimal) Round(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return Decimal{unscaled: d.rescale(places), scale: places}
+	}
+	divisor := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(d.int(), divisor, new(big.Int))
+	r.Abs(r).Mul(r, big.NewInt(2))
+	if r.Cmp(divisor) >= 0 {
+		q.Add(q, big.NewInt(int64(d.Sign())))
+	}
+	return Decimal{unscaled: q, scale: places}
+}
+
+// trimmed entfernt Nullen am Ende der Nachkommastellen: 12.50 -> 12.5, 3.00 -> 3.
+func (d Decimal) trimmed() Decimal {
+	v, scale := new(big.Int).Set(d.int()), d.scale
+	ten, r := big.NewInt(10), new(big.Int)
+	for scale > 0 {
+		q, _ := new(big.Int).QuoRem(v, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		v, scale = q, scale-1
+	}
+	return Decimal{unscaled: v, scale: scale}
+}
+
+// Cmp vergleicht d mit o: -1, 0 oder +1. 1.5 und 1.50 sind gleich.
+func (d Decimal) Cmp(o Decimal) int {
+	scale := max(d.scale, o.scale)
+	return d.rescale(scale).Cmp(o.rescale(scale))
+}
+
+// Equal meldet, ob d und o denselben Wert haben.
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// Sign liefert -1, 0 oder +1.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero meldet, ob d null ist.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Scale liefert die Anzahl der Nachkommastellen.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Float64 liefert den nächstgelegenen float64, z.B. für Statistiken.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String liefert die Zahl ohne Exponent mit allen Nachkommastellen, z.B. "-1234.50".
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	split := len(digits) - int(d.scale)
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(bytes.TrimSpace(data))
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = strings.TrimSpace(unquoted)
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// SumDecimals addiert values exakt, z.B. die Positionen einer Rechnung.
+func SumDecimals(values ...Decimal) Decimal {
+	var sum Decimal
+	for _, v := range values {
+		sum = sum.Add(v)
+	}
+	return sum
+}
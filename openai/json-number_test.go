@@ -0,0 +1,137 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSON_NumberModes(t *testing.T) {
+	content := []byte(`{"iban": 12345678901234567890, "amount": 1234567890123.45, "items": [0.1, 0.2]}`)
+
+	var f map[string]any
+	require.NoError(t, DecodeJSON(content, &f, ""))
+	require.IsType(t, float64(0), f["amount"])
+
+	var n map[string]any
+	require.NoError(t, DecodeJSON(content, &n, NumberJSON))
+	require.Equal(t, json.Number("12345678901234567890"), n["iban"])
+
+	var d any
+	require.NoError(t, DecodeJSON(content, &d, NumberDecimal))
+	obj := d.(map[string]any)
+	require.Equal(t, "1234567890123.45", obj["amount"].(Decimal).String())
+	items := obj["items"].([]any)
+	require.Equal(t, "0.3", items[0].(Decimal).Add(items[1].(Decimal)).String())
+
+	var typed struct {
+		Amount Decimal     `json:"amount"`
+		IBAN   json.Number `json:"iban"`
+	}
+	require.NoError(t, DecodeJSON(content, &typed, NumberFloat64))
+	require.Equal(t, "1234567890123.45", typed.Amount.String())
+	require.Equal(t, "12345678901234567890", typed.IBAN.String())
+	out, err := json.Marshal(typed)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"amount": 1234567890123.45, "iban": 12345678901234567890}`, string(out))
+
+	require.ErrorContains(t, DecodeJSON([]byte(`{} {}`), &d, NumberJSON), "trailing data")
+	require.ErrorContains(t, DecodeJSON(content, &d, "bigfloat"), "unknown number mode")
+}
+
+func TestExtraction_DecodeData(t *testing.T) {
+	e, err := ParseExtraction(`{"data": {"total": 9007199254740993}, "confidence": {"total": {"score": 0.9}}}`)
+	require.NoError(t, err)
+	var data struct {
+		Total Decimal `json:"total"`
+	}
+	require.NoError(t, e.DecodeData(&data, NumberDecimal))
+	require.Equal(t, "9007199254740993", data.Total.String())
+}
+
+func TestDecimal(t *testing.T) {
+	for in, want := range map[string]string{
+		"12.50":  "12.50",
+		"-0.05":  "-0.05",
+		"1.5e3":  "1500",
+		"25E-4":  "0.0025",
+		"+7":     "7",
+		"000.10": "0.10",
+	} {
+		d, err := ParseDecimal(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, d.String(), in)
+	}
+	for _, in := range []string{"", "-", "1.2.3", "1,5", "1e99999", "abc"} {
+		_, err := ParseDecimal(in)
+		require.Error(t, err, in)
+	}
+
+	require.Equal(t, "0", Decimal{}.String())
+	require.Equal(t, "12.50", NewDecimal(1250, 2).String())
+	require.Equal(t, "1200", NewDecimal(12, -2).String())
+
+	a, b := MustDecimal("0.1"), MustDecimal("0.2")
+	require.True(t, a.Add(b).Equal(MustDecimal("0.30")))
+	require.Equal(t, "-0.1", a.Sub(b).String())
+	require.Equal(t, "22.48875", MustDecimal("19.99").Mul(MustDecimal("1.125")).String())
+	require.Equal(t, "60.60", SumDecimals(MustDecimal("10.10"), MustDecimal("20.20"), MustDecimal("30.30")).String())
+
+	for _, tt := range []struct {
+		in     string
+		places int32
+		want   string
+	}{
+		{"2.345", 2, "2.35"},
+		{"2.344", 2, "2.34"},
+		{"-2.345", 2, "-2.35"},
+		{"0.5", 0, "1"},
+		{"1.2", 3, "1.200"},
+	} {
+		require.Equal(t, tt.want, MustDecimal(tt.in).Round(tt.places).String(), tt.in)
+	}
+
+	q, err := MustDecimal("100").Div(MustDecimal("3"), 2)
+	require.NoError(t, err)
+	require.Equal(t, "33.33", q.String())
+	q, err = MustDecimal("-1").Div(MustDecimal("8"), 2)
+	require.NoError(t, err)
+	require.Equal(t, "-0.13", q.String())
+	_, err = a.Div(Decimal{}, 2)
+	require.ErrorIs(t, err, ErrDivisionByZero)
+
+	require.Equal(t, -1, a.Cmp(b))
+	require.Equal(t, "12.5", MustDecimal("12.500").trimmed().String())
+	require.InDelta(t, 0.1, a.Float64(), 1e-12)
+
+	var quoted Decimal
+	require.NoError(t, json.Unmarshal([]byte(`"1234.56"`), &quoted))
+	require.Equal(t, "1234.56", quoted.String())
+	require.Error(t, json.Unmarshal([]byte(`"12 EUR"`), &quoted))
+}
+
+func TestNormalizeDecimal(t *testing.T) {
+	d, currency, err := NormalizeDecimal("12.345.678.901.234,56 €", LocaleDE)
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234.56", d.String())
+	require.Equal(t, "EUR", currency)
+
+	d, _, err = NormalizeDecimal("1.234,50-", LocaleDE)
+	require.NoError(t, err)
+	require.Equal(t, "-1234.50", d.String())
+
+	_, _, err = NormalizeDecimal("1e5", LocaleEN)
+	require.Error(t, err)
+
+	content, err := ApplyPostProcessors(`{"betrag": "98.765.432.109.876,54"}`, PostProcessGermanNumbers)
+	require.NoError(t, err)
+	require.Equal(t, `{"betrag": 98765432109876.54}`, content)
+}
+
+func TestMergeVotes_KeepsLargeNumbers(t *testing.T) {
+	var result VoteResult
+	content := mergeVotes([]string{`{"id": 12345678901234567891}`, `{"id": 12345678901234567891}`, `{"id": 12345678901234567892}`}, &result)
+	require.Equal(t, `{"id":12345678901234567891}`, content)
+	require.Len(t, result.Disagreements, 1)
+}
@@ -0,0 +1,75 @@
+package openai
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// repairJSON repariert typische Fehler in JSON-Antworten: Kommas vor } oder ] und am Ende
+// abgeschnittene Antworten (offene Zeichenkette, fehlende schließende Klammern). ok ist
+// false, wenn content kein JSON-Objekt oder -Array ist oder sich nicht reparieren lässt.
+func repairJSON(content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	if content == "" || content[0] != '{' && content[0] != '[' {
+		return content, false
+	}
+	if json.Valid([]byte(content)) {
+		return content, true
+	}
+
+	var sb strings.Builder
+	var closers []byte
+	inString, escaped := false, false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			sb.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			if len(closers) > 0 {
+				closers = closers[:len(closers)-1]
+			}
+		case ',':
+			if next := strings.TrimLeft(content[i+1:], " \t\r\n"); next == "" || next[0] == '}' || next[0] == ']' {
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+
+	repaired := sb.String()
+	if inString {
+		if escaped {
+			repaired = repaired[:len(repaired)-1]
+		}
+		repaired += `"`
+	}
+	repaired = strings.TrimRight(repaired, " \t\r\n")
+	repaired = strings.TrimSuffix(repaired, ",")
+	if strings.HasSuffix(repaired, ":") {
+		repaired += "null"
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		repaired += string(closers[i])
+	}
+	if !json.Valid([]byte(repaired)) {
+		return content, false
+	}
+	return repaired, true
+}
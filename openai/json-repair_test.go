@@ -0,0 +1,28 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{`{"a": 1}`, `{"a": 1}`, true},
+		{`{"a": [1, 2,],}`, `{"a": [1, 2]}`, true},
+		{`{"a": {"b": "text`, `{"a": {"b": "text"}}`, true},
+		{`{"a": "x\`, `{"a": "x"}`, true},
+		{`[{"a": 1}, {"b":`, `[{"a": 1}, {"b":null}]`, true},
+		{`{"a": "}, [ bleiben"`, `{"a": "}, [ bleiben"}`, true},
+		{`plain text`, `plain text`, false},
+		{`{"a" 1}`, `{"a" 1}`, false},
+	}
+	for _, tt := range tests {
+		got, ok := repairJSON(tt.in)
+		require.Equal(t, tt.ok, ok, tt.in)
+		require.Equal(t, tt.want, got, tt.in)
+	}
+}
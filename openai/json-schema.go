@@ -0,0 +1,663 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// ErrSchemaViolation meldet eine Antwort, die nicht zum JSON-Schema passt, siehe SchemaError.
+var ErrSchemaViolation = errors.New("response violates JSON schema")
+
+// maxSchemaDepth begrenzt die Verschachtelung bei der Prüfung, z.B. bei zyklischen $ref.
+const maxSchemaDepth = 64
+
+// maxViolationsInError begrenzt die im Fehlertext genannten Verstöße; SchemaError enthält alle.
+const maxViolationsInError = 5
+
+// SchemaViolation ist ein Verstoß gegen das Schema.
+type SchemaViolation struct {
+	Path    string `json:"path"`    // Feldpfad wie "items[0].total", leer = ganzes Ergebnis
+	Keyword string `json:"keyword"` // verletzte Regel, z.B. "required" oder "type"
+	Message string `json:"message"`
+}
+
+func (v SchemaViolation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + v.Message
+}
+
+// SchemaError listet alle Verstöße einer Antwort; errors.Is(err, ErrSchemaViolation) gilt.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	parts := []string{}
+	for i, v := range e.Violations {
+		if i == maxViolationsInError {
+			parts = append(parts, fmt.Sprintf("and %d more", len(e.Violations)-i))
+			break
+		}
+		parts = append(parts, v.String())
+	}
+	return fmt.Sprintf("%v: %s", ErrSchemaViolation, strings.Join(parts, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// JSONSchema prüft Antworten gegen ein JSON-Schema (Draft 7 bis 2020-12). Unterstützt werden
+// type, enum, const, properties, required, additionalProperties, patternProperties, items,
+// prefixItems, min/maxItems, uniqueItems, min/maxLength, pattern, format (date, date-time,
+// email, uuid), minimum, maximum, exclusiveMinimum/-Maximum, multipleOf, allOf, anyOf, oneOf,
+// not, if/then/else und $ref innerhalb des Dokuments sowie auf andere Dateien. Unbekannte
+// Schlüsselwörter werden ignoriert. Zahlen werden exakt verglichen. Ein JSONSchema darf von
+// mehreren Goroutinen genutzt werden.
+type JSONSchema struct {
+	root    any
+	file    string         // absoluter Pfad, leer bei ParseJSONSchema
+	docs    map[string]any // per $ref eingebundene Dateien, nach absolutem Pfad
+	regexps map[string]*regexp.Regexp
+}
+
+// LoadJSONSchema liest ein Schema aus einer Datei. $ref auf andere Dateien werden relativ
+// zu ihr aufgelöst und gleich mitgeladen.
+func LoadJSONSchema(fileName string) (*JSONSchema, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	s := &JSONSchema{file: abs, docs: map[string]any{}, regexps: map[string]*regexp.Regexp{}}
+	if s.root, err = s.load(abs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseJSONSchema liest ein Schema aus data; $ref sind nur innerhalb des Dokuments möglich.
+func ParseJSONSchema(data []byte) (*JSONSchema, error) {
+	s := &JSONSchema{docs: map[string]any{}, regexps: map[string]*regexp.Regexp{}}
+	if err := DecodeJSON(data, &s.root, NumberJSON); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	if err := s.prepare(s.root, ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load liest eine Schemadatei samt der von ihr referenzierten.
+func (s *JSONSchema) load(file string) (any, error) {
+	if doc, ok := s.docs[file]; ok {
+		return doc, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	var doc any
+	if err := DecodeJSON(data, &doc, NumberJSON); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema %s: %w", file, err)
+	}
+	s.docs[file] = doc
+	if err := s.prepare(doc, file); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema %s: %w", file, err)
+	}
+	return doc, nil
+}
+
+// prepare übersetzt alle Muster und lädt referenzierte Dateien, damit die Prüfung selbst
+// nichts mehr verändert. node ist ein Schema oder eine Liste von Schemas; Schlüsselwörter
+// werden nur an Schema-Positionen ausgewertet, nicht z.B. als Eigenschaftsnamen.
+func (s *JSONSchema) prepare(node any, file string) error {
+	switch node := node.(type) {
+	case map[string]any:
+		for key, value := range node {
+			switch key {
+			case "enum", "const", "default", "examples":
+				// Daten, kein Schema
+			case "pattern":
+				if err := s.compile(value); err != nil {
+					return err
+				}
+			case "properties", "patternProperties", "$defs", "definitions", "dependentSchemas":
+				subschemas, ok := value.(map[string]any)
+				if !ok {
+					return fmt.Errorf("%s must be an object", key)
+				}
+				for name, sub := range subschemas {
+					if key == "patternProperties" {
+						if err := s.compile(name); err != nil {
+							return err
+						}
+					}
+					if err := s.prepare(sub, file); err != nil {
+						return err
+					}
+				}
+			case "$ref":
+				ref, ok := value.(string)
+				if !ok {
+					return fmt.Errorf("$ref must be a string")
+				}
+				if target, _, _ := strings.Cut(ref, "#"); target != "" {
+					if file == "" {
+						return fmt.Errorf("cannot resolve $ref %q without schema file", ref)
+					}
+					if _, err := s.load(filepath.Join(filepath.Dir(file), target)); err != nil {
+						return err
+					}
+				}
+			default:
+				if err := s.prepare(value, file); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		for _, item := range node {
+			if err := s.prepare(item, file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *JSONSchema) compile(pattern any) error {
+	p, ok := pattern.(string)
+	if !ok {
+		return fmt.Errorf("pattern must be a string")
+	}
+	if _, ok := s.regexps[p]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	s.regexps[p] = re
+	return nil
+}
+
+// Validate prüft content und liefert alle Verstöße; nil, wenn content passt.
+func (s *JSONSchema) Validate(content string) []SchemaViolation {
+	var value any
+	if err := DecodeJSON([]byte(content), &value, NumberJSON); err != nil {
+		return []SchemaViolation{{Keyword: "json", Message: err.Error()}}
+	}
+	v := schemaValidator{schema: s}
+	v.validate(s.root, s.file, value, "", 0)
+	return v.violations
+}
+
+// Check wie Validate, liefert Verstöße aber als *SchemaError.
+func (s *JSONSchema) Check(content string) error {
+	if violations := s.Validate(content); len(violations) > 0 {
+		return &SchemaError{Violations: violations}
+	}
+	return nil
+}
+
+// fingerprint liefert einen Hash über das Schema samt eingebundener Dateien, z.B. für den
+// Result-Cache.
+func (s *JSONSchema) fingerprint() string {
+	data, err := json.Marshal([]any{s.root, s.docs})
+	if err != nil {
+		return ""
+	}
+	return contentHash(string(data))
+}
+
+// WithSchema prüft jede Antwort dieses Aufrufs gegen schema; bei Verstößen kommt ein
+// *SchemaError. Bei WithFieldConfidence oder WithCitations wird nur das Ergebnis geprüft.
+func WithSchema(schema *JSONSchema) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.schema = schema
+	}
+}
+
+// schemaValidator sammelt die Verstöße einer Prüfung.
+type schemaValidator struct {
+	schema     *JSONSchema
+	violations []SchemaViolation
+}
+
+func (v *schemaValidator) fail(path, keyword, format string, args ...any) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches prüft value gegen schema, ohne Verstöße zu sammeln.
+func (v *schemaValidator) matches(schema any, file string, value any, path string, depth int) bool {
+	sub := schemaValidator{schema: v.schema}
+	sub.validate(schema, file, value, path, depth)
+	return len(sub.violations) == 0
+}
+
+func (v *schemaValidator) validate(schema any, file string, value any, path string, depth int) {
+	if depth > maxSchemaDepth {
+		v.fail(path, "$ref", "schema nesting too deep")
+		return
+	}
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			v.fail(path, "false", "no value allowed")
+		}
+		return
+	case map[string]any:
+		v.validateObjectSchema(schema, file, value, path, depth)
+	}
+}
+
+func (v *schemaValidator) validateObjectSchema(schema map[string]any, file string, value any, path string, depth int) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, targetFile, err := v.schema.resolve(ref, file)
+		if err != nil {
+			v.fail(path, "$ref", "%v", err)
+		} else {
+			v.validate(target, targetFile, value, path, depth+1)
+		}
+	}
+
+	if t, ok := schema["type"]; ok && !typeMatches(t, value) {
+		v.fail(path, "type", "expected %s, got %s", typeNames(t), jsonTypeOf(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || jsonEqual(allowed, value)
+		}
+		if !found {
+			v.fail(path, "enum", "must be one of %s", schemaText(enum))
+		}
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, value) {
+		v.fail(path, "const", "must be %s", schemaText(c))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, file, value, path, depth)
+	case []any:
+		v.validateArray(schema, file, value, path, depth)
+	case string:
+		v.validateString(schema, value, path)
+	case json.Number:
+		v.validateNumber(schema, value, path)
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			v.validate(sub, file, value, path, depth+1)
+		}
+	}
+	if alternatives, ok := schema["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range alternatives {
+			if v.matches(sub, file, value, path, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "anyOf", "does not match any alternative")
+		}
+	}
+	if one, ok := schema["oneOf"].([]any); ok {
+		n := 0
+		for _, sub := range one {
+			if v.matches(sub, file, value, path, depth+1) {
+				n++
+			}
+		}
+		if n != 1 {
+			v.fail(path, "oneOf", "matches %d alternatives, expected exactly one", n)
+		}
+	}
+	if not, ok := schema["not"]; ok && v.matches(not, file, value, path, depth+1) {
+		v.fail(path, "not", "must not match schema")
+	}
+	if cond, ok := schema["if"]; ok {
+		branch := "else"
+		if v.matches(cond, file, value, path, depth+1) {
+			branch = "then"
+		}
+		if sub, ok := schema[branch]; ok {
+			v.validate(sub, file, value, path, depth+1)
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]any, file string, obj map[string]any, path string, depth int) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := obj[name]; !present {
+					v.fail(childPath(path, name), "required", "is required")
+				}
+			}
+		}
+	}
+	props, _ := schema["properties"].(map[string]any)
+	patterns, _ := schema["patternProperties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+	for _, name := range slices.Sorted(maps.Keys(obj)) {
+		value, child := obj[name], childPath(path, name)
+		matched := false
+		if sub, ok := props[name]; ok {
+			matched = true
+			v.validate(sub, file, value, child, depth+1)
+		}
+		for pattern, sub := range patterns {
+			if re := v.schema.regexps[pattern]; re != nil && re.MatchString(name) {
+				matched = true
+				v.validate(sub, file, value, child, depth+1)
+			}
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			v.fail(child, "additionalProperties", "is not allowed")
+		} else if !ok {
+			v.validate(additional, file, value, child, depth+1)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]any, file string, arr []any, path string, depth int) {
+	if n, ok := schemaInt(schema["minItems"]); ok && len(arr) < n {
+		v.fail(path, "minItems", "must have at least %d items, has %d", n, len(arr))
+	}
+	if n, ok := schemaInt(schema["maxItems"]); ok && len(arr) > n {
+		v.fail(path, "maxItems", "must have at most %d items, has %d", n, len(arr))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := range i {
+				if jsonEqual(arr[i], arr[j]) {
+					v.fail(itemPath(path, i), "uniqueItems", "duplicates item %d", j)
+				}
+			}
+		}
+	}
+
+	prefix, _ := schema["prefixItems"].([]any)
+	items, hasItems := schema["items"]
+	if tuple, ok := items.([]any); ok {
+		// Draft 7: items als Liste entspricht prefixItems
+		prefix = tuple
+		items, hasItems = schema["additionalItems"]
+	}
+	for i, item := range arr {
+		switch {
+		case i < len(prefix):
+			v.validate(prefix[i], file, item, itemPath(path, i), depth+1)
+		case hasItems:
+			v.validate(items, file, item, itemPath(path, i), depth+1)
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]any, s, path string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := schemaInt(schema["minLength"]); ok && length < n {
+		v.fail(path, "minLength", "must be at least %d characters, has %d", n, length)
+	}
+	if n, ok := schemaInt(schema["maxLength"]); ok && length > n {
+		v.fail(path, "maxLength", "must be at most %d characters, has %d", n, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok && v.schema.regexps[pattern] != nil && !v.schema.regexps[pattern].MatchString(s) {
+		v.fail(path, "pattern", "must match %s", pattern)
+	}
+	if format, ok := schema["format"].(string); ok && !formatMatches(format, s) {
+		v.fail(path, "format", "must be a valid %s", format)
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]any, n json.Number, path string) {
+	value, err := ParseDecimal(n.String())
+	if err != nil {
+		v.fail(path, "type", "invalid number %s", n)
+		return
+	}
+	bound := func(keyword string) (Decimal, bool) {
+		b, ok := schema[keyword].(json.Number)
+		if !ok {
+			return Decimal{}, false
+		}
+		d, err := ParseDecimal(b.String())
+		return d, err == nil
+	}
+	// Draft 4: exclusiveMinimum/-Maximum als bool an minimum/maximum
+	exclusiveMin, _ := schema["exclusiveMinimum"].(bool)
+	exclusiveMax, _ := schema["exclusiveMaximum"].(bool)
+	if lo, ok := bound("minimum"); ok {
+		if c := value.Cmp(lo); exclusiveMin && c <= 0 {
+			v.fail(path, "minimum", "must be > %s", lo)
+		} else if c < 0 {
+			v.fail(path, "minimum", "must be >= %s", lo)
+		}
+	}
+	if hi, ok := bound("maximum"); ok {
+		if c := value.Cmp(hi); exclusiveMax && c >= 0 {
+			v.fail(path, "maximum", "must be < %s", hi)
+		} else if c > 0 {
+			v.fail(path, "maximum", "must be <= %s", hi)
+		}
+	}
+	if lo, ok := bound("exclusiveMinimum"); ok && value.Cmp(lo) <= 0 {
+		v.fail(path, "exclusiveMinimum", "must be > %s", lo)
+	}
+	if hi, ok := bound("exclusiveMaximum"); ok && value.Cmp(hi) >= 0 {
+		v.fail(path, "exclusiveMaximum", "must be < %s", hi)
+	}
+	if m, ok := bound("multipleOf"); ok && m.Sign() > 0 {
+		q, _ := new(big.Rat).SetString(value.String())
+		d, _ := new(big.Rat).SetString(m.String())
+		if !q.Quo(q, d).IsInt() {
+			v.fail(path, "multipleOf", "must be a multiple of %s", m)
+		}
+	}
+}
+
+// resolve liefert das Ziel von ref, gesehen aus file.
+func (s *JSONSchema) resolve(ref, file string) (any, string, error) {
+	target, pointer, _ := strings.Cut(ref, "#")
+	doc := s.root
+	if file != "" {
+		doc = s.docs[file]
+	}
+	if target != "" {
+		file = filepath.Join(filepath.Dir(file), target)
+		var ok bool
+		if doc, ok = s.docs[file]; !ok {
+			return nil, "", fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	node := doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]any:
+			var ok bool
+			if node, ok = n[token]; !ok {
+				return nil, "", fmt.Errorf("unresolved $ref %q", ref)
+			}
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, "", fmt.Errorf("unresolved $ref %q", ref)
+			}
+			node = n[i]
+		default:
+			return nil, "", fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return node, file, nil
+}
+
+func typeMatches(t any, value any) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonTypeOf(value)
+		if t == "integer" {
+			n, ok := value.(json.Number)
+			if !ok {
+				return false
+			}
+			d, err := ParseDecimal(n.String())
+			return err == nil && d.trimmed().Scale() == 0
+		}
+		return t == actual
+	case []any:
+		for _, item := range t {
+			if typeMatches(item, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func typeNames(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, len(list))
+		for i, item := range list {
+			names[i] = fmt.Sprint(item)
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// jsonEqual vergleicht dekodierte Werte; Zahlen nach Wert, 1.0 = 1.
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		da, errA := ParseDecimal(a.String())
+		db, errB := ParseDecimal(bn.String())
+		return errA == nil && errB == nil && da.Equal(db)
+	case map[string]any:
+		bm, ok := b.(map[string]any)
+		if !ok || len(a) != len(bm) {
+			return false
+		}
+		for k, av := range a {
+			if bv, ok := bm[k]; !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		ba, ok := b.([]any)
+		if !ok || len(a) != len(ba) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], ba[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+var (
+	emailRe = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRe  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// formatMatches prüft die bekannten Formate; unbekannte gelten als erfüllt.
+func formatMatches(format, s string) bool {
+	switch format {
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "email":
+		return emailRe.MatchString(s)
+	case "uuid":
+		return uuidRe.MatchString(s)
+	}
+	return true
+}
+
+func schemaInt(v any) (int, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(n.String())
+	return i, err == nil
+}
+
+func childPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func itemPath(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+func schemaText(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(buf.String())
+}
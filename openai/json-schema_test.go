@@ -0,0 +1,171 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testInvoiceSchema = `{
+  "type": "object",
+  "required": ["number", "date", "total", "items"],
+  "additionalProperties": false,
+  "properties": {
+    "number": {"type": "string", "pattern": "^RE-[0-9]+$"},
+    "date": {"type": "string", "format": "date"},
+    "currency": {"enum": ["EUR", "USD"]},
+    "total": {"type": "number", "minimum": 0, "multipleOf": 0.01},
+    "note": {"type": ["string", "null"], "maxLength": 10},
+    "items": {
+      "type": "array",
+      "minItems": 1,
+      "items": {"$ref": "#/$defs/item"}
+    }
+  },
+  "$defs": {
+    "item": {
+      "type": "object",
+      "required": ["qty"],
+      "properties": {"qty": {"type": "integer", "exclusiveMinimum": 0}}
+    }
+  }
+}`
+
+func TestJSONSchema_Validate(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(testInvoiceSchema))
+	require.NoError(t, err)
+
+	require.Empty(t, schema.Validate(`{"number": "RE-1", "date": "2024-12-31", "currency": "EUR", "total": 98765432109876.54, "note": null, "items": [{"qty": 2.0}]}`))
+
+	violations := schema.Validate(`{"number": "R1", "date": "31.12.2024", "currency": "CHF", "total": 1.005, "note": "viel zu lang", "items": [{"qty": 0}, {"qty": 1.5}, {}], "extra": 1}`)
+	require.Equal(t, []SchemaViolation{
+		{Path: "currency", Keyword: "enum", Message: `must be one of ["EUR","USD"]`},
+		{Path: "date", Keyword: "format", Message: "must be a valid date"},
+		{Path: "extra", Keyword: "additionalProperties", Message: "is not allowed"},
+		{Path: "items[0].qty", Keyword: "exclusiveMinimum", Message: "must be > 0"},
+		{Path: "items[1].qty", Keyword: "type", Message: "expected integer, got number"},
+		{Path: "items[2].qty", Keyword: "required", Message: "is required"},
+		{Path: "note", Keyword: "maxLength", Message: "must be at most 10 characters, has 12"},
+		{Path: "number", Keyword: "pattern", Message: "must match ^RE-[0-9]+$"},
+		{Path: "total", Keyword: "multipleOf", Message: "must be a multiple of 0.01"},
+	}, violations)
+
+	err = schema.Check(`{"items": []}`)
+	require.ErrorIs(t, err, ErrSchemaViolation)
+	var schemaErr *SchemaError
+	require.True(t, errors.As(err, &schemaErr))
+	require.Len(t, schemaErr.Violations, 4)
+	require.Contains(t, err.Error(), "number: is required")
+
+	require.Equal(t, []SchemaViolation{{Keyword: "type", Message: "expected object, got array"}}, schema.Validate(`[]`))
+	require.Equal(t, "json", schema.Validate(`{`)[0].Keyword)
+}
+
+func TestJSONSchema_Combinators(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{
+  "oneOf": [
+    {"type": "object", "required": ["iban"]},
+    {"type": "object", "required": ["konto", "blz"]}
+  ],
+  "not": {"required": ["bar"]},
+  "if": {"properties": {"land": {"const": "DE"}}},
+  "then": {"properties": {"iban": {"pattern": "^DE"}}}
+}`))
+	require.NoError(t, err)
+	require.Empty(t, schema.Validate(`{"iban": "DE89", "land": "DE"}`))
+	require.Empty(t, schema.Validate(`{"konto": "1", "blz": "2"}`))
+	require.Equal(t, "oneOf", schema.Validate(`{"konto": "1"}`)[0].Keyword)
+	require.Equal(t, "oneOf", schema.Validate(`{"iban": "AT1", "konto": "1", "blz": "2"}`)[0].Keyword)
+	require.Equal(t, "not", schema.Validate(`{"iban": "AT1", "bar": true}`)[0].Keyword)
+	require.Equal(t, []SchemaViolation{{Path: "iban", Keyword: "pattern", Message: "must match ^DE"}}, schema.Validate(`{"iban": "AT1", "land": "DE"}`))
+}
+
+func TestJSONSchema_KeywordsAsPropertyNames(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{"properties": {"pattern": {"type": "string"}}}`))
+	require.NoError(t, err)
+	require.Empty(t, schema.Validate(`{"pattern": "^a"}`))
+
+	schema, err = ParseJSONSchema([]byte(`{"properties": {"enum": {"type": "string", "pattern": "^a"}}, "$defs": {"default": {"pattern": "^b"}}}`))
+	require.NoError(t, err)
+	require.Empty(t, schema.Validate(`{"enum": "abc"}`))
+	require.Equal(t, []SchemaViolation{{Path: "enum", Keyword: "pattern", Message: "must match ^a"}}, schema.Validate(`{"enum": "xyz"}`))
+
+	_, err = ParseJSONSchema([]byte(`{"properties": {"enum": {"pattern": "("}}}`))
+	require.ErrorContains(t, err, "invalid pattern")
+}
+
+func TestLoadJSONSchema_ExternalRef(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "common"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "common", "address.json"), []byte(`{
+  "$defs": {"address": {"type": "object", "required": ["zip"], "properties": {"zip": {"$ref": "#/$defs/zip"}}},
+            "zip": {"type": "string", "pattern": "^[0-9]{5}$"}}
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invoice.json"), []byte(`{
+  "type": "object",
+  "properties": {"supplier": {"$ref": "common/address.json#/$defs/address"}}
+}`), 0644))
+
+	schema, err := LoadJSONSchema(filepath.Join(dir, "invoice.json"))
+	require.NoError(t, err)
+	require.Empty(t, schema.Validate(`{"supplier": {"zip": "01067"}}`))
+	require.Equal(t, []SchemaViolation{{Path: "supplier.zip", Keyword: "pattern", Message: "must match ^[0-9]{5}$"}}, schema.Validate(`{"supplier": {"zip": "1067"}}`))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"$ref": "missing.json"}`), 0644))
+	_, err = LoadJSONSchema(filepath.Join(dir, "broken.json"))
+	require.Error(t, err)
+
+	_, err = ParseJSONSchema([]byte(`{"pattern": "("}`))
+	require.ErrorContains(t, err, "invalid pattern")
+	_, err = ParseJSONSchema([]byte(`{"$ref": "other.json"}`))
+	require.ErrorContains(t, err, "without schema file")
+}
+
+func TestBatchConverter_Schema(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF), 0644))
+	}
+	answers := []string{`{"number": "RE-1", "date": "2024-01-31", "total": 10, "items": [{"qty": 1}]}`, `{"number": "1", "total": -1, "items": [{"qty": 1}]}`}
+	calls := 0
+	bc := NewBatchConverter(newBatchTestService(t, func() string {
+		calls++
+		return answers[(calls-1)%2]
+	}), "system", src, t.TempDir())
+	bc.ContinueOnError = true
+	var err error
+	bc.Schema, err = ParseJSONSchema([]byte(testInvoiceSchema))
+	require.NoError(t, err)
+
+	result, err := bc.RunContext(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Converted)
+	require.Equal(t, 1, result.Failed)
+	failed := result.Documents[1]
+	require.Equal(t, DocumentFailed, failed.Status)
+	require.Equal(t, []SchemaViolation{
+		{Path: "date", Keyword: "required", Message: "is required"},
+		{Path: "number", Keyword: "pattern", Message: "must match ^RE-[0-9]+$"},
+		{Path: "total", Keyword: "minimum", Message: "must be >= 0"},
+	}, failed.Violations)
+}
+
+func TestLoadJobManifest_ValidateSchema(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invoice.json"), []byte(testInvoiceSchema), 0644))
+	path := filepath.Join(dir, "job.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\nschemaFile: invoice.json\nvalidateSchema: true\n"), 0644))
+	m, err := LoadJobManifest(path)
+	require.NoError(t, err)
+	bc, err := m.NewBatchConverter(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, bc.Schema)
+
+	require.NoError(t, os.WriteFile(path, []byte("name: x\ninput:\n  folder: in\noutput:\n  folder: out\nvalidateSchema: true\n"), 0644))
+	_, err = LoadJobManifest(path)
+	require.ErrorContains(t, err, "validateSchema requires schemaFile")
+}
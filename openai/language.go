@@ -0,0 +1,162 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// ErrLanguageMismatch meldet, dass die Antwort trotz erneuter Anfrage in einer anderen
+// Sprache als gefordert vorliegt.
+var ErrLanguageMismatch = errors.New("response language mismatch")
+
+// minLanguageHits ist die Mindestzahl erkannter Funktionswörter für ein Ergebnis; kürzere
+// Texte (Namen, Beträge, Kennzeichen) gelten als sprachneutral.
+const minLanguageHits = 3
+
+// LanguagePolicy prüft die Sprache der Antwort. Geprüft werden nur die Textwerte, nicht
+// die Schlüssel des JSON.
+type LanguagePolicy struct {
+	Language string // ISO 639-1, z.B. "de"
+	// Retries ist die Zahl erneuter Anfragen mit ausdrücklicher Sprachvorgabe, wenn die
+	// Sprache nicht passt. Default: 1, negativ = nur prüfen und ErrLanguageMismatch melden.
+	Retries int
+}
+
+// WithLanguage prüft für diesen Aufruf die Sprache der Antwort und ersetzt die Policy des Services.
+func WithLanguage(p LanguagePolicy) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.language = &p
+	}
+}
+
+// languageNames enthält die erkennbaren Sprachen mit ihrem Namen für die Anweisung.
+var languageNames = map[string]string{
+	"de": "Deutsch",
+	"en": "Englisch",
+	"fr": "Französisch",
+	"es": "Spanisch",
+	"it": "Italienisch",
+	"nl": "Niederländisch",
+}
+
+// languageStopwords sind häufige Funktionswörter, die möglichst nur in einer Sprache vorkommen.
+var languageStopwords = map[string][]string{
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "für", "auf", "dem", "den", "des", "ein", "eine", "einer", "sich", "auch", "wird", "werden", "wurde", "oder", "bei", "nach", "über", "zum", "zur", "durch", "sind"},
+	"en": {"the", "and", "is", "are", "of", "with", "for", "on", "this", "that", "was", "were", "be", "been", "has", "have", "from", "by", "which", "not", "or", "an", "it", "its"},
+	"fr": {"le", "la", "les", "et", "est", "des", "du", "une", "pour", "dans", "sur", "avec", "pas", "qui", "que", "sont", "au", "aux", "ce", "cette"},
+	"es": {"el", "los", "las", "y", "es", "del", "una", "para", "con", "por", "que", "como", "son", "pero", "su", "sus", "está", "fue"},
+	"it": {"il", "gli", "e", "è", "della", "delle", "di", "per", "con", "che", "non", "sono", "una", "nel", "alla", "anche"},
+	"nl": {"de", "het", "een", "en", "is", "van", "voor", "met", "op", "niet", "zijn", "dat", "wordt", "werd", "ook", "bij", "naar"},
+}
+
+var stopwordLanguages = func() map[string][]string {
+	m := map[string][]string{}
+	for lang, words := range languageStopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// DetectLanguage schätzt die Sprache eines Textes anhand häufiger Funktionswörter und
+// liefert den ISO-639-1-Code sowie den Anteil der Treffer, der auf diese Sprache fällt.
+// Ist der Text zu kurz für eine Aussage, ist der Code leer.
+func DetectLanguage(text string) (string, float64) {
+	hits := map[string]int{}
+	total := 0
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		langs := stopwordLanguages[w]
+		for _, lang := range langs {
+			hits[lang]++
+		}
+		if len(langs) > 0 {
+			total++
+		}
+	}
+	best, bestHits := "", 0
+	for lang, n := range hits {
+		if n > bestHits || n == bestHits && lang < best {
+			best, bestHits = lang, n
+		}
+	}
+	if bestHits < minLanguageHits {
+		return "", 0
+	}
+	return best, float64(bestHits) / float64(total)
+}
+
+// responseText sammelt die Textwerte einer Antwort. Bei Extraktion zählen nur die Daten,
+// weil Fundstellen in der Sprache des Dokuments zitiert werden.
+func responseText(content string, cfg requestConfig) string {
+	if cfg.extraction() {
+		if extraction, err := ParseExtraction(content); err == nil {
+			content = string(extraction.Data)
+		}
+	}
+	var v any
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return content
+	}
+	var sb strings.Builder
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			sb.WriteString(v)
+			sb.WriteString("\n")
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]any:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(v)
+	return sb.String()
+}
+
+// languageRequest prüft die Sprache der Antwort und fragt bei Abweichung mit ausdrücklicher
+// Sprachvorgabe erneut an.
+func (ai *AiCommunicationService) languageRequest(request requestFunc) requestFunc {
+	return func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+		p := cfg.language
+		retries := p.Retries
+		if retries == 0 {
+			retries = 1
+		}
+		name := languageNames[p.Language]
+		if name == "" {
+			name = p.Language
+		}
+		for attempt := 0; ; attempt++ {
+			content, err := request(ctx, systemMessage, f, cfg)
+			if err != nil {
+				return "", err
+			}
+			detected, _ := DetectLanguage(responseText(content, cfg))
+			if detected == "" || detected == p.Language {
+				return content, nil
+			}
+			if attempt >= retries {
+				return "", fmt.Errorf("%w: expected %s, got %s", ErrLanguageMismatch, p.Language, detected)
+			}
+			log.Warn("response language is %s instead of %s, asking again", detected, p.Language)
+			if attempt == 0 {
+				systemMessage += fmt.Sprintf("\n\nAntworte ausschließlich auf %s. Alle Textwerte müssen auf %s sein, auch wenn das Dokument in einer anderen Sprache verfasst ist.", name, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	lang, confidence := DetectLanguage("Die Rechnung ist nicht bezahlt und wird an den Kunden geschickt.")
+	require.Equal(t, "de", lang)
+	require.Greater(t, confidence, 0.5)
+
+	lang, _ = DetectLanguage("The invoice has not been paid and is sent to the customer.")
+	require.Equal(t, "en", lang)
+
+	lang, _ = DetectLanguage("ACME GmbH, 1.234,56 EUR, DE123456789")
+	require.Empty(t, lang)
+}
+
+func TestGenerateContent_LanguageRetry(t *testing.T) {
+	answers := []string{
+		`{"summary": "The invoice is overdue and the customer has not paid the amount."}`,
+		`{"summary": "Die Rechnung ist überfällig und der Kunde hat den Betrag nicht bezahlt."}`,
+	}
+	var systemMessages []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		systemMessages = append(systemMessages, body.Messages[0].Content)
+		content, _ := json.Marshal(answers[len(systemMessages)-1])
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, string(content), 1)))
+	})
+
+	content, err := ai.GenerateContent("Fasse die Rechnung zusammen.", WithLanguage(LanguagePolicy{Language: "de"}))
+	require.NoError(t, err)
+	require.Contains(t, content, "überfällig")
+	require.Len(t, systemMessages, 2)
+	require.Contains(t, systemMessages[1], "ausschließlich auf Deutsch")
+
+	systemMessages = nil
+	_, err = ai.GenerateContent("Fasse die Rechnung zusammen.", WithLanguage(LanguagePolicy{Language: "de", Retries: -1}))
+	require.ErrorIs(t, err, ErrLanguageMismatch)
+	require.Len(t, systemMessages, 1)
+}
@@ -0,0 +1,262 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// Message ist eine empfangene Nachricht. Ack bestätigt die Verarbeitung, Nack gibt sie
+// zur erneuten Zustellung frei; beide sind optional.
+type Message struct {
+	Key  string
+	Data []byte
+	Ack  func() error
+	Nack func() error
+}
+
+// MessageSource liefert Nachrichten aus einem Topic bzw. Subject. Receive blockiert,
+// bis eine Nachricht vorliegt oder ctx endet.
+type MessageSource interface {
+	Receive(ctx context.Context) (*Message, error)
+}
+
+// MessagePublisher veröffentlicht Nachrichten in einem Topic bzw. Subject.
+type MessagePublisher interface {
+	Publish(ctx context.Context, key string, data []byte) error
+}
+
+// MessageSourceFunc macht aus einer Funktion eine MessageSource, z.B. um einen
+// kafka-go Reader (FetchMessage/CommitMessages) anzubinden.
+type MessageSourceFunc func(ctx context.Context) (*Message, error)
+
+func (f MessageSourceFunc) Receive(ctx context.Context) (*Message, error) {
+	return f(ctx)
+}
+
+// MessagePublisherFunc macht aus einer Funktion einen MessagePublisher, z.B. um einen
+// kafka-go Writer (WriteMessages) anzubinden.
+type MessagePublisherFunc func(ctx context.Context, key string, data []byte) error
+
+func (f MessagePublisherFunc) Publish(ctx context.Context, key string, data []byte) error {
+	return f(ctx, key, data)
+}
+
+// NATSConn ist der Teil von *nats.Conn, den NATSPublisher benötigt.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher veröffentlicht Nachrichten auf einem NATS-Subject.
+type NATSPublisher struct {
+	Conn    NATSConn
+	Subject string
+}
+
+func (p NATSPublisher) Publish(ctx context.Context, key string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.Conn.Publish(p.Subject, data)
+}
+
+// ChannelSource liest Nachrichten aus einem Go-Channel, z.B. gespeist aus einer
+// nats.ChanSubscribe-Subscription. Ein geschlossener Channel beendet den Worker.
+type ChannelSource <-chan *Message
+
+func (c ChannelSource) Receive(ctx context.Context) (*Message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case msg, ok := <-c:
+		if !ok {
+			return nil, ErrSourceClosed
+		}
+		return msg, nil
+	}
+}
+
+// ErrSourceClosed meldet, dass eine MessageSource keine weiteren Nachrichten liefert.
+var ErrSourceClosed = errors.New("message source closed")
+
+// MessageSink veröffentlicht Batch-Ergebnisse über einen MessagePublisher.
+type MessageSink struct {
+	Publisher MessagePublisher
+}
+
+func (s MessageSink) Publish(ctx context.Context, result DocumentResult) error {
+	data, err := marshalResult(result)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	return s.Publisher.Publish(ctx, filepath.Base(result.SourceFile), data)
+}
+
+// DocumentMessage ist das Nachrichtenformat für zu verarbeitende Dokumente. Entweder
+// verweist FileName auf eine Datei in StreamWorker.BaseDir, oder Data enthält das PDF.
+// Nachrichten, die direkt mit "%PDF" beginnen, werden als rohes PDF behandelt.
+type DocumentMessage struct {
+	ID       string `json:"id"`
+	FileName string `json:"fileName,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+func decodeDocumentMessage(msg *Message) (DocumentMessage, error) {
+	if bytes.HasPrefix(msg.Data, []byte("%PDF")) {
+		return DocumentMessage{ID: msg.Key, Data: msg.Data}, nil
+	}
+	var doc DocumentMessage
+	if err := json.Unmarshal(msg.Data, &doc); err != nil {
+		return doc, fmt.Errorf("%w: invalid document message: %w", ErrBadInput, err)
+	}
+	if doc.ID == "" {
+		doc.ID = msg.Key
+	}
+	if doc.FileName == "" && len(doc.Data) == 0 {
+		return doc, fmt.Errorf("%w: document message %s has neither fileName nor data", ErrBadInput, doc.ID)
+	}
+	return doc, nil
+}
+
+// StreamWorker liest Dokumente aus einer Queue, konvertiert sie und veröffentlicht die
+// Ergebnisse in einer anderen. Fehlgeschlagene Dokumente werden per Nack zurückgegeben,
+// außer bei dauerhaften Fehlern wie unlesbaren Nachrichten oder ErrBadInput: Diese würden
+// endlos neu zugestellt und werden nach dem Protokollieren bestätigt.
+type StreamWorker struct {
+	Service       *AiCommunicationService
+	SystemMessage string
+	Source        MessageSource
+	Results       MessagePublisher
+	TempDir       string     // für Dokumente, die als Daten kommen; Default: os.TempDir()
+	Temp          *TempStore // ersetzt TempDir durch ein Verzeichnis mit Größenlimit, optional
+	// BaseDir ist das Verzeichnis, aus dem DocumentMessage.FileName gelesen werden darf;
+	// relative Namen gelten darin. Leer = nur Dokumente als Daten.
+	BaseDir string
+}
+
+// Run verarbeitet Nachrichten, bis ctx endet oder die Quelle geschlossen wird.
+func (w *StreamWorker) Run(ctx context.Context) error {
+	for {
+		msg, err := w.Source.Receive(ctx)
+		if err != nil {
+			if errors.Is(err, ErrSourceClosed) {
+				return nil
+			}
+			return log.WrapError(err)
+		}
+
+		if err := w.handle(ctx, msg); err != nil {
+			log.Error(log.WrapError(err))
+			if permanentMessageError(err) && msg.Ack != nil {
+				if err := msg.Ack(); err != nil {
+					log.Error(log.WrapError(err))
+				}
+				continue
+			}
+			if msg.Nack != nil {
+				if err := msg.Nack(); err != nil {
+					log.Error(log.WrapError(err))
+				}
+			}
+			continue
+		}
+		if msg.Ack != nil {
+			if err := msg.Ack(); err != nil {
+				log.Error(log.WrapError(err))
+			}
+		}
+	}
+}
+
+// permanentMessageError meldet, ob eine erneute Zustellung an err nichts ändert, z.B. bei
+// beschädigten Dokumenten oder vom Modell abgelehnten Inhalten.
+func permanentMessageError(err error) bool {
+	status := HTTPStatusFor(err)
+	return status == http.StatusBadRequest || status == http.StatusUnprocessableEntity
+}
+
+func (w *StreamWorker) handle(ctx context.Context, msg *Message) error {
+	doc, err := decodeDocumentMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	fileName := doc.FileName
+	if len(doc.Data) == 0 {
+		if fileName, err = w.documentFile(doc); err != nil {
+			return err
+		}
+	} else if w.Temp != nil {
+		if fileName, err = w.Temp.WriteFile("document-*.pdf", doc.Data); err != nil {
+			return err
+		}
+		defer w.Temp.Remove(fileName)
+	} else {
+		tmp, err := os.CreateTemp(w.TempDir, "myailib-*.pdf")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(doc.Data)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		fileName = tmp.Name()
+	}
+
+	cfg := w.Service.newRequestConfig([]RequestOption{WithPriority(PriorityBatch), WithTag("stream")})
+	usage := &callUsage{}
+	cfg.usage = usage
+	content, err := w.Service.generateContentWithPDF(ctx, w.SystemMessage, fileName, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to convert document %s: %w", doc.ID, err)
+	}
+
+	data, err := marshalResult(DocumentResult{
+		SourceFile:  doc.ID,
+		Content:     content,
+		Cost:        usage.cost(),
+		Status:      DocumentDone,
+		CompletedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return w.Results.Publish(ctx, doc.ID, data)
+}
+
+// documentFile liefert den Pfad zu doc.FileName, sofern er nach dem Auflösen symbolischer
+// Links in BaseDir liegt. Sonst könnte jeder, der in die Queue schreibt, beliebige Dateien
+// des Workers an das Modell schicken.
+func (w *StreamWorker) documentFile(doc DocumentMessage) (string, error) {
+	if w.BaseDir == "" {
+		return "", fmt.Errorf("%w: document message %s refers to a file, but no base directory is configured", ErrBadInput, doc.ID)
+	}
+	base, err := filepath.EvalSymlinks(w.BaseDir)
+	if err != nil {
+		return "", err
+	}
+	fileName := doc.FileName
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(base, fileName)
+	}
+	resolved, err := filepath.EvalSymlinks(fileName)
+	if err != nil {
+		return "", fmt.Errorf("%w: document message %s: %w", ErrBadInput, doc.ID, err)
+	}
+	if !isWithin(resolved, base) {
+		return "", fmt.Errorf("%w: document message %s refers to %s outside the base directory", ErrBadInput, doc.ID, doc.FileName)
+	}
+	return resolved, nil
+}
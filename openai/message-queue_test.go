@@ -0,0 +1,161 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type natsRecorder struct {
+	subject string
+	data    []byte
+}
+
+func (r *natsRecorder) Publish(subject string, data []byte) error {
+	r.subject, r.data = subject, data
+	return nil
+}
+
+func TestMessageSink_NATS(t *testing.T) {
+	conn := &natsRecorder{}
+	sink := MessageSink{Publisher: NATSPublisher{Conn: conn, Subject: "results"}}
+
+	err := sink.Publish(context.Background(), DocumentResult{SourceFile: "in/a.pdf", Content: `{"a":1}`})
+	require.NoError(t, err)
+	require.Equal(t, "results", conn.subject)
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(conn.data, &payload))
+	require.Equal(t, "in/a.pdf", payload["sourceFile"])
+	require.Equal(t, map[string]any{"a": 1.0}, payload["result"])
+}
+
+func TestDecodeDocumentMessage(t *testing.T) {
+	doc, err := decodeDocumentMessage(&Message{Key: "k1", Data: []byte("%PDF-1.7 ...")})
+	require.NoError(t, err)
+	require.Equal(t, "k1", doc.ID)
+	require.NotEmpty(t, doc.Data)
+
+	doc, err = decodeDocumentMessage(&Message{Key: "k2", Data: []byte(`{"fileName":"/data/a.pdf"}`)})
+	require.NoError(t, err)
+	require.Equal(t, "k2", doc.ID)
+	require.Equal(t, "/data/a.pdf", doc.FileName)
+
+	_, err = decodeDocumentMessage(&Message{Data: []byte(`{"id":"x"}`)})
+	require.Error(t, err)
+}
+
+func TestStreamWorker_StopsOnClosedSource(t *testing.T) {
+	ch := make(chan *Message)
+	close(ch)
+	w := &StreamWorker{Source: ChannelSource(ch)}
+	require.NoError(t, w.Run(context.Background()))
+}
+
+func TestStreamWorker_AcksPermanentErrors(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files") {
+			_, _ = w.Write([]byte(testUploadedFile))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error": {"message": "provider trouble", "type": "server_error"}}`))
+	})
+	ai.MaxAttempts = 1
+
+	var acked, nacked []string
+	message := func(key, data string) *Message {
+		return &Message{
+			Key:  key,
+			Data: []byte(data),
+			Ack:  func() error { acked = append(acked, key); return nil },
+			Nack: func() error { nacked = append(nacked, key); return nil },
+		}
+	}
+	ch := make(chan *Message, 3)
+	ch <- message("poison", `{"id": `)
+	ch <- message("truncated", "%PDF-1.7 ...")
+	ch <- message("unavailable", testPDF)
+	close(ch)
+
+	w := &StreamWorker{Service: ai, SystemMessage: "system", Source: ChannelSource(ch), TempDir: t.TempDir()}
+	require.NoError(t, w.Run(context.Background()))
+	require.Equal(t, []string{"poison", "truncated"}, acked)
+	require.Equal(t, []string{"unavailable"}, nacked)
+}
+
+func TestStreamWorker_FileNamesStayInBaseDir(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "inbox")
+	require.NoError(t, os.Mkdir(base, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "a.pdf"), []byte(testPDF), 0644))
+	secret := filepath.Join(root, "secret.pdf")
+	require.NoError(t, os.WriteFile(secret, []byte(testPDF), 0644))
+	require.NoError(t, os.Symlink(secret, filepath.Join(base, "link.pdf")))
+
+	var acked, published []string
+	ch := make(chan *Message, 5)
+	for _, name := range []string{"a.pdf", "../secret.pdf", secret, "link.pdf", filepath.Join(base, "a.pdf")} {
+		data, err := json.Marshal(DocumentMessage{ID: name, FileName: name})
+		require.NoError(t, err)
+		ch <- &Message{Data: data, Ack: func() error { acked = append(acked, name); return nil }}
+	}
+	close(ch)
+	w := &StreamWorker{
+		Service:       newBatchTestService(t, func() string { return `{"ok": true}` }),
+		SystemMessage: "system",
+		Source:        ChannelSource(ch),
+		Results: MessagePublisherFunc(func(ctx context.Context, key string, data []byte) error {
+			published = append(published, key)
+			return nil
+		}),
+		BaseDir: base,
+	}
+	require.NoError(t, w.Run(context.Background()))
+	require.Equal(t, []string{"a.pdf", filepath.Join(base, "a.pdf")}, published)
+	require.Len(t, acked, 5)
+
+	// ohne BaseDir werden keine Dateinamen angenommen
+	ch = make(chan *Message, 1)
+	ch <- &Message{Data: []byte(`{"id": "a", "fileName": "a.pdf"}`)}
+	close(ch)
+	published = nil
+	w.Source, w.BaseDir = ChannelSource(ch), ""
+	require.NoError(t, w.Run(context.Background()))
+	require.Empty(t, published)
+}
+
+func TestStreamWorker_CancelsConversion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	var nacked int
+	ch := make(chan *Message, 1)
+	ch <- &Message{Key: "a", Data: []byte(testPDF), Nack: func() error { nacked++; return nil }}
+	w := &StreamWorker{Service: ai, SystemMessage: "system", Source: ChannelSource(ch), TempDir: t.TempDir()}
+
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	select {
+	case err := <-done:
+		require.ErrorContains(t, err, context.Canceled.Error())
+	case <-time.After(3 * time.Second):
+		t.Fatal("worker did not stop with its context")
+	}
+	require.Equal(t, 1, nacked)
+}
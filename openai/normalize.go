@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale beschreibt die Zahlen- und Datumsformate, in denen extrahierte Werte vorliegen.
+type Locale struct {
+	DecimalSeparator   string   // z.B. ","
+	ThousandsSeparator string   // z.B. "."
+	DateLayouts        []string // Go-Layouts, in der angegebenen Reihenfolge probiert
+}
+
+var (
+	LocaleDE = Locale{
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		DateLayouts:        []string{"2.1.2006", "2.1.06", "2006-01-02"},
+	}
+	LocaleEN = Locale{
+		DecimalSeparator:   ".",
+		ThousandsSeparator: ",",
+		DateLayouts:        []string{"1/2/2006", "1/2/06", "2006-01-02", "2 Jan 2006", "January 2, 2006"},
+	}
+)
+
+var currencySymbols = map[string]string{
+	"€": "EUR",
+	"$": "USD",
+	"£": "GBP",
+	"¥": "JPY",
+}
+
+var currencyCodeRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// NormalizeNumber wandelt eine formatierte Zahl in float64 um und liefert ggf. die Währung mit.
+// Beispiel: NormalizeNumber("1.234,56 EUR", LocaleDE) -> 1234.56, "EUR".
+func NormalizeNumber(s string, loc Locale) (float64, string, error) {
+	raw, negative, currency := splitNumber(s, loc)
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("unrecognized number format: %s", s)
+	}
+	if negative {
+		f = -f
+	}
+	return f, currency, nil
+}
+
+// NormalizeDecimal wie NormalizeNumber, aber exakt; für Geldbeträge und lange Ganzzahlen.
+// Beispiel: NormalizeDecimal("12.345.678.901.234,56 €", LocaleDE) -> 12345678901234.56, "EUR".
+func NormalizeDecimal(s string, loc Locale) (Decimal, string, error) {
+	raw, negative, currency := splitNumber(s, loc)
+	if strings.ContainsAny(raw, "eE+-") {
+		return Decimal{}, "", fmt.Errorf("unrecognized number format: %s", s)
+	}
+	d, err := ParseDecimal(raw)
+	if err != nil {
+		return Decimal{}, "", fmt.Errorf("unrecognized number format: %s", s)
+	}
+	if negative {
+		d = d.Neg()
+	}
+	return d, currency, nil
+}
+
+// splitNumber trennt Währung und Vorzeichen ab und bringt den Betrag in die Form "1234.56".
+func splitNumber(s string, loc Locale) (raw string, negative bool, currency string) {
+	raw = strings.TrimSpace(s)
+
+	// Währung vorne oder hinten abtrennen
+	for symbol, code := range currencySymbols {
+		if strings.HasPrefix(raw, symbol) || strings.HasSuffix(raw, symbol) {
+			currency = code
+			raw = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(raw, symbol), symbol))
+			break
+		}
+	}
+	if currency == "" {
+		if fields := strings.Fields(raw); len(fields) == 2 {
+			switch {
+			case currencyCodeRe.MatchString(fields[0]):
+				currency, raw = fields[0], fields[1]
+			case currencyCodeRe.MatchString(fields[1]):
+				currency, raw = fields[1], fields[0]
+			}
+		}
+	}
+
+	// Vorzeichen, auch nachgestellt wie in Kontoauszügen ("1.234,56-")
+	if strings.HasSuffix(raw, "-") {
+		negative = true
+		raw = strings.TrimSuffix(raw, "-")
+	}
+	if strings.HasPrefix(raw, "-") {
+		negative = !negative
+		raw = strings.TrimPrefix(raw, "-")
+	}
+
+	if loc.ThousandsSeparator != "" {
+		raw = strings.ReplaceAll(raw, loc.ThousandsSeparator, "")
+	}
+	if loc.DecimalSeparator != "" && loc.DecimalSeparator != "." {
+		raw = strings.Replace(raw, loc.DecimalSeparator, ".", 1)
+	}
+	return strings.TrimSpace(raw), negative, currency
+}
+
+// NormalizeDate wandelt ein Datum im Format der Locale in ISO 8601 (2006-01-02) um.
+func NormalizeDate(s string, loc Locale) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range loc.DateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date format: %s", s)
+}
@@ -0,0 +1,49 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		in       string
+		loc      Locale
+		value    float64
+		currency string
+	}{
+		{"1.234,56 EUR", LocaleDE, 1234.56, "EUR"},
+		{"€ 1.234,56", LocaleDE, 1234.56, "EUR"},
+		{"1.234,56-", LocaleDE, -1234.56, ""},
+		{"-0,5", LocaleDE, -0.5, ""},
+		{"USD 1,234.56", LocaleEN, 1234.56, "USD"},
+		{"$99.90", LocaleEN, 99.9, "USD"},
+	}
+	for _, tt := range tests {
+		value, currency, err := NormalizeNumber(tt.in, tt.loc)
+		require.NoError(t, err, tt.in)
+		require.InDelta(t, tt.value, value, 1e-9, tt.in)
+		require.Equal(t, tt.currency, currency, tt.in)
+	}
+
+	_, _, err := NormalizeNumber("abc", LocaleDE)
+	require.Error(t, err)
+}
+
+func TestNormalizeDate(t *testing.T) {
+	date, err := NormalizeDate("31.12.2024", LocaleDE)
+	require.NoError(t, err)
+	require.Equal(t, "2024-12-31", date)
+
+	date, err = NormalizeDate("1.2.2024", LocaleDE)
+	require.NoError(t, err)
+	require.Equal(t, "2024-02-01", date)
+
+	date, err = NormalizeDate("12/31/2024", LocaleEN)
+	require.NoError(t, err)
+	require.Equal(t, "2024-12-31", date)
+
+	_, err = NormalizeDate("31.02.2024", LocaleDE)
+	require.Error(t, err)
+}
@@ -2,10 +2,12 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dchaykin/mygolib/log"
@@ -14,6 +16,17 @@ import (
 	"github.com/openai/openai-go/packages/param"
 )
 
+const defaultMaxAttempts = 3
+
+var (
+	ErrMaxLength       = errors.New("chat completion reached maximum length")
+	ErrContentFiltered = errors.New("chat completion was filtered due to content policy")
+	ErrRefused         = errors.New("model refused the request")
+	// ErrRetryAfterTooLong wird zusammen mit dem *OpenAIError geliefert, wenn die vom Server
+	// empfohlene Wartezeit MaxRetryAfter übersteigt.
+	ErrRetryAfterTooLong = errors.New("suggested retry wait too long")
+)
+
 func NewAiCommunicationService(prompt string) *AiCommunicationService {
 	config := config{
 		AuthData: map[string]any{
@@ -33,36 +46,141 @@ type config struct {
 	AuthData map[string]any
 }
 
+// AiCommunicationService kapselt die Kommunikation mit OpenAI. Der Service darf von mehreren
+// Goroutinen gleichzeitig genutzt werden; die exportierten Konfigurationsfelder dürfen dann
+// aber nach dem ersten Aufruf nicht mehr verändert werden. Für einen abweichenden Prompt je
+// Aufruf gibt es WithPrompt, die Kosten liest man über TotalCosts bzw. CostsBetween.
 type AiCommunicationService struct {
-	config      config
-	Model       openai.ChatModel
-	Prompt      string
-	Costs       []chatCosts
-	Temperature float64
+	config             config
+	Model              openai.ChatModel
+	Prompt             string
+	Costs              []chatCosts
+	Temperature        float64
+	PostProcessors     []string               // Namen registrierter Post-Prozessoren, siehe RegisterPostProcessor
+	ExchangeRates      ExchangeRateProvider   // für TotalCostsIn, optional
+	Forecaster         *QuotaForecaster       // sammelt RateInfo aus 429-Antworten, optional
+	RateLimiter        *RateLimiter           // drosselt Aufrufe und lernt aus 429-Antworten, optional
+	Scheduler          *Scheduler             // begrenzt parallele Aufrufe nach Priorität, optional
+	IdempotencyTTL     time.Duration          // Default: DefaultIdempotencyTTL
+	ClientOptions      []option.RequestOption // zusätzliche Optionen für den openai.Client, z.B. option.WithBaseURL
+	Transport          *TransportConfig       // Connection-Pool des Clients; nil = DefaultTransportConfig
+	RequestTimeout     time.Duration          // je Chat-Request und Versuch; 0 = nur Kontext-Deadline
+	UploadTimeout      time.Duration          // je Datei-Upload; 0 = nur Kontext-Deadline
+	MaxUploadSize      int64                  // größere Dateien werden abgelehnt; 0 = DefaultMaxUploadSize
+	MaxResponseBytes   int                    // größere Antworten führen zu ErrResponseTooLarge; 0 = unbegrenzt
+	TruncateResponses  bool                   // Antworten über MaxResponseBytes kürzen statt ablehnen (nur für Freitext sinnvoll)
+	Truncation         *TruncationPolicy      // kürzt zu lange System-Messages statt eines Fehlers, optional
+	Features           Features               // experimentelle Features; ergänzt und übersteuert FeaturesEnv
+	Faults             *FaultInjector         // stört Requests zum Testen der Fehlerbehandlung, nur Tests und Staging
+	MaxAttempts        int                    // Versuche je Chat-Request bei Rate-Limits, 5xx und Netzwerkfehlern, Default: 3
+	Backoff            *BackoffPolicy         // Wartezeiten ohne Vorgabe des Servers; nil = DefaultBackoffPolicy
+	MaxRetryAfter      time.Duration          // längere Wartezeiten des Servers führen sofort zu ErrRetryAfterTooLong; 0 = unbegrenzt
+	Hedging            *HedgePolicy           // zweiter Request bei langsamer Antwort, optional
+	CheapFirst         *CheapFirstPolicy      // erst günstiges Modell, Eskalation bei schwacher Antwort, optional
+	Voting             *VotingPolicy          // mehrere Durchläufe, Mehrheitsentscheid je Feld, optional
+	Verification       *VerificationPolicy    // zweiter Aufruf prüft das Ergebnis am Dokument, optional
+	Language           *LanguagePolicy        // prüft die Sprache der Antwort, optional
+	Routes             map[string]Route       // Aufgabentyp -> Modell/Prompt/Parameter, siehe WithTask
+	Shadow             *ShadowPolicy          // asynchrone Kopie der Requests an ein Kandidaten-Modell, optional
+	Canary             *Canary                // schrittweise Umstellung auf ein neues Modell, optional
+	Hooks              []EventHook            // erhalten je Request ein EventCompletion, optional
+	Safety             *SafetySettings        // Sicherheitseinstellungen des Providers, optional
+	User               string                 // user-Feld für alle Requests (gehasht, siehe HashUserID), optional
+	Component          string                 // Teilsystem in Costs und Audit-Log; leer = Paket des Aufrufers, siehe WithComponent
+	Audit              AuditLog               // protokolliert jeden Request, optional
+	Estimator          *TokenEstimator        // lernt Tokenverbrauch je Dokumenttyp, optional
+	Cache              ResultCache            // Ergebnisse von Aufrufen mit Datei, optional
+	CacheTTL           time.Duration          // danach wird ein Cache-Eintrag neu angefragt; 0 = unbegrenzt gültig
+	Stale              *StaleFallback         // veraltete Cache-Einträge bei Ausfall oder erschöpftem Budget, optional
+	PromptVersion      string                 // Teil des Cache-Keys; leer = aus den Prompts abgeleitet
+	Corrections        *CorrectionStore       // korrigierte Ergebnisse als Few-Shot-Beispiele je Dokumenttyp, optional
+	CorrectionExamples int                    // Zahl der Beispiele, Default: 3
+	Retention          *RetentionPolicy       // Aufbewahrungsfristen für EnforceRetention, optional
+	Residency          *ResidencyPolicy       // erlaubte Endpoints, Verstöße führen zu ErrResidencyViolation; optional
+
+	initOnce    sync.Once
+	client      openai.Client
+	idempotency *idempotencyCache
+	costsMu     sync.Mutex
+	stats       serviceStats
+	tenant      string        // Mandant eines TenantService, für pprof-Labels
+	budget      *tenantBudget // Budget eines Mandanten, siehe TenantConfig.MaxCost
+}
+
+// init baut beim ersten Aufruf den gemeinsam genutzten Client, damit alle Aufrufe
+// denselben Connection-Pool nutzen und nicht jedes Mal einen TLS-Handshake machen.
+func (ai *AiCommunicationService) init() {
+	ai.initOnce.Do(func() {
+		transport := DefaultTransportConfig
+		if ai.Transport != nil {
+			transport = *ai.Transport
+		}
+		// Wiederholungen steuert createChatCompletion (Backoff, RateLimiter, MaxRetryAfter),
+		// nicht der Client selbst; über ClientOptions weiterhin überschreibbar
+		opts := append([]option.RequestOption{
+			option.WithMaxRetries(0),
+			option.WithAPIKey(ai.apiKey()),
+			option.WithHTTPClient(transport.NewHTTPClient()),
+			option.WithHeader("User-Agent", UserAgent()),
+		}, ai.ClientOptions...)
+		if ai.Residency != nil && ai.Residency.BaseURL != "" {
+			opts = append(opts, option.WithBaseURL(ai.Residency.BaseURL))
+		}
+		// immer aktiv, damit auch Policies greifen, die Hedging oder Shadow im Kontext mitgeben
+		opts = append(opts, option.WithMiddleware(ai.statsMiddleware, ai.residencyMiddleware))
+		if ai.Faults != nil {
+			opts = append(opts, option.WithMiddleware(ai.Faults.middleware))
+		}
+		ai.client = openai.NewClient(opts...)
+		ai.idempotency = newIdempotencyCache()
+	})
 }
 
 func (ai *AiCommunicationService) AddCosts(usage openai.CompletionUsage) {
+	ai.addCosts(usage, ai.baseRequestConfig())
+}
+
+// addCosts erfasst die Kosten eines Requests und liefert sie zurück.
+func (ai *AiCommunicationService) addCosts(usage openai.CompletionUsage, cfg requestConfig) float64 {
 	log.Debug("Prompt Tokens: %d\n", usage.PromptTokens)
 	log.Debug("Completion Tokens: %d\n", usage.CompletionTokens)
 	log.Debug("Total Tokens: %d\n", usage.TotalTokens)
 
-	promptPrice := 0.005 // USD per 1k tokens
-	completionPrice := 0.015
-	pt := float64(usage.PromptTokens)
-	ct := float64(usage.CompletionTokens)
-	cost := (pt/1000.0)*promptPrice + (ct/1000.0)*completionPrice
+	cost, promptPrice, completionPrice := costFor(usage.PromptTokens, usage.CompletionTokens)
 	log.Debug("Estimated Cost: $%.4f\n", cost)
 
+	ai.costsMu.Lock()
+	defer ai.costsMu.Unlock()
 	ai.Costs = append(ai.Costs, chatCosts{
 		PromptTokens:     usage.PromptTokens,
 		CompletionTokens: usage.CompletionTokens,
 		PromptPrice:      promptPrice,
 		CompletionPrice:  completionPrice,
 		TotalCost:        cost,
+		Timestamp:        time.Now(),
+		DocumentType:     cfg.documentType,
+		Model:            string(cfg.model),
+		Variant:          cfg.variant,
+		User:             cfg.user,
+		Component:        cfg.component,
+		Caller:           cfg.caller,
 	})
+	return cost
 }
 
-func (ai AiCommunicationService) TotalCosts() float64 {
+// costFor berechnet die Kosten (USD) für die angegebenen Tokens.
+func costFor(promptTokens, completionTokens int64) (cost, promptPrice, completionPrice float64) {
+	promptPrice = 0.005 // USD per 1k tokens
+	completionPrice = 0.015
+	pt := float64(promptTokens)
+	ct := float64(completionTokens)
+	cost = (pt/1000.0)*promptPrice + (ct/1000.0)*completionPrice
+	return cost, promptPrice, completionPrice
+}
+
+func (ai *AiCommunicationService) TotalCosts() float64 {
+	ai.costsMu.Lock()
+	defer ai.costsMu.Unlock()
 	total := 0.0
 	for _, cost := range ai.Costs {
 		total += cost.TotalCost
@@ -70,18 +188,45 @@ func (ai AiCommunicationService) TotalCosts() float64 {
 	return total
 }
 
+// costsSnapshot liefert eine Kopie der bisher erfassten Kosten.
+func (ai *AiCommunicationService) costsSnapshot() []chatCosts {
+	ai.costsMu.Lock()
+	defer ai.costsMu.Unlock()
+	return append([]chatCosts{}, ai.Costs...)
+}
+
+// CostsBetween liefert die geschätzten Kosten (USD) aller Aufrufe im Zeitraum [start, end).
+func (ai *AiCommunicationService) CostsBetween(start, end time.Time) float64 {
+	ai.costsMu.Lock()
+	defer ai.costsMu.Unlock()
+	total := 0.0
+	for _, cost := range ai.Costs {
+		if !cost.Timestamp.Before(start) && cost.Timestamp.Before(end) {
+			total += cost.TotalCost
+		}
+	}
+	return total
+}
+
 /*****************************************************/
 /*                    AI COSTS                       */
 /*****************************************************/
 type chatCosts struct {
-	PromptTokens     int64   `json:"promptTokens"`
-	CompletionTokens int64   `json:"completionTokens"`
-	PromptPrice      float64 `json:"promptPrice"`
-	CompletionPrice  float64 `json:"completionPrice"`
-	TotalCost        float64 `json:"totalCost"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	PromptPrice      float64   `json:"promptPrice"`
+	CompletionPrice  float64   `json:"completionPrice"`
+	TotalCost        float64   `json:"totalCost"`
+	Timestamp        time.Time `json:"timestamp"`
+	DocumentType     string    `json:"documentType,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	Variant          string    `json:"variant,omitempty"`
+	User             string    `json:"user,omitempty"`
+	Component        string    `json:"component,omitempty"`
+	Caller           string    `json:"caller,omitempty"`
 }
 
-func (ai AiCommunicationService) apiKey() string {
+func (ai *AiCommunicationService) apiKey() string {
 	if ai.config.AuthData == nil {
 		return ""
 	}
@@ -92,30 +237,11 @@ func (ai AiCommunicationService) apiKey() string {
 	return apiKey.(string)
 }
 
-func (ai AiCommunicationService) getFilePart(ctx context.Context, client *openai.Client, fileName string) (*openai.ChatCompletionContentPartUnionParam, error) {
-	// Step 1: Lade PDF-Datei
-	fileReader, err := os.Open(fileName)
-	if err != nil {
-		return nil, log.WrapError(err)
-
-	}
-	defer fileReader.Close()
-
-	name := func(s []string) string {
-		if len(s) > 0 {
-			return s[len(s)-1]
-		}
-		return ""
-	}(strings.Split(fileReader.Name(), "/"))
-
-	inputFile := openai.File(fileReader, name, "application/pdf")
-
-	storedFile, err := client.Files.New(ctx, openai.FileNewParams{
-		File:    inputFile,
-		Purpose: openai.FilePurposeUserData,
-	})
+func (ai *AiCommunicationService) getFilePart(ctx context.Context, client *openai.Client, fileName string, cfg requestConfig) (*openai.ChatCompletionContentPartUnionParam, error) {
+	// 1. PDF-Datei hochladen
+	storedFile, err := ai.uploadFile(ctx, client, fileName, cfg)
 	if err != nil {
-		return nil, log.WrapError(fmt.Errorf("error uploading file to OpenAI: %s", err.Error()))
+		return nil, err
 	}
 
 	// 2. Create messages
@@ -129,37 +255,323 @@ func (ai AiCommunicationService) getFilePart(ctx context.Context, client *openai
 
 type onGetDocument func(ctx context.Context, client *openai.Client) (*openai.ChatCompletionContentPartUnionParam, error)
 
-func (ai *AiCommunicationService) GenerateContentWithPDF(systemMessage, fileName string) (string, error) {
-	return ai.generateJsonContent(systemMessage,
+// onceDocument merkt sich je Client den ersten erfolgreich geladenen Dokument-Teil, damit
+// mehrere Requests desselben Aufrufs die Datei nicht erneut hochladen.
+func onceDocument(f onGetDocument) onGetDocument {
+	var (
+		mu    sync.Mutex
+		parts = map[*openai.Client]*openai.ChatCompletionContentPartUnionParam{}
+	)
+	return func(ctx context.Context, client *openai.Client) (*openai.ChatCompletionContentPartUnionParam, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if part, ok := parts[client]; ok {
+			return part, nil
+		}
+		part, err := f(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		parts[client] = part
+		return part, nil
+	}
+}
+
+type requestFunc func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error)
+
+// GenerateContentWithPDF schickt die Datei mit der System-Message an das Modell.
+//
+// Deprecated: Generate mit Request.FileName liefert zusätzlich Tokens und Kosten und nimmt einen Kontext.
+func (ai *AiCommunicationService) GenerateContentWithPDF(systemMessage, fileName string, opts ...RequestOption) (string, error) {
+	return ai.generateContentWithPDF(context.Background(), systemMessage, fileName, ai.newRequestConfig(opts))
+}
+
+// GenerateContentWithPDFContext wie GenerateContentWithPDF, aber abbrechbar über ctx: Upload,
+// Requests und Wartezeiten zwischen Wiederholungen enden mit ctx.
+func (ai *AiCommunicationService) GenerateContentWithPDFContext(ctx context.Context, systemMessage, fileName string, opts ...RequestOption) (string, error) {
+	return ai.generateContentWithPDF(ctx, systemMessage, fileName, ai.newRequestConfig(opts))
+}
+
+func (ai *AiCommunicationService) generateContentWithPDF(ctx context.Context, systemMessage, fileName string, cfg requestConfig) (string, error) {
+	if cfg.taskErr != nil {
+		return "", log.WrapError(cfg.taskErr)
+	}
+	if err := CheckInputFile(fileName); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(fileName); err == nil {
+		cfg.documentSize = int(info.Size())
+	}
+
+	cacheKey := ""
+	var cached *cacheEntry // veralteter Eintrag für StaleFallback
+	if ai.Cache != nil {
+		fileHash, err := fileSHA256(fileName)
+		if err != nil {
+			return "", log.WrapError(err)
+		}
+		cacheKey = ai.resultCacheKey(fileHash, systemMessage, cfg)
+		entry, err := lookupCache(ai.Cache, cacheKey)
+		switch {
+		case err != nil:
+			log.Warn("result cache lookup failed: %v", err)
+		case entry != nil && ai.fresh(entry):
+			log.Debug("Result for %s taken from cache", fileName)
+			return entry.content, nil
+		case entry != nil:
+			cached = entry
+		}
+	}
+	if cfg.budgetErr != nil {
+		return ai.serveStale(cached, cfg, cfg.budgetErr)
+	}
+
+	content, err := ai.generateJsonContent(ctx, systemMessage,
 		func(ctx context.Context, client *openai.Client) (*openai.ChatCompletionContentPartUnionParam, error) {
-			return ai.getFilePart(ctx, client, fileName)
+			return ai.getFilePart(ctx, client, fileName, cfg)
 		},
+		cfg,
 	)
+	if err != nil {
+		if degraded(err) {
+			return ai.serveStale(cached, cfg, err)
+		}
+		return "", err
+	}
+
+	if ai.Cache != nil {
+		if err := ai.Cache.Set(cacheKey, content); err != nil {
+			log.Warn("result cache update failed: %v", err)
+		}
+	}
+	return content, nil
 }
 
-func (ai *AiCommunicationService) GenerateContent(systemMessage string) (string, error) {
-	return ai.generateJsonContent(systemMessage, nil)
+// GenerateContent schickt die System-Message und den Prompt an das Modell.
+//
+// Deprecated: Generate liefert zusätzlich Tokens und Kosten und nimmt einen Kontext.
+func (ai *AiCommunicationService) GenerateContent(systemMessage string, opts ...RequestOption) (string, error) {
+	return ai.generateJsonContent(context.Background(), systemMessage, nil, ai.newRequestConfig(opts))
 }
 
-func (ai *AiCommunicationService) generateJsonContent(systemMessage string, f onGetDocument) (string, error) {
-	client := openai.NewClient(
-		option.WithAPIKey(ai.apiKey()),
-	)
-	ctx := context.Background()
+// GenerateContentContext wie GenerateContent, aber abbrechbar über ctx: Requests und
+// Wartezeiten zwischen Wiederholungen enden mit ctx.
+func (ai *AiCommunicationService) GenerateContentContext(ctx context.Context, systemMessage string, opts ...RequestOption) (string, error) {
+	return ai.generateJsonContent(ctx, systemMessage, nil, ai.newRequestConfig(opts))
+}
+
+func (ai *AiCommunicationService) generateJsonContent(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+	if cfg.taskErr != nil {
+		return "", log.WrapError(cfg.taskErr)
+	}
+	if cfg.budgetErr != nil {
+		return "", cfg.budgetErr
+	}
+	ai.init()
+	ctx = withResidency(ctx, ai.Residency)
+	systemMessage, err := truncateSystemMessage(systemMessage, cfg)
+	if err != nil {
+		return "", err
+	}
+	if ai.Corrections != nil {
+		systemMessage = ai.correctionExamples(systemMessage, cfg)
+	}
+	if cfg.extraction() {
+		systemMessage += extractionInstruction(cfg)
+	}
+	if f != nil {
+		// bei Hedging und Eskalation die Datei nur einmal hochladen
+		f = onceDocument(f)
+	}
+	var request requestFunc = ai.requestJsonContent
+	if cfg.hedge != nil && cfg.hedge.After > 0 {
+		request = ai.hedgedRequest
+	}
+	if cfg.language != nil && cfg.language.Language != "" {
+		request = ai.languageRequest(request)
+	}
+	if cfg.cheapFirst != nil && cfg.cheapFirst.Model != "" {
+		request = ai.cheapFirstRequest(request)
+	}
+	if cfg.voting != nil && cfg.voting.Runs > 1 {
+		request = ai.votingRequest(request)
+	}
+	if cfg.verification != nil {
+		request = ai.verifiedRequest(request)
+	}
+	if exp := cfg.experiment; exp != nil {
+		// erfolgreiche Requests erfasst requestJsonContent samt Kosten
+		inner := request
+		request = func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+			content, err := inner(ctx, systemMessage, f, cfg)
+			if err != nil {
+				exp.observe(cfg.variant, 0, err)
+			}
+			return content, err
+		}
+	}
+	if ai.Canary != nil {
+		request = ai.Canary.wrap(request)
+	}
+	if ai.Audit != nil {
+		// erfolgreiche Requests protokolliert requestJsonContent samt Tokens und Kosten
+		inner := request
+		request = func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+			content, err := inner(ctx, systemMessage, f, cfg)
+			if err != nil {
+				rec := ai.auditRecord(systemMessage, cfg)
+				rec.Error = err.Error()
+				ai.audit(rec)
+			}
+			return content, err
+		}
+	}
+	if ai.Shadow != nil {
+		inner := request
+		request = func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+			content, err := inner(ctx, systemMessage, f, cfg)
+			ai.shadow(ctx, systemMessage, f, cfg, content, err)
+			return content, err
+		}
+	}
+	if cfg.idempotencyKey == "" {
+		return request(ctx, systemMessage, f, cfg)
+	}
+	ttl := ai.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return ai.idempotency.do(ctx, cfg.idempotencyKey, ttl, func() (string, error) {
+		return request(ctx, systemMessage, f, cfg)
+	})
+}
+
+func (ai *AiCommunicationService) requestJsonContent(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+	client := &ai.client
+
+	if ai.Scheduler != nil {
+		release, err := ai.Scheduler.Acquire(ctx, cfg.priority, cfg.tag)
+		if err != nil {
+			return "", log.WrapError(err)
+		}
+		defer release()
+	}
+	releaseBudget, err := ai.reserveBudget(estimateTokens(systemMessage, cfg.prompt))
+	if err != nil {
+		return "", err
+	}
+	defer releaseBudget()
+
+	params, err := ai.chatParams(ctx, client, systemMessage, f, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var chatCompletion *openai.ChatCompletion
+	var finishReason string
+	var usage openai.CompletionUsage
+	partial := ""
+	for continuation := 0; ; continuation++ {
+		var err error
+		chatCompletion, err = ai.createChatCompletion(ctx, params, cfg, estimateTokens(systemMessage, cfg.prompt)+len(partial)/4)
+		if err != nil {
+			return "", err
+		}
+		finishReason = chatCompletion.Choices[0].FinishReason
+		if len(ai.Hooks) > 0 {
+			emitEvent(ai.Hooks, ai.completionEvent(systemMessage, cfg, chatCompletion))
+		}
+		usage.PromptTokens += chatCompletion.Usage.PromptTokens
+		usage.CompletionTokens += chatCompletion.Usage.CompletionTokens
+		usage.TotalTokens += chatCompletion.Usage.TotalTokens
+		if finishReason != "length" || !cfg.features.Enabled(FeatureAutoContinue) || continuation >= maxContinuations {
+			break
+		}
+		// abgeschnittene Antwort fortsetzen lassen
+		text := chatCompletion.Choices[0].Message.Content
+		partial += text
+		log.Warn("chat completion reached maximum length, requesting continuation %d", continuation+1)
+		params.Messages = append(params.Messages, openai.AssistantMessage(text), openai.UserMessage(continuePrompt))
+	}
+	chatCompletion.Usage = usage
+	chatCompletion.Choices[0].Message.Content = partial + chatCompletion.Choices[0].Message.Content
+
+	if err := finishReasonError(finishReason); err != nil {
+		return "", err
+	}
+
+	// Step 3: Kosten hinzufügen
+	cost := ai.addCosts(chatCompletion.Usage, cfg)
+	cfg.usage.add(chatCompletion.Model, chatCompletion.Usage.PromptTokens, chatCompletion.Usage.CompletionTokens, cost)
+	rec := ai.auditRecord(systemMessage, cfg)
+	rec.FinishReason = string(finishReason)
+	rec.PromptTokens = chatCompletion.Usage.PromptTokens
+	rec.CompletionTokens = chatCompletion.Usage.CompletionTokens
+	rec.Cost = cost
+	ai.audit(rec)
+	if cfg.experiment != nil {
+		cfg.experiment.observe(cfg.variant, cost, nil)
+	}
+	if ai.Estimator != nil {
+		ai.Estimator.Observe(cfg.documentType, cfg.documentSize, chatCompletion.Usage.PromptTokens, chatCompletion.Usage.CompletionTokens)
+	}
 
+	resp := chatCompletion.Choices[0].Message
+	if resp.Refusal != "" {
+		return "", fmt.Errorf("%w: %s", ErrRefused, resp.Refusal)
+	}
+	content, err := ai.limitResponse(resp.Content)
+	if err != nil {
+		return "", err
+	}
+	content = NormalizeText(stripJSONWrapper(content))
+	content, err = ApplyPostProcessors(content, cfg.postProcessors...)
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	if cfg.features.Enabled(FeatureJSONRepair) && !json.Valid([]byte(content)) {
+		if repaired, ok := repairJSON(content); ok {
+			log.Warn("repaired invalid JSON in response")
+			content = repaired
+		}
+	}
+	if content == "" {
+		return "", fmt.Errorf("no content returned from OpenAI API")
+	}
+	result := content
+	if cfg.extraction() {
+		extraction, err := ParseExtraction(content)
+		if err != nil {
+			return "", err
+		}
+		result = string(extraction.Data)
+	}
+	if cfg.schema != nil {
+		if err := cfg.schema.Check(result); err != nil {
+			return "", err
+		}
+	}
+	log.Debug("Content from OpenAI: %s", logContent(content))
+
+	return content, nil
+}
+
+// chatParams baut die Nachrichten samt Dokument und die Parameter eines Chat-Requests und
+// prüft die Eingaben mit der Moderation, falls konfiguriert.
+func (ai *AiCommunicationService) chatParams(ctx context.Context, client *openai.Client, systemMessage string, f onGetDocument, cfg requestConfig) (params openai.ChatCompletionNewParams, err error) {
 	messages := []openai.ChatCompletionMessageParamUnion{}
 
 	if systemMessage != "" {
 		messages = append(messages, openai.SystemMessage(systemMessage))
 	}
-	if ai.Prompt != "" {
-		messages = append(messages, openai.UserMessage(ai.Prompt))
+	if cfg.prompt != "" {
+		messages = append(messages, openai.UserMessage(cfg.prompt))
 	}
 
 	if f != nil {
-		file, err := f(ctx, &client)
+		file, err := f(ctx, client)
 		if err != nil {
-			return "", log.WrapError(err)
+			return params, log.WrapError(err)
 		}
 		messages = append(messages,
 			openai.UserMessage(
@@ -168,63 +580,129 @@ func (ai *AiCommunicationService) generateJsonContent(systemMessage string, f on
 		)
 	}
 
+	if err := cfg.safety.moderate(ctx, client, systemMessage, cfg.prompt); err != nil {
+		return params, err
+	}
+	params = openai.ChatCompletionNewParams{
+		Messages:    messages,
+		Model:       cfg.model,
+		Temperature: openai.Float(cfg.temperature),
+	}
+	cfg.safety.apply(&params)
+	if cfg.user != "" {
+		params.User = param.NewOpt(cfg.user)
+	}
+	return params, nil
+}
+
+// createChatCompletion stellt einen Chat-Request mit Rate-Limit und Wiederholungen bei
+// Rate-Limits, 5xx und Netzwerkfehlern.
+func (ai *AiCommunicationService) createChatCompletion(ctx context.Context, params openai.ChatCompletionNewParams, cfg requestConfig, estTokens int) (*openai.ChatCompletion, error) {
+	ctx, restoreLabels := ai.labelGoroutine(ctx, string(params.Model), cfg)
+	defer restoreLabels()
+	client := &ai.client
 	var chatCompletion *openai.ChatCompletion
 	var err error
-	for range 3 {
-		chatCompletion, err = client.Chat.Completions.New(ctx,
-			openai.ChatCompletionNewParams{
-				Messages:    messages,
-				Model:       ai.Model,
-				Temperature: openai.Float(ai.Temperature),
-			})
+	maxAttempts := ai.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	for attempt := range maxAttempts {
+		if ai.RateLimiter != nil {
+			if err := ai.RateLimiter.Wait(ctx, estTokens); err != nil {
+				return nil, log.WrapError(err)
+			}
+		}
+		chatCompletion, err = client.Chat.Completions.New(ctx, params, cfg.requestOptions()...)
 		if err != nil {
-			rawError := err.Error()
-			e, err1 := ParseOpenAIJsonError(rawError)
-			if err1 != nil {
-				e, err1 = ParseOpenAIPlainError(rawError)
+			e := parseAPIError(err)
+			if e == nil {
+				if isTransientNetworkError(err) && attempt < maxAttempts-1 {
+					log.Warn("chat completion failed, retrying: %v", err)
+					if err := sleepContext(ctx, ai.backoffPolicy().Delay(attempt)); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				if IsNetwork(err) || IsTimeout(err) || ctx.Err() != nil || errors.Is(err, ErrResidencyViolation) {
+					// ungewrappt, damit IsNetwork/IsTimeout und errors.Is beim Aufrufer greifen
+					return nil, err
+				}
+				return nil, log.WrapError(err)
 			}
-			if err1 != nil {
-				return "", log.WrapError(err)
+			e.receivedAt = time.Now()
+			if ai.Forecaster != nil {
+				ai.Forecaster.Observe(e.RateInfo)
 			}
-			if e.Status == 429 && e.Code == "rate_limit_exceeded" && e.RateInfo != nil {
-				// z.B. Backoff/Retry planen:
-				time.Sleep(e.RateInfo.RetryAfter + 100*time.Millisecond)
+			if ai.RateLimiter != nil && e.IsRateLimit() {
+				ai.RateLimiter.OnRateLimited(e.retryAfter())
+			}
+			if e.isRetryable() && attempt < maxAttempts-1 {
+				if wait, ok := e.RetryAfter(); ok && cfg.maxRetryAfter > 0 && wait > cfg.maxRetryAfter {
+					return nil, fmt.Errorf("%w: suggested wait %s exceeds %s: %w", ErrRetryAfterTooLong, wait.Round(time.Millisecond), cfg.maxRetryAfter, e)
+				}
+				if err := sleepContext(ctx, ai.retryDelay(e, attempt)); err != nil {
+					return nil, err
+				}
 			} else {
-				return "", log.WrapError(err)
+				// nicht wrappen, damit Aufrufer per errors.As (z.B. RetryAfter) auswerten können
+				return nil, e
 			}
 		} else {
+			if ai.RateLimiter != nil {
+				ai.RateLimiter.OnSuccess()
+			}
 			break
 		}
 	}
-	if err != nil {
-		return "", log.WrapError(err)
-	}
+	return chatCompletion, nil
+}
 
-	finishReason := chatCompletion.Choices[0].FinishReason
+// finishReasonError liefert den Fehler zu einer Finish-Reason; nil bei "stop".
+func finishReasonError(finishReason string) error {
 	switch finishReason {
 	case "stop":
 		log.Debug("Chat completion finished successfully.")
+		return nil
 	case "length":
-		return "", fmt.Errorf("chat completion reached maximum length")
+		return ErrMaxLength
 	case "content_filter":
-		return "", fmt.Errorf("Chat completion was filtered due to content policy.")
+		return ErrContentFiltered
 	case "tool_calls":
-		return "", fmt.Errorf("Chat completion used tool calls.")
+		return fmt.Errorf("Chat completion used tool calls.")
 	default:
-		return "", fmt.Errorf("Chat completion finished with unknown reason: %s", finishReason)
+		return fmt.Errorf("Chat completion finished with unknown reason: %s", finishReason)
 	}
+}
 
-	// Step 3: Kosten hinzufügen
-	ai.AddCosts(chatCompletion.Usage)
+// parseAPIError wertet den Fehlerstring des Clients aus; nil, wenn er keine Antwort der API
+// beschreibt, z.B. bei Netzwerkfehlern.
+func parseAPIError(err error) *OpenAIError {
+	rawError := err.Error()
+	e, err1 := ParseOpenAIJsonError(rawError)
+	if err1 != nil {
+		e, err1 = ParseOpenAIPlainError(rawError)
+	}
+	if err1 != nil {
+		return nil
+	}
+	return e
+}
 
-	resp := chatCompletion.Choices[0].Message
-	content := stripJSONWrapper(resp.Content)
-	if content == "" {
-		return "", fmt.Errorf("no content returned from OpenAI API")
+// retryDelay liefert die Wartezeit vor dem nächsten Versuch: die Vorgabe des Servers,
+// falls vorhanden, sonst die Backoff-Policy.
+func (ai *AiCommunicationService) retryDelay(e *OpenAIError, attempt int) time.Duration {
+	if wait, ok := e.RetryAfter(); ok {
+		return wait + 100*time.Millisecond
 	}
-	log.Debug("Content from OpenAI: %s", content)
+	return ai.backoffPolicy().Delay(attempt)
+}
 
-	return content, nil
+func (ai *AiCommunicationService) backoffPolicy() BackoffPolicy {
+	if ai.Backoff != nil {
+		return *ai.Backoff
+	}
+	return DefaultBackoffPolicy
 }
 
 func stripJSONWrapper(data string) string {
@@ -242,41 +720,3 @@ func stripJSONWrapper(data string) string {
 	}
 	return data
 }
-
-func convertDir(systemMessage, prompt, srcFolder, destFolder string) error {
-	aiService := NewAiCommunicationService(prompt)
-
-	entries, err := os.ReadDir(srcFolder)
-	if err != nil {
-		return err
-	}
-
-	if err := os.MkdirAll(destFolder, 0755); err != nil {
-		return fmt.Errorf("failed to create destination folder: %w", err)
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		if err := aiService.convertFile(systemMessage, srcFolder, destFolder, entry.Name()); err != nil {
-			return err
-		}
-
-		log.Info("Converted file: %s", entry.Name())
-	}
-	return nil
-}
-
-func (aiService *AiCommunicationService) convertFile(systemMessage, srcFolder, destFolder, fileName string) error {
-	content, err := aiService.GenerateContentWithPDF(systemMessage, srcFolder+"/"+fileName)
-	if err != nil {
-		return fmt.Errorf("failed to generate content from PDF %s: %w", fileName, err)
-	}
-	destFilePath := filepath.Join(destFolder, fileName)
-	if err := os.WriteFile(destFilePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write content to file %s: %w", destFilePath, err)
-	}
-	return nil
-}
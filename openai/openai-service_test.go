@@ -0,0 +1,298 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+const testChatCompletion = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"created": 1700000000,
+	"model": "gpt-4.1",
+	"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "{\"ok\": true}"}}],
+	"usage": {"prompt_tokens": 100, "completion_tokens": 20, "total_tokens": 120}
+}`
+
+func newTestService(t *testing.T, handler http.HandlerFunc) *AiCommunicationService {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	ai := NewAiCommunicationService("prompt")
+	ai.config.AuthData["apiKey"] = "test-key"
+	ai.ClientOptions = []option.RequestOption{option.WithBaseURL(srv.URL)}
+	return ai
+}
+
+func TestGenerateContent_Concurrent(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+
+	const n = 20
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := ai.GenerateContent("system")
+			require.NoError(t, err)
+			require.Equal(t, `{"ok": true}`, content)
+			_ = ai.TotalCosts()
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, n, calls.Load())
+	require.Len(t, ai.Costs, n)
+	require.InDelta(t, n*costOf(100, 20), ai.TotalCosts(), 1e-9)
+}
+
+func costOf(pt, ct int64) float64 {
+	cost, _, _ := costFor(pt, ct)
+	return cost
+}
+
+func TestGenerateContent_RequestTimeout(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	})
+
+	start := time.Now()
+	_, err := ai.GenerateContent("system", WithRequestTimeout(50*time.Millisecond))
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestGenerateContent_BackoffWithoutRetryHint(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error": {"message": "Too many requests, slow down.", "type": "requests", "param": null, "code": "rate_limit_exceeded"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	ai.Backoff = &BackoffPolicy{Initial: 20 * time.Millisecond, Multiplier: 2}
+
+	start := time.Now()
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.EqualValues(t, 3, calls.Load())
+	require.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+}
+
+func TestGenerateContent_NoClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "Too many requests, slow down.", "type": "requests", "param": null, "code": "rate_limit_exceeded"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	ai := NewAiCommunicationService("prompt")
+	ai.config.AuthData["apiKey"] = "test-key"
+	ai.ClientOptions = []option.RequestOption{option.WithBaseURL(srv.URL)}
+	ai.Backoff = &BackoffPolicy{Initial: time.Millisecond}
+	ai.MaxAttempts = 3
+
+	_, err := ai.GenerateContent("system")
+	require.Error(t, err)
+	require.EqualValues(t, 3, calls.Load(), "one request per attempt, no retries inside the client")
+}
+
+func TestGenerateContent_RateLimitedExposesRetryAfter(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "Rate limit reached for gpt-4.1 in organization org-1 on tokens per min (TPM): Limit 30000, Used 30000, Requested 1741. Please try again in 2s. Visit https://platform.openai.com/account/rate-limits to learn more.", "type": "tokens", "param": null, "code": "rate_limit_exceeded"}}`))
+	})
+	ai.MaxAttempts = 1
+
+	_, err := ai.GenerateContent("system")
+	var oe *OpenAIError
+	require.ErrorAs(t, err, &oe)
+	wait, ok := oe.RetryAfter()
+	require.True(t, ok)
+	require.Greater(t, wait, time.Second)
+	require.LessOrEqual(t, wait, 2*time.Second)
+}
+
+func TestGenerateContent_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error": {"message": "overloaded", "type": "server_error", "param": null, "code": null}}`))
+		case 2:
+			// Verbindung ohne Antwort schließen
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			_ = conn.Close()
+		default:
+			_, _ = w.Write([]byte(testChatCompletion))
+		}
+	})
+	ai.Backoff = &BackoffPolicy{Initial: time.Millisecond}
+
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.EqualValues(t, 3, calls.Load())
+}
+
+func TestGenerateContent_NoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": {"message": "bad", "type": "invalid_request_error", "param": null, "code": null}}`))
+	})
+	ai.Backoff = &BackoffPolicy{Initial: time.Millisecond}
+
+	_, err := ai.GenerateContent("system")
+	require.Error(t, err)
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestGenerateContent_CancelDuringRetryWait(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "Rate limit reached for gpt-4.1 in organization org-1 on tokens per min (TPM): Limit 30000, Used 30000, Requested 1741. Please try again in 30s. Visit https://platform.openai.com/account/rate-limits to learn more.", "type": "tokens", "param": null, "code": "rate_limit_exceeded"}}`))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ai.GenerateContentContext(ctx, "system")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestGenerateContentContext_CancelStuckRequest(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/files" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id": "file-1", "object": "file", "bytes": 16, "created_at": 1700000000, "filename": "a.pdf", "purpose": "user_data"}`))
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	fileName := filepath.Join(t.TempDir(), "a.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := ai.GenerateContentWithPDFContext(ctx, "system", fileName)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ai.GenerateContentContext(ctx, "system")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualValues(t, 3, calls.Load(), "no retries after the context ended")
+}
+
+func TestGenerateContent_MaxRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "Rate limit reached for gpt-4.1 in organization org-1 on tokens per min (TPM): Limit 30000, Used 30000, Requested 1741. Please try again in 3600s. Visit https://platform.openai.com/account/rate-limits to learn more.", "type": "tokens", "param": null, "code": "rate_limit_exceeded"}}`))
+	})
+
+	start := time.Now()
+	_, err := ai.GenerateContent("system", WithMaxRetryAfter(time.Minute))
+	require.Less(t, time.Since(start), time.Second)
+	require.ErrorIs(t, err, ErrRetryAfterTooLong)
+	require.EqualValues(t, 1, calls.Load())
+
+	var oe *OpenAIError
+	require.ErrorAs(t, err, &oe)
+	wait, ok := oe.RetryAfter()
+	require.True(t, ok)
+	require.Greater(t, wait, 59*time.Minute)
+	require.Equal(t, http.StatusTooManyRequests, HTTPStatusFor(err))
+}
+
+func TestGenerateContent_CompletionEvent(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "chatcmpl-2", "object": "chat.completion", "created": 1700000000, "model": "gpt-4.1",
+			"choices": [{
+				"index": 0, "finish_reason": "content_filter",
+				"message": {"role": "assistant", "content": null, "refusal": "I can't help with that."},
+				"content_filter_results": {"hate": {"filtered": false}, "violence": {"filtered": true, "severity": "medium"}}
+			}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 0, "total_tokens": 10}
+		}`))
+	})
+	var events []Event
+	ai.Hooks = []EventHook{func(ev Event) { events = append(events, ev) }}
+	ai.PromptVersion = "v3"
+
+	_, err := ai.GenerateContent("system", WithDocumentType("invoice"))
+	require.ErrorIs(t, err, ErrContentFiltered)
+
+	require.Len(t, events, 1)
+	require.Equal(t, EventCompletion, events[0].Type)
+	fields := events[0].Fields
+	require.Equal(t, "content_filter", fields["finishReason"])
+	require.Equal(t, "v3", fields["promptVersion"])
+	require.Equal(t, "invoice", fields["documentType"])
+	require.Equal(t, true, fields["refused"])
+	require.Equal(t, "I can't help with that.", fields["refusal"])
+	require.Equal(t, []string{"violence"}, fields["filteredCategories"])
+}
+
+func TestGenerateContent_UserAgent(t *testing.T) {
+	var userAgent string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+
+	_, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, UserAgent(), userAgent)
+	require.True(t, strings.HasPrefix(userAgent, "myailib/v"+Version+" "), userAgent)
+}
@@ -0,0 +1,233 @@
+package openai
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// DiffKind beschreibt die Art einer Änderung.
+type DiffKind string
+
+const (
+	DiffAdded     DiffKind = "added"
+	DiffRemoved   DiffKind = "removed"
+	DiffChanged   DiffKind = "changed"
+	DiffUnchanged DiffKind = "unchanged"
+)
+
+// FieldChange ist die Änderung eines Feldes. Path benennt es wie "items[0].total";
+// leer steht für das ganze Dokument, z.B. wenn es kein JSON ist.
+type FieldChange struct {
+	Path string   `json:"path"`
+	Kind DiffKind `json:"kind"`
+	Old  any      `json:"old,omitempty"`
+	New  any      `json:"new,omitempty"`
+}
+
+// FileDiff fasst die Änderungen einer Ergebnisdatei zusammen.
+type FileDiff struct {
+	File    string        `json:"file"`
+	Kind    DiffKind      `json:"kind"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// DiffReport ist der Vergleich zweier Batch-Läufe, z.B. vor und nach einem Prompt- oder
+// Modellwechsel. Fields zählt je Feldpfad (ohne Array-Indizes) die geänderten Dokumente.
+type DiffReport struct {
+	Files     []FileDiff     `json:"files"`
+	Added     int            `json:"added"`
+	Removed   int            `json:"removed"`
+	Changed   int            `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+	Fields    map[string]int `json:"fields"`
+}
+
+// DiffOutputs vergleicht die Ergebnisdateien zweier Zielverzeichnisse feldweise. Zahlen
+// werden kanonisch verglichen (1.50 = 1.5); Journal und Herkunftsdateien werden ignoriert.
+func DiffOutputs(oldDir, newDir string) (*DiffReport, error) {
+	oldFiles, err := outputFiles(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := outputFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DiffReport{Files: []FileDiff{}, Fields: map[string]int{}}
+	for _, name := range unionKeys(oldFiles, newFiles) {
+		fd := FileDiff{File: name}
+		_, inOld := oldFiles[name]
+		_, inNew := newFiles[name]
+		switch {
+		case !inNew:
+			fd.Kind = DiffRemoved
+			report.Removed++
+		case !inOld:
+			fd.Kind = DiffAdded
+			report.Added++
+		default:
+			fd.Changes, err = diffFiles(filepath.Join(oldDir, name), filepath.Join(newDir, name))
+			if err != nil {
+				return nil, err
+			}
+			fd.Kind = DiffUnchanged
+			if len(fd.Changes) > 0 {
+				fd.Kind = DiffChanged
+			}
+		}
+		switch fd.Kind {
+		case DiffChanged:
+			report.Changed++
+			seen := map[string]bool{}
+			for _, c := range fd.Changes {
+				field := fieldName(c.Path)
+				if !seen[field] {
+					seen[field] = true
+					report.Fields[field]++
+				}
+			}
+		case DiffUnchanged:
+			report.Unchanged++
+		}
+		report.Files = append(report.Files, fd)
+	}
+	return report, nil
+}
+
+// outputFiles liefert die Ergebnisdateien eines Verzeichnisses.
+func outputFiles(dir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	files := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, provenanceSuffix) {
+			continue
+		}
+		files[name] = true
+	}
+	return files, nil
+}
+
+func diffFiles(oldFile, newFile string) ([]FieldChange, error) {
+	oldData, err := ReadDecompressed(oldFile)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := ReadDecompressed(newFile)
+	if err != nil {
+		return nil, err
+	}
+
+	oldValue, oldErr := decodeCanonical(oldData, NumberFloat64)
+	newValue, newErr := decodeCanonical(newData, NumberFloat64)
+	if oldErr != nil || newErr != nil {
+		if string(oldData) == string(newData) {
+			return nil, nil
+		}
+		return []FieldChange{{Kind: DiffChanged, Old: string(oldData), New: string(newData)}}, nil
+	}
+	changes := []FieldChange{}
+	diffValues("", oldValue, newValue, &changes)
+	return changes, nil
+}
+
+// decodeCanonical liest JSON in kanonischer Form, Zahlen gemäß mode.
+func decodeCanonical(data []byte, mode NumberMode) (any, error) {
+	canonical, err := CanonicalJSON(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := DecodeJSON([]byte(canonical), &v, mode); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func diffValues(path string, oldValue, newValue any, changes *[]FieldChange) {
+	switch o := oldValue.(type) {
+	case map[string]any:
+		n, ok := newValue.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(o, n) {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			ov, inOld := o[key]
+			nv, inNew := n[key]
+			switch {
+			case !inNew:
+				*changes = append(*changes, FieldChange{Path: child, Kind: DiffRemoved, Old: ov})
+			case !inOld:
+				*changes = append(*changes, FieldChange{Path: child, Kind: DiffAdded, New: nv})
+			default:
+				diffValues(child, ov, nv, changes)
+			}
+		}
+		return
+	case []any:
+		n, ok := newValue.([]any)
+		if !ok {
+			break
+		}
+		for i := range max(len(o), len(n)) {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(n):
+				*changes = append(*changes, FieldChange{Path: child, Kind: DiffRemoved, Old: o[i]})
+			case i >= len(o):
+				*changes = append(*changes, FieldChange{Path: child, Kind: DiffAdded, New: n[i]})
+			default:
+				diffValues(child, o[i], n[i], changes)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*changes = append(*changes, FieldChange{Path: path, Kind: DiffChanged, Old: oldValue, New: newValue})
+	}
+}
+
+// unionKeys liefert die Schlüssel beider Maps sortiert.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := slices.Collect(maps.Keys(a))
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// fieldName entfernt Array-Indizes aus dem Pfad: "items[3].total" -> "items[].total".
+func fieldName(path string) string {
+	var b strings.Builder
+	skip := false
+	for _, r := range path {
+		switch {
+		case r == '[':
+			skip = true
+			b.WriteString("[]")
+		case r == ']':
+			skip = false
+		case !skip:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffOutputs(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write(oldDir, "a.pdf", `{"total": 1.50, "items": [{"sku": "x", "qty": 1}]}`)
+	write(newDir, "a.pdf", `{"total":1.5,"items":[{"sku":"x","qty":1}]}`)
+	write(oldDir, "b.pdf", `{"total": 2, "items": [{"sku": "x", "qty": 1}], "note": "n"}`)
+	write(newDir, "b.pdf", `{"total": 3, "items": [{"sku": "y", "qty": 1}, {"sku": "z", "qty": 2}], "currency": "EUR"}`)
+	write(oldDir, "c.pdf", `{}`)
+	write(newDir, "d.pdf", `{}`)
+	write(newDir, journalFileName, "{}")
+	write(newDir, "b.pdf"+provenanceSuffix, "{}")
+
+	report, err := DiffOutputs(oldDir, newDir)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Unchanged)
+	require.Equal(t, 1, report.Changed)
+	require.Equal(t, 1, report.Removed)
+	require.Equal(t, 1, report.Added)
+	require.Len(t, report.Files, 4)
+
+	b := report.Files[1]
+	require.Equal(t, "b.pdf", b.File)
+	require.Equal(t, DiffChanged, b.Kind)
+	require.Equal(t, []FieldChange{
+		{Path: "currency", Kind: DiffAdded, New: "EUR"},
+		{Path: "items[0].sku", Kind: DiffChanged, Old: "x", New: "y"},
+		{Path: "items[1]", Kind: DiffAdded, New: map[string]any{"sku": "z", "qty": 2.0}},
+		{Path: "note", Kind: DiffRemoved, Old: "n"},
+		{Path: "total", Kind: DiffChanged, Old: 2.0, New: 3.0},
+	}, b.Changes)
+	require.Equal(t, map[string]int{"currency": 1, "items[].sku": 1, "items[]": 1, "note": 1, "total": 1}, report.Fields)
+}
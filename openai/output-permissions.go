@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// FileOwner ist der Eigentümer geschriebener Dateien (nur Unix); -1 lässt UID bzw. GID unverändert.
+type FileOwner struct {
+	UID int
+	GID int
+}
+
+// OutputPermissions legt Rechte und Eigentümer der Ergebnisse fest, z.B. wenn sie ein
+// anderer Dienst-Account weiterverarbeitet. Gesetzte Modi gelten unabhängig von der umask.
+type OutputPermissions struct {
+	FileMode os.FileMode // Default: 0644 abzüglich umask
+	DirMode  os.FileMode // für neu angelegte Verzeichnisse; Default: 0755 abzüglich umask
+	Owner    *FileOwner  // optional; erfordert entsprechende Rechte des Prozesses
+}
+
+// writeFile schreibt data nach name und setzt Modus und Eigentümer.
+func (p OutputPermissions) writeFile(name string, data []byte) error {
+	if err := os.WriteFile(name, data, p.fileMode()); err != nil {
+		return log.WrapError(err)
+	}
+	return p.apply(name, p.FileMode)
+}
+
+// mkdirAll legt dir samt fehlender Elternverzeichnisse an. Modus und Eigentümer werden nur
+// für neu angelegte Verzeichnisse gesetzt, vorhandene bleiben unverändert.
+func (p OutputPermissions) mkdirAll(dir string) error {
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		created = append(created, d)
+	}
+	if err := os.MkdirAll(dir, p.dirMode()); err != nil {
+		return log.WrapError(err)
+	}
+	for _, d := range created {
+		if err := p.apply(d, p.DirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p OutputPermissions) apply(name string, mode os.FileMode) error {
+	if mode != 0 {
+		if err := os.Chmod(name, mode); err != nil {
+			return log.WrapError(err)
+		}
+	}
+	if p.Owner != nil {
+		if err := os.Chown(name, p.Owner.UID, p.Owner.GID); err != nil {
+			return log.WrapError(err)
+		}
+	}
+	return nil
+}
+
+func (p OutputPermissions) fileMode() os.FileMode {
+	if p.FileMode != 0 {
+		return p.FileMode
+	}
+	return 0644
+}
+
+func (p OutputPermissions) dirMode() os.FileMode {
+	if p.DirMode != 0 {
+		return p.DirMode
+	}
+	return 0755
+}
@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputPermissions(t *testing.T) {
+	if os.Getuid() < 0 {
+		t.Skip("file modes and owners are Unix only")
+	}
+	base := t.TempDir()
+	require.NoError(t, os.Chmod(base, 0700))
+	// Modi, die eine übliche umask (022) kürzen würde
+	perm := OutputPermissions{FileMode: 0666, DirMode: 0777, Owner: &FileOwner{UID: -1, GID: os.Getgid()}}
+
+	dir := filepath.Join(base, "a", "b")
+	require.NoError(t, perm.mkdirAll(dir))
+	for _, d := range []string{filepath.Join(base, "a"), dir} {
+		info, err := os.Stat(d)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0777), info.Mode().Perm(), d)
+	}
+	info, err := os.Stat(base)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm(), "existing folders stay unchanged")
+
+	name := filepath.Join(dir, "a.json")
+	require.NoError(t, perm.writeFile(name, []byte("{}")))
+	info, err = os.Stat(name)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0666), info.Mode().Perm())
+}
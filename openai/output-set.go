@@ -0,0 +1,94 @@
+package openai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// outputSetPrefix kennzeichnet Arbeitsverzeichnisse von outputSet im Zielordner.
+const outputSetPrefix = ".myailib-tx-"
+
+// outputSet legt zusammengehörige Dateien eines Ergebnisses (z.B. JSON und Herkunftsdatei)
+// gemeinsam ab: Alle Dateien werden zuerst in ein Arbeitsverzeichnis im Zielordner
+// geschrieben und erst danach per Rename an ihren Platz gebracht, die Hauptdatei zuletzt.
+// Wer auf die Hauptdatei wartet, sieht so nie einen unvollständigen Satz.
+type outputSet struct {
+	perm  OutputPermissions
+	names []string // Zieldateien, names[0] ist die Hauptdatei
+	data  [][]byte
+	// stageDir nimmt das Arbeitsverzeichnis auf; leer = Ordner der Hauptdatei.
+	stageDir string
+}
+
+func newOutputSet(perm OutputPermissions) *outputSet {
+	return &outputSet{perm: perm}
+}
+
+// add merkt eine Datei vor; die erste ist die Hauptdatei.
+func (s *outputSet) add(name string, data []byte) {
+	s.names = append(s.names, name)
+	s.data = append(s.data, data)
+}
+
+// commit schreibt alle Dateien. Schlägt ein Schritt fehl, werden bereits abgelegte Dateien
+// des Satzes wieder entfernt.
+func (s *outputSet) commit() error {
+	if len(s.names) == 0 {
+		return nil
+	}
+	stageDir := s.stageDir
+	if stageDir == "" {
+		stageDir = filepath.Dir(s.names[0])
+	}
+	dir, err := os.MkdirTemp(stageDir, outputSetPrefix+"*")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	defer os.RemoveAll(dir)
+
+	staged := make([]string, len(s.names))
+	for i, name := range s.names {
+		staged[i] = filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(name)))
+		if err := s.perm.writeFile(staged[i], s.data[i]); err != nil {
+			return err
+		}
+	}
+	var moved []string
+	for i := len(s.names) - 1; i >= 0; i-- {
+		if err := os.Rename(staged[i], s.names[i]); err != nil {
+			for _, name := range moved {
+				os.Remove(name)
+			}
+			return log.WrapError(err)
+		}
+		moved = append(moved, s.names[i])
+	}
+	return nil
+}
+
+// removeStaleOutputSets entfernt Arbeitsverzeichnisse abgebrochener Läufe, die älter als
+// olderThan sind. Mit 0 nur aufrufen, während der Ordner gesperrt ist; in Ordnern, die
+// mehrere Läufe teilen (z.B. ObjectStore), mit einer Frist über der Dauer eines Schreibvorgangs.
+func removeStaleOutputSets(dir string, olderThan time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), outputSetPrefix) {
+			continue
+		}
+		if info, err := entry.Info(); olderThan > 0 && (err != nil || time.Since(info.ModTime()) < olderThan) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			log.Warn("failed to remove stale output set %s: %v", path, err)
+		}
+	}
+}
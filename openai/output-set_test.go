@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputSet_Commit(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "a.json")
+
+	set := newOutputSet(OutputPermissions{})
+	set.add(main, []byte(`{"a": 1}`))
+	set.add(main+provenanceSuffix, []byte(`{}`))
+	require.NoError(t, set.commit())
+
+	data, err := os.ReadFile(main)
+	require.NoError(t, err)
+	require.Equal(t, `{"a": 1}`, string(data))
+	require.FileExists(t, main+provenanceSuffix)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "no staging folder left behind")
+}
+
+func TestOutputSet_Rollback(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "a.json")
+	// ein nicht leeres Verzeichnis an der Stelle der Herkunftsdatei lässt den Rename scheitern
+	blocked := main + provenanceSuffix
+	require.NoError(t, os.MkdirAll(filepath.Join(blocked, "x"), 0755))
+	meta := filepath.Join(dir, "a.meta.json")
+
+	set := newOutputSet(OutputPermissions{})
+	set.add(main, []byte(`{}`))
+	set.add(blocked, []byte(`{}`))
+	set.add(meta, []byte(`{}`))
+	require.Error(t, set.commit())
+
+	require.NoFileExists(t, main)
+	require.NoFileExists(t, meta)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestRemoveStaleOutputSets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, outputSetPrefix+"123"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "keep"), 0755))
+
+	removeStaleOutputSets(dir, 0)
+	require.NoDirExists(t, filepath.Join(dir, outputSetPrefix+"123"))
+	require.DirExists(t, filepath.Join(dir, "keep"))
+}
@@ -0,0 +1,148 @@
+package openai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Namen der mitgelieferten Post-Prozessoren.
+const (
+	PostProcessStripFences    = "strip-fences"
+	PostProcessFixEncoding    = "fix-encoding"
+	PostProcessNormalizeDates = "normalize-dates"
+	PostProcessGermanNumbers  = "german-numbers"
+)
+
+// PostProcessor bereinigt den Inhalt einer Antwort, bevor er an den Aufrufer geht.
+type PostProcessor func(content string) (string, error)
+
+var (
+	postProcessorsMu sync.RWMutex
+	postProcessors   = map[string]PostProcessor{
+		PostProcessStripFences:    stripCodeFences,
+		PostProcessFixEncoding:    fixEncoding,
+		PostProcessNormalizeDates: NewDatePostProcessor(LocaleDE),
+		PostProcessGermanNumbers:  NewNumberPostProcessor(LocaleDE),
+	}
+)
+
+// RegisterPostProcessor registriert einen Post-Prozessor unter dem angegebenen Namen.
+// Ein bereits vorhandener Eintrag wird überschrieben.
+func RegisterPostProcessor(name string, p PostProcessor) error {
+	if name == "" {
+		return fmt.Errorf("post processor name must not be empty")
+	}
+	if p == nil {
+		return fmt.Errorf("post processor %q is nil", name)
+	}
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+	postProcessors[name] = p
+	return nil
+}
+
+// LookupPostProcessor liefert den unter name registrierten Post-Prozessor.
+func LookupPostProcessor(name string) (PostProcessor, bool) {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+	p, ok := postProcessors[name]
+	return p, ok
+}
+
+// ApplyPostProcessors wendet die genannten Post-Prozessoren in der angegebenen Reihenfolge an.
+func ApplyPostProcessors(content string, names ...string) (string, error) {
+	for _, name := range names {
+		p, ok := LookupPostProcessor(name)
+		if !ok {
+			return "", fmt.Errorf("unknown post processor: %s", name)
+		}
+		var err error
+		content, err = p(content)
+		if err != nil {
+			return "", fmt.Errorf("post processor %s failed: %w", name, err)
+		}
+	}
+	return content, nil
+}
+
+// stripCodeFences entfernt Markdown-Codeblöcke (```json ... ``` oder ``` ... ```) um die Antwort.
+func stripCodeFences(content string) (string, error) {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "```") {
+		return stripJSONWrapper(content), nil
+	}
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) < 2 {
+		return content, nil
+	}
+	end := len(lines)
+	if strings.TrimSpace(lines[end-1]) == "```" {
+		end--
+	}
+	return strings.Join(lines[1:end], "\n"), nil
+}
+
+// fixEncoding entfernt BOM/Zero-Width-Zeichen und repariert typische UTF-8/Latin-1-Verwechslungen,
+// siehe CleanText.
+func fixEncoding(content string) (string, error) {
+	return CleanText(content), nil
+}
+
+var jsonStringLiteralRe = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+// mapStringLiterals ruft fn für jeden JSON-String-Wert auf und ersetzt ihn durch das Ergebnis,
+// falls fn true meldet. Das Ergebnis wird unverändert (also inkl. eventueller Anführungszeichen) eingesetzt.
+// Objektschlüssel (Literale, auf die ein ":" folgt) bleiben unberührt.
+func mapStringLiterals(content string, fn func(value string) (string, bool)) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range jsonStringLiteralRe.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if strings.HasPrefix(strings.TrimLeft(content[end:], " \t\r\n"), ":") {
+			continue
+		}
+		if replaced, ok := fn(content[start+1 : end-1]); ok {
+			b.WriteString(content[last:start])
+			b.WriteString(replaced)
+			last = end
+		}
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// NewDatePostProcessor liefert einen Post-Prozessor, der Datumswerte im Format der Locale
+// in ISO 8601 umwandelt.
+func NewDatePostProcessor(loc Locale) PostProcessor {
+	return func(content string) (string, error) {
+		return mapStringLiterals(content, func(value string) (string, bool) {
+			date, err := NormalizeDate(value, loc)
+			if err != nil {
+				return "", false
+			}
+			return `"` + date + `"`, true
+		}), nil
+	}
+}
+
+var numericLiteralRe = regexp.MustCompile(`^-?[\d.,' ]+-?$`)
+
+// NewNumberPostProcessor liefert einen Post-Prozessor, der Zahlen im Format der Locale
+// ("1.234,56") durch JSON-Zahlen (1234.56) ersetzt, exakt auch bei großen Beträgen. Werte mit Währung bleiben unverändert,
+// ebenso Werte ohne Dezimaltrenner (PLZ, Kontonummern).
+func NewNumberPostProcessor(loc Locale) PostProcessor {
+	return func(content string) (string, error) {
+		return mapStringLiterals(content, func(value string) (string, bool) {
+			if !numericLiteralRe.MatchString(value) || !strings.Contains(value, loc.DecimalSeparator) {
+				return "", false
+			}
+			d, currency, err := NormalizeDecimal(value, loc)
+			if err != nil || currency != "" {
+				return "", false
+			}
+			return d.trimmed().String(), true
+		}), nil
+	}
+}
@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPostProcessors_German(t *testing.T) {
+	raw := "```json\n{\"datum\": \"31.12.2024\", \"betrag\": \"1.234,56\", \"firma\": \"MÃ¼ller GmbH\"}\n```"
+
+	content, err := ApplyPostProcessors(raw,
+		PostProcessStripFences,
+		PostProcessFixEncoding,
+		PostProcessNormalizeDates,
+		PostProcessGermanNumbers,
+	)
+	require.NoError(t, err)
+	require.Equal(t, `{"datum": "2024-12-31", "betrag": 1234.56, "firma": "Müller GmbH"}`, content)
+}
+
+func TestApplyPostProcessors_Unknown(t *testing.T) {
+	_, err := ApplyPostProcessors("{}", "does-not-exist")
+	require.Error(t, err)
+}
+
+func TestRegisterPostProcessor(t *testing.T) {
+	err := RegisterPostProcessor("upper", func(content string) (string, error) {
+		return strings.ToUpper(content), nil
+	})
+	require.NoError(t, err)
+
+	content, err := ApplyPostProcessors("abc", "upper")
+	require.NoError(t, err)
+	require.Equal(t, "ABC", content)
+
+	require.Error(t, RegisterPostProcessor("", nil))
+}
+
+func TestNormalizeDates_InvalidDate(t *testing.T) {
+	content, err := ApplyPostProcessors(`{"faellig": "31.02.2024"}`, PostProcessNormalizeDates)
+	require.NoError(t, err)
+	require.Equal(t, `{"faellig": "31.02.2024"}`, content)
+}
+
+func TestGermanNumbers_KeepsCodesAndCurrencies(t *testing.T) {
+	content, err := ApplyPostProcessors(`{"plz": "01067", "betrag": "1.234,56 EUR", "netto": "99,90"}`, PostProcessGermanNumbers)
+	require.NoError(t, err)
+	require.Equal(t, `{"plz": "01067", "betrag": "1.234,56 EUR", "netto": 99.9}`, content)
+}
+
+func TestPostProcessors_KeepObjectKeys(t *testing.T) {
+	raw := `{"31.12.2024": "01.01.2025", "1,5" : "2,5", "liste": ["3,5"]}`
+	content, err := ApplyPostProcessors(raw, PostProcessNormalizeDates, PostProcessGermanNumbers)
+	require.NoError(t, err)
+	require.Equal(t, `{"31.12.2024": "2025-01-01", "1,5" : 2.5, "liste": [3.5]}`, content)
+}
@@ -0,0 +1,42 @@
+package openai
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Namen der pprof-Labels, mit denen Requests in CPU- und Heap-Profilen erscheinen, z.B.
+// "go tool pprof -tagfocus=job=nightly-invoices".
+const (
+	LabelModel     = "model"
+	LabelTenant    = "tenant"
+	LabelComponent = "component"
+	LabelJob       = "job"
+)
+
+// labelGoroutine versieht die laufende Goroutine für die Dauer eines Requests mit Modell,
+// Mandant und Komponente; Labels aus ctx, z.B. der Job von BatchConverter.Job, bleiben
+// erhalten. Goroutinen, die währenddessen starten, erben die Labels. Die zurückgegebene
+// Funktion stellt die Labels von ctx wieder her.
+func (ai *AiCommunicationService) labelGoroutine(ctx context.Context, model string, cfg requestConfig) (context.Context, func()) {
+	labels := []string{LabelModel, model}
+	if ai.tenant != "" {
+		labels = append(labels, LabelTenant, ai.tenant)
+	}
+	if cfg.component != "" {
+		labels = append(labels, LabelComponent, cfg.component)
+	}
+	labeled := pprof.WithLabels(ctx, pprof.Labels(labels...))
+	pprof.SetGoroutineLabels(labeled)
+	return labeled, func() { pprof.SetGoroutineLabels(ctx) }
+}
+
+// withJobLabel setzt das Job-Label auf ctx und die laufende Goroutine; leer = unverändert.
+func withJobLabel(ctx context.Context, job string) (context.Context, func()) {
+	if job == "" {
+		return ctx, func() {}
+	}
+	labeled := pprof.WithLabels(ctx, pprof.Labels(LabelJob, job))
+	pprof.SetGoroutineLabels(labeled)
+	return labeled, func() { pprof.SetGoroutineLabels(ctx) }
+}
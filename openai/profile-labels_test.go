@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+// labelRecorder merkt sich die pprof-Labels der Chat-Requests.
+type labelRecorder struct {
+	mu     sync.Mutex
+	labels []map[string]string
+}
+
+func (l *labelRecorder) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if req.URL.Path == "/chat/completions" {
+		labels := map[string]string{}
+		pprof.ForLabels(req.Context(), func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+		l.mu.Lock()
+		l.labels = append(l.labels, labels)
+		l.mu.Unlock()
+	}
+	return next(req)
+}
+
+func TestProfileLabels(t *testing.T) {
+	rec := &labelRecorder{}
+	base := newBatchTestService(t, func() string { return `{"ok": true}` })
+	base.ClientOptions = append(base.ClientOptions, option.WithMiddleware(rec.middleware))
+
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{})
+	_, err := ts.GenerateContent("acme", "system", WithComponent("reports"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		LabelModel:     string(base.Model),
+		LabelTenant:    "acme",
+		LabelComponent: "reports",
+	}, rec.labels[0])
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	bc := NewBatchConverter(base, "system", src, filepath.Join(t.TempDir(), "out"))
+	bc.Job = "nightly-invoices"
+	_, err = bc.RunContext(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "nightly-invoices", rec.labels[1][LabelJob])
+	require.Equal(t, string(base.Model), rec.labels[1][LabelModel])
+	require.NotContains(t, rec.labels[1], LabelTenant)
+}
@@ -0,0 +1,182 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// TruncationStrategy legt fest, welcher Teil einer zu langen System-Message wegfällt.
+type TruncationStrategy string
+
+const (
+	TruncateHead      TruncationStrategy = "head"       // Anfang entfernen, Ende behalten
+	TruncateTail      TruncationStrategy = "tail"       // Ende entfernen
+	TruncateMiddleOut TruncationStrategy = "middle-out" // Mitte entfernen, Anfang und Ende behalten
+	TruncateSentence  TruncationStrategy = "sentence"   // Ende entfernen, an einer Satzgrenze
+)
+
+const (
+	// DefaultReserveTokens bleibt im Kontextfenster für die Antwort frei.
+	DefaultReserveTokens = 4096
+	// truncationMarker ersetzt bei TruncateMiddleOut den entfernten Teil.
+	truncationMarker = "\n[...]\n"
+)
+
+// contextWindows enthält die Kontextfenster (Tokens) der gängigen Modelle.
+var contextWindows = map[openai.ChatModel]int{
+	openai.ChatModelGPT4_1:     1_047_576,
+	openai.ChatModelGPT4_1Mini: 1_047_576,
+	openai.ChatModelGPT4_1Nano: 1_047_576,
+	openai.ChatModelGPT4o:      128_000,
+	openai.ChatModelGPT4oMini:  128_000,
+	openai.ChatModelO3:         200_000,
+	openai.ChatModelO4Mini:     200_000,
+	openai.ChatModelGPT4Turbo:  128_000,
+	openai.ChatModelGPT4:       8_192,
+}
+
+// ContextWindow liefert das Kontextfenster des Modells in Tokens; 0, wenn es unbekannt ist.
+// Datierte Varianten wie "gpt-4o-2024-08-06" zählen wie das Basismodell.
+func ContextWindow(model openai.ChatModel) int {
+	if n, ok := contextWindows[model]; ok {
+		return n
+	}
+	best, n := "", 0
+	for m, size := range contextWindows {
+		if strings.HasPrefix(string(model), string(m)+"-") && len(m) > len(best) {
+			best, n = string(m), size
+		}
+	}
+	return n
+}
+
+// TruncationPolicy kürzt die System-Message, wenn sie zusammen mit dem Prompt das Budget
+// übersteigt. Statt eines Fehlers der API oder eines Requests über dem Limit läuft der
+// Aufruf dann mit gekürzter Eingabe; die Kürzung steht als Warnung im Log und in
+// Result.Warnings. Der Prompt selbst und mitgeschickte Dateien werden nicht gekürzt.
+type TruncationPolicy struct {
+	Strategy TruncationStrategy // Default: TruncateTail
+	// MaxTokens ist das Budget für System-Message und Prompt (geschätzt, 4 Zeichen je Token).
+	// 0 = Kontextfenster des Modells abzüglich ReserveTokens.
+	MaxTokens     int
+	ReserveTokens int // Default: DefaultReserveTokens
+}
+
+// WithTruncation ersetzt für diesen Aufruf die TruncationPolicy des Services; nil schaltet
+// das Kürzen ab.
+func WithTruncation(p *TruncationPolicy) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.truncation = p
+	}
+}
+
+// budget liefert die erlaubten Tokens für System-Message und Prompt; 0 = unbegrenzt.
+func (p *TruncationPolicy) budget(model openai.ChatModel) int {
+	if p.MaxTokens > 0 {
+		return p.MaxTokens
+	}
+	window := ContextWindow(model)
+	if window == 0 {
+		return 0
+	}
+	reserve := p.ReserveTokens
+	if reserve <= 0 {
+		reserve = DefaultReserveTokens
+	}
+	return max(window-reserve, 0)
+}
+
+// truncateSystemMessage kürzt systemMessage nach cfg.truncation und meldet die Kürzung
+// als Warnung.
+func truncateSystemMessage(systemMessage string, cfg requestConfig) (string, error) {
+	p := cfg.truncation
+	if p == nil {
+		return systemMessage, nil
+	}
+	budget := p.budget(cfg.model)
+	if budget == 0 {
+		return systemMessage, nil
+	}
+	tokens := estimateTokens(systemMessage, cfg.prompt)
+	if tokens <= budget {
+		return systemMessage, nil
+	}
+	available := max(budget-estimateTokens(cfg.prompt), 0)
+	truncated, err := TruncateText(systemMessage, available*4, p.Strategy)
+	if err != nil {
+		return "", err
+	}
+	warning := fmt.Sprintf("prompt of about %d tokens exceeds budget of %d for %s, system message truncated (%s) from %d to %d bytes",
+		tokens, budget, cfg.model, orTruncateTail(p.Strategy), len(systemMessage), len(truncated))
+	log.Warn("%s", warning)
+	cfg.usage.warn(warning)
+	return truncated, nil
+}
+
+func orTruncateTail(s TruncationStrategy) TruncationStrategy {
+	if s == "" {
+		return TruncateTail
+	}
+	return s
+}
+
+// TruncateText kürzt text nach strategy auf höchstens maxBytes, ohne ein Zeichen zu zerschneiden.
+func TruncateText(text string, maxBytes int, strategy TruncationStrategy) (string, error) {
+	if len(text) <= maxBytes {
+		return text, nil
+	}
+	maxBytes = max(maxBytes, 0)
+	switch orTruncateTail(strategy) {
+	case TruncateTail:
+		return truncateUTF8(text, maxBytes), nil
+	case TruncateHead:
+		return keepSuffix(text, maxBytes), nil
+	case TruncateMiddleOut:
+		if maxBytes <= len(truncationMarker) {
+			return truncateUTF8(text, maxBytes), nil
+		}
+		keep := maxBytes - len(truncationMarker)
+		return truncateUTF8(text, keep-keep/2) + truncationMarker + keepSuffix(text, keep/2), nil
+	case TruncateSentence:
+		cut := truncateUTF8(text, maxBytes)
+		if i := lastSentenceEnd(cut); i >= len(cut)/2 {
+			return cut[:i], nil
+		}
+		if i := strings.LastIndexAny(cut, " \n\t"); i >= len(cut)/2 {
+			return cut[:i], nil
+		}
+		return cut, nil
+	default:
+		return "", fmt.Errorf("unknown truncation strategy %q", strategy)
+	}
+}
+
+// keepSuffix liefert die letzten höchstens limit Bytes von s, ohne ein Zeichen zu zerschneiden.
+func keepSuffix(s string, limit int) string {
+	start := len(s) - limit
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
+
+// lastSentenceEnd liefert die Position hinter dem letzten Satzende in s, -1 ohne Satzende.
+// Ein Satz endet mit '.', '!' oder '?' vor Leerraum oder mit einem Absatz.
+func lastSentenceEnd(s string) int {
+	if s != "" && strings.IndexByte(".!?", s[len(s)-1]) >= 0 {
+		return len(s)
+	}
+	for i := len(s) - 1; i > 0; i-- {
+		switch {
+		case s[i] == '\n' && s[i-1] == '\n':
+			return i - 1
+		case (s[i] == ' ' || s[i] == '\n') && strings.IndexByte(".!?", s[i-1]) >= 0:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateText(t *testing.T) {
+	text := "Erster Satz. Zweiter Satz! Dritter Satz? Vierter Satz."
+	cases := []struct {
+		strategy TruncationStrategy
+		maxBytes int
+		want     string
+	}{
+		{TruncateTail, 20, "Erster Satz. Zweiter"},
+		{"", 20, "Erster Satz. Zweiter"},
+		{TruncateHead, 13, "Vierter Satz."},
+		{TruncateMiddleOut, 27, "Erster Sat" + truncationMarker + "rter Satz."},
+		{TruncateSentence, 30, "Erster Satz. Zweiter Satz!"},
+		{TruncateSentence, 12, "Erster Satz."},
+		{TruncateSentence, 10, "Erster"},
+		{TruncateTail, 100, text},
+	}
+	for _, c := range cases {
+		got, err := TruncateText(text, c.maxBytes, c.strategy)
+		require.NoError(t, err, c.strategy)
+		require.Equal(t, c.want, got, "%s/%d", c.strategy, c.maxBytes)
+		require.LessOrEqual(t, len(got), c.maxBytes)
+	}
+
+	// Umlaute werden nicht zerschnitten
+	got, err := TruncateText("ääää", 5, TruncateHead)
+	require.NoError(t, err)
+	require.Equal(t, "ää", got)
+
+	_, err = TruncateText(text, 10, "random")
+	require.ErrorContains(t, err, "unknown truncation strategy")
+}
+
+func TestContextWindow(t *testing.T) {
+	require.Equal(t, 128_000, ContextWindow(openai.ChatModelGPT4o))
+	require.Equal(t, 128_000, ContextWindow("gpt-4o-2024-08-06"))
+	require.Equal(t, 128_000, ContextWindow("gpt-4o-mini-2024-07-18"))
+	require.Equal(t, 1_047_576, ContextWindow("gpt-4.1-mini-2025-04-14"))
+	require.Zero(t, ContextWindow("unknown-model"))
+
+	p := &TruncationPolicy{}
+	require.Equal(t, 128_000-DefaultReserveTokens, p.budget(openai.ChatModelGPT4o))
+	require.Zero(t, p.budget("unknown-model"))
+}
+
+func TestGenerate_Truncation(t *testing.T) {
+	ai, srv := newMockService(t)
+	ai.Truncation = &TruncationPolicy{Strategy: TruncateHead, MaxTokens: 100}
+	systemMessage := strings.Repeat("alt ", 200) + "NEU"
+
+	result, err := ai.Generate(context.Background(), Request{SystemMessage: systemMessage})
+	require.NoError(t, err)
+	require.Len(t, result.Warnings, 1)
+	require.Contains(t, result.Warnings[0], "truncated (head)")
+
+	var body struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(srv.Requests()[0].Body, &body))
+	require.Equal(t, "system", body.Messages[0].Role)
+	require.True(t, strings.HasSuffix(body.Messages[0].Content, "NEU"))
+	require.LessOrEqual(t, estimateTokens(body.Messages[0].Content, ai.Prompt), 100)
+
+	// kurze Eingaben bleiben unverändert, WithTruncation(nil) schaltet ab
+	result, err = ai.Generate(context.Background(), Request{SystemMessage: "kurz"})
+	require.NoError(t, err)
+	require.Empty(t, result.Warnings)
+	result, err = ai.Generate(context.Background(), Request{SystemMessage: systemMessage}, WithTruncation(nil))
+	require.NoError(t, err)
+	require.Empty(t, result.Warnings)
+}
@@ -0,0 +1,124 @@
+package openai
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dchaykin/mygolib/helper"
+	"github.com/dchaykin/mygolib/log"
+)
+
+// provenanceSuffix wird an die Ergebnisdatei angehängt, um die Herkunftsdatei zu benennen.
+const provenanceSuffix = ".provenance.json"
+
+// ErrProvenanceInvalid meldet, dass Herkunftsangaben nicht zum Ergebnis passen oder die
+// Signatur nicht stimmt.
+var ErrProvenanceInvalid = errors.New("invalid result provenance")
+
+// Provenance beschreibt, mit welcher Pipeline ein Ergebnis erzeugt wurde. Mit Schlüssel
+// wird sie per HMAC-SHA256 signiert; die Signatur deckt über ContentHash auch das Ergebnis ab.
+type Provenance struct {
+	Model         string    `json:"model"`
+	PromptHash    string    `json:"promptHash"` // SHA-256 über System-Message und Prompt
+	PromptVersion string    `json:"promptVersion,omitempty"`
+	FileHash      string    `json:"fileHash"`    // SHA-256 der Quelldatei
+	ContentHash   string    `json:"contentHash"` // SHA-256 des Ergebnisses
+	Timestamp     time.Time `json:"timestamp"`
+	Signature     string    `json:"signature,omitempty"` // HMAC-SHA256 als Hex, leer = unsigniert
+}
+
+// newProvenance erfasst die Herkunft eines Ergebnisses und signiert sie, wenn key gesetzt ist.
+func (ai *AiCommunicationService) newProvenance(systemMessage, fileName, content string, cfg requestConfig, key []byte) (*Provenance, error) {
+	fileHash, err := fileSHA256(fileName)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	promptHash := sha256.Sum256([]byte(systemMessage + "\x00" + cfg.prompt))
+	p := &Provenance{
+		Model:         string(cfg.model),
+		PromptHash:    hex.EncodeToString(promptHash[:]),
+		PromptVersion: ai.PromptVersion,
+		FileHash:      fileHash,
+		ContentHash:   contentHash(content),
+		Timestamp:     time.Now().UTC(),
+	}
+	if len(key) > 0 {
+		if err := p.Sign(key); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Sign setzt die Signatur über alle übrigen Felder.
+func (p *Provenance) Sign(key []byte) error {
+	if len(key) == 0 {
+		return fmt.Errorf("provenance signing key is empty")
+	}
+	signature, err := p.signature(key)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	p.Signature = signature
+	return nil
+}
+
+// Verify prüft, ob die Herkunftsangaben zu content gehören und, wenn key gesetzt ist,
+// ob die Signatur stimmt.
+func (p Provenance) Verify(content string, key []byte) error {
+	if p.ContentHash != contentHash(content) {
+		return fmt.Errorf("%w: content hash mismatch", ErrProvenanceInvalid)
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	if p.Signature == "" {
+		return fmt.Errorf("%w: missing signature", ErrProvenanceInvalid)
+	}
+	expected, err := p.signature(key)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	if !hmac.Equal([]byte(expected), []byte(p.Signature)) {
+		return fmt.Errorf("%w: signature mismatch", ErrProvenanceInvalid)
+	}
+	return nil
+}
+
+func (p Provenance) signature(key []byte) (string, error) {
+	p.Signature = ""
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return helper.SignInput(data, key)
+}
+
+// LoadProvenance liest die Herkunftsdatei, die der BatchConverter neben outputFile ablegt.
+func LoadProvenance(outputFile string) (*Provenance, error) {
+	data, err := os.ReadFile(outputFile + provenanceSuffix)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	p := &Provenance{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid provenance file for %s: %w", outputFile, err)
+	}
+	return p, nil
+}
+
+func marshalProvenance(p *Provenance) ([]byte, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	return data, log.WrapError(err)
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
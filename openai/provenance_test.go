@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance_SignVerify(t *testing.T) {
+	p := Provenance{Model: "gpt-4.1", FileHash: "abc", ContentHash: contentHash(`{"a":1}`)}
+	require.NoError(t, p.Verify(`{"a":1}`, nil))
+	require.ErrorIs(t, p.Verify(`{"a":2}`, nil), ErrProvenanceInvalid)
+	require.ErrorIs(t, p.Verify(`{"a":1}`, []byte("key")), ErrProvenanceInvalid)
+
+	require.NoError(t, p.Sign([]byte("key")))
+	require.NoError(t, p.Verify(`{"a":1}`, []byte("key")))
+	require.ErrorIs(t, p.Verify(`{"a":1}`, []byte("other")), ErrProvenanceInvalid)
+
+	p.Model = "gpt-4.1-mini"
+	require.ErrorIs(t, p.Verify(`{"a":1}`, []byte("key")), ErrProvenanceInvalid)
+}
+
+func TestBatchConverter_WritesProvenance(t *testing.T) {
+	src, dest := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	fileHash, err := fileSHA256(filepath.Join(src, "a.pdf"))
+	require.NoError(t, err)
+
+	ai := newBatchTestService(t, func() string { return `{"ok": true}` })
+	bc := NewBatchConverter(ai, "system", src, dest)
+	bc.WriteProvenance = true
+	bc.ProvenanceKey = []byte("key")
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Converted)
+	doc := result.Documents[0]
+	require.NotNil(t, doc.Provenance)
+	require.EqualValues(t, ai.Model, doc.Provenance.Model)
+	require.Equal(t, fileHash, doc.Provenance.FileHash)
+
+	p, err := LoadProvenance(doc.OutputFile)
+	require.NoError(t, err)
+	require.Equal(t, doc.Provenance.Signature, p.Signature)
+	content, err := os.ReadFile(doc.OutputFile)
+	require.NoError(t, err)
+	require.NoError(t, p.Verify(string(content), []byte("key")))
+}
@@ -0,0 +1,110 @@
+package openai
+
+import (
+	"context"
+	"sync"
+)
+
+// Provider erzeugt Inhalte mit einem Sprachmodell. AiCommunicationService implementiert
+// Provider; Anwendungen sollten gegen diese Schnittstelle programmieren, damit sich der
+// Service in Tests ersetzen lässt.
+type Provider interface {
+	Generate(ctx context.Context, req Request, opts ...RequestOption) (*Result, error)
+}
+
+var _ Provider = (*AiCommunicationService)(nil)
+
+// Request beschreibt einen Aufruf. Prompt, Modell und weitere Einstellungen je Aufruf
+// kommen als RequestOption, z.B. WithPrompt oder WithModel.
+type Request struct {
+	SystemMessage string
+	FileName      string // Dokument, das dem Modell mitgegeben wird (z.B. PDF); leer = nur Text
+}
+
+// Result ist das Ergebnis eines Aufrufs.
+type Result struct {
+	Content          string
+	Model            string // Modell der letzten Antwort
+	PromptTokens     int64  // alle Requests des Aufrufs, auch Wiederholungen, Abstimmungen und Prüfungen
+	CompletionTokens int64
+	Cost             float64  // USD, Summe über dieselben Requests
+	Requests         int      // Zahl der Chat-Requests
+	Cached           bool     // aus Result-Cache oder Idempotenz-Cache, ohne Request
+	Stale            bool     // veralteter Cache-Eintrag statt eines Fehlers, siehe StaleFallback
+	Warnings         []string // z.B. gekürzte Eingaben, siehe TruncationPolicy
+}
+
+// Generate stellt den Aufruf und liefert Inhalt, Tokens und Kosten.
+func (ai *AiCommunicationService) Generate(ctx context.Context, req Request, opts ...RequestOption) (*Result, error) {
+	cfg := ai.newRequestConfig(opts)
+	usage := &callUsage{}
+	cfg.usage = usage
+
+	var content string
+	var err error
+	if req.FileName != "" {
+		content, err = ai.generateContentWithPDF(ctx, req.SystemMessage, req.FileName, cfg)
+	} else {
+		content, err = ai.generateJsonContent(ctx, req.SystemMessage, nil, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return usage.result(content), nil
+}
+
+// callUsage sammelt Tokens und Kosten aller Requests eines Aufrufs; Abstimmungen laufen parallel.
+type callUsage struct {
+	mu  sync.Mutex
+	sum Result
+}
+
+func (u *callUsage) add(model string, promptTokens, completionTokens int64, cost float64) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sum.Model = model
+	u.sum.PromptTokens += promptTokens
+	u.sum.CompletionTokens += completionTokens
+	u.sum.Cost += cost
+	u.sum.Requests++
+}
+
+// warn merkt eine Warnung für Result.Warnings vor.
+func (u *callUsage) warn(msg string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sum.Warnings = append(u.sum.Warnings, msg)
+}
+
+// markStale kennzeichnet das Ergebnis als veraltet, msg nennt Alter und Ursache.
+func (u *callUsage) markStale(msg string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sum.Stale = true
+	u.sum.Warnings = append(u.sum.Warnings, msg)
+}
+
+// cost liefert die Kosten (USD) der bisher gesammelten Requests.
+func (u *callUsage) cost() float64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.sum.Cost
+}
+
+func (u *callUsage) result(content string) *Result {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	r := u.sum
+	r.Content = content
+	r.Cached = r.Requests == 0
+	return &r
+}
@@ -0,0 +1,57 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	var calls atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/files" {
+			_, _ = w.Write([]byte(testUploadedFile))
+			return
+		}
+		calls.Add(1)
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	var p Provider = ai
+
+	result, err := p.Generate(context.Background(), Request{SystemMessage: "system"})
+	require.NoError(t, err)
+	require.Equal(t, &Result{
+		Content:          `{"ok": true}`,
+		Model:            "gpt-4.1",
+		PromptTokens:     100,
+		CompletionTokens: 20,
+		Cost:             costOf(100, 20),
+		Requests:         1,
+	}, result)
+
+	// Abstimmungen zählen zum Aufruf
+	result, err = p.Generate(context.Background(), Request{SystemMessage: "system"}, WithVoting(VotingPolicy{Runs: 3}))
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Requests)
+	require.EqualValues(t, 300, result.PromptTokens)
+	require.InDelta(t, 3*costOf(100, 20), result.Cost, 1e-9)
+
+	fileName := filepath.Join(t.TempDir(), "a.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+	ai.Cache = NewMemoryResultCache()
+	calls.Store(0)
+	for range 2 {
+		result, err = p.Generate(context.Background(), Request{SystemMessage: "system", FileName: fileName})
+		require.NoError(t, err)
+		require.Equal(t, `{"ok": true}`, result.Content)
+	}
+	require.True(t, result.Cached)
+	require.Zero(t, result.Cost)
+	require.EqualValues(t, 1, calls.Load())
+}
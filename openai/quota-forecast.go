@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxQuotaSamples = 100
+
+// QuotaForecaster sammelt RateInfo-Stichproben aus 429-Antworten und schätzt daraus,
+// wann wieder genügend Kontingent verfügbar ist. OpenAI füllt die Limits kontinuierlich
+// über das Zeitfenster der Metrik (Minute bzw. Tag) wieder auf.
+type QuotaForecaster struct {
+	MaxSamples int // Default: 100
+
+	mu      sync.Mutex
+	samples []rateSample
+	now     func() time.Time
+}
+
+type rateSample struct {
+	at   time.Time
+	info OpenAIRateInfo
+}
+
+func NewQuotaForecaster() *QuotaForecaster {
+	return &QuotaForecaster{
+		MaxSamples: defaultMaxQuotaSamples,
+		now:        time.Now,
+	}
+}
+
+// Observe nimmt eine neue Stichprobe auf. nil wird ignoriert.
+func (f *QuotaForecaster) Observe(info *OpenAIRateInfo) {
+	if info == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.samples = append(f.samples, rateSample{at: f.clock(), info: *info})
+	maxSamples := f.MaxSamples
+	if maxSamples <= 0 {
+		maxSamples = defaultMaxQuotaSamples
+	}
+	if len(f.samples) > maxSamples {
+		f.samples = f.samples[len(f.samples)-maxSamples:]
+	}
+}
+
+// TimeUntilQuotaAvailable schätzt die Wartezeit, bis tokensNeeded Tokens (und ein Request)
+// in allen beobachteten Limits Platz haben. 0 bedeutet: sofort bzw. keine Daten.
+func (f *QuotaForecaster) TimeUntilQuotaAvailable(tokensNeeded int) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock()
+	var wait time.Duration
+	for _, s := range f.latestPerMetric() {
+		needed := tokensNeeded
+		if !strings.Contains(strings.ToLower(s.info.Metric), "token") {
+			needed = 1
+		}
+		if w := s.waitFor(needed, now); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// Samples liefert eine Kopie der gesammelten Stichproben, älteste zuerst.
+func (f *QuotaForecaster) Samples() []OpenAIRateInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := make([]OpenAIRateInfo, 0, len(f.samples))
+	for _, s := range f.samples {
+		result = append(result, s.info)
+	}
+	return result
+}
+
+func (f *QuotaForecaster) latestPerMetric() map[string]rateSample {
+	latest := map[string]rateSample{}
+	for _, s := range f.samples {
+		key := s.info.Model + "|" + s.info.ScopeID + "|" + s.info.Metric
+		latest[key] = s
+	}
+	return latest
+}
+
+func (f *QuotaForecaster) clock() time.Time {
+	if f.now == nil {
+		return time.Now()
+	}
+	return f.now()
+}
+
+func (s rateSample) waitFor(needed int, now time.Time) time.Duration {
+	if s.info.Limit <= 0 {
+		return 0
+	}
+	window := metricWindow(s.info.Metric)
+	perToken := window / time.Duration(s.info.Limit)
+
+	if needed > s.info.Limit {
+		needed = s.info.Limit // passt nie komplett – bestenfalls ein volles Fenster
+	}
+	deficit := s.info.Used + needed - s.info.Limit
+	if deficit <= 0 {
+		return 0
+	}
+	wait := time.Duration(deficit)*perToken - now.Sub(s.at)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// metricWindow leitet das Zeitfenster aus Texten wie "tokens per min (TPM)" ab.
+func metricWindow(metric string) time.Duration {
+	metric = strings.ToLower(metric)
+	switch {
+	case strings.Contains(metric, "per day"):
+		return 24 * time.Hour
+	case strings.Contains(metric, "per hour"):
+		return time.Hour
+	default:
+		return time.Minute
+	}
+}
@@ -0,0 +1,41 @@
+package openai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaForecaster(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	f := NewQuotaForecaster()
+	f.now = func() time.Time { return now }
+
+	require.Zero(t, f.TimeUntilQuotaAvailable(1000))
+
+	f.Observe(&OpenAIRateInfo{
+		Model:  "gpt-4.1",
+		Metric: "tokens per min (TPM)",
+		Limit:  30000,
+		Used:   30000,
+	})
+
+	// 30000 TPM => 2ms pro Token
+	require.Equal(t, 3*time.Second, f.TimeUntilQuotaAvailable(1500))
+
+	now = now.Add(time.Second)
+	require.Equal(t, 2*time.Second, f.TimeUntilQuotaAvailable(1500))
+
+	now = now.Add(5 * time.Second)
+	require.Zero(t, f.TimeUntilQuotaAvailable(1500))
+
+	f.Observe(&OpenAIRateInfo{
+		Model:  "gpt-4.1",
+		Metric: "requests per day (RPD)",
+		Limit:  1440,
+		Used:   1440,
+	})
+	require.Equal(t, time.Minute, f.TimeUntilQuotaAvailable(1500))
+	require.Len(t, f.Samples(), 2)
+}
@@ -0,0 +1,106 @@
+package openai
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dchaykin/myailib/openai/internal/loadgen"
+	"github.com/dchaykin/myailib/openai/internal/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+// soakConfig liefert die Last für die Soak-Tests; MYAILIB_SOAK_RPS und
+// MYAILIB_SOAK_DURATION verlängern sie z.B. für nächtliche Läufe.
+func soakConfig(t *testing.T) loadgen.Config {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	cfg := loadgen.Config{RPS: 200, Duration: 2 * time.Second}
+	if v := os.Getenv("MYAILIB_SOAK_RPS"); v != "" {
+		rps, err := strconv.ParseFloat(v, 64)
+		require.NoError(t, err)
+		cfg.RPS = rps
+	}
+	if v := os.Getenv("MYAILIB_SOAK_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		require.NoError(t, err)
+		cfg.Duration = d
+	}
+	return cfg
+}
+
+// runSoak treibt den Service mit cfg gegen den simulierten Server und liefert die
+// Ankunftszeiten der Chat-Requests.
+func runSoak(t *testing.T, ai *AiCommunicationService, srv *mockserver.Server, cfg loadgen.Config, systemMessage string) (*loadgen.Report, []time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Duration+500*time.Millisecond)
+	defer cancel()
+	report := loadgen.Run(ctx, cfg, func(ctx context.Context) error {
+		_, err := ai.Generate(ctx, Request{SystemMessage: systemMessage})
+		return err
+	})
+	times := chatRequestTimes(srv)
+	t.Logf("offered %d, completed %d, failed %d in %s, p50 %s, p99 %s",
+		report.Offered, report.Completed, report.Failed, report.Elapsed.Round(time.Millisecond), report.Percentile(0.5), report.Percentile(0.99))
+	return report, times
+}
+
+func TestRateLimiter_SoakRPM(t *testing.T) {
+	cfg := soakConfig(t)
+	ai, srv := newMockService(t)
+	const rpm = 300 // Startguthaben 300, danach 5/s
+	ai.RateLimiter = NewRateLimiter(rpm, 0)
+
+	report, times := runSoak(t, ai, srv, cfg, "system")
+	require.NoError(t, loadgen.CheckPacing(times, nil, rpm, 2))
+	require.Equal(t, report.Completed, len(times))
+	if cfg.RPS*cfg.Duration.Seconds() > rpm {
+		// bei Überlast soll der Limiter das Kontingent auch ausschöpfen
+		require.GreaterOrEqual(t, report.Completed, rpm)
+	}
+}
+
+func TestRateLimiter_SoakTPM(t *testing.T) {
+	cfg := soakConfig(t)
+	ai, srv := newMockService(t)
+	systemMessage := strings.Repeat("Extrahiere die Daten. ", 50)
+	perRequest := estimateTokens(systemMessage, ai.Prompt)
+	tpm := 300 * perRequest
+	ai.RateLimiter = NewRateLimiter(0, tpm)
+
+	report, times := runSoak(t, ai, srv, cfg, systemMessage)
+	weights := make([]int, len(times))
+	for i := range weights {
+		weights[i] = perRequest
+	}
+	require.NoError(t, loadgen.CheckPacing(times, weights, tpm, float64(2*perRequest)))
+	if cfg.RPS*cfg.Duration.Seconds() > 300 {
+		require.GreaterOrEqual(t, report.Completed, 300)
+	}
+}
+
+// chatRequestTimes liefert die Ankunftszeiten der Chat-Requests am Server.
+func chatRequestTimes(srv *mockserver.Server) []time.Time {
+	var times []time.Time
+	for _, r := range srv.Requests() {
+		if r.Path == "/chat/completions" {
+			times = append(times, r.At)
+		}
+	}
+	return times
+}
+
+func TestCheckPacing(t *testing.T) {
+	start := time.Now()
+	times := []time.Time{}
+	for i := range 10 {
+		times = append(times, start.Add(time.Duration(i)*time.Second))
+	}
+	// Startguthaben 6, danach 6/min = 1 je 10s
+	require.NoError(t, loadgen.CheckPacing(times[:6], nil, 6, 0))
+	require.ErrorContains(t, loadgen.CheckPacing(times, nil, 6, 0), "pacing exceeded")
+	require.NoError(t, loadgen.CheckPacing(times, nil, 6, 4))
+}
@@ -0,0 +1,224 @@
+package openai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMinPacingDelay = 500 * time.Millisecond
+	defaultMaxPacingDelay = time.Minute
+)
+
+// RateLimiter begrenzt Requests (RPM) und Tokens (TPM) per Token-Bucket. Zusätzlich
+// passt er einen Mindestabstand zwischen zwei Aufrufen an die beobachteten 429-Antworten an:
+// jeder 429 verdoppelt den Abstand, jeder erfolgreiche Aufruf halbiert ihn wieder.
+type RateLimiter struct {
+	RPM      int           // 0 = unbegrenzt
+	TPM      int           // 0 = unbegrenzt
+	MinDelay time.Duration // Abstand nach dem ersten 429, Default: 500ms
+	MaxDelay time.Duration // Obergrenze für den Abstand, Default: 1min
+
+	mu        sync.Mutex
+	requests  float64 // verfügbare Requests im Bucket, kann durch Reservierungen negativ werden
+	tokens    float64 // verfügbare Tokens im Bucket
+	refilled  time.Time
+	delay     time.Duration
+	nextStart time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(rpm, tpm int) *RateLimiter {
+	return &RateLimiter{
+		RPM:      rpm,
+		TPM:      tpm,
+		MinDelay: defaultMinPacingDelay,
+		MaxDelay: defaultMaxPacingDelay,
+		requests: float64(rpm),
+		tokens:   float64(tpm),
+		now:      time.Now,
+	}
+}
+
+// Reserve bucht einen Request mit estTokens geschätzten Tokens und liefert die Zeit,
+// die bis zum Start gewartet werden muss.
+func (rl *RateLimiter) Reserve(estTokens int) time.Duration {
+	wait, _ := rl.reserve(estTokens)
+	return wait
+}
+
+// reserve bucht wie Reserve und liefert zusätzlich eine Funktion, die die Buchung
+// zurücknimmt, solange noch keine neuere den Startzeitpunkt weitergeschoben hat.
+func (rl *RateLimiter) reserve(estTokens int) (time.Duration, func()) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock()
+	rl.refill(now)
+
+	var wait time.Duration
+	if rl.RPM > 0 {
+		rl.requests--
+		wait = max(wait, deficitWait(rl.requests, rl.RPM))
+	}
+	if rl.TPM > 0 && estTokens > 0 {
+		rl.tokens -= float64(estTokens)
+		wait = max(wait, deficitWait(rl.tokens, rl.TPM))
+	}
+
+	start := now.Add(wait)
+	if start.Before(rl.nextStart) {
+		start = rl.nextStart
+	}
+	prevStart := rl.nextStart
+	rl.nextStart = start.Add(rl.delay)
+	reserved := rl.nextStart
+
+	release := func() {
+		rl.mu.Lock()
+		defer rl.mu.Unlock()
+		if rl.RPM > 0 {
+			rl.requests = min(float64(rl.RPM), rl.requests+1)
+		}
+		if rl.TPM > 0 && estTokens > 0 {
+			rl.tokens = min(float64(rl.TPM), rl.tokens+float64(estTokens))
+		}
+		if rl.nextStart.Equal(reserved) {
+			rl.nextStart = prevStart
+		}
+	}
+	return start.Sub(now), release
+}
+
+// Peek liefert die Wartezeit, die Reserve für estTokens liefern würde, ohne etwas zu buchen.
+func (rl *RateLimiter) Peek(estTokens int) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock()
+	rl.refill(now)
+
+	var wait time.Duration
+	if rl.RPM > 0 {
+		wait = max(wait, deficitWait(rl.requests-1, rl.RPM))
+	}
+	if rl.TPM > 0 && estTokens > 0 {
+		wait = max(wait, deficitWait(rl.tokens-float64(estTokens), rl.TPM))
+	}
+	if start := now.Add(wait); start.Before(rl.nextStart) {
+		wait = rl.nextStart.Sub(now)
+	}
+	return wait
+}
+
+// Wait reserviert wie Reserve und blockiert, bis der Request starten darf oder ctx endet.
+// Endet ctx vorher, wird die Reservierung wieder freigegeben.
+func (rl *RateLimiter) Wait(ctx context.Context, estTokens int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wait, release := rl.reserve(estTokens)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		release()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// OnRateLimited meldet einen 429. Der Abstand zwischen Aufrufen wird verdoppelt,
+// mindestens aber auf retryAfter angehoben.
+func (rl *RateLimiter) OnRateLimited(retryAfter time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	minDelay, maxDelay := rl.bounds()
+	delay := max(rl.delay*2, minDelay, retryAfter)
+	rl.delay = min(delay, maxDelay)
+
+	// bereits verbrauchtes Kontingent liegt offenbar höher als gedacht
+	if rl.RPM > 0 {
+		rl.requests = min(rl.requests, 0)
+	}
+	if rl.TPM > 0 {
+		rl.tokens = min(rl.tokens, 0)
+	}
+}
+
+// OnSuccess meldet einen erfolgreichen Aufruf; der Abstand wird halbiert und fällt
+// unterhalb von MinDelay ganz weg.
+func (rl *RateLimiter) OnSuccess() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	minDelay, _ := rl.bounds()
+	rl.delay /= 2
+	if rl.delay < minDelay {
+		rl.delay = 0
+	}
+}
+
+// Delay liefert den aktuellen adaptiven Abstand zwischen zwei Aufrufen.
+func (rl *RateLimiter) Delay() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.delay
+}
+
+func (rl *RateLimiter) refill(now time.Time) {
+	if rl.refilled.IsZero() {
+		rl.refilled = now
+		rl.requests = float64(rl.RPM)
+		rl.tokens = float64(rl.TPM)
+		return
+	}
+	elapsed := now.Sub(rl.refilled)
+	if elapsed <= 0 {
+		return
+	}
+	rl.refilled = now
+	rl.requests = min(float64(rl.RPM), rl.requests+elapsed.Minutes()*float64(rl.RPM))
+	rl.tokens = min(float64(rl.TPM), rl.tokens+elapsed.Minutes()*float64(rl.TPM))
+}
+
+func (rl *RateLimiter) bounds() (time.Duration, time.Duration) {
+	minDelay, maxDelay := rl.MinDelay, rl.MaxDelay
+	if minDelay <= 0 {
+		minDelay = defaultMinPacingDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxPacingDelay
+	}
+	return minDelay, maxDelay
+}
+
+func (rl *RateLimiter) clock() time.Time {
+	if rl.now == nil {
+		return time.Now()
+	}
+	return rl.now()
+}
+
+// deficitWait liefert die Zeit, bis ein negativer Bucket-Stand bei perMinute wieder 0 erreicht.
+func deficitWait(available float64, perMinute int) time.Duration {
+	if available >= 0 {
+		return 0
+	}
+	return time.Duration(-available / float64(perMinute) * float64(time.Minute))
+}
+
+// estimateTokens schätzt die Tokenzahl eines Textes grob mit 4 Zeichen pro Token.
+func estimateTokens(texts ...string) int {
+	n := 0
+	for _, text := range texts {
+		n += len(text)
+	}
+	return (n + 3) / 4
+}
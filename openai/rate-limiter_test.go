@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_RPM(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(60, 0)
+	rl.now = func() time.Time { return now }
+
+	for range 60 {
+		require.Zero(t, rl.Reserve(0))
+	}
+	require.Equal(t, time.Second, rl.Reserve(0))
+	require.Equal(t, 2*time.Second, rl.Reserve(0))
+
+	now = now.Add(time.Minute)
+	require.Zero(t, rl.Reserve(0))
+}
+
+func TestRateLimiter_TPM(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(0, 6000)
+	rl.now = func() time.Time { return now }
+
+	require.Zero(t, rl.Reserve(6000))
+	require.Equal(t, 10*time.Second, rl.Reserve(1000))
+}
+
+func TestRateLimiter_WaitReleasesOnCancel(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(1, 6000)
+	rl.now = func() time.Time { return now }
+
+	require.Zero(t, rl.Reserve(6000))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, rl.Wait(ctx, 1000), context.DeadlineExceeded)
+
+	// der abgebrochene Aufruf belegt weder Request noch Tokens
+	require.Equal(t, time.Minute, rl.Peek(0))
+	require.Equal(t, time.Minute, rl.Peek(6000))
+	require.Equal(t, time.Minute, rl.Reserve(6000))
+
+	// ein bereits abgebrochener ctx bucht gar nicht erst
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, rl.Wait(ctx, 0), context.Canceled)
+	require.Equal(t, 2*time.Minute, rl.Peek(0))
+}
+
+func TestRateLimiter_Peek(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(0, 6000)
+	rl.now = func() time.Time { return now }
+
+	require.Zero(t, rl.Peek(6000))
+	require.Equal(t, 10*time.Second, rl.Peek(7000))
+	// Peek bucht nichts
+	require.Zero(t, rl.Reserve(6000))
+	require.Equal(t, 10*time.Second, rl.Peek(1000))
+}
+
+func TestRateLimiter_AdaptiveDelay(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(0, 0)
+	rl.now = func() time.Time { return now }
+	rl.MinDelay = time.Second
+	rl.MaxDelay = 4 * time.Second
+
+	require.Zero(t, rl.Reserve(0))
+
+	rl.OnRateLimited(0)
+	require.Equal(t, time.Second, rl.Delay())
+	rl.OnRateLimited(0)
+	require.Equal(t, 2*time.Second, rl.Delay())
+	rl.OnRateLimited(10 * time.Second)
+	require.Equal(t, 4*time.Second, rl.Delay())
+
+	require.Zero(t, rl.Reserve(0))
+	require.Equal(t, 4*time.Second, rl.Reserve(0))
+
+	rl.OnSuccess()
+	require.Equal(t, 2*time.Second, rl.Delay())
+	rl.OnSuccess()
+	rl.OnSuccess()
+	require.Zero(t, rl.Delay())
+}
@@ -0,0 +1,216 @@
+package openai
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// RequestOption passt einen einzelnen Aufruf an, ohne den Service zu verändern.
+type RequestOption func(*requestConfig)
+
+type requestConfig struct {
+	task           string
+	taskErr        error
+	budgetErr      error // Budget des Mandanten erschöpft, nur Cache, siehe TenantConfig.MaxCost
+	experiment     *Experiment
+	experimentKey  string
+	variant        string
+	model          openai.ChatModel
+	temperature    float64
+	postProcessors []string
+	priority       Priority
+	tag            string
+	prompt         string
+	idempotencyKey string
+	documentType   string
+	documentSize   int
+	requestTimeout time.Duration
+	uploadTimeout  time.Duration
+	uploadProgress UploadProgress
+	maxRetryAfter  time.Duration
+	hedge          *HedgePolicy
+	cheapFirst     *CheapFirstPolicy
+	voting         *VotingPolicy
+	verification   *VerificationPolicy
+	language       *LanguagePolicy
+	safety         *SafetySettings
+	user           string
+	component      string // Teilsystem für Kosten und Audit, siehe WithComponent
+	caller         string // aufrufende Funktion, siehe attribute
+	// fieldConfidence fordert Konfidenz je Feld an, siehe WithFieldConfidence
+	fieldConfidence bool
+	// citations fordert Fundstellen je Feld an, siehe WithCitations
+	citations  bool
+	features   Features
+	truncation *TruncationPolicy
+	schema     *JSONSchema // prüft jede Antwort, siehe WithSchema
+	usage      *callUsage  // sammelt Tokens und Kosten für Generate, optional
+}
+
+// WithPostProcessors legt die Post-Prozessoren für diesen Aufruf fest
+// und ersetzt die am Service konfigurierten.
+func WithPostProcessors(names ...string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.postProcessors = names
+	}
+}
+
+// WithModel ersetzt für diesen Aufruf das Modell des Services.
+func WithModel(model openai.ChatModel) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.model = model
+	}
+}
+
+// WithPrompt ersetzt für diesen Aufruf den Prompt des Services.
+func WithPrompt(prompt string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.prompt = prompt
+	}
+}
+
+// WithPriority legt die Priorität fest, mit der der Aufruf im Scheduler wartet.
+func WithPriority(p Priority) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.priority = p
+	}
+}
+
+// WithTag ordnet den Aufruf einer Gruppe zu (z.B. Job oder Benutzer); der Scheduler
+// verteilt Slots innerhalb einer Priorität reihum über die Tags.
+func WithTag(tag string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.tag = tag
+	}
+}
+
+// WithIdempotencyKey schützt vor doppelten Kosten, wenn ein Aufrufer auf höherer Ebene
+// wiederholt: der Key wird als Idempotency-Key-Header mitgeschickt, und innerhalb von
+// IdempotencyTTL liefert ein erneuter Aufruf mit demselben Key das gemerkte Ergebnis.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.idempotencyKey = key
+	}
+}
+
+// WithDocumentType ordnet den Aufruf einer Dokumentkategorie zu (z.B. "invoice"), nach der
+// Kosten erfasst und Tokenschätzungen gelernt werden.
+func WithDocumentType(docType string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.documentType = docType
+	}
+}
+
+// WithRequestTimeout begrenzt für diesen Aufruf die Dauer eines einzelnen Chat-Requests
+// (je Versuch, unabhängig von der Deadline des Kontexts).
+func WithRequestTimeout(d time.Duration) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.requestTimeout = d
+	}
+}
+
+// WithUploadTimeout begrenzt für diesen Aufruf die Dauer des Datei-Uploads.
+func WithUploadTimeout(d time.Duration) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.uploadTimeout = d
+	}
+}
+
+// WithMaxRetryAfter begrenzt für diesen Aufruf die Wartezeit, die der Service bei einem
+// Rate-Limit abwartet; bei längeren Vorgaben kommt sofort ErrRetryAfterTooLong zurück.
+func WithMaxRetryAfter(d time.Duration) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.maxRetryAfter = d
+	}
+}
+
+// WithUploadProgress meldet den Fortschritt des Datei-Uploads dieses Aufrufs.
+func WithUploadProgress(fn UploadProgress) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.uploadProgress = fn
+	}
+}
+
+func (ai *AiCommunicationService) newRequestConfig(opts []RequestOption) requestConfig {
+	cfg := ai.baseRequestConfig()
+	applyRequestOptions(&cfg, opts)
+	if cfg.task == "" && cfg.experiment == nil {
+		cfg.attribute()
+		return cfg
+	}
+
+	// Route und Variante zuerst, danach die Optionen erneut, damit sie Vorrang haben
+	task, exp, key := cfg.task, cfg.experiment, cfg.experimentKey
+	cfg = ai.baseRequestConfig()
+	if task != "" {
+		if route, ok := ai.Routes[task]; ok {
+			route.apply(&cfg)
+		} else {
+			cfg.taskErr = fmt.Errorf("no route configured for task %q", task)
+		}
+	}
+	if exp != nil {
+		variant := exp.Assign(key)
+		variant.Route.apply(&cfg)
+		cfg.variant = variant.ID
+	}
+	applyRequestOptions(&cfg, opts)
+	cfg.attribute()
+	return cfg
+}
+
+func (ai *AiCommunicationService) baseRequestConfig() requestConfig {
+	return requestConfig{
+		budgetErr:      ai.budgetExceeded(),
+		model:          ai.Model,
+		temperature:    ai.Temperature,
+		postProcessors: ai.PostProcessors,
+		priority:       PriorityInteractive,
+		prompt:         ai.Prompt,
+		requestTimeout: ai.RequestTimeout,
+		uploadTimeout:  ai.UploadTimeout,
+		maxRetryAfter:  ai.MaxRetryAfter,
+		hedge:          ai.Hedging,
+		cheapFirst:     ai.CheapFirst,
+		voting:         ai.Voting,
+		verification:   ai.Verification,
+		language:       ai.Language,
+		safety:         ai.Safety,
+		user:           ai.User,
+		component:      ai.Component,
+		features:       envFeatures().with(ai.Features),
+		truncation:     ai.Truncation,
+	}
+}
+
+func applyRequestOptions(cfg *requestConfig, opts []RequestOption) {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+}
+
+// requestOptions liefert die HTTP-Optionen, die sich aus der Konfiguration ergeben.
+func (cfg requestConfig) requestOptions() []option.RequestOption {
+	opts := []option.RequestOption{}
+	if cfg.idempotencyKey != "" {
+		opts = append(opts, option.WithHeader("Idempotency-Key", cfg.idempotencyKey))
+	}
+	if cfg.requestTimeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.requestTimeout))
+	}
+	return opts
+}
+
+// uploadOptions liefert die HTTP-Optionen für den Datei-Upload.
+func (cfg requestConfig) uploadOptions() []option.RequestOption {
+	opts := []option.RequestOption{}
+	if cfg.uploadTimeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.uploadTimeout))
+	}
+	return opts
+}
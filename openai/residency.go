@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go/option"
+)
+
+// ErrResidencyViolation meldet einen Request an einen Endpoint außerhalb der ResidencyPolicy.
+// Der Request wird nicht gesendet und nicht wiederholt.
+var ErrResidencyViolation = errors.New("request violates data residency policy")
+
+// EndpointEU ist der Endpoint von OpenAI für Projekte mit Datenresidenz in der EU.
+const EndpointEU = "https://eu.api.openai.com/v1"
+
+// ResidencyPolicy beschränkt, wohin ein Service Daten schicken darf, z.B. für Verträge, die
+// eine Verarbeitung in der EU verlangen. Geprüft wird jeder Request des Clients, auch
+// Uploads, sowie Hedging- und Shadow-Requests an andere Services (HedgePolicy.Service,
+// ShadowPolicy.Service), selbst wenn diese keine eigene Policy haben. Ohne BaseURL und
+// AllowedBaseURLs ist nichts erlaubt.
+type ResidencyPolicy struct {
+	// BaseURL legt den Endpoint des Services fest, auch gegen ClientOptions und
+	// OPENAI_BASE_URL, z.B. EndpointEU.
+	BaseURL string `json:"baseURL,omitempty" yaml:"baseURL,omitempty"`
+	// AllowedBaseURLs sind weitere erlaubte Endpoints, z.B. ein eigener Proxy. Eine
+	// Request-URL muss mit Schema, Host und Pfad eines davon beginnen.
+	AllowedBaseURLs []string `json:"allowedBaseURLs,omitempty" yaml:"allowedBaseURLs,omitempty"`
+}
+
+// EUResidency liefert eine Policy, die den Service auf EndpointEU festlegt.
+func EUResidency() *ResidencyPolicy {
+	return &ResidencyPolicy{BaseURL: EndpointEU}
+}
+
+// Validate prüft, ob alle Endpoints absolute http(s)-URLs sind.
+func (p *ResidencyPolicy) Validate() error {
+	_, err := p.endpoints()
+	return err
+}
+
+func (p *ResidencyPolicy) endpoints() ([]*url.URL, error) {
+	var endpoints []*url.URL
+	for _, raw := range append([]string{p.BaseURL}, p.AllowedBaseURLs...) {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid residency endpoint %q", raw)
+		}
+		endpoints = append(endpoints, u)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("residency policy allows no endpoint")
+	}
+	return endpoints, nil
+}
+
+// Allows meldet, ob ein Request an u erlaubt ist.
+func (p *ResidencyPolicy) Allows(u *url.URL) bool {
+	return p.check(u) == nil
+}
+
+func (p *ResidencyPolicy) check(u *url.URL) error {
+	endpoints, err := p.endpoints()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResidencyViolation, err)
+	}
+	for _, e := range endpoints {
+		if !strings.EqualFold(u.Scheme, e.Scheme) || !strings.EqualFold(u.Host, e.Host) {
+			continue
+		}
+		// nur an Segmentgrenzen, damit "/v1" nicht "/v10" erlaubt
+		prefix := strings.TrimSuffix(e.Path, "/")
+		if u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s://%s%s is not allowed", ErrResidencyViolation, u.Scheme, u.Host, u.Path)
+}
+
+type residencyKey struct{}
+
+// withResidency hängt p an die Policies im Kontext an. So gelten sie auch für Requests
+// anderer Services, an die der Aufruf weitergereicht wird (Hedging, Shadow).
+func withResidency(ctx context.Context, p *ResidencyPolicy) context.Context {
+	if p == nil {
+		return ctx
+	}
+	policies, _ := ctx.Value(residencyKey{}).([]*ResidencyPolicy)
+	for _, existing := range policies {
+		if existing == p {
+			return ctx
+		}
+	}
+	return context.WithValue(ctx, residencyKey{}, append(policies[:len(policies):len(policies)], p))
+}
+
+// residencyMiddleware hängt sich in den openai.Client und blockiert Requests, die gegen
+// Service.Residency oder eine Policy aus dem Kontext verstoßen.
+func (ai *AiCommunicationService) residencyMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	policies, _ := req.Context().Value(residencyKey{}).([]*ResidencyPolicy)
+	if ai.Residency != nil {
+		policies = append(policies[:len(policies):len(policies)], ai.Residency)
+	}
+	for _, p := range policies {
+		if err := p.check(req.URL); err != nil {
+			log.Warn("%s %s blocked: %v", req.Method, req.URL.Redacted(), err)
+			// die Antwort verhindert nur Wiederholungen durch den Client, sie wird nie gesendet
+			res := &http.Response{
+				StatusCode: http.StatusUnavailableForLegalReasons,
+				Header:     http.Header{"X-Should-Retry": []string{"false"}},
+				Body:       http.NoBody,
+				Request:    req,
+			}
+			return res, err
+		}
+	}
+	return next(req)
+}
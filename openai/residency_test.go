@@ -0,0 +1,104 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResidencyPolicy_Allows(t *testing.T) {
+	p := &ResidencyPolicy{BaseURL: EndpointEU, AllowedBaseURLs: []string{"http://proxy.internal:8080/openai/"}}
+	require.NoError(t, p.Validate())
+	for raw, allowed := range map[string]bool{
+		"https://eu.api.openai.com/v1/chat/completions":         true,
+		"https://EU.api.openai.com/v1/files":                    true,
+		"http://proxy.internal:8080/openai/v1/chat/completions": true,
+		"https://api.openai.com/v1/chat/completions":            false,
+		"http://eu.api.openai.com/v1/chat/completions":          false,
+		"https://eu.api.openai.com/v10/chat/completions":        false,
+		"http://proxy.internal/openai/v1/chat/completions":      false,
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		require.Equal(t, allowed, p.Allows(u), raw)
+	}
+
+	require.ErrorContains(t, (&ResidencyPolicy{}).Validate(), "allows no endpoint")
+	require.ErrorContains(t, (&ResidencyPolicy{AllowedBaseURLs: []string{"eu.api.openai.com"}}).Validate(), "invalid residency endpoint")
+	u, _ := url.Parse(EndpointEU + "/files")
+	require.False(t, (&ResidencyPolicy{}).Allows(u))
+}
+
+// newResidencyServer liefert einen Testserver, der seine Requests zählt.
+func newResidencyServer(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestGenerateContent_Residency(t *testing.T) {
+	srv, calls := newResidencyServer(t, 0)
+	ai := NewAiCommunicationService("prompt")
+	ai.config.AuthData["apiKey"] = "test-key"
+	ai.ClientOptions = []option.RequestOption{option.WithBaseURL(srv.URL)}
+	ai.Residency = &ResidencyPolicy{AllowedBaseURLs: []string{srv.URL}}
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.EqualValues(t, 1, calls.Load())
+
+	// ohne Wiederholungen des Clients, der Server sieht nichts
+	blocked := NewAiCommunicationService("prompt")
+	blocked.config.AuthData["apiKey"] = "test-key"
+	blocked.ClientOptions = []option.RequestOption{option.WithBaseURL(srv.URL)}
+	blocked.Residency = &ResidencyPolicy{AllowedBaseURLs: []string{EndpointEU}}
+	start := time.Now()
+	_, err = blocked.GenerateContent("system")
+	require.ErrorIs(t, err, ErrResidencyViolation)
+	require.Less(t, time.Since(start), 300*time.Millisecond)
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestGenerateContent_ResidencyHedgeService(t *testing.T) {
+	primary, primaryCalls := newResidencyServer(t, 200*time.Millisecond)
+	other, otherCalls := newResidencyServer(t, 0)
+
+	fallback := NewAiCommunicationService("prompt")
+	fallback.config.AuthData["apiKey"] = "test-key"
+	fallback.ClientOptions = []option.RequestOption{option.WithBaseURL(other.URL)}
+
+	ai := NewAiCommunicationService("prompt")
+	ai.config.AuthData["apiKey"] = "test-key"
+	ai.ClientOptions = []option.RequestOption{option.WithBaseURL(primary.URL)}
+	ai.Residency = &ResidencyPolicy{AllowedBaseURLs: []string{primary.URL}}
+	ai.Hedging = &HedgePolicy{After: 20 * time.Millisecond, Model: openai.ChatModelGPT4_1Mini, Service: fallback}
+
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.EqualValues(t, 1, primaryCalls.Load())
+	require.Zero(t, otherCalls.Load(), "the hedge service must not receive data")
+
+	// ohne eigene Policy darf der Fallback-Service selbst weiterhin überall hin
+	_, err = fallback.GenerateContent("system")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, otherCalls.Load())
+}
@@ -0,0 +1,48 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// maxLoggedContent begrenzt Antworten im Debug-Log.
+const maxLoggedContent = 4096
+
+// ErrResponseTooLarge meldet eine Antwort über MaxResponseBytes, z.B. weil das Modell das
+// ganze Dokument zurückgibt.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// limitResponse setzt MaxResponseBytes durch: je nach TruncateResponses wird gekürzt oder
+// ErrResponseTooLarge gemeldet.
+func (ai *AiCommunicationService) limitResponse(content string) (string, error) {
+	if ai.MaxResponseBytes <= 0 || len(content) <= ai.MaxResponseBytes {
+		return content, nil
+	}
+	if !ai.TruncateResponses {
+		return "", fmt.Errorf("%w: %d bytes, limit is %d", ErrResponseTooLarge, len(content), ai.MaxResponseBytes)
+	}
+	log.Warn("response of %d bytes truncated to %d", len(content), ai.MaxResponseBytes)
+	return truncateUTF8(content, ai.MaxResponseBytes), nil
+}
+
+// truncateUTF8 kürzt s auf höchstens limit Bytes, ohne ein Zeichen zu zerschneiden.
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// logContent kürzt content für das Log.
+func logContent(content string) string {
+	if len(content) <= maxLoggedContent {
+		return content
+	}
+	return fmt.Sprintf("%s... [%d bytes]", truncateUTF8(content, maxLoggedContent), len(content))
+}
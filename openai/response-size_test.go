@@ -0,0 +1,34 @@
+package openai
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateContent_MaxResponseBytes(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, `"Größenangabe"`, 1)))
+	})
+	ai.MaxResponseBytes = 3
+
+	_, err := ai.GenerateContent("system")
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	// nicht mitten im ö abschneiden
+	ai.TruncateResponses = true
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, "Gr", content)
+}
+
+func TestLogContent(t *testing.T) {
+	long := strings.Repeat("ä", maxLoggedContent)
+	logged := logContent(long)
+	require.Less(t, len(logged), maxLoggedContent+50)
+	require.True(t, strings.HasSuffix(logged, "... [8192 bytes]"))
+	require.Equal(t, "kurz", logContent("kurz"))
+}
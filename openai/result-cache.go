@@ -0,0 +1,239 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// ResultCache speichert strukturierte Ergebnisse, damit dasselbe Dokument mit demselben
+// Prompt und Modell nicht erneut bezahlt werden muss.
+type ResultCache interface {
+	Get(key string) (content string, ok bool, err error)
+	Set(key, content string) error
+}
+
+// agingCache ist ein ResultCache, der weiß, wann seine Einträge geschrieben wurden.
+type agingCache interface {
+	GetWithTime(key string) (content string, stored time.Time, ok bool, err error)
+}
+
+// cacheEntry ist ein Treffer im Result-Cache; stored ist leer, wenn der Cache kein Alter kennt.
+type cacheEntry struct {
+	content string
+	stored  time.Time
+}
+
+// lookupCache liest einen Eintrag samt Alter, sofern der Cache es kennt.
+func lookupCache(cache ResultCache, key string) (*cacheEntry, error) {
+	if c, ok := cache.(agingCache); ok {
+		content, stored, ok, err := c.GetWithTime(key)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return &cacheEntry{content: content, stored: stored}, nil
+	}
+	content, ok, err := cache.Get(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &cacheEntry{content: content}, nil
+}
+
+// MemoryResultCache hält Ergebnisse im Speicher des Prozesses.
+type MemoryResultCache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+func NewMemoryResultCache() *MemoryResultCache {
+	return &MemoryResultCache{entries: map[string]cacheEntry{}}
+}
+
+func (c *MemoryResultCache) Get(key string) (string, bool, error) {
+	content, _, ok, err := c.GetWithTime(key)
+	return content, ok, err
+}
+
+// GetWithTime wie Get, zusätzlich mit dem Zeitpunkt von Set.
+func (c *MemoryResultCache) GetWithTime(key string) (string, time.Time, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[key]
+	return entry.content, entry.stored, ok, nil
+}
+
+func (c *MemoryResultCache) Set(key, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]cacheEntry{}
+	}
+	c.entries[key] = cacheEntry{content: content, stored: time.Now()}
+	return nil
+}
+
+// FileResultCache legt jedes Ergebnis als eigene Datei im Verzeichnis Dir ab.
+type FileResultCache struct {
+	Dir string
+	// Encryption verschlüsselt die Einträge; unverschlüsselte Einträge gelten dann als nicht
+	// vorhanden und werden beim nächsten Set ersetzt. Optional.
+	Encryption *Encryption
+}
+
+func NewFileResultCache(dir string) (*FileResultCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, log.WrapError(err)
+	}
+	return &FileResultCache{Dir: dir}, nil
+}
+
+func (c *FileResultCache) Get(key string) (string, bool, error) {
+	content, _, ok, err := c.GetWithTime(key)
+	return content, ok, err
+}
+
+// GetWithTime wie Get, zusätzlich mit der Änderungszeit der Datei.
+func (c *FileResultCache) GetWithTime(key string) (string, time.Time, bool, error) {
+	f, err := os.Open(c.path(key))
+	if os.IsNotExist(err) {
+		return "", time.Time{}, false, nil
+	}
+	if err != nil {
+		return "", time.Time{}, false, log.WrapError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", time.Time{}, false, log.WrapError(err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", time.Time{}, false, log.WrapError(err)
+	}
+	switch {
+	case c.Encryption != nil && !IsEncrypted(data):
+		return "", time.Time{}, false, nil
+	case c.Encryption != nil:
+		if data, err = c.Encryption.Open(context.Background(), data); err != nil {
+			return "", time.Time{}, false, fmt.Errorf("cache entry %s: %w", key, err)
+		}
+	case IsEncrypted(data):
+		return "", time.Time{}, false, fmt.Errorf("cache entry %s: %w: cache has no encryption", key, ErrDecrypt)
+	}
+	return string(data), info.ModTime(), true, nil
+}
+
+func (c *FileResultCache) Set(key, content string) error {
+	data := []byte(content)
+	if c.Encryption != nil {
+		var err error
+		if data, err = c.Encryption.Seal(context.Background(), data); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return log.WrapError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return log.WrapError(err)
+	}
+	return log.WrapError(os.Rename(tmp.Name(), c.path(key)))
+}
+
+// Prune entfernt Einträge, die älter als maxAge sind, und liefert ihre Zahl.
+func (c *FileResultCache) Prune(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return 0, log.WrapError(err)
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return removed, log.WrapError(err)
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return removed, log.WrapError(err)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (c *FileResultCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// fileSHA256 liefert den SHA-256 einer Datei als Hex-String, ohne sie komplett zu laden.
+func fileSHA256(fileName string) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// promptVersion liefert PromptVersion oder, falls leer, einen aus System-Message und
+// Prompt abgeleiteten Wert.
+func (ai *AiCommunicationService) promptVersion(systemMessage string, cfg requestConfig) string {
+	if ai.PromptVersion != "" {
+		return ai.PromptVersion
+	}
+	sum := sha256.Sum256([]byte(systemMessage + "\x00" + cfg.prompt))
+	return hex.EncodeToString(sum[:8])
+}
+
+// resultCacheKey bildet den Schlüssel aus Datei-Hash, Prompt-Version, System-Message,
+// Prompt und allem, was die Antwort des Modells oder das gespeicherte Ergebnis verändert:
+// Modell, Temperatur, Post-Prozessoren, Konfidenz und Fundstellen sowie das Schema.
+func (ai *AiCommunicationService) resultCacheKey(fileHash, systemMessage string, cfg requestConfig) string {
+	schema := ""
+	if cfg.schema != nil {
+		schema = cfg.schema.fingerprint()
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		fileHash,
+		ai.promptVersion(systemMessage, cfg),
+		contentHash(systemMessage + "\x00" + cfg.prompt),
+		string(cfg.model),
+		strconv.FormatFloat(cfg.temperature, 'g', -1, 64),
+		strings.Join(cfg.postProcessors, ","),
+		strconv.FormatBool(cfg.fieldConfidence),
+		strconv.FormatBool(cfg.citations),
+		schema,
+	}, "|")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileResultCache(t *testing.T) {
+	c, err := NewFileResultCache(filepath.Join(t.TempDir(), "cache"))
+	require.NoError(t, err)
+
+	_, ok, err := c.Get("missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, c.Set("key", `{"a":1}`))
+	content, ok, err := c.Get("key")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `{"a":1}`, content)
+}
+
+func TestResultCacheKey(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "invoice.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+	hash, err := fileSHA256(fileName)
+	require.NoError(t, err)
+
+	ai := NewAiCommunicationService("prompt")
+	cfg := ai.newRequestConfig(nil)
+	key := ai.resultCacheKey(hash, "system", cfg)
+	require.Equal(t, key, ai.resultCacheKey(hash, "system", cfg))
+	require.NotEqual(t, key, ai.resultCacheKey(hash, "other system", cfg))
+
+	ai.Model = "gpt-4o"
+	cfg = ai.newRequestConfig(nil)
+	require.NotEqual(t, key, ai.resultCacheKey(hash, "system", cfg))
+
+	ai.PromptVersion = "v2"
+	versioned := ai.resultCacheKey(hash, "system", cfg)
+	require.NotEqual(t, key, versioned)
+	require.NotEqual(t, versioned, ai.resultCacheKey(hash, "other system", cfg))
+	ai.PromptVersion = ""
+
+	// alles, was die Antwort verändert, gehört zum Schlüssel
+	key = ai.resultCacheKey(hash, "system", cfg)
+	schema, err := ParseJSONSchema([]byte(`{"type": "object", "required": ["total"]}`))
+	require.NoError(t, err)
+	other, err := ParseJSONSchema([]byte(`{"type": "object", "required": ["id"]}`))
+	require.NoError(t, err)
+	warmer := NewAiCommunicationService("prompt")
+	warmer.Model, warmer.Temperature = ai.Model, 0.7
+	keys := map[string]bool{key: true}
+	for i, k := range []string{
+		warmer.resultCacheKey(hash, "system", warmer.newRequestConfig(nil)),
+		ai.resultCacheKey(hash, "system", ai.newRequestConfig([]RequestOption{WithFieldConfidence()})),
+		ai.resultCacheKey(hash, "system", ai.newRequestConfig([]RequestOption{WithCitations()})),
+		ai.resultCacheKey(hash, "system", ai.newRequestConfig([]RequestOption{WithFieldConfidence(), WithCitations()})),
+		ai.resultCacheKey(hash, "system", ai.newRequestConfig([]RequestOption{WithSchema(schema)})),
+		ai.resultCacheKey(hash, "system", ai.newRequestConfig([]RequestOption{WithSchema(other)})),
+	} {
+		require.False(t, keys[k], "key %d is not unique", i)
+		keys[k] = true
+	}
+	require.True(t, keys[ai.resultCacheKey(hash, "system", ai.newRequestConfig([]RequestOption{WithSchema(schema)}))])
+}
@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// RetentionPolicy legt fest, wie lange gespeicherte Artefakte aufbewahrt werden; siehe
+// EnforceRetention. Eine Dauer von 0 bewahrt die Art unbegrenzt auf.
+type RetentionPolicy struct {
+	// AuditDir enthält Audit-Logs (siehe OpenAuditLog); Dateien, die länger als AuditLogs
+	// nicht geschrieben wurden, werden gelöscht. Das laufende Log bleibt so erhalten.
+	AuditDir  string
+	AuditLogs time.Duration
+	// DebugDir enthält Debug-Ausgaben wie Agent-Transkripte (siehe Agent.Transcripts).
+	DebugDir   string
+	DebugDumps time.Duration
+	// Cache gilt für Einträge von Service.Cache, sofern der Cache sie altern lassen kann
+	// (FileResultCache); MemoryResultCache endet mit dem Prozess.
+	Cache time.Duration
+	// UploadedFiles gilt für Dokumente bei der Files API (Zweck user_data) des API-Keys,
+	// auch für solche, die andere Programme mit demselben Key hochgeladen haben.
+	UploadedFiles time.Duration
+}
+
+// RetentionReport zählt die gelöschten Artefakte.
+type RetentionReport struct {
+	AuditLogs     int `json:"auditLogs"`
+	DebugDumps    int `json:"debugDumps"`
+	CacheEntries  int `json:"cacheEntries"`
+	UploadedFiles int `json:"uploadedFiles"`
+}
+
+// Total ist die Gesamtzahl gelöschter Artefakte.
+func (r RetentionReport) Total() int {
+	return r.AuditLogs + r.DebugDumps + r.CacheEntries + r.UploadedFiles
+}
+
+// prunableCache ist ein ResultCache, der alte Einträge entfernen kann.
+type prunableCache interface {
+	Prune(maxAge time.Duration) (int, error)
+}
+
+// EnforceRetention löscht nach Service.Retention alle Artefakte, deren Aufbewahrungsfrist
+// abgelaufen ist. Fehler bei einer Art brechen die übrigen nicht ab; sie kommen gesammelt
+// zurück, der Bericht zählt, was bis dahin gelöscht wurde.
+func (ai *AiCommunicationService) EnforceRetention(ctx context.Context) (RetentionReport, error) {
+	var report RetentionReport
+	p := ai.Retention
+	if p == nil {
+		return report, nil
+	}
+	var errs []error
+	var err error
+	if p.AuditDir != "" && p.AuditLogs > 0 {
+		report.AuditLogs, err = removeOldFiles(p.AuditDir, p.AuditLogs)
+		errs = append(errs, err)
+	}
+	if p.DebugDir != "" && p.DebugDumps > 0 {
+		report.DebugDumps, err = removeOldFiles(p.DebugDir, p.DebugDumps)
+		errs = append(errs, err)
+	}
+	if cache, ok := ai.Cache.(prunableCache); ok && p.Cache > 0 {
+		report.CacheEntries, err = cache.Prune(p.Cache)
+		errs = append(errs, err)
+	}
+	if p.UploadedFiles > 0 {
+		report.UploadedFiles, err = ai.deleteUploadedFiles(ctx, p.UploadedFiles)
+		errs = append(errs, err)
+	}
+	if report.Total() > 0 {
+		log.Info("Retention removed %d audit logs, %d debug dumps, %d cache entries and %d uploaded files",
+			report.AuditLogs, report.DebugDumps, report.CacheEntries, report.UploadedFiles)
+	}
+	return report, errors.Join(errs...)
+}
+
+// removeOldFiles löscht unter dir alle Dateien, die länger als maxAge nicht geändert wurden.
+// Verzeichnisse bleiben stehen; fehlt dir, gibt es nichts zu tun.
+func removeOldFiles(dir string, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, log.WrapError(err)
+}
+
+// deleteUploadedFiles löscht bei der Files API alle Dokumente, die vor mehr als maxAge
+// hochgeladen wurden.
+func (ai *AiCommunicationService) deleteUploadedFiles(ctx context.Context, maxAge time.Duration) (int, error) {
+	ai.init()
+	cutoff := time.Now().Add(-maxAge).Unix()
+	// älteste zuerst, damit die Liste nach dem ersten zu jungen Dokument enden kann
+	pager := ai.client.Files.ListAutoPaging(ctx, openai.FileListParams{
+		Purpose: param.NewOpt(string(openai.FilePurposeUserData)),
+		Order:   openai.FileListParamsOrderAsc,
+	})
+	var ids []string
+	for pager.Next() {
+		file := pager.Current()
+		if file.CreatedAt >= cutoff {
+			break
+		}
+		ids = append(ids, file.ID)
+	}
+	if err := pager.Err(); err != nil {
+		return 0, log.WrapError(fmt.Errorf("failed to list uploaded files: %w", err))
+	}
+	deleted := 0
+	for _, id := range ids {
+		if _, err := ai.client.Files.Delete(ctx, id); err != nil {
+			return deleted, log.WrapError(fmt.Errorf("failed to delete uploaded file %s: %w", id, err))
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// AddRetention plant EnforceRetention für ai nach schedule ein, siehe ParseSchedule.
+func (s *JobScheduler) AddRetention(schedule string, ai *AiCommunicationService) error {
+	return s.AddTask("retention", schedule, func() error {
+		_, err := ai.EnforceRetention(context.Background())
+		return err
+	})
+}
@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeAged legt name mit der angegebenen Änderungszeit an.
+func writeAged(t *testing.T, name string, age time.Duration) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	require.NoError(t, os.WriteFile(name, []byte("{}"), 0644))
+	mtime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(name, mtime, mtime))
+}
+
+func TestEnforceRetention(t *testing.T) {
+	now := time.Now()
+	var mu sync.Mutex
+	var deleted []string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/files":
+			require.Equal(t, "user_data", r.URL.Query().Get("purpose"))
+			require.Equal(t, "asc", r.URL.Query().Get("order"))
+			fmt.Fprintf(w, `{"object": "list", "has_more": false, "data": [
+				{"id": "file-old", "object": "file", "bytes": 10, "created_at": %d, "filename": "a.pdf", "purpose": "user_data"},
+				{"id": "file-new", "object": "file", "bytes": 10, "created_at": %d, "filename": "b.pdf", "purpose": "user_data"}]}`,
+				now.Add(-48*time.Hour).Unix(), now.Add(-time.Hour).Unix())
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/files/"):
+			mu.Lock()
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/files/"))
+			mu.Unlock()
+			fmt.Fprintf(w, `{"id": %q, "object": "file", "deleted": true}`, strings.TrimPrefix(r.URL.Path, "/files/"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	dir := t.TempDir()
+	auditDir := filepath.Join(dir, "audit")
+	writeAged(t, filepath.Join(auditDir, "2024-01.jsonl.gz"), 100*24*time.Hour)
+	writeAged(t, filepath.Join(auditDir, "current.jsonl"), time.Minute)
+	debugDir := filepath.Join(dir, "debug")
+	writeAged(t, filepath.Join(debugDir, "runs", "old.json"), 8*24*time.Hour)
+	writeAged(t, filepath.Join(debugDir, "new.json"), time.Hour)
+	cache, err := NewFileResultCache(filepath.Join(dir, "cache"))
+	require.NoError(t, err)
+	require.NoError(t, cache.Set("fresh", "{}"))
+	writeAged(t, cache.path("stale"), 31*24*time.Hour)
+
+	ai.Cache = cache
+	ai.Retention = &RetentionPolicy{
+		AuditDir:      auditDir,
+		AuditLogs:     90 * 24 * time.Hour,
+		DebugDir:      debugDir,
+		DebugDumps:    7 * 24 * time.Hour,
+		Cache:         30 * 24 * time.Hour,
+		UploadedFiles: 24 * time.Hour,
+	}
+	report, err := ai.EnforceRetention(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, RetentionReport{AuditLogs: 1, DebugDumps: 1, CacheEntries: 1, UploadedFiles: 1}, report)
+	require.Equal(t, []string{"file-old"}, deleted)
+
+	require.NoFileExists(t, filepath.Join(auditDir, "2024-01.jsonl.gz"))
+	require.FileExists(t, filepath.Join(auditDir, "current.jsonl"))
+	require.NoFileExists(t, filepath.Join(debugDir, "runs", "old.json"))
+	require.DirExists(t, filepath.Join(debugDir, "runs"))
+	require.FileExists(t, filepath.Join(debugDir, "new.json"))
+	_, ok, err := cache.Get("stale")
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, ok, err = cache.Get("fresh")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// fehlende Verzeichnisse sind kein Fehler, ein Fehler der Files API bricht den Rest nicht ab
+	ai.Retention = &RetentionPolicy{AuditDir: filepath.Join(dir, "missing"), AuditLogs: time.Hour, UploadedFiles: time.Hour}
+	ai.Retention.DebugDir, ai.Retention.DebugDumps = debugDir, time.Minute
+	failing := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "boom"}}`, http.StatusInternalServerError)
+	})
+	failing.Retention = ai.Retention
+	report, err = failing.EnforceRetention(context.Background())
+	require.ErrorContains(t, err, "failed to list uploaded files")
+	require.Equal(t, RetentionReport{DebugDumps: 1}, report)
+
+	report, err = NewAiCommunicationService("").EnforceRetention(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, report.Total())
+}
+
+func TestJobScheduler_AddRetention(t *testing.T) {
+	dir := t.TempDir()
+	writeAged(t, filepath.Join(dir, "debug", "old.json"), 2*time.Hour)
+	ai := NewAiCommunicationService("")
+	ai.Retention = &RetentionPolicy{DebugDir: filepath.Join(dir, "debug"), DebugDumps: time.Hour}
+
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	s := NewJobScheduler(filepath.Join(dir, "locks"))
+	require.NoError(t, os.MkdirAll(s.LockDir, 0755))
+	s.now = func() time.Time { return now }
+	require.NoError(t, s.AddRetention("@hourly", ai))
+	require.ErrorContains(t, s.AddRetention("@daily", ai), "already scheduled")
+
+	now = now.Add(time.Hour)
+	s.runDue(context.Background())
+	s.wg.Wait()
+	status := s.Status()
+	require.Len(t, status, 1)
+	require.Equal(t, "retention", status[0].Name)
+	require.Equal(t, 1, status[0].Runs)
+	require.Empty(t, status[0].LastError)
+	require.NoFileExists(t, filepath.Join(dir, "debug", "old.json"))
+}
@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// Gründe, aus denen ein Ergebnis zur Prüfung vorgelegt wird.
+const (
+	ReviewFailed        = "failed"         // Konvertierung fehlgeschlagen
+	ReviewInvalid       = "invalid"        // Validate oder Schema hat das Ergebnis abgelehnt
+	ReviewLowConfidence = "low-confidence" // Felder unter MinConfidence
+	ReviewUnsupported   = "unsupported"    // Prüfdurchlauf fand Angaben nicht im Dokument
+)
+
+// ReviewItem enthält alles, was ein Mensch zum Korrigieren eines Ergebnisses braucht.
+type ReviewItem struct {
+	SourceFile    string                     `json:"sourceFile"`
+	OutputFile    string                     `json:"outputFile,omitempty"`
+	SystemMessage string                     `json:"systemMessage"`
+	Prompt        string                     `json:"prompt,omitempty"`
+	Content       string                     `json:"content,omitempty"`
+	Reason        string                     `json:"reason"`
+	Errors        []string                   `json:"errors,omitempty"`
+	Confidence    map[string]FieldConfidence `json:"confidence,omitempty"`
+	LowConfidence []string                   `json:"lowConfidence,omitempty"` // Feldpfade unter MinConfidence
+	Unsupported   []UnsupportedClaim         `json:"unsupported,omitempty"`
+	Variant       string                     `json:"variant,omitempty"`
+	CreatedAt     time.Time                  `json:"createdAt"`
+}
+
+// ReviewSink nimmt Ergebnisse entgegen, die ein Mensch prüfen soll.
+type ReviewSink interface {
+	Submit(ctx context.Context, item ReviewItem) error
+}
+
+// FolderReviewSink legt jedes ReviewItem als <Quelldatei>.review.json im Verzeichnis Dir ab;
+// ein erneuter Eintrag zur selben Datei ersetzt den alten.
+type FolderReviewSink struct {
+	Dir string
+}
+
+func NewFolderReviewSink(dir string) (*FolderReviewSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, log.WrapError(err)
+	}
+	return &FolderReviewSink{Dir: dir}, nil
+}
+
+func (s *FolderReviewSink) Submit(ctx context.Context, item ReviewItem) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".tmp-*")
+	if err != nil {
+		return log.WrapError(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return log.WrapError(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return log.WrapError(err)
+	}
+	return log.WrapError(os.Rename(tmp.Name(), filepath.Join(s.Dir, filepath.Base(item.SourceFile)+".review.json")))
+}
+
+// review legt ein Ergebnis zur Prüfung vor, wenn die Konvertierung fehlschlug, Validate es
+// ablehnt, Felder unter MinConfidence liegen oder der Prüfdurchlauf Angaben nicht belegen
+// konnte, und vermerkt das im Dokument.
+func (bc *BatchConverter) review(ctx context.Context, doc *DocumentResult, cfg requestConfig, convErr error) {
+	if len(bc.Review) == 0 {
+		return
+	}
+	item := ReviewItem{
+		SourceFile:    doc.SourceFile,
+		SystemMessage: bc.SystemMessage,
+		Prompt:        cfg.prompt,
+		Content:       doc.Content,
+		Confidence:    doc.Confidence,
+		Variant:       doc.Variant,
+		Unsupported:   doc.Unsupported,
+	}
+	switch {
+	case len(doc.Violations) > 0:
+		item.Reason = ReviewInvalid
+		for _, v := range doc.Violations {
+			item.Errors = append(item.Errors, v.String())
+		}
+	case convErr != nil:
+		item.Reason = ReviewFailed
+		item.Errors = []string{convErr.Error()}
+	case bc.Validate != nil:
+		if err := bc.Validate(doc.Content); err != nil {
+			item.Reason = ReviewInvalid
+			item.Errors = []string{err.Error()}
+		}
+	}
+	if convErr == nil && bc.MinConfidence > 0 {
+		extraction := Extraction{Confidence: doc.Confidence}
+		if item.LowConfidence = extraction.LowConfidence(bc.MinConfidence); len(item.LowConfidence) > 0 && item.Reason == "" {
+			item.Reason = ReviewLowConfidence
+		}
+	}
+	if item.Reason == "" && len(doc.Unsupported) > 0 {
+		item.Reason = ReviewUnsupported
+	}
+	if item.Reason == "" {
+		return
+	}
+	if doc.Status == DocumentDone {
+		item.OutputFile = doc.OutputFile
+	}
+	item.CreatedAt = time.Now()
+
+	doc.Review = item.Reason
+	for _, sink := range bc.Review {
+		if err := sink.Submit(ctx, item); err != nil {
+			log.Error(log.WrapError(err))
+		}
+	}
+	log.Info("Submitted %s for review: %s %s", doc.SourceFile, item.Reason, strings.Join(item.LowConfidence, ", "))
+}
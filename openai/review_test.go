@@ -0,0 +1,87 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchConverter_Review(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(testPDF+name), 0644))
+	}
+	answers := map[int]string{
+		0: `{"data": {"total": 12.5}, "confidence": {"total": {"score": 0.95}}}`,
+		1: `{"data": {"total": 7}, "confidence": {"total": {"score": 0.3, "pages": [1]}}}`,
+		2: `{"data": {"total": -1}, "confidence": {"total": {"score": 0.99}}}`,
+	}
+	calls := 0
+	ai := newBatchTestService(t, func() string {
+		defer func() { calls++ }()
+		return answers[calls]
+	})
+
+	reviewDir := filepath.Join(t.TempDir(), "review")
+	sink, err := NewFolderReviewSink(reviewDir)
+	require.NoError(t, err)
+	bc := NewBatchConverter(ai, "system", src, filepath.Join(t.TempDir(), "out"))
+	bc.FieldConfidence = true
+	bc.Review = []ReviewSink{sink}
+	bc.MinConfidence = 0.8
+	bc.Validate = func(content string) error {
+		var v struct{ Total float64 }
+		if err := json.Unmarshal([]byte(content), &v); err != nil {
+			return err
+		}
+		if v.Total < 0 {
+			return errors.New("total must not be negative")
+		}
+		return nil
+	}
+
+	result, err := bc.Run()
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Converted)
+	require.Equal(t, 2, result.Review)
+	require.Empty(t, result.Documents[0].Review)
+	require.Equal(t, ReviewLowConfidence, result.Documents[1].Review)
+	require.Equal(t, ReviewInvalid, result.Documents[2].Review)
+
+	data, err := os.ReadFile(filepath.Join(reviewDir, "b.pdf.review.json"))
+	require.NoError(t, err)
+	var item ReviewItem
+	require.NoError(t, json.Unmarshal(data, &item))
+	require.Equal(t, []string{"total"}, item.LowConfidence)
+	require.Equal(t, "system", item.SystemMessage)
+	require.Equal(t, filepath.Join(src, "b.pdf"), item.SourceFile)
+	require.NotEmpty(t, item.OutputFile)
+
+	data, err = os.ReadFile(filepath.Join(reviewDir, "c.pdf.review.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &item))
+	require.Equal(t, []string{"total must not be negative"}, item.Errors)
+}
+
+func TestWebhookSink_Submit(t *testing.T) {
+	var got ReviewItem
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Signature-256")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, []byte("secret"))
+	require.NoError(t, sink.Submit(context.Background(), ReviewItem{SourceFile: "a.pdf", Reason: ReviewFailed, Errors: []string{"boom"}}))
+	require.Equal(t, ReviewFailed, got.Reason)
+	require.Equal(t, []string{"boom"}, got.Errors)
+	require.NotEmpty(t, signature)
+}
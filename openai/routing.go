@@ -0,0 +1,42 @@
+package openai
+
+import (
+	"github.com/openai/openai-go"
+)
+
+// Route legt für einen Aufgabentyp Modell, Prompt und Parameter fest.
+// Leere Felder übernehmen die Werte des Services.
+type Route struct {
+	Model          openai.ChatModel
+	Prompt         string
+	Temperature    *float64
+	PostProcessors []string
+	Schema         *JSONSchema // prüft die Antworten, siehe WithSchema
+}
+
+// WithTask wählt die unter Routes konfigurierte Route für diesen Aufruf, z.B.
+// WithTask("invoice-extraction"). Weitere Optionen des Aufrufs haben Vorrang vor der Route.
+func WithTask(task string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.task = task
+	}
+}
+
+// apply überträgt die Route auf die Konfiguration.
+func (r Route) apply(cfg *requestConfig) {
+	if r.Model != "" {
+		cfg.model = r.Model
+	}
+	if r.Prompt != "" {
+		cfg.prompt = r.Prompt
+	}
+	if r.Temperature != nil {
+		cfg.temperature = *r.Temperature
+	}
+	if r.PostProcessors != nil {
+		cfg.postProcessors = r.PostProcessors
+	}
+	if r.Schema != nil {
+		cfg.schema = r.Schema
+	}
+}
@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequestConfig_Routes(t *testing.T) {
+	temperature := 0.7
+	ai := NewAiCommunicationService("default prompt")
+	ai.Routes = map[string]Route{
+		"invoice-extraction": {
+			Model:       openai.ChatModelGPT4_1Mini,
+			Prompt:      "invoice prompt",
+			Temperature: &temperature,
+		},
+	}
+
+	cfg := ai.newRequestConfig([]RequestOption{WithTask("invoice-extraction")})
+	require.NoError(t, cfg.taskErr)
+	require.Equal(t, openai.ChatModelGPT4_1Mini, cfg.model)
+	require.Equal(t, "invoice prompt", cfg.prompt)
+	require.Equal(t, 0.7, cfg.temperature)
+
+	// Optionen des Aufrufs haben Vorrang, unabhängig von der Reihenfolge
+	cfg = ai.newRequestConfig([]RequestOption{WithPrompt("own prompt"), WithTask("invoice-extraction")})
+	require.Equal(t, "own prompt", cfg.prompt)
+	require.Equal(t, openai.ChatModelGPT4_1Mini, cfg.model)
+
+	cfg = ai.newRequestConfig([]RequestOption{WithTask("unknown")})
+	require.Error(t, cfg.taskErr)
+}
+
+func TestGenerateContent_WithTask(t *testing.T) {
+	var body struct {
+		Model       string  `json:"model"`
+		Temperature float64 `json:"temperature"`
+	}
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	temperature := 0.3
+	ai.Routes = map[string]Route{"summary": {Model: openai.ChatModelGPT4_1Nano, Temperature: &temperature}}
+
+	_, err := ai.GenerateContent("system", WithTask("summary"))
+	require.NoError(t, err)
+	require.Equal(t, string(openai.ChatModelGPT4_1Nano), body.Model)
+	require.Equal(t, 0.3, body.Temperature)
+
+	_, err = ai.GenerateContent("system", WithTask("missing"))
+	require.ErrorContains(t, err, "no route configured")
+}
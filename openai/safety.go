@@ -0,0 +1,104 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// ErrModerationBlocked meldet, dass die Eingabe von der Moderation abgelehnt wurde.
+var ErrModerationBlocked = errors.New("input blocked by moderation")
+
+// SafetySettings reicht die Sicherheitseinstellungen des Providers durch. OpenAI kennt keine
+// Filterstufen je Request; strengere oder lockerere Filterung erfolgt deshalb über eine
+// vorgeschaltete Prüfung der Eingabe mit der Moderation-API.
+type SafetySettings struct {
+	// Identifier wird als safety_identifier mitgeschickt: eine stabile, anonymisierte
+	// Nutzer-ID, mit der OpenAI Missbrauch einzelnen Nutzern zuordnen kann.
+	Identifier string `json:"identifier,omitempty" yaml:"identifier,omitempty"`
+
+	// ModerateInput prüft System-Message und Prompt vor dem Request mit der Moderation-API.
+	ModerateInput   bool   `json:"moderateInput,omitempty" yaml:"moderateInput,omitempty"`
+	ModerationModel string `json:"moderationModel,omitempty" yaml:"moderationModel,omitempty"` // Default: omni-moderation-latest
+
+	// BlockCategories begrenzt das Blockieren auf diese Kategorien (z.B. "violence");
+	// leer = jede von OpenAI markierte Kategorie.
+	BlockCategories []string `json:"blockCategories,omitempty" yaml:"blockCategories,omitempty"`
+
+	// Thresholds blockiert je Kategorie ab diesem Score (0..1), unabhängig von der
+	// Markierung durch OpenAI: kleinere Werte filtern strenger, größere lockerer.
+	Thresholds map[string]float64 `json:"thresholds,omitempty" yaml:"thresholds,omitempty"`
+}
+
+// WithSafety ersetzt für diesen Aufruf die Sicherheitseinstellungen des Services.
+func WithSafety(s SafetySettings) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.safety = &s
+	}
+}
+
+// apply überträgt die Einstellungen in die Request-Parameter.
+func (s *SafetySettings) apply(params *openai.ChatCompletionNewParams) {
+	if s == nil || s.Identifier == "" {
+		return
+	}
+	params.SafetyIdentifier = param.NewOpt(s.Identifier)
+}
+
+// moderate prüft die Texte mit der Moderation-API und liefert ErrModerationBlocked,
+// wenn eine zu blockierende Kategorie zutrifft.
+func (s *SafetySettings) moderate(ctx context.Context, client *openai.Client, texts ...string) error {
+	if s == nil || !s.ModerateInput {
+		return nil
+	}
+	inputs := slices.DeleteFunc(slices.Clone(texts), func(t string) bool { return strings.TrimSpace(t) == "" })
+	if len(inputs) == 0 {
+		return nil
+	}
+	model := openai.ModerationModel(s.ModerationModel)
+	if model == "" {
+		model = openai.ModerationModelOmniModerationLatest
+	}
+	resp, err := client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfStringArray: inputs},
+		Model: model,
+	})
+	if err != nil {
+		return log.WrapError(fmt.Errorf("moderation failed: %w", err))
+	}
+	for _, result := range resp.Results {
+		if blocked := s.blockedCategories(result); len(blocked) > 0 {
+			return fmt.Errorf("%w: %s", ErrModerationBlocked, strings.Join(blocked, ", "))
+		}
+	}
+	return nil
+}
+
+// blockedCategories wertet ein Moderationsergebnis gemäß den Einstellungen aus.
+func (s *SafetySettings) blockedCategories(result openai.Moderation) []string {
+	var flagged map[string]bool
+	var scores map[string]float64
+	_ = json.Unmarshal([]byte(result.Categories.RawJSON()), &flagged)
+	_ = json.Unmarshal([]byte(result.CategoryScores.RawJSON()), &scores)
+
+	var blocked []string
+	for category, isFlagged := range flagged {
+		if threshold, ok := s.Thresholds[category]; ok {
+			isFlagged = scores[category] >= threshold
+		} else if len(s.BlockCategories) > 0 && !slices.Contains(s.BlockCategories, category) {
+			isFlagged = false
+		}
+		if isFlagged {
+			blocked = append(blocked, category)
+		}
+	}
+	slices.Sort(blocked)
+	return blocked
+}
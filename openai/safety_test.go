@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testModeration = `{
+	"id": "modr-1", "model": "omni-moderation-latest",
+	"results": [{
+		"flagged": false,
+		"categories": {"violence": false, "hate": false},
+		"category_scores": {"violence": 0.6, "hate": 0.01},
+		"category_applied_input_types": {}
+	}]
+}`
+
+func newSafetyTestService(t *testing.T) (*AiCommunicationService, *map[string]any) {
+	t.Helper()
+	var chatBody map[string]any
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/moderations") {
+			_, _ = w.Write([]byte(testModeration))
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&chatBody))
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	return ai, &chatBody
+}
+
+func TestSafety_StricterThresholdBlocks(t *testing.T) {
+	ai, chatBody := newSafetyTestService(t)
+	ai.Safety = &SafetySettings{ModerateInput: true, Thresholds: map[string]float64{"violence": 0.5}}
+
+	_, err := ai.GenerateContent("system")
+	require.ErrorIs(t, err, ErrModerationBlocked)
+	require.ErrorContains(t, err, "violence")
+	require.Nil(t, *chatBody)
+}
+
+func TestSafety_PassesIdentifier(t *testing.T) {
+	ai, chatBody := newSafetyTestService(t)
+
+	_, err := ai.GenerateContent("system", WithSafety(SafetySettings{Identifier: "user-42", ModerateInput: true}))
+	require.NoError(t, err)
+	require.Equal(t, "user-42", (*chatBody)["safety_identifier"])
+}
@@ -0,0 +1,181 @@
+package openai
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Priority legt fest, in welcher Reihenfolge wartende Aufrufe einen Slot erhalten. Höhere
+// Werte gehen vor; neben den Konstanten sind beliebige Werte erlaubt.
+type Priority int
+
+const (
+	PriorityBatch       Priority = iota // Hintergrundverarbeitung, z.B. convertDir
+	PriorityInteractive                 // latenzkritische Benutzeranfragen (Default)
+)
+
+// Scheduler begrenzt die Zahl paralleler Aufrufe. Freie Slots gehen immer zuerst an die
+// höchste wartende Priorität, innerhalb einer Priorität reihum an die Tags, damit ein
+// einzelner Batch-Lauf andere Aufrufer mit demselben Key nicht aushungert.
+type Scheduler struct {
+	mu      sync.Mutex
+	slots   int
+	running int
+	queues  map[Priority]*tagQueues
+}
+
+type schedulerWaiter struct {
+	ready chan struct{}
+}
+
+// tagQueues hält je Tag eine FIFO-Warteschlange und bedient die Tags reihum.
+type tagQueues struct {
+	order []string
+	next  int
+	byTag map[string][]*schedulerWaiter
+}
+
+func NewScheduler(concurrency int) *Scheduler {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Scheduler{
+		slots:  concurrency,
+		queues: map[Priority]*tagQueues{},
+	}
+}
+
+// Acquire wartet auf einen freien Slot. Die zurückgegebene Funktion gibt ihn wieder frei
+// und muss genau einmal aufgerufen werden.
+func (s *Scheduler) Acquire(ctx context.Context, priority Priority, tag string) (func(), error) {
+	s.mu.Lock()
+	if s.running < s.slots && s.waiting() == 0 {
+		s.running++
+		s.mu.Unlock()
+		return s.releaseFunc(), nil
+	}
+	w := &schedulerWaiter{ready: make(chan struct{})}
+	s.queue(priority).push(tag, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaseFunc(), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.queue(priority).remove(tag, w) {
+			return nil, ctx.Err()
+		}
+		// Slot wurde parallel zugeteilt – gleich wieder abgeben
+		s.dispatch()
+		return nil, ctx.Err()
+	}
+}
+
+// Waiting liefert die Zahl der wartenden Aufrufe.
+func (s *Scheduler) Waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiting()
+}
+
+func (s *Scheduler) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.dispatch()
+		})
+	}
+}
+
+// dispatch übergibt einen frei gewordenen Slot an den nächsten Wartenden.
+// Aufruf nur mit gehaltenem Lock.
+func (s *Scheduler) dispatch() {
+	priorities := slices.Sorted(maps.Keys(s.queues))
+	for _, priority := range slices.Backward(priorities) {
+		if w := s.queues[priority].pop(); w != nil {
+			close(w.ready)
+			return
+		}
+	}
+	s.running--
+}
+
+func (s *Scheduler) waiting() int {
+	n := 0
+	for _, q := range s.queues {
+		for _, waiters := range q.byTag {
+			n += len(waiters)
+		}
+	}
+	return n
+}
+
+func (s *Scheduler) queue(priority Priority) *tagQueues {
+	q, ok := s.queues[priority]
+	if !ok {
+		q = &tagQueues{byTag: map[string][]*schedulerWaiter{}}
+		s.queues[priority] = q
+	}
+	return q
+}
+
+func (q *tagQueues) push(tag string, w *schedulerWaiter) {
+	if _, ok := q.byTag[tag]; !ok {
+		q.order = append(q.order, tag)
+	}
+	q.byTag[tag] = append(q.byTag[tag], w)
+}
+
+func (q *tagQueues) pop() *schedulerWaiter {
+	if len(q.order) == 0 {
+		return nil
+	}
+	if q.next >= len(q.order) {
+		q.next = 0
+	}
+	tag := q.order[q.next]
+	waiters := q.byTag[tag]
+	w := waiters[0]
+	if len(waiters) == 1 {
+		q.dropTag(q.next)
+	} else {
+		q.byTag[tag] = waiters[1:]
+		q.next++
+	}
+	return w
+}
+
+func (q *tagQueues) remove(tag string, w *schedulerWaiter) bool {
+	waiters := q.byTag[tag]
+	for i, candidate := range waiters {
+		if candidate != w {
+			continue
+		}
+		if len(waiters) == 1 {
+			for j, t := range q.order {
+				if t == tag {
+					q.dropTag(j)
+					break
+				}
+			}
+		} else {
+			q.byTag[tag] = append(waiters[:i:i], waiters[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+func (q *tagQueues) dropTag(i int) {
+	delete(q.byTag, q.order[i])
+	q.order = append(q.order[:i], q.order[i+1:]...)
+	if q.next > i {
+		q.next--
+	}
+}
@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_PriorityAndFairness(t *testing.T) {
+	s := NewScheduler(1)
+	ctx := context.Background()
+
+	release, err := s.Acquire(ctx, PriorityBatch, "running")
+	require.NoError(t, err)
+
+	order := make(chan string, 5)
+	enqueue := func(priority Priority, tag, name string) {
+		waiting := s.Waiting()
+		go func() {
+			release, err := s.Acquire(ctx, priority, tag)
+			require.NoError(t, err)
+			order <- name
+			release()
+		}()
+		require.Eventually(t, func() bool { return s.Waiting() == waiting+1 }, time.Second, time.Millisecond)
+	}
+	enqueue(PriorityBatch, "a", "a1")
+	enqueue(PriorityBatch, "a", "a2")
+	enqueue(PriorityBatch, "b", "b1")
+	enqueue(PriorityInteractive, "user", "u1")
+
+	release()
+
+	var got []string
+	for range 4 {
+		got = append(got, <-order)
+	}
+	require.Equal(t, []string{"u1", "a1", "b1", "a2"}, got)
+}
+
+func TestScheduler_CustomPriorities(t *testing.T) {
+	s := NewScheduler(1)
+	ctx := context.Background()
+
+	release, err := s.Acquire(ctx, PriorityBatch, "")
+	require.NoError(t, err)
+
+	order := make(chan Priority, 3)
+	for i, priority := range []Priority{-1, PriorityBatch, PriorityInteractive + 5} {
+		go func() {
+			release, err := s.Acquire(ctx, priority, "")
+			require.NoError(t, err)
+			order <- priority
+			release()
+		}()
+		require.Eventually(t, func() bool { return s.Waiting() == i+1 }, time.Second, time.Millisecond)
+	}
+	release()
+
+	var got []Priority
+	for range 3 {
+		select {
+		case priority := <-order:
+			got = append(got, priority)
+		case <-time.After(time.Second):
+			t.Fatalf("waiters left behind, served %v", got)
+		}
+	}
+	require.Equal(t, []Priority{PriorityInteractive + 5, PriorityBatch, -1}, got)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	s := NewScheduler(1)
+	release, err := s.Acquire(context.Background(), PriorityInteractive, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Acquire(ctx, PriorityBatch, "")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Zero(t, s.Waiting())
+
+	release()
+	release2, err := s.Acquire(context.Background(), PriorityBatch, "")
+	require.NoError(t, err)
+	release2()
+}
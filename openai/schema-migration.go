@@ -0,0 +1,374 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// DefaultSchemaVersionField ist das Feld, in dem SchemaMigrator die Schemaversion eines
+// Ergebnisses ablegt. Ergebnisse ohne dieses Feld haben Version 0.
+const DefaultSchemaVersionField = "schemaVersion"
+
+// SchemaMigration beschreibt eine Änderung des Extraktionsschemas. Die Schritte laufen in
+// der Reihenfolge Rename, Remove, Add, Transform, Backfill. Feldpfade sind Objektpfade wie
+// "lieferant.ustId"; Listenelemente lassen sich nur in Transform ändern.
+type SchemaMigration struct {
+	Description string
+	Rename      map[string]string // alter Pfad -> neuer Pfad; fehlt der alte, passiert nichts
+	Remove      []string
+	Add         map[string]any // neue Felder mit festem Wert, z.B. nil; vorhandene bleiben unverändert
+	// Transform ändert das Ergebnis beliebig, z.B. um Werte umzurechnen. Optional.
+	Transform func(data map[string]any) error
+	// Backfill lässt neue Felder vom Modell aus dem Quelldokument nachtragen, siehe SchemaMigrator.Provider.
+	Backfill []BackfillField
+}
+
+// BackfillField ist ein Feld, das bei der Migration aus dem Quelldokument nachgetragen wird.
+type BackfillField struct {
+	Path        string
+	Description string // was das Modell liefern soll, z.B. "Umsatzsteuer-ID des Lieferanten"
+}
+
+// SchemaMigrator bringt gespeicherte Ergebnisse auf den aktuellen Stand des Schemas. Wie bei
+// den Tabellen von SQLSink ist Version n der Stand nach Migrations[n-1]; jedes Ergebnis
+// durchläuft nur die Migrationen nach seiner Version.
+type SchemaMigrator struct {
+	Migrations   []SchemaMigration
+	VersionField string // leer = DefaultSchemaVersionField
+	// Provider trägt Backfill-Felder nach; nil = sie bleiben null. Ein Fehler dabei lässt das
+	// Ergebnis unverändert.
+	Provider Provider
+	Options  []RequestOption // für die Backfill-Aufrufe, z.B. WithModel
+	// Permissions für die neu geschriebenen Ergebnisse, siehe BatchConverter.Permissions.
+	Permissions OutputPermissions
+	// ProvenanceKey signiert angepasste Herkunftsdateien neu; leer = die Signatur entfällt,
+	// denn sie passt nicht mehr zum Ergebnis.
+	ProvenanceKey []byte
+}
+
+// MigrationResult beschreibt die Migration eines Ergebnisses.
+type MigrationResult struct {
+	SourceFile  string   `json:"sourceFile,omitempty"`
+	OutputFile  string   `json:"outputFile,omitempty"`
+	FromVersion int      `json:"fromVersion"`
+	ToVersion   int      `json:"toVersion"`
+	Backfilled  []string `json:"backfilled,omitempty"` // vom Modell nachgetragene Feldpfade
+	Cost        float64  `json:"cost"`                 // USD für Backfill
+	Error       string   `json:"error,omitempty"`
+	Reason      string   `json:"reason,omitempty"` // Grund, wenn übersprungen
+}
+
+// MigrationReport ist der Bericht über MigrateFolder.
+type MigrationReport struct {
+	Files    []MigrationResult `json:"files"`
+	Migrated int               `json:"migrated"`
+	UpToDate int               `json:"upToDate"`
+	Skipped  int               `json:"skipped"`
+	Failed   int               `json:"failed"`
+	Cost     float64           `json:"cost"`
+}
+
+// Version liefert die aktuelle Schemaversion.
+func (m *SchemaMigrator) Version() int {
+	return len(m.Migrations)
+}
+
+func (m *SchemaMigrator) versionField() string {
+	if m.VersionField != "" {
+		return m.VersionField
+	}
+	return DefaultSchemaVersionField
+}
+
+// Migrate bringt content auf die aktuelle Version. sourceFile ist das Quelldokument für
+// Backfill. Zahlen bleiben exakt (siehe NumberJSON). Ist content schon aktuell, kommt es
+// unverändert zurück.
+func (m *SchemaMigrator) Migrate(ctx context.Context, content, sourceFile string) (string, MigrationResult, error) {
+	result := MigrationResult{SourceFile: sourceFile}
+	var data map[string]any
+	if err := DecodeJSON([]byte(content), &data, NumberJSON); err != nil {
+		return "", result, fmt.Errorf("cannot migrate result: %w", err)
+	}
+	if data == nil {
+		return "", result, fmt.Errorf("cannot migrate result: not a JSON object")
+	}
+	version, err := m.versionOf(data)
+	if err != nil {
+		return "", result, err
+	}
+	result.FromVersion, result.ToVersion = version, version
+	if version == m.Version() {
+		return content, result, nil
+	}
+
+	for i, migration := range m.Migrations[version:] {
+		if err := m.apply(ctx, migration, data, sourceFile, &result); err != nil {
+			return "", result, fmt.Errorf("migration %d failed: %w", version+i+1, err)
+		}
+	}
+	data[m.versionField()] = json.Number(strconv.Itoa(m.Version()))
+	result.ToVersion = m.Version()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return "", result, log.WrapError(err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), result, nil
+}
+
+// versionOf liest die Schemaversion; neuere Versionen als die bekannten sind ein Fehler.
+func (m *SchemaMigrator) versionOf(data map[string]any) (int, error) {
+	raw, ok := data[m.versionField()]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	n, isNumber := raw.(json.Number)
+	version, err := strconv.Atoi(n.String())
+	if !isNumber || err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid schema version %v", raw)
+	}
+	if version > m.Version() {
+		return 0, fmt.Errorf("result has schema version %d, newer than %d", version, m.Version())
+	}
+	return version, nil
+}
+
+func (m *SchemaMigrator) apply(ctx context.Context, migration SchemaMigration, data map[string]any, sourceFile string, result *MigrationResult) error {
+	for _, from := range slices.Sorted(maps.Keys(migration.Rename)) {
+		if value, ok := deletePath(data, from); ok {
+			if !putPath(data, migration.Rename[from], value) {
+				return fmt.Errorf("cannot rename %s to %s", from, migration.Rename[from])
+			}
+		}
+	}
+	for _, path := range migration.Remove {
+		deletePath(data, path)
+	}
+	for _, path := range slices.Sorted(maps.Keys(migration.Add)) {
+		if _, ok := lookupPath(data, path); !ok && !putPath(data, path, migration.Add[path]) {
+			return fmt.Errorf("cannot add %s", path)
+		}
+	}
+	if migration.Transform != nil {
+		if err := migration.Transform(data); err != nil {
+			return err
+		}
+	}
+	return m.backfill(ctx, migration.Backfill, data, sourceFile, result)
+}
+
+// backfill fragt das Modell nach den fehlenden Feldern; vorhandene Werte bleiben.
+func (m *SchemaMigrator) backfill(ctx context.Context, fields []BackfillField, data map[string]any, sourceFile string, result *MigrationResult) error {
+	missing := []BackfillField{}
+	for _, field := range fields {
+		if value, ok := lookupPath(data, field.Path); !ok || value == nil {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if m.Provider == nil {
+		for _, field := range missing {
+			if !putPath(data, field.Path, nil) {
+				return fmt.Errorf("cannot add %s", field.Path)
+			}
+		}
+		return nil
+	}
+	if sourceFile == "" {
+		return fmt.Errorf("backfill needs the source document")
+	}
+
+	current, err := json.Marshal(data)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	var lines strings.Builder
+	for _, field := range missing {
+		fmt.Fprintf(&lines, "- %s: %s\n", field.Path, field.Description)
+	}
+	systemMessage := "Zu diesem Dokument liegen bereits extrahierte Daten vor:\n" + string(current) +
+		"\n\nErgänze die folgenden Felder aus dem Dokument:\n" + lines.String() +
+		`Antworte ausschließlich mit einem JSON-Objekt, das je Feldpfad den Wert enthält, z.B. {"` +
+		missing[0].Path + `": ...}. Steht eine Angabe nicht im Dokument, setze null.`
+
+	res, err := m.Provider.Generate(ctx, Request{SystemMessage: systemMessage, FileName: sourceFile}, m.Options...)
+	if res != nil {
+		result.Cost += res.Cost
+	}
+	if err != nil {
+		return fmt.Errorf("backfill failed: %w", err)
+	}
+	var values map[string]any
+	if err := DecodeJSON([]byte(stripJSONWrapper(res.Content)), &values, NumberJSON); err != nil {
+		return fmt.Errorf("backfill failed: %w", err)
+	}
+	for _, field := range missing {
+		value := values[field.Path]
+		if !putPath(data, field.Path, value) {
+			return fmt.Errorf("cannot add %s", field.Path)
+		}
+		if value != nil {
+			result.Backfilled = append(result.Backfilled, field.Path)
+		}
+	}
+	return nil
+}
+
+// MigrateFolder migriert alle Ergebnisse, die der Ergebnisindex von destFolder verzeichnet,
+// und schreibt sie samt Herkunftsdatei an ihren Platz zurück. Komprimierte Ergebnisse bleiben
+// komprimiert. Inhaltsadressierte Ergebnisse (OutputNamingContent) werden übersprungen, denn
+// ihr Name hängt am Inhalt. Während der Migration ist der Ordner für BatchConverter gesperrt.
+func (m *SchemaMigrator) MigrateFolder(ctx context.Context, destFolder string) (*MigrationReport, error) {
+	index, err := LoadBatchIndex(destFolder)
+	if err != nil {
+		return nil, err
+	}
+	lockPath := filepath.Join(destFolder, lockFileName)
+	lock, err := acquireFileLock(lockPath, defaultBatchLockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("destination folder %s is in use: %w", destFolder, err)
+	}
+	defer lock.release()
+	defer lock.refresh(defaultBatchLockTTL / 3)()
+
+	report := &MigrationReport{Files: []MigrationResult{}}
+	for _, doc := range index.Documents {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if doc.OutputFile == "" || (doc.Status != DocumentDone && doc.Status != DocumentSkipped) {
+			continue
+		}
+		result := m.migrateFile(ctx, doc)
+		switch {
+		case result.Error != "":
+			report.Failed++
+			log.Warn("Failed to migrate %s: %s", result.OutputFile, result.Error)
+		case result.Reason != "":
+			report.Skipped++
+		case result.FromVersion == result.ToVersion:
+			report.UpToDate++
+		default:
+			report.Migrated++
+		}
+		report.Cost += result.Cost
+		report.Files = append(report.Files, result)
+	}
+	return report, nil
+}
+
+func (m *SchemaMigrator) migrateFile(ctx context.Context, doc DocumentResult) MigrationResult {
+	if doc.ContentHash != "" {
+		return MigrationResult{SourceFile: doc.SourceFile, OutputFile: doc.OutputFile, Reason: "content-addressed output"}
+	}
+	data, err := ReadDecompressed(doc.OutputFile)
+	if err != nil {
+		return MigrationResult{SourceFile: doc.SourceFile, OutputFile: doc.OutputFile, Error: err.Error()}
+	}
+	content, result, err := m.Migrate(ctx, string(data), doc.SourceFile)
+	result.OutputFile = doc.OutputFile
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if result.FromVersion == result.ToVersion {
+		return result
+	}
+
+	data = []byte(content)
+	if compression := detectCompression(doc.OutputFile, nil); compression != nil {
+		if data, err = compression.compress(data); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	outputs := newOutputSet(m.Permissions)
+	outputs.add(doc.OutputFile, data)
+	if _, err := os.Stat(doc.OutputFile + provenanceSuffix); err == nil {
+		p, err := LoadProvenance(doc.OutputFile)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		p.ContentHash = contentHash(content)
+		p.Signature = ""
+		if len(m.ProvenanceKey) > 0 {
+			if err := p.Sign(m.ProvenanceKey); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+		}
+		provenance, err := marshalProvenance(p)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		outputs.add(doc.OutputFile+provenanceSuffix, provenance)
+	}
+	if err := outputs.commit(); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// putPath setzt den Wert unter einem Objektpfad und legt fehlende Zwischenobjekte an.
+func putPath(data map[string]any, path string, value any) bool {
+	parts := strings.Split(path, ".")
+	obj := data
+	for _, part := range parts[:len(parts)-1] {
+		if part == "" || strings.Contains(part, "[") {
+			return false
+		}
+		next, ok := obj[part]
+		if !ok || next == nil {
+			next = map[string]any{}
+			obj[part] = next
+		}
+		if obj, ok = next.(map[string]any); !ok {
+			return false
+		}
+	}
+	last := parts[len(parts)-1]
+	if last == "" || strings.Contains(last, "[") {
+		return false
+	}
+	obj[last] = value
+	return true
+}
+
+// deletePath entfernt den Wert unter einem Objektpfad und liefert ihn zurück.
+func deletePath(data map[string]any, path string) (any, bool) {
+	parentPath, last := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parentPath, last = path[:i], path[i+1:]
+	}
+	var parent any = data
+	if parentPath != "" {
+		var ok bool
+		if parent, ok = lookupPath(data, parentPath); !ok {
+			return nil, false
+		}
+	}
+	obj, ok := parent.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	value, ok := obj[last]
+	delete(obj, last)
+	return value, ok
+}
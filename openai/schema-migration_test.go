@@ -0,0 +1,116 @@
+package openai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// providerFunc macht eine Funktion zum Provider.
+type providerFunc func(ctx context.Context, req Request, opts ...RequestOption) (*Result, error)
+
+func (f providerFunc) Generate(ctx context.Context, req Request, opts ...RequestOption) (*Result, error) {
+	return f(ctx, req, opts...)
+}
+
+func TestSchemaMigrator_Migrate(t *testing.T) {
+	var requests []Request
+	m := &SchemaMigrator{
+		Migrations: []SchemaMigration{
+			{
+				Description: "Kunde als Objekt, Währung",
+				Rename:      map[string]string{"kunde": "kunde.name"},
+				Remove:      []string{"intern"},
+				Add:         map[string]any{"waehrung": "EUR"},
+			},
+			{
+				Description: "USt-ID",
+				Backfill:    []BackfillField{{Path: "kunde.ustId", Description: "Umsatzsteuer-ID des Kunden"}},
+			},
+		},
+		Provider: providerFunc(func(ctx context.Context, req Request, opts ...RequestOption) (*Result, error) {
+			requests = append(requests, req)
+			return &Result{Content: "```json\n{\"kunde.ustId\": \"DE123456789\"}\n```", Cost: 0.01}, nil
+		}),
+	}
+	require.Equal(t, 2, m.Version())
+
+	content, result, err := m.Migrate(context.Background(), `{"kunde": "Müller & Co", "betrag": 12345678901234567.89, "intern": 1}`, "rechnung.pdf")
+	require.NoError(t, err)
+	require.Equal(t, MigrationResult{SourceFile: "rechnung.pdf", FromVersion: 0, ToVersion: 2, Backfilled: []string{"kunde.ustId"}, Cost: 0.01}, result)
+	require.Equal(t, `{
+  "betrag": 12345678901234567.89,
+  "kunde": {
+    "name": "Müller & Co",
+    "ustId": "DE123456789"
+  },
+  "schemaVersion": 2,
+  "waehrung": "EUR"
+}`, content)
+	require.Len(t, requests, 1)
+	require.Equal(t, "rechnung.pdf", requests[0].FileName)
+	require.Contains(t, requests[0].SystemMessage, "- kunde.ustId: Umsatzsteuer-ID des Kunden")
+
+	// aktuelle Ergebnisse bleiben unverändert
+	again, result, err := m.Migrate(context.Background(), content, "rechnung.pdf")
+	require.NoError(t, err)
+	require.Equal(t, content, again)
+	require.Equal(t, 2, result.FromVersion)
+	require.Len(t, requests, 1)
+
+	// nur die Migrationen nach der gespeicherten Version laufen
+	content, result, err = m.Migrate(context.Background(), `{"schemaVersion": 1, "kunde": {"name": "A", "ustId": "DE1"}}`, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.FromVersion)
+	require.NotContains(t, content, "waehrung")
+
+	_, _, err = m.Migrate(context.Background(), `{"schemaVersion": 3}`, "")
+	require.ErrorContains(t, err, "newer than 2")
+	_, _, err = m.Migrate(context.Background(), `[1, 2]`, "")
+	require.Error(t, err)
+	_, _, err = m.Migrate(context.Background(), `{"kunde": "A"}`, "")
+	require.ErrorContains(t, err, "migration 2 failed: backfill needs the source document")
+
+	// ohne Provider bleiben nachzutragende Felder leer
+	m.Provider = nil
+	content, _, err = m.Migrate(context.Background(), `{"kunde": "A"}`, "")
+	require.NoError(t, err)
+	require.Contains(t, content, `"ustId": null`)
+}
+
+func TestSchemaMigrator_MigrateFolder(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a.pdf"), []byte(testPDF), 0644))
+	dest := filepath.Join(t.TempDir(), "out")
+
+	bc := NewBatchConverter(newBatchTestService(t, func() string { return `{"total": 10}` }), "system", src, dest)
+	bc.Compression = CompressionGzip
+	bc.WriteProvenance = true
+	bc.ProvenanceKey = []byte("key")
+	_, err := bc.Run()
+	require.NoError(t, err)
+
+	m := &SchemaMigrator{
+		Migrations:    []SchemaMigration{{Rename: map[string]string{"total": "brutto"}}},
+		ProvenanceKey: []byte("key"),
+	}
+	report, err := m.MigrateFolder(context.Background(), dest)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Migrated)
+
+	outputFile := filepath.Join(dest, "a.pdf.gz")
+	data, err := ReadDecompressed(outputFile)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"brutto": 10, "schemaVersion": 1}`, string(data))
+	p, err := LoadProvenance(outputFile)
+	require.NoError(t, err)
+	require.NoError(t, p.Verify(string(data), []byte("key")))
+
+	report, err = m.MigrateFolder(context.Background(), dest)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.UpToDate)
+	require.Equal(t, 0, report.Migrated)
+}
@@ -0,0 +1,94 @@
+package openai
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// defaultShadowTimeout begrenzt Shadow-Requests, die nicht mehr am Kontext des Aufrufers hängen.
+const defaultShadowTimeout = 2 * time.Minute
+
+// ShadowPolicy schickt eine Kopie der Requests asynchron an ein Kandidaten-Modell bzw. einen
+// zweiten Service. Das Ergebnis geht nur an OnResult bzw. ins Log, nie an den Aufrufer.
+type ShadowPolicy struct {
+	Service    *AiCommunicationService // Service für die Kopie; nil = derselbe Service
+	Model      openai.ChatModel        // Modell für die Kopie; leer = Modell des Shadow-Services bzw. des Aufrufs
+	SampleRate float64                 // Anteil der Requests (0..1); 0 = alle
+	Timeout    time.Duration           // Default: 2m
+	OnResult   func(ShadowResult)      // Default: Ergebnis wird protokolliert
+}
+
+// ShadowResult stellt Primär- und Shadow-Ergebnis eines Requests gegenüber.
+type ShadowResult struct {
+	Model          openai.ChatModel
+	Content        string
+	Err            error
+	Latency        time.Duration
+	PrimaryModel   openai.ChatModel
+	PrimaryContent string
+	PrimaryErr     error
+}
+
+// Match meldet, ob beide Requests erfolgreich waren und denselben Inhalt geliefert haben.
+func (r ShadowResult) Match() bool {
+	return r.Err == nil && r.PrimaryErr == nil && r.Content == r.PrimaryContent
+}
+
+// shadow startet die Kopie des Requests im Hintergrund, sofern die Stichprobe es vorsieht.
+func (ai *AiCommunicationService) shadow(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig, primaryContent string, primaryErr error) {
+	p := ai.Shadow
+	if p == nil || (p.SampleRate > 0 && rand.Float64() >= p.SampleRate) {
+		return
+	}
+
+	target := ai
+	shadowCfg := cfg
+	shadowCfg.hedge = nil
+	shadowCfg.cheapFirst = nil
+	shadowCfg.experiment = nil
+	shadowCfg.idempotencyKey = ""
+	shadowCfg.usage = nil // der Schatten zählt nicht zum Ergebnis des Aufrufs
+	if p.Service != nil {
+		target = p.Service
+		target.init()
+		shadowCfg.model = p.Service.Model
+	}
+	if p.Model != "" {
+		shadowCfg.model = p.Model
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultShadowTimeout
+	}
+
+	go func() {
+		// der Aufrufer wartet nicht auf die Kopie, deshalb eigener Timeout statt seines Kontexts
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+
+		start := time.Now()
+		content, err := target.requestJsonContent(ctx, systemMessage, f, shadowCfg)
+		result := ShadowResult{
+			Model:          shadowCfg.model,
+			Content:        content,
+			Err:            err,
+			Latency:        time.Since(start),
+			PrimaryModel:   cfg.model,
+			PrimaryContent: primaryContent,
+			PrimaryErr:     primaryErr,
+		}
+		if p.OnResult != nil {
+			p.OnResult(result)
+			return
+		}
+		if err != nil {
+			log.Warn("shadow request to %s failed after %s: %v", result.Model, result.Latency, err)
+			return
+		}
+		log.Info("shadow request to %s finished after %s, match with %s: %t", result.Model, result.Latency, result.PrimaryModel, result.Match())
+	}()
+}
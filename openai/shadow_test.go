@@ -0,0 +1,36 @@
+package openai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateContent_Shadow(t *testing.T) {
+	ai, _ := newModelTestService(t, map[string]string{
+		string(openai.ChatModelGPT4_1):     `{"total": 12}`,
+		string(openai.ChatModelGPT4_1Mini): `{"total": 10}`,
+	})
+	results := make(chan ShadowResult, 1)
+	ai.Shadow = &ShadowPolicy{
+		Model:    openai.ChatModelGPT4_1Mini,
+		OnResult: func(r ShadowResult) { results <- r },
+	}
+
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"total": 12}`, content)
+
+	select {
+	case r := <-results:
+		require.NoError(t, r.Err)
+		require.Equal(t, openai.ChatModelGPT4_1Mini, r.Model)
+		require.Equal(t, `{"total": 10}`, r.Content)
+		require.Equal(t, `{"total": 12}`, r.PrimaryContent)
+		require.False(t, r.Match())
+	case <-time.After(2 * time.Second):
+		t.Fatal("shadow result missing")
+	}
+}
@@ -0,0 +1,184 @@
+package openai
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// SQLDialect wählt die SQL-Syntax für Schema und Platzhalter.
+type SQLDialect string
+
+const (
+	DialectSQLite   SQLDialect = "sqlite"
+	DialectPostgres SQLDialect = "postgres"
+)
+
+// DefaultResultTable ist der Tabellenname, wenn SQLSink.Table leer ist.
+const DefaultResultTable = "myailib_results"
+
+var sqlIdentifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLSink schreibt Ergebnisse samt Quelldaten, Kosten und Status in eine Tabelle. Das Schema
+// wird von der Bibliothek angelegt und über eine Versionstabelle (<Table>_schema) migriert.
+// Der Treiber (z.B. modernc.org/sqlite oder pgx) wird vom Aufrufer registriert.
+type SQLSink struct {
+	DB      *sql.DB
+	Dialect SQLDialect
+	Table   string
+
+	ownsDB bool // DB wurde für den Sink geöffnet, z.B. aus einem Job-Manifest
+}
+
+// NewSQLSink legt das Schema an bzw. migriert es auf den aktuellen Stand.
+func NewSQLSink(ctx context.Context, db *sql.DB, dialect SQLDialect, table string) (*SQLSink, error) {
+	if table == "" {
+		table = DefaultResultTable
+	}
+	if !sqlIdentifierRe.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name: %s", table)
+	}
+	if dialect != DialectSQLite && dialect != DialectPostgres {
+		return nil, fmt.Errorf("unsupported SQL dialect: %s", dialect)
+	}
+	s := &SQLSink{DB: db, Dialect: dialect, Table: table}
+	if err := s.migrate(ctx); err != nil {
+		return nil, log.WrapError(err)
+	}
+	return s, nil
+}
+
+// Close schließt DB, sofern der Sink sie selbst geöffnet hat (Job-Manifest). Eine vom
+// Aufrufer übergebene DB bleibt offen.
+func (s *SQLSink) Close() error {
+	if !s.ownsDB {
+		return nil
+	}
+	return s.DB.Close()
+}
+
+// migrations liefert die Schemaänderungen in ihrer Reihenfolge; Version n = Index n+1.
+func (s *SQLSink) migrations() []string {
+	idColumn := "id INTEGER PRIMARY KEY AUTOINCREMENT"
+	floatType := "REAL"
+	if s.Dialect == DialectPostgres {
+		idColumn = "id BIGSERIAL PRIMARY KEY"
+		floatType = "DOUBLE PRECISION"
+	}
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.Table + ` (
+	` + idColumn + `,
+	source_file TEXT NOT NULL,
+	source_size BIGINT,
+	source_sha256 TEXT,
+	output_file TEXT,
+	content TEXT,
+	cost ` + floatType + `,
+	status TEXT NOT NULL,
+	error TEXT,
+	completed_at TIMESTAMP
+)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.Table + `_source_idx ON ` + s.Table + ` (source_file)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.Table + `_review (
+	` + idColumn + `,
+	source_file TEXT NOT NULL,
+	output_file TEXT,
+	reason TEXT NOT NULL,
+	item TEXT NOT NULL,
+	created_at TIMESTAMP,
+	resolved_at TIMESTAMP
+)`,
+	}
+}
+
+func (s *SQLSink) migrate(ctx context.Context) error {
+	versionTable := s.Table + "_schema"
+	if _, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	version := 0
+	var current sql.NullInt64
+	if err := s.DB.QueryRowContext(ctx, `SELECT MAX(version) FROM `+versionTable).Scan(&current); err != nil {
+		return err
+	}
+	if current.Valid {
+		version = int(current.Int64)
+	}
+
+	for i, stmt := range s.migrations()[version:] {
+		tx, err := s.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version+i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+versionTable+` (version) VALUES (`+s.placeholder(1)+`)`, version+i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLSink) Publish(ctx context.Context, result DocumentResult) error {
+	var size sql.NullInt64
+	var hash sql.NullString
+	if info, err := os.Stat(result.SourceFile); err == nil {
+		size = sql.NullInt64{Int64: info.Size(), Valid: true}
+		if h, err := fileSHA256(result.SourceFile); err == nil {
+			hash = sql.NullString{String: h, Valid: true}
+		}
+	}
+
+	columns := []string{"source_file", "source_size", "source_sha256", "output_file", "content", "cost", "status", "error", "completed_at"}
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = s.placeholder(i + 1)
+	}
+	stmt := `INSERT INTO ` + s.Table + ` (` + strings.Join(columns, ", ") + `) VALUES (` + strings.Join(placeholders, ", ") + `)`
+
+	_, err := s.DB.ExecContext(ctx, stmt,
+		result.SourceFile,
+		size,
+		hash,
+		result.OutputFile,
+		result.Content,
+		result.Cost,
+		string(result.Status),
+		result.Error,
+		result.CompletedAt.UTC(),
+	)
+	return log.WrapError(err)
+}
+
+// Submit legt ein ReviewItem in der Tabelle <Table>_review ab; item enthält es als JSON.
+// Erledigte Einträge markiert der Prüfprozess über resolved_at.
+func (s *SQLSink) Submit(ctx context.Context, item ReviewItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	stmt := `INSERT INTO ` + s.Table + `_review (source_file, output_file, reason, item, created_at) VALUES (` +
+		strings.Join([]string{s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5)}, ", ") + `)`
+	_, err = s.DB.ExecContext(ctx, stmt, item.SourceFile, item.OutputFile, item.Reason, string(data), item.CreatedAt.UTC())
+	return log.WrapError(err)
+}
+
+func (s *SQLSink) placeholder(n int) string {
+	if s.Dialect == DialectPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
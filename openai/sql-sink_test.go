@@ -0,0 +1,278 @@
+package openai
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSQL ist ein database/sql-Treiber im Speicher: er merkt sich die ausgeführten
+// Statements samt Argumenten und die Schema-Versionen, mehr braucht SQLSink nicht.
+type fakeSQL struct {
+	mu       sync.Mutex
+	execs    []fakeExec
+	versions []int64
+	failOn   string // Statements mit diesem Text schlagen fehl
+	closed   bool
+}
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+func newFakeSQL() *fakeSQL {
+	return &fakeSQL{}
+}
+
+func (f *fakeSQL) open() *sql.DB {
+	return sql.OpenDB(f)
+}
+
+// queries liefert die ausgeführten Statements ohne die Argumente.
+func (f *fakeSQL) queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var queries []string
+	for _, e := range f.execs {
+		queries = append(queries, e.query)
+	}
+	return queries
+}
+
+func (f *fakeSQL) last() fakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.execs[len(f.execs)-1]
+}
+
+func (f *fakeSQL) Connect(ctx context.Context) (driver.Conn, error) { return &fakeSQLConn{db: f}, nil }
+func (f *fakeSQL) Driver() driver.Driver                            { return fakeSQLDriver{} }
+
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("use sql.OpenDB")
+}
+
+type fakeSQLConn struct {
+	db *fakeSQL
+	tx []fakeExec // Statements der laufenden Transaktion
+	in bool
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.closed = true
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	c.in, c.tx = true, nil
+	return c, nil
+}
+
+func (c *fakeSQLConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for _, e := range c.tx {
+		c.db.apply(e)
+	}
+	c.in, c.tx = false, nil
+	return nil
+}
+
+func (c *fakeSQLConn) Rollback() error {
+	c.in, c.tx = false, nil
+	return nil
+}
+
+// apply übernimmt ein Statement; db.mu muss gesperrt sein.
+func (f *fakeSQL) apply(e fakeExec) {
+	f.execs = append(f.execs, e)
+	if strings.HasPrefix(e.query, "INSERT INTO ") && strings.Contains(e.query, "_schema (version)") {
+		f.versions = append(f.versions, e.args[0].(int64))
+	}
+}
+
+type fakeSQLStmt struct {
+	conn  *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return -1 }
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.failOn != "" && strings.Contains(s.query, db.failOn) {
+		return nil, errors.New("syntax error")
+	}
+	e := fakeExec{query: s.query, args: args}
+	if s.conn.in {
+		s.conn.tx = append(s.conn.tx, e)
+	} else {
+		db.apply(e)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "SELECT MAX(version) FROM ") {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var current driver.Value
+	for _, v := range db.versions {
+		if current == nil || v > current.(int64) {
+			current = v
+		}
+	}
+	return &fakeSQLRows{values: []driver.Value{current}}, nil
+}
+
+type fakeSQLRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"max"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+func TestSQLSink_Migration(t *testing.T) {
+	fake := newFakeSQL()
+	db := fake.open()
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err := NewSQLSink(ctx, db, DialectSQLite, "results")
+	require.NoError(t, err)
+	queries := fake.queries()
+	require.Len(t, queries, 7)
+	require.Equal(t, "CREATE TABLE IF NOT EXISTS results_schema (version INTEGER NOT NULL)", queries[0])
+	require.Contains(t, queries[1], "CREATE TABLE IF NOT EXISTS results (")
+	require.Contains(t, queries[1], "id INTEGER PRIMARY KEY AUTOINCREMENT")
+	require.Equal(t, "INSERT INTO results_schema (version) VALUES (?)", queries[2])
+	require.Contains(t, queries[3], "CREATE INDEX IF NOT EXISTS results_source_idx")
+	require.Contains(t, queries[5], "CREATE TABLE IF NOT EXISTS results_review (")
+	require.Equal(t, []int64{1, 2, 3}, fake.versions)
+
+	// aktuelles Schema: nur die Versionstabelle wird geprüft
+	_, err = NewSQLSink(ctx, db, DialectSQLite, "results")
+	require.NoError(t, err)
+	require.Len(t, fake.queries(), 8)
+	require.Equal(t, []int64{1, 2, 3}, fake.versions)
+
+	// eine fehlgeschlagene Migration wird nicht als erledigt vermerkt
+	failing := newFakeSQL()
+	failing.failOn = "CREATE INDEX"
+	db2 := failing.open()
+	defer db2.Close()
+	_, err = NewSQLSink(ctx, db2, DialectPostgres, "")
+	require.ErrorContains(t, err, "migration 2 failed")
+	require.Equal(t, []int64{1}, failing.versions)
+	require.Contains(t, failing.queries()[1], "CREATE TABLE IF NOT EXISTS "+DefaultResultTable+" (")
+	require.Contains(t, failing.queries()[1], "id BIGSERIAL PRIMARY KEY")
+	require.Equal(t, "INSERT INTO "+DefaultResultTable+"_schema (version) VALUES ($1)", failing.queries()[2])
+
+	_, err = NewSQLSink(ctx, db, DialectSQLite, "results; DROP TABLE x")
+	require.ErrorContains(t, err, "invalid table name")
+	_, err = NewSQLSink(ctx, db, "mysql", "results")
+	require.ErrorContains(t, err, "unsupported SQL dialect")
+}
+
+func TestSQLSink_PublishAndSubmit(t *testing.T) {
+	fake := newFakeSQL()
+	db := fake.open()
+	defer db.Close()
+	ctx := context.Background()
+	sink, err := NewSQLSink(ctx, db, DialectPostgres, "results")
+	require.NoError(t, err)
+
+	fileName := filepath.Join(t.TempDir(), "a.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+	completed := time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	require.NoError(t, sink.Publish(ctx, DocumentResult{
+		SourceFile:  fileName,
+		OutputFile:  "out/a.pdf",
+		Content:     `{"ok": true}`,
+		Cost:        0.25,
+		Status:      DocumentDone,
+		CompletedAt: completed,
+	}))
+	e := fake.last()
+	require.Equal(t, "INSERT INTO results (source_file, source_size, source_sha256, output_file, content, cost, status, error, completed_at) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)", e.query)
+	require.Equal(t, []driver.Value{
+		fileName, int64(len(testPDF)), mustFileSHA256(t, fileName), "out/a.pdf", `{"ok": true}`, 0.25,
+		string(DocumentDone), "", completed.UTC(),
+	}, e.args)
+
+	// fehlende Quelldatei: Größe und Hash bleiben NULL
+	require.NoError(t, sink.Publish(ctx, DocumentResult{SourceFile: "gone.pdf", Status: DocumentFailed, Error: "boom"}))
+	e = fake.last()
+	require.Nil(t, e.args[1])
+	require.Nil(t, e.args[2])
+	require.Equal(t, "boom", e.args[7])
+
+	item := ReviewItem{SourceFile: fileName, OutputFile: "out/a.pdf", Reason: "low confidence", CreatedAt: completed}
+	require.NoError(t, sink.Submit(ctx, item))
+	e = fake.last()
+	require.Equal(t, "INSERT INTO results_review (source_file, output_file, reason, item, created_at) VALUES ($1, $2, $3, $4, $5)", e.query)
+	require.Equal(t, fileName, e.args[0])
+	require.Equal(t, "low confidence", e.args[2])
+	var stored ReviewItem
+	require.NoError(t, json.Unmarshal([]byte(e.args[3].(string)), &stored))
+	require.Equal(t, item.Reason, stored.Reason)
+	require.Equal(t, completed.UTC(), e.args[4])
+}
+
+func TestSQLSink_CloseKeepsCallerDB(t *testing.T) {
+	fake := newFakeSQL()
+	db := fake.open()
+	defer db.Close()
+	ctx := context.Background()
+	sink, err := NewSQLSink(ctx, db, DialectSQLite, "")
+	require.NoError(t, err)
+
+	bc := NewBatchConverter(NewAiCommunicationService(""), "system", t.TempDir(), t.TempDir())
+	bc.Sinks = []ResultSink{sink}
+	require.NoError(t, bc.Close())
+	require.NoError(t, db.PingContext(ctx))
+	require.NoError(t, sink.Publish(ctx, DocumentResult{SourceFile: "a.pdf", Status: DocumentDone}))
+
+	// eine vom Job-Manifest geöffnete DB schließt der Sink selbst
+	sink.ownsDB = true
+	require.NoError(t, bc.Close())
+	require.Error(t, db.PingContext(ctx))
+	require.True(t, fake.closed)
+}
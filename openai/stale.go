@@ -0,0 +1,47 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// StaleFallback liefert ein veraltetes Ergebnis aus Service.Cache, wenn der Provider
+// ausfällt (Netzwerkfehler, Timeouts, 5xx, Rate-Limits, erschöpfte Quota) oder das Budget
+// eines TenantService aufgebraucht ist, statt eines Fehlers. Veraltet sind Einträge älter
+// als Service.CacheTTL; Result.Stale markiert solche Ergebnisse. Gilt nur für Aufrufe, die
+// den Cache nutzen, also mit Datei.
+type StaleFallback struct {
+	// MaxAge begrenzt das Alter ausgelieferter Einträge; 0 = beliebig alt.
+	MaxAge time.Duration
+}
+
+// fresh meldet, ob der Eintrag nach CacheTTL noch ohne neuen Request ausgeliefert wird.
+func (ai *AiCommunicationService) fresh(entry *cacheEntry) bool {
+	return ai.CacheTTL <= 0 || entry.stored.IsZero() || time.Since(entry.stored) <= ai.CacheTTL
+}
+
+// degraded meldet, ob err einen Ausfall des Providers oder ein erschöpftes Budget beschreibt.
+func degraded(err error) bool {
+	if errors.Is(err, ErrBudgetExceeded) || IsNetwork(err) || IsTimeout(err) {
+		return true
+	}
+	var oe *OpenAIError
+	return errors.As(err, &oe) && (oe.IsRateLimit() || oe.IsServerError())
+}
+
+// serveStale liefert entry statt des Fehlers cause, sofern StaleFallback es erlaubt.
+func (ai *AiCommunicationService) serveStale(entry *cacheEntry, cfg requestConfig, cause error) (string, error) {
+	if ai.Stale == nil || entry == nil {
+		return "", cause
+	}
+	age := time.Since(entry.stored)
+	if ai.Stale.MaxAge > 0 && age > ai.Stale.MaxAge {
+		return "", cause
+	}
+	log.Warn("serving stale result from %s: %v", entry.stored.Format(time.RFC3339), cause)
+	cfg.usage.markStale(fmt.Sprintf("stale result from %s: %v", entry.stored.Format(time.RFC3339), cause))
+	return entry.content, nil
+}
@@ -0,0 +1,141 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ageCache setzt alle Einträge eines FileResultCache auf das angegebene Alter.
+func ageCache(t *testing.T, cache *FileResultCache, age time.Duration) {
+	t.Helper()
+	entries, err := os.ReadDir(cache.Dir)
+	require.NoError(t, err)
+	mtime := time.Now().Add(-age)
+	for _, entry := range entries {
+		require.NoError(t, os.Chtimes(filepath.Join(cache.Dir, entry.Name()), mtime, mtime))
+	}
+}
+
+func TestGenerate_StaleFallback(t *testing.T) {
+	var status atomic.Int32
+	var chats atomic.Int32
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files") {
+			_, _ = w.Write([]byte(testUploadedFile))
+			return
+		}
+		chats.Add(1)
+		if code := int(status.Load()); code != 0 {
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(`{"error": {"message": "provider trouble", "type": "server_error"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	cache, err := NewFileResultCache(t.TempDir())
+	require.NoError(t, err)
+	ai.Cache = cache
+	ai.CacheTTL = time.Hour
+	ai.MaxAttempts = 1
+	fileName := filepath.Join(t.TempDir(), "report.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+	ctx := context.Background()
+	req := Request{SystemMessage: "summarize", FileName: fileName}
+
+	_, err = ai.Generate(ctx, req)
+	require.NoError(t, err)
+	result, err := ai.Generate(ctx, req)
+	require.NoError(t, err)
+	require.True(t, result.Cached)
+	require.False(t, result.Stale)
+	require.EqualValues(t, 1, chats.Load())
+
+	// abgelaufen und der Provider ist down: ohne StaleFallback ein Fehler
+	ageCache(t, cache, 3*time.Hour)
+	status.Store(http.StatusServiceUnavailable)
+	_, err = ai.Generate(ctx, req)
+	require.ErrorContains(t, err, "provider trouble")
+
+	ai.Stale = &StaleFallback{MaxAge: 24 * time.Hour}
+	result, err = ai.Generate(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, result.Content)
+	require.True(t, result.Stale)
+	require.Len(t, result.Warnings, 1)
+	require.Contains(t, result.Warnings[0], "stale result from")
+	content, err := ai.GenerateContentWithPDF("summarize", fileName)
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+
+	// Fehler der Anfrage selbst und zu alte Einträge werden nicht überdeckt
+	status.Store(http.StatusBadRequest)
+	_, err = ai.Generate(ctx, req)
+	require.ErrorContains(t, err, "provider trouble")
+	status.Store(http.StatusTooManyRequests)
+	ageCache(t, cache, 48*time.Hour)
+	_, err = ai.Generate(ctx, req)
+	require.ErrorContains(t, err, "provider trouble")
+
+	// der Provider ist zurück: der abgelaufene Eintrag wird erneuert
+	status.Store(0)
+	result, err = ai.Generate(ctx, req)
+	require.NoError(t, err)
+	require.False(t, result.Cached)
+	_, stored, ok, err := cache.GetWithTime(ai.resultCacheKey(mustFileSHA256(t, fileName), "summarize", ai.newRequestConfig(nil)))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now(), stored, time.Minute)
+}
+
+func mustFileSHA256(t *testing.T, fileName string) string {
+	t.Helper()
+	hash, err := fileSHA256(fileName)
+	require.NoError(t, err)
+	return hash
+}
+
+func TestTenantService_StaleWhenBudgetExhausted(t *testing.T) {
+	base := newBatchTestService(t, func() string { return `{"summary": "ok"}` })
+	cache, err := NewFileResultCache(t.TempDir())
+	require.NoError(t, err)
+	base.CacheTTL = time.Hour
+	base.Stale = &StaleFallback{}
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{MaxCost: costOf(100, 20) / 2, Cache: cache})
+
+	fileName := filepath.Join(t.TempDir(), "report.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+	other := filepath.Join(t.TempDir(), "other.pdf")
+	require.NoError(t, os.WriteFile(other, []byte(testPDF+"\n"), 0644))
+	ctx := context.Background()
+	req := Request{SystemMessage: "summarize", FileName: fileName}
+
+	_, err = ts.Generate(ctx, "acme", req)
+	require.NoError(t, err)
+
+	// Budget erschöpft: aktuelle Einträge wie gewohnt, abgelaufene als veraltet
+	result, err := ts.Generate(ctx, "acme", req)
+	require.NoError(t, err)
+	require.True(t, result.Cached)
+	require.False(t, result.Stale)
+	ageCache(t, cache, 2*time.Hour)
+	result, err = ts.Generate(ctx, "acme", req)
+	require.NoError(t, err)
+	require.True(t, result.Stale)
+	require.Equal(t, `{"summary": "ok"}`, result.Content)
+
+	_, err = ts.Generate(ctx, "acme", Request{SystemMessage: "summarize", FileName: other})
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	_, err = ts.GenerateContent("acme", "summarize")
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.InDelta(t, costOf(100, 20), ts.TotalCosts("acme"), 1e-9)
+}
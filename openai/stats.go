@@ -0,0 +1,185 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// maxRecentErrors ist die Zahl der Fehler, die Stats zuletzt aufbewahrt.
+const maxRecentErrors = 20
+
+// maxErrorBody begrenzt, wie viel einer Fehlerantwort für RequestError.Message gelesen wird.
+const maxErrorBody = 64 << 10
+
+// ServiceStats ist eine Momentaufnahme des Services für Betrieb und Fehlersuche, z.B. wenn
+// ein Worker hängt, siehe Stats und StatsHandler.
+type ServiceStats struct {
+	Time             time.Time         `json:"time"`
+	InFlight         []InFlightRequest `json:"inFlight"` // Requests ohne Antwort-Header, älteste zuerst
+	Requests         int64             `json:"requests"` // HTTP-Requests seit dem Start
+	Failures         int64             `json:"failures"` // davon Netzwerkfehler und Status >= 400
+	Queued           int               `json:"queued"`   // im Scheduler wartende Aufrufe
+	Calls            int               `json:"calls"`    // abgerechnete Aufrufe, siehe Costs
+	PromptTokens     int64             `json:"promptTokens"`
+	CompletionTokens int64             `json:"completionTokens"`
+	TotalCost        float64           `json:"totalCost"`           // USD
+	RateLimit        *RateLimitStats   `json:"rateLimit,omitempty"` // nur mit RateLimiter oder Forecaster
+	RecentErrors     []RequestError    `json:"recentErrors"`        // neueste zuerst
+}
+
+// InFlightRequest ist ein laufender HTTP-Request.
+type InFlightRequest struct {
+	Method  string        `json:"method"`
+	Path    string        `json:"path"`
+	Started time.Time     `json:"started"`
+	Age     time.Duration `json:"age"`
+}
+
+// RateLimitStats ist der Stand von RateLimiter und QuotaForecaster.
+type RateLimitStats struct {
+	Delay     time.Duration   `json:"delay"`               // adaptiver Abstand nach 429-Antworten
+	Wait      time.Duration   `json:"wait"`                // Wartezeit, die der nächste Aufruf ohne Tokens hätte
+	LastLimit *OpenAIRateInfo `json:"lastLimit,omitempty"` // zuletzt gemeldetes Limit
+}
+
+// RequestError ist ein fehlgeschlagener HTTP-Request.
+type RequestError struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Status  int       `json:"status,omitempty"` // 0 = keine Antwort, z.B. Netzwerkfehler
+	Message string    `json:"message"`
+}
+
+// serviceStats zählt die HTTP-Requests des Clients; der Nullwert ist einsatzbereit.
+type serviceStats struct {
+	mu       sync.Mutex
+	nextID   uint64
+	inFlight map[uint64]InFlightRequest
+	requests int64
+	failures int64
+	errors   []RequestError // Ringpuffer, älteste zuerst
+}
+
+// statsMiddleware hängt sich in den openai.Client und erfasst jeden HTTP-Request.
+func (ai *AiCommunicationService) statsMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	s := &ai.stats
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	if s.inFlight == nil {
+		s.inFlight = map[uint64]InFlightRequest{}
+	}
+	s.inFlight[id] = InFlightRequest{Method: req.Method, Path: req.URL.Path, Started: time.Now()}
+	s.requests++
+	s.mu.Unlock()
+
+	res, err := next(req)
+
+	var failure *RequestError
+	switch {
+	case err != nil:
+		failure = &RequestError{Message: err.Error()}
+		if res != nil {
+			failure.Status = res.StatusCode
+		}
+	case res.StatusCode >= 400:
+		failure = &RequestError{Status: res.StatusCode, Message: errorMessage(res)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, id)
+	if failure != nil {
+		failure.Time, failure.Method, failure.Path = time.Now(), req.Method, req.URL.Path
+		s.failures++
+		s.errors = append(s.errors, *failure)
+		if len(s.errors) > maxRecentErrors {
+			s.errors = s.errors[len(s.errors)-maxRecentErrors:]
+		}
+	}
+	return res, err
+}
+
+// errorMessage liest die Meldung einer Fehlerantwort; der Body bleibt für den Client lesbar.
+func errorMessage(res *http.Response) string {
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), res.Body), res.Body}
+	if err != nil {
+		return err.Error()
+	}
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+	return http.StatusText(res.StatusCode)
+}
+
+// Stats liefert eine Momentaufnahme: laufende Requests, Warteschlange, Kosten, letzte
+// Fehler und Rate-Limit-Zustand. Erfasst werden nur Requests dieses Services.
+func (ai *AiCommunicationService) Stats() ServiceStats {
+	now := time.Now()
+	stats := ServiceStats{Time: now}
+
+	s := &ai.stats
+	s.mu.Lock()
+	for _, r := range s.inFlight {
+		r.Age = now.Sub(r.Started)
+		stats.InFlight = append(stats.InFlight, r)
+	}
+	stats.Requests, stats.Failures = s.requests, s.failures
+	stats.RecentErrors = slices.Clone(s.errors)
+	s.mu.Unlock()
+	slices.SortFunc(stats.InFlight, func(a, b InFlightRequest) int {
+		return a.Started.Compare(b.Started)
+	})
+	slices.Reverse(stats.RecentErrors)
+
+	if ai.Scheduler != nil {
+		stats.Queued = ai.Scheduler.Waiting()
+	}
+	for _, cost := range ai.costsSnapshot() {
+		stats.Calls++
+		stats.PromptTokens += cost.PromptTokens
+		stats.CompletionTokens += cost.CompletionTokens
+		stats.TotalCost += cost.TotalCost
+	}
+	if ai.RateLimiter != nil || ai.Forecaster != nil {
+		stats.RateLimit = &RateLimitStats{}
+		if ai.RateLimiter != nil {
+			stats.RateLimit.Delay = ai.RateLimiter.Delay()
+			stats.RateLimit.Wait = ai.RateLimiter.Peek(0)
+		}
+		if ai.Forecaster != nil {
+			if samples := ai.Forecaster.Samples(); len(samples) > 0 {
+				stats.RateLimit.LastLimit = &samples[len(samples)-1]
+			}
+		}
+	}
+	return stats
+}
+
+// StatsHandler liefert Stats als JSON, z.B. für einen internen Debug-Endpoint. Der
+// Endpoint zeigt Kosten und Fehlermeldungen und gehört nicht ins öffentliche Netz.
+func (ai *AiCommunicationService) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(ai.Stats())
+	})
+}
@@ -0,0 +1,78 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Stats(t *testing.T) {
+	release := make(chan struct{})
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		switch body.Messages[len(body.Messages)-1].Content {
+		case "fail":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "invalid model", "type": "invalid_request_error"}}`))
+		case "block":
+			<-release
+			_, _ = w.Write([]byte(testChatCompletion))
+		default:
+			_, _ = w.Write([]byte(testChatCompletion))
+		}
+	})
+	ai.Scheduler = NewScheduler(1)
+	ai.RateLimiter = NewRateLimiter(60, 0)
+
+	_, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	_, err = ai.GenerateContent("system", WithPrompt("fail"))
+	require.ErrorContains(t, err, "invalid model")
+
+	done := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := ai.GenerateContent("system", WithPrompt("block"))
+			done <- err
+		}()
+	}
+	require.Eventually(t, func() bool {
+		stats := ai.Stats()
+		return len(stats.InFlight) == 1 && stats.Queued == 1
+	}, time.Second, 5*time.Millisecond)
+
+	stats := ai.Stats()
+	require.Equal(t, "/chat/completions", stats.InFlight[0].Path)
+	require.Positive(t, stats.InFlight[0].Age)
+	require.EqualValues(t, 3, stats.Requests)
+	require.EqualValues(t, 1, stats.Failures)
+	require.Len(t, stats.RecentErrors, 1)
+	require.Equal(t, http.StatusBadRequest, stats.RecentErrors[0].Status)
+	require.Equal(t, "invalid model", stats.RecentErrors[0].Message)
+	require.Equal(t, 1, stats.Calls)
+	require.InDelta(t, ai.TotalCosts(), stats.TotalCost, 1e-9)
+	require.NotNil(t, stats.RateLimit)
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	rec := httptest.NewRecorder()
+	ai.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/ai", nil))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var decoded ServiceStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded))
+	require.Empty(t, decoded.InFlight)
+	require.Equal(t, 3, decoded.Calls)
+	require.EqualValues(t, 4, decoded.Requests)
+}
@@ -0,0 +1,317 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// DefaultStreamBuffer ist die Zahl der Stücke, die StreamChan ohne BackPressure.Buffer puffert.
+const DefaultStreamBuffer = 16
+
+// ErrSlowConsumer bricht einen Stream ab, dessen Verbraucher länger als BackPressure.MaxBlock
+// nicht gelesen hat.
+var ErrSlowConsumer = errors.New("stream consumer too slow")
+
+// StreamHandler erhält die Stücke einer gestreamten Antwort der Reihe nach. Solange der
+// Handler läuft, liest der Service nichts weiter vom Server; ein langsamer Handler bremst
+// so über die Verbindung den Server, statt Stücke im Speicher zu sammeln. Ein Fehler des
+// Handlers bricht den Stream ab und wird von Stream zurückgegeben.
+type StreamHandler func(chunk string) error
+
+// BackPressure legt fest, wie StreamChan mit einem langsamen Verbraucher umgeht.
+type BackPressure struct {
+	// Buffer ist die Zahl der Stücke, die der Kanal aufnimmt, bevor der Service mit dem
+	// Lesen vom Server wartet. Default: DefaultStreamBuffer.
+	Buffer int
+	// MaxBlock begrenzt, wie lange der Service bei vollem Kanal wartet; danach endet der
+	// Stream mit ErrSlowConsumer. 0 = warten, bis gelesen wird oder der Kontext endet.
+	MaxBlock time.Duration
+}
+
+// Stream stellt den Request gestreamt und übergibt die Antwort stückweise an handler.
+// Der Inhalt wird nicht gesammelt: Result.Content bleibt leer, Tokens und Kosten sind
+// gesetzt. Post-Prozessoren, JSON-Prüfung, Hedging, Abstimmungen und Result-Cache greifen
+// bei Streams nicht. Wiederholt wird nur, solange noch kein Stück übergeben wurde.
+func (ai *AiCommunicationService) Stream(ctx context.Context, req Request, handler StreamHandler, opts ...RequestOption) (*Result, error) {
+	cfg := ai.newRequestConfig(opts)
+	if cfg.taskErr != nil {
+		return nil, log.WrapError(cfg.taskErr)
+	}
+	if cfg.budgetErr != nil {
+		return nil, cfg.budgetErr
+	}
+	var f onGetDocument
+	if req.FileName != "" {
+		if err := CheckInputFile(req.FileName); err != nil {
+			return nil, err
+		}
+		f = func(ctx context.Context, client *openai.Client) (*openai.ChatCompletionContentPartUnionParam, error) {
+			return ai.getFilePart(ctx, client, req.FileName, cfg)
+		}
+	}
+	ai.init()
+	client := &ai.client
+	systemMessage, err := truncateSystemMessage(req.SystemMessage, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if ai.Scheduler != nil {
+		release, err := ai.Scheduler.Acquire(ctx, cfg.priority, cfg.tag)
+		if err != nil {
+			return nil, log.WrapError(err)
+		}
+		defer release()
+	}
+	releaseBudget, err := ai.reserveBudget(estimateTokens(systemMessage, cfg.prompt))
+	if err != nil {
+		return nil, err
+	}
+	defer releaseBudget()
+	params, err := ai.chatParams(ctx, client, systemMessage, f, cfg)
+	if err != nil {
+		return nil, err
+	}
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+	maxAttempts := ai.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	for attempt := 0; ; attempt++ {
+		if ai.RateLimiter != nil {
+			if err := ai.RateLimiter.Wait(ctx, estimateTokens(systemMessage, cfg.prompt)); err != nil {
+				return nil, log.WrapError(err)
+			}
+		}
+		s, delivered, err := ai.streamOnce(ctx, params, cfg, handler)
+		if err == nil {
+			if ai.RateLimiter != nil {
+				ai.RateLimiter.OnSuccess()
+			}
+			return ai.finishStream(systemMessage, cfg, s)
+		}
+		// auch ein abgebrochener Stream hat Tokens gekostet
+		ai.recordStream(systemMessage, cfg, s, nil, err)
+		if delivered || attempt >= maxAttempts-1 {
+			return nil, err
+		}
+		e := parseAPIError(err)
+		switch {
+		case e != nil && e.isRetryable():
+			if ai.RateLimiter != nil && e.IsRateLimit() {
+				ai.RateLimiter.OnRateLimited(e.retryAfter())
+			}
+			if err := sleepContext(ctx, ai.retryDelay(e, attempt)); err != nil {
+				return nil, err
+			}
+		case e == nil && isTransientNetworkError(err):
+			if err := sleepContext(ctx, ai.backoffPolicy().Delay(attempt)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+		log.Warn("chat completion stream failed, retrying: %v", err)
+	}
+}
+
+// GenerateContentStream stellt den Request gestreamt, z.B. für interaktive Oberflächen:
+// handler erhält jedes Stück, sobald es eintrifft. Anders als bei Stream enthält
+// Result.Content am Ende die gesamte Antwort, dazu Tokens und Kosten. Es gelten die
+// Einschränkungen von Stream; Stücke über einen Kanal liefert StreamChan.
+func (ai *AiCommunicationService) GenerateContentStream(ctx context.Context, req Request, handler StreamHandler, opts ...RequestOption) (*Result, error) {
+	var content strings.Builder
+	result, err := ai.Stream(ctx, req, func(chunk string) error {
+		content.WriteString(chunk)
+		return handler(chunk)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	result.Content = content.String()
+	return result, nil
+}
+
+// streamSummary sammelt aus den Stücken, was für Kosten und Prüfung gebraucht wird.
+type streamSummary struct {
+	model        string
+	finishReason string
+	refusal      string
+	usage        openai.CompletionUsage
+	bytes        int
+	received     bool // mindestens ein Stück kam an
+}
+
+// streamOnce liest einen Stream bis zum Ende; delivered meldet, ob der Handler schon ein
+// Stück erhalten hat.
+func (ai *AiCommunicationService) streamOnce(ctx context.Context, params openai.ChatCompletionNewParams, cfg requestConfig, handler StreamHandler) (s streamSummary, delivered bool, err error) {
+	ctx, restoreLabels := ai.labelGoroutine(ctx, string(params.Model), cfg)
+	defer restoreLabels()
+	stream := ai.client.Chat.Completions.NewStreaming(ctx, params, cfg.requestOptions()...)
+	defer stream.Close()
+	for stream.Next() {
+		chunk := stream.Current()
+		s.received = true
+		if chunk.Model != "" {
+			s.model = chunk.Model
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			s.usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			s.finishReason = choice.FinishReason
+		}
+		s.refusal += choice.Delta.Refusal
+		text := choice.Delta.Content
+		if text == "" {
+			continue
+		}
+		s.bytes += len(text)
+		if ai.MaxResponseBytes > 0 && s.bytes > ai.MaxResponseBytes {
+			return s, delivered, fmt.Errorf("%w: more than %d bytes streamed", ErrResponseTooLarge, ai.MaxResponseBytes)
+		}
+		delivered = true
+		if err := handler(text); err != nil {
+			return s, delivered, err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		if e := parseAPIError(err); e != nil {
+			e.receivedAt = time.Now()
+			return s, delivered, e
+		}
+		return s, delivered, err
+	}
+	return s, delivered, nil
+}
+
+// finishStream prüft das Ende des Streams und erfasst Kosten und Audit wie bei Generate.
+func (ai *AiCommunicationService) finishStream(systemMessage string, cfg requestConfig, s streamSummary) (*Result, error) {
+	usage := &callUsage{}
+	if s.finishReason == "" {
+		err := fmt.Errorf("chat completion stream ended without finish reason")
+		ai.recordStream(systemMessage, cfg, s, usage, err)
+		return nil, err
+	}
+	ai.recordStream(systemMessage, cfg, s, usage, nil)
+	if err := finishReasonError(s.finishReason); err != nil {
+		return nil, err
+	}
+	if s.refusal != "" {
+		return nil, fmt.Errorf("%w: %s", ErrRefused, s.refusal)
+	}
+	result := usage.result("")
+	result.Model = s.model
+	result.Cached = false
+	return result, nil
+}
+
+// recordStream erfasst Kosten und Audit eines Streams, sobald eine Antwort eingegangen ist,
+// auch wenn er mit err abbricht. Die Usage kommt erst mit dem letzten Stück; fehlt sie,
+// werden die Tokens aus Anfrage und gestreamtem Text geschätzt.
+func (ai *AiCommunicationService) recordStream(systemMessage string, cfg requestConfig, s streamSummary, usage *callUsage, err error) {
+	tokens := s.usage
+	if tokens.TotalTokens == 0 {
+		if !s.received {
+			return
+		}
+		tokens.PromptTokens = int64(estimateTokens(systemMessage, cfg.prompt))
+		tokens.CompletionTokens = int64((s.bytes + 3) / 4)
+		tokens.TotalTokens = tokens.PromptTokens + tokens.CompletionTokens
+	}
+	cost := ai.addCosts(tokens, cfg)
+	usage.add(s.model, tokens.PromptTokens, tokens.CompletionTokens, cost)
+	cfg.usage.add(s.model, tokens.PromptTokens, tokens.CompletionTokens, cost)
+	rec := ai.auditRecord(systemMessage, cfg)
+	rec.FinishReason = s.finishReason
+	rec.PromptTokens = tokens.PromptTokens
+	rec.CompletionTokens = tokens.CompletionTokens
+	rec.Cost = cost
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	ai.audit(rec)
+}
+
+// ChunkStream liefert die Stücke einer Antwort über den Kanal C, siehe StreamChan.
+type ChunkStream struct {
+	C <-chan string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *Result
+	err    error
+}
+
+// StreamChan startet den Request wie Stream und liefert die Stücke über einen Kanal mit
+// höchstens bp.Buffer Einträgen. Ist der Kanal voll, liest der Service nicht weiter vom
+// Server, bis der Verbraucher aufholt oder bp.MaxBlock abläuft. C wird am Ende geschlossen;
+// danach liefert Wait das Ergebnis. Wer vorher aufhört zu lesen, muss Close aufrufen.
+func (ai *AiCommunicationService) StreamChan(ctx context.Context, req Request, bp BackPressure, opts ...RequestOption) *ChunkStream {
+	ctx, cancel := context.WithCancel(ctx)
+	buffer := bp.Buffer
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+	ch := make(chan string, buffer)
+	cs := &ChunkStream{C: ch, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(cs.done)
+		defer cancel()
+		defer close(ch)
+		cs.result, cs.err = ai.Stream(ctx, req, func(chunk string) error {
+			return sendChunk(ctx, ch, chunk, bp.MaxBlock)
+		}, opts...)
+	}()
+	return cs
+}
+
+// sendChunk legt chunk in ch ab und wartet bei vollem Kanal höchstens maxBlock.
+func sendChunk(ctx context.Context, ch chan<- string, chunk string, maxBlock time.Duration) error {
+	select {
+	case ch <- chunk:
+		return nil
+	default:
+	}
+	var timeout <-chan time.Time
+	if maxBlock > 0 {
+		timer := time.NewTimer(maxBlock)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case ch <- chunk:
+		return nil
+	case <-timeout:
+		return fmt.Errorf("%w: no read within %s", ErrSlowConsumer, maxBlock)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait wartet auf das Ende des Streams und liefert Tokens und Kosten bzw. den Fehler.
+func (cs *ChunkStream) Wait() (*Result, error) {
+	<-cs.done
+	return cs.result, cs.err
+}
+
+// Close bricht den Stream ab, falls er noch läuft, und wartet auf sein Ende.
+func (cs *ChunkStream) Close() error {
+	cs.cancel()
+	for range cs.C {
+		// restliche Stücke verwerfen, damit der Stream nicht blockiert
+	}
+	<-cs.done
+	return nil
+}
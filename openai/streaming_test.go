@@ -0,0 +1,136 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dchaykin/myailib/openai/internal/mockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_Handler(t *testing.T) {
+	ai, srv := newMockService(t)
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+	content := `{"text": "` + strings.Repeat("abcdefgh", 20) + `"}`
+	srv.Enqueue(mockserver.ServerError(), mockserver.Response{Content: content, PromptTokens: 1000, CompletionTokens: 200})
+
+	var chunks []string
+	result, err := ai.Stream(context.Background(), Request{SystemMessage: "system"}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 10)
+	require.Equal(t, content, strings.Join(chunks, ""))
+	require.Empty(t, result.Content)
+	require.EqualValues(t, 1000, result.PromptTokens)
+	require.Equal(t, 1, result.Requests)
+	require.InDelta(t, costOf(1000, 200), ai.TotalCosts(), 1e-9)
+	require.Len(t, audit.records, 1)
+	require.Equal(t, 2, srv.ChatRequests(), "server error before the first chunk is retried")
+	require.Contains(t, string(srv.Requests()[1].Body), `"stream":true`)
+}
+
+func TestGenerateContentStream(t *testing.T) {
+	ai, srv := newMockService(t)
+	content := `{"text": "` + strings.Repeat("abcdefgh", 20) + `"}`
+	srv.Enqueue(mockserver.Response{Content: content, PromptTokens: 1000, CompletionTokens: 200})
+
+	var chunks []string
+	result, err := ai.GenerateContentStream(context.Background(), Request{SystemMessage: "system"}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 10)
+	require.Equal(t, content, result.Content)
+	require.EqualValues(t, 200, result.CompletionTokens)
+	require.InDelta(t, costOf(1000, 200), result.Cost, 1e-9)
+
+	srv.Enqueue(mockserver.Response{Content: "abgeschnitten", FinishReason: "length"})
+	_, err = ai.GenerateContentStream(context.Background(), Request{SystemMessage: "system"}, func(string) error { return nil })
+	require.ErrorIs(t, err, ErrMaxLength)
+}
+
+func TestStream_HandlerError(t *testing.T) {
+	ai, srv := newMockService(t)
+	audit := &memoryAuditLog{}
+	ai.Audit = audit
+	srv.Enqueue(mockserver.Response{Content: strings.Repeat("x", 100)})
+
+	stop := errors.New("client gone")
+	calls := 0
+	_, err := ai.Stream(context.Background(), Request{SystemMessage: "system"}, func(string) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+	require.Equal(t, 1, srv.ChatRequests(), "no retry after a chunk was delivered")
+	// ohne Usage im Stream geschätzt, aber erfasst
+	require.Greater(t, ai.TotalCosts(), 0.0)
+	require.Len(t, audit.records, 1)
+	require.Equal(t, "client gone", audit.records[0].Error)
+	require.Greater(t, audit.records[0].CompletionTokens, int64(0))
+
+	// zu lange Antworten kosten ebenfalls
+	ai.MaxResponseBytes = 10
+	srv.Enqueue(mockserver.Response{Content: strings.Repeat("x", 100), PromptTokens: 1000, CompletionTokens: 200})
+	costs := ai.TotalCosts()
+	_, err = ai.Stream(context.Background(), Request{SystemMessage: "system"}, func(string) error { return nil })
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+	require.Greater(t, ai.TotalCosts(), costs)
+	require.Len(t, audit.records, 2)
+	require.Contains(t, audit.records[1].Error, ErrResponseTooLarge.Error())
+}
+
+func TestStream_FinishReason(t *testing.T) {
+	ai, srv := newMockService(t)
+	srv.Enqueue(mockserver.Response{Content: "abgeschnitten", FinishReason: "length"})
+
+	_, err := ai.Stream(context.Background(), Request{SystemMessage: "system"}, func(string) error { return nil })
+	require.ErrorIs(t, err, ErrMaxLength)
+}
+
+func TestStreamChan(t *testing.T) {
+	ai, srv := newMockService(t)
+	content := strings.Repeat("0123456789", 30)
+	srv.SetDefault(mockserver.Response{Content: content})
+
+	t.Run("reads all chunks", func(t *testing.T) {
+		cs := ai.StreamChan(context.Background(), Request{SystemMessage: "system"}, BackPressure{Buffer: 2})
+		var sb strings.Builder
+		for chunk := range cs.C {
+			require.LessOrEqual(t, len(cs.C), 2)
+			sb.WriteString(chunk)
+		}
+		result, err := cs.Wait()
+		require.NoError(t, err)
+		require.Equal(t, content, sb.String())
+		require.EqualValues(t, 120, result.PromptTokens+result.CompletionTokens)
+	})
+
+	t.Run("slow consumer", func(t *testing.T) {
+		cs := ai.StreamChan(context.Background(), Request{SystemMessage: "system"}, BackPressure{Buffer: 1, MaxBlock: 20 * time.Millisecond})
+		_, err := cs.Wait()
+		require.ErrorIs(t, err, ErrSlowConsumer)
+		// die gepufferten Stücke bleiben lesbar, danach ist der Kanal geschlossen
+		n := 0
+		for range cs.C {
+			n++
+		}
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("close early", func(t *testing.T) {
+		cs := ai.StreamChan(context.Background(), Request{SystemMessage: "system"}, BackPressure{Buffer: 1})
+		<-cs.C
+		require.NoError(t, cs.Close())
+		_, err := cs.Wait()
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
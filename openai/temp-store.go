@@ -0,0 +1,165 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+const (
+	tempStorePrefix = "myailib-"
+	// staleTempAge: Verzeichnisse abgestürzter Instanzen werden danach beim nächsten Start entfernt.
+	staleTempAge = 24 * time.Hour
+)
+
+// ErrTempSpace meldet, dass eine Zwischendatei MaxBytes des TempStore überschreiten würde.
+var ErrTempSpace = errors.New("temp space limit reached")
+
+// TempStore nimmt Zwischendateien (z.B. Dokumente aus Nachrichten, geteilte PDFs, Debug-
+// Ausgaben) in einem eigenen Unterverzeichnis auf, begrenzt deren Gesamtgröße und räumt
+// mit Close alles weg. So füllen lang laufende Worker nicht /tmp.
+type TempStore struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	used  int64
+	files map[string]int64
+}
+
+// NewTempStore legt unter base (leer = os.TempDir()) ein Verzeichnis für diese Instanz an
+// und entfernt dort verwaiste Verzeichnisse früherer Instanzen. maxBytes 0 = unbegrenzt.
+func NewTempStore(base string, maxBytes int64) (*TempStore, error) {
+	if base == "" {
+		base = os.TempDir()
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, log.WrapError(err)
+	}
+	removeStaleTempDirs(base)
+	dir, err := os.MkdirTemp(base, tempStorePrefix+"*")
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	return &TempStore{dir: dir, maxBytes: maxBytes, files: map[string]int64{}}, nil
+}
+
+// Dir ist das Verzeichnis dieser Instanz.
+func (s *TempStore) Dir() string {
+	return s.dir
+}
+
+// Used liefert die Größe der aktuell belegten Zwischendateien in Bytes.
+func (s *TempStore) Used() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+// WriteFile legt data als neue Datei nach pattern (siehe os.CreateTemp) an. Die Datei
+// belegt Platz, bis sie mit Remove oder Close entfernt wird.
+func (s *TempStore) WriteFile(pattern string, data []byte) (string, error) {
+	size := int64(len(data))
+	s.mu.Lock()
+	if s.files == nil {
+		s.mu.Unlock()
+		return "", fmt.Errorf("temp store %s is closed", s.dir)
+	}
+	if s.maxBytes > 0 && s.used+size > s.maxBytes {
+		used := s.used
+		s.mu.Unlock()
+		return "", fmt.Errorf("%w: %d bytes in use, %d requested, limit is %d", ErrTempSpace, used, size, s.maxBytes)
+	}
+	s.used += size
+	s.mu.Unlock()
+
+	name, err := s.write(pattern, data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.used -= size
+		return "", err
+	}
+	if s.files == nil {
+		os.Remove(name)
+		return "", fmt.Errorf("temp store %s is closed", s.dir)
+	}
+	s.files[name] = size
+	return name, nil
+}
+
+func (s *TempStore) write(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp(s.dir, pattern)
+	if os.IsNotExist(err) {
+		// von einer anderen Instanz als verwaist entfernt
+		if err = os.MkdirAll(s.dir, 0700); err == nil {
+			f, err = os.CreateTemp(s.dir, pattern)
+		}
+	}
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", log.WrapError(err)
+	}
+	return f.Name(), nil
+}
+
+// Remove löscht eine mit WriteFile angelegte Datei und gibt ihren Platz frei.
+func (s *TempStore) Remove(name string) {
+	s.mu.Lock()
+	size, ok := s.files[name]
+	if ok {
+		delete(s.files, name)
+		s.used -= size
+	}
+	s.mu.Unlock()
+	if ok {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Warn("failed to remove temp file %s: %v", name, err)
+		}
+	}
+}
+
+// Close entfernt das Verzeichnis samt allen Zwischendateien.
+func (s *TempStore) Close() error {
+	s.mu.Lock()
+	s.files = nil
+	s.used = 0
+	s.mu.Unlock()
+	return log.WrapError(os.RemoveAll(s.dir))
+}
+
+// removeStaleTempDirs entfernt Verzeichnisse abgestürzter Instanzen.
+func removeStaleTempDirs(base string) {
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), tempStorePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		path := filepath.Join(base, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			log.Warn("failed to remove stale temp dir %s: %v", path, err)
+		} else {
+			log.Info("Removed stale temp dir %s", path)
+		}
+	}
+}
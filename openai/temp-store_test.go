@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempStore(t *testing.T) {
+	base := t.TempDir()
+	stale := filepath.Join(base, "myailib-crashed")
+	require.NoError(t, os.Mkdir(stale, 0755))
+	old := time.Now().Add(-2 * staleTempAge)
+	require.NoError(t, os.Chtimes(stale, old, old))
+	foreign := filepath.Join(base, "other")
+	require.NoError(t, os.Mkdir(foreign, 0755))
+
+	store, err := NewTempStore(base, 10)
+	require.NoError(t, err)
+	require.NoDirExists(t, stale)
+	require.DirExists(t, foreign)
+
+	a, err := store.WriteFile("a-*.pdf", []byte("123456"))
+	require.NoError(t, err)
+	require.Equal(t, store.Dir(), filepath.Dir(a))
+	require.EqualValues(t, 6, store.Used())
+
+	_, err = store.WriteFile("b-*.pdf", []byte("123456"))
+	require.ErrorIs(t, err, ErrTempSpace)
+
+	store.Remove(a)
+	require.NoFileExists(t, a)
+	require.Zero(t, store.Used())
+	_, err = store.WriteFile("b-*.pdf", []byte("123456"))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Close())
+	require.NoDirExists(t, store.Dir())
+	_, err = store.WriteFile("c-*.pdf", []byte("1"))
+	require.Error(t, err)
+}
@@ -0,0 +1,272 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dchaykin/mygolib/log"
+)
+
+// TenantConfig enthält die Einstellungen eines Mandanten. Leere Felder übernehmen die
+// Werte des Basis-Services, sofern unten nicht anders angegeben.
+type TenantConfig struct {
+	APIKey  string      // eigener OpenAI-Key; leer = Key des Basis-Services
+	MaxCost float64     // Budget (USD) über die Lebensdauer des TenantService, 0 = unbegrenzt
+	RPM     int         // eigenes Rate-Limit; RPM und TPM 0 = nur lernend, siehe NewRateLimiter
+	TPM     int         //
+	Audit   AuditLog    // eigenes Audit-Log; leer = Audit-Log des Basis-Services mit Tenant-Feld
+	Cache   ResultCache // eigener Ergebnis-Cache; ein gemeinsamer Cache wird nie geteilt
+	// Corrections sind die Korrekturen des Mandanten; wie der Cache wird der Store des
+	// Basis-Services nicht geteilt.
+	Corrections *CorrectionStore
+}
+
+// TenantService trennt für eine Anwendung mit mehreren Kunden API-Keys, Budgets,
+// Rate-Limits, Kosten und Audit-Log je Mandant. Jeder Mandant erhält einen eigenen
+// Service, der aus dem Basis-Service abgeleitet wird; Scheduler, Routen und
+// Policies werden geteilt.
+type TenantService struct {
+	Base *AiCommunicationService
+
+	mu       sync.Mutex
+	configs  map[string]TenantConfig
+	services map[string]*AiCommunicationService
+}
+
+func NewTenantService(base *AiCommunicationService) *TenantService {
+	return &TenantService{
+		Base:     base,
+		configs:  map[string]TenantConfig{},
+		services: map[string]*AiCommunicationService{},
+	}
+}
+
+// AddTenant registriert einen Mandanten. Ein bereits genutzter Mandant behält seine Kosten,
+// erhält aber beim nächsten Aufruf einen neu abgeleiteten Service.
+func (ts *TenantService) AddTenant(tenantID string, cfg TenantConfig) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.configs[tenantID] = cfg
+	if old, ok := ts.services[tenantID]; ok {
+		svc := ts.newTenantService(tenantID, cfg)
+		svc.Costs = old.costsSnapshot()
+		ts.services[tenantID] = svc
+	}
+}
+
+// Service liefert den Service des Mandanten. Er hält Budget und Rate-Limit des Mandanten
+// selbst ein, auch bei Streams, Agenten und Konversationen.
+func (ts *TenantService) Service(tenantID string) (*AiCommunicationService, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if svc, ok := ts.services[tenantID]; ok {
+		return svc, nil
+	}
+	cfg, ok := ts.configs[tenantID]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant %q", tenantID)
+	}
+	svc := ts.newTenantService(tenantID, cfg)
+	ts.services[tenantID] = svc
+	return svc, nil
+}
+
+// Generate stellt den Aufruf für den Mandanten, sofern sein Budget reicht.
+func (ts *TenantService) Generate(ctx context.Context, tenantID string, req Request, opts ...RequestOption) (*Result, error) {
+	svc, err := ts.Service(tenantID)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	return svc.Generate(ctx, req, opts...)
+}
+
+// Deprecated: Generate liefert zusätzlich Tokens und Kosten und nimmt einen Kontext.
+func (ts *TenantService) GenerateContent(tenantID, systemMessage string, opts ...RequestOption) (string, error) {
+	return ts.GenerateContentContext(context.Background(), tenantID, systemMessage, opts...)
+}
+
+// GenerateContentContext wie GenerateContent, aber abbrechbar über ctx.
+func (ts *TenantService) GenerateContentContext(ctx context.Context, tenantID, systemMessage string, opts ...RequestOption) (string, error) {
+	svc, err := ts.Service(tenantID)
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	return svc.GenerateContentContext(ctx, systemMessage, opts...)
+}
+
+// Deprecated: Generate mit Request.FileName liefert zusätzlich Tokens und Kosten und nimmt einen Kontext.
+func (ts *TenantService) GenerateContentWithPDF(tenantID, systemMessage, fileName string, opts ...RequestOption) (string, error) {
+	return ts.GenerateContentWithPDFContext(context.Background(), tenantID, systemMessage, fileName, opts...)
+}
+
+// GenerateContentWithPDFContext wie GenerateContentWithPDF, aber abbrechbar über ctx.
+func (ts *TenantService) GenerateContentWithPDFContext(ctx context.Context, tenantID, systemMessage, fileName string, opts ...RequestOption) (string, error) {
+	svc, err := ts.Service(tenantID)
+	if err != nil {
+		return "", log.WrapError(err)
+	}
+	return svc.GenerateContentWithPDFContext(ctx, systemMessage, fileName, opts...)
+}
+
+// Allow prüft vor aufwendiger Vorarbeit, ob der Mandant einen Request mit estTokens
+// geschätzten Tokens stellen dürfte. Ist das Budget erschöpft, ist ok false und wait 0,
+// denn Warten hilft dann nicht. Greift das Rate-Limit, liefert wait die Zeit bis zur
+// Freigabe. Es wird nichts gebucht; der eigentliche Request reserviert selbst.
+func (ts *TenantService) Allow(tenantID string, estTokens int) (ok bool, wait time.Duration) {
+	svc, err := ts.Service(tenantID)
+	if err != nil {
+		return false, 0
+	}
+	if b := svc.budget; b != nil {
+		estCost, _, _ := costFor(int64(estTokens), 0)
+		if svc.TotalCosts()+b.pendingCosts()+estCost > b.maxCost {
+			return false, 0
+		}
+	}
+	if svc.RateLimiter != nil {
+		if wait = svc.RateLimiter.Peek(estTokens); wait > 0 {
+			return false, wait
+		}
+	}
+	return true, 0
+}
+
+// TotalCosts liefert die Kosten (USD) des Mandanten.
+func (ts *TenantService) TotalCosts(tenantID string) float64 {
+	svc, err := ts.Service(tenantID)
+	if err != nil {
+		return 0
+	}
+	return svc.TotalCosts()
+}
+
+// tenantBudget begrenzt die Kosten eines Mandanten. Laufende Requests reservieren ihre
+// geschätzten Kosten, bis die tatsächlichen gebucht sind; so überziehen parallele Requests
+// das Budget nicht gemeinsam.
+type tenantBudget struct {
+	tenant  string
+	maxCost float64
+
+	mu      sync.Mutex
+	pending float64
+}
+
+func (b *tenantBudget) pendingCosts() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pending
+}
+
+func (b *tenantBudget) exceeded(spent float64) error {
+	return fmt.Errorf("%w: tenant %s spent $%.4f of $%.4f", ErrBudgetExceeded, b.tenant, spent, b.maxCost)
+}
+
+// budgetExceeded liefert ErrBudgetExceeded, wenn das Budget des Mandanten aufgebraucht ist.
+// Mit StaleFallback bedient der Service solche Aufrufe nur noch aus dem Cache.
+func (ai *AiCommunicationService) budgetExceeded() error {
+	if ai.budget == nil {
+		return nil
+	}
+	if spent := ai.TotalCosts(); spent >= ai.budget.maxCost {
+		return ai.budget.exceeded(spent)
+	}
+	return nil
+}
+
+// reserveBudget reserviert die geschätzten Kosten eines Requests mit estTokens Tokens.
+// release gibt die Reservierung frei und wird aufgerufen, nachdem die Kosten gebucht sind.
+func (ai *AiCommunicationService) reserveBudget(estTokens int) (release func(), err error) {
+	b := ai.budget
+	if b == nil {
+		return func() {}, nil
+	}
+	estCost, _, _ := costFor(int64(estTokens), 0)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if spent := ai.TotalCosts(); spent+b.pending+estCost > b.maxCost {
+		return nil, b.exceeded(spent)
+	}
+	b.pending += estCost
+	return sync.OnceFunc(func() {
+		b.mu.Lock()
+		b.pending -= estCost
+		b.mu.Unlock()
+	}), nil
+}
+
+// newTenantService leitet den Service des Mandanten aus dem Basis-Service ab.
+func (ts *TenantService) newTenantService(tenantID string, cfg TenantConfig) *AiCommunicationService {
+	base := ts.Base
+	svc := &AiCommunicationService{
+		config:             base.config,
+		Model:              base.Model,
+		Prompt:             base.Prompt,
+		Costs:              []chatCosts{},
+		Temperature:        base.Temperature,
+		PostProcessors:     base.PostProcessors,
+		ExchangeRates:      base.ExchangeRates,
+		Forecaster:         base.Forecaster,
+		RateLimiter:        NewRateLimiter(cfg.RPM, cfg.TPM),
+		Scheduler:          base.Scheduler,
+		IdempotencyTTL:     base.IdempotencyTTL,
+		ClientOptions:      base.ClientOptions,
+		Transport:          base.Transport,
+		RequestTimeout:     base.RequestTimeout,
+		UploadTimeout:      base.UploadTimeout,
+		MaxUploadSize:      base.MaxUploadSize,
+		MaxResponseBytes:   base.MaxResponseBytes,
+		TruncateResponses:  base.TruncateResponses,
+		Truncation:         base.Truncation,
+		Features:           base.Features,
+		Faults:             base.Faults,
+		MaxAttempts:        base.MaxAttempts,
+		Backoff:            base.Backoff,
+		MaxRetryAfter:      base.MaxRetryAfter,
+		Hedging:            base.Hedging,
+		CheapFirst:         base.CheapFirst,
+		Voting:             base.Voting,
+		Verification:       base.Verification,
+		Language:           base.Language,
+		Routes:             base.Routes,
+		Shadow:             base.Shadow,
+		Canary:             base.Canary,
+		Hooks:              base.Hooks,
+		Safety:             base.Safety,
+		User:               HashUserID(tenantID),
+		Audit:              cfg.Audit,
+		Estimator:          base.Estimator,
+		Cache:              cfg.Cache,
+		Corrections:        cfg.Corrections,
+		PromptVersion:      base.PromptVersion,
+		CorrectionExamples: base.CorrectionExamples,
+		Retention:          base.Retention,
+		Residency:          base.Residency,
+		Component:          base.Component,
+		CacheTTL:           base.CacheTTL,
+		Stale:              base.Stale,
+		tenant:             tenantID,
+	}
+	if cfg.APIKey != "" {
+		svc.config = config{AuthData: map[string]any{"apiKey": cfg.APIKey}}
+	}
+	if cfg.MaxCost > 0 {
+		svc.budget = &tenantBudget{tenant: tenantID, maxCost: cfg.MaxCost}
+	}
+	if svc.Audit == nil && base.Audit != nil {
+		svc.Audit = tenantAuditLog{tenant: tenantID, log: base.Audit}
+	}
+	return svc
+}
+
+// tenantAuditLog ergänzt die Einträge um den Mandanten.
+type tenantAuditLog struct {
+	tenant string
+	log    AuditLog
+}
+
+func (t tenantAuditLog) Record(rec AuditRecord) error {
+	rec.Tenant = t.tenant
+	return t.log.Record(rec)
+}
@@ -0,0 +1,175 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantService_Isolation(t *testing.T) {
+	var mu sync.Mutex
+	keys := []string{}
+	base := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	audit := &memoryAuditLog{}
+	base.Audit = audit
+
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{APIKey: "acme-key"})
+	ts.AddTenant("globex", TenantConfig{})
+
+	_, err := ts.GenerateContent("acme", "system")
+	require.NoError(t, err)
+	_, err = ts.GenerateContent("acme", "system")
+	require.NoError(t, err)
+	result, err := ts.Generate(context.Background(), "globex", Request{SystemMessage: "system"})
+	require.NoError(t, err)
+	require.InDelta(t, costOf(100, 20), result.Cost, 1e-9)
+
+	require.Equal(t, []string{"Bearer acme-key", "Bearer acme-key", "Bearer test-key"}, keys)
+	require.InDelta(t, 2*costOf(100, 20), ts.TotalCosts("acme"), 1e-9)
+	require.InDelta(t, costOf(100, 20), ts.TotalCosts("globex"), 1e-9)
+	require.Zero(t, base.TotalCosts())
+
+	require.Len(t, audit.records, 3)
+	require.Equal(t, "acme", audit.records[0].Tenant)
+	require.Equal(t, "globex", audit.records[2].Tenant)
+	require.Equal(t, HashUserID("globex"), audit.records[2].User)
+
+	_, err = ts.GenerateContent("initech", "system")
+	require.Error(t, err)
+}
+
+func TestTenantService_Budget(t *testing.T) {
+	base := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{MaxCost: costOf(100, 20) * 1.5})
+
+	_, err := ts.GenerateContent("acme", "system")
+	require.NoError(t, err)
+	_, err = ts.GenerateContent("acme", "system")
+	require.NoError(t, err)
+	_, err = ts.GenerateContent("acme", "system")
+	require.True(t, errors.Is(err, ErrBudgetExceeded))
+
+	// höheres Budget gibt den Mandanten wieder frei, die Kosten bleiben erhalten
+	ts.AddTenant("acme", TenantConfig{MaxCost: 1})
+	require.InDelta(t, 2*costOf(100, 20), ts.TotalCosts("acme"), 1e-9)
+	_, err = ts.GenerateContent("acme", "system")
+	require.NoError(t, err)
+}
+
+func TestTenantService_BudgetInService(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	base := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	estCost, _, _ := costFor(int64(estimateTokens("system", "prompt")), 0)
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{MaxCost: estCost * 2.5})
+
+	// parallele Requests reservieren ihre geschätzten Kosten: nur zwei passen ins Budget
+	errs := make(chan error, 5)
+	for range 5 {
+		go func() {
+			_, err := ts.Generate(context.Background(), "acme", Request{SystemMessage: "system"})
+			errs <- err
+		}()
+	}
+	for range 3 {
+		require.ErrorIs(t, <-errs, ErrBudgetExceeded)
+	}
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	require.EqualValues(t, 2, calls.Load())
+
+	// auch der Service selbst hält das Budget ein, z.B. bei Streams und Agenten
+	svc, err := ts.Service("acme")
+	require.NoError(t, err)
+	_, err = svc.Stream(context.Background(), Request{SystemMessage: "system"}, func(string) error { return nil })
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	_, err = NewAgent(svc, "").Run(context.Background(), "los")
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	_, err = NewConversation(svc, "").Send(context.Background(), "hallo")
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestTenantService_Context(t *testing.T) {
+	var calls atomic.Int32
+	base := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{})
+	fileName := filepath.Join(t.TempDir(), "a.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ts.GenerateContentContext(ctx, "acme", "system")
+	require.ErrorContains(t, err, context.Canceled.Error())
+	_, err = ts.GenerateContentWithPDFContext(ctx, "acme", "system", fileName)
+	require.ErrorContains(t, err, context.Canceled.Error())
+	require.Zero(t, calls.Load())
+
+	content, err := ts.GenerateContentContext(context.Background(), "acme", "system")
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+}
+
+func TestTenantService_Allow(t *testing.T) {
+	base := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+	ts := NewTenantService(base)
+	ts.AddTenant("acme", TenantConfig{TPM: 6000})
+	ts.AddTenant("globex", TenantConfig{MaxCost: costOf(100, 20) * 1.5})
+
+	ok, wait := ts.Allow("acme", 6000)
+	require.True(t, ok)
+	require.Zero(t, wait)
+
+	ok, wait = ts.Allow("acme", 7000)
+	require.False(t, ok)
+	require.Equal(t, 10*time.Second, wait.Round(time.Second))
+
+	ok, _ = ts.Allow("globex", 10)
+	require.True(t, ok)
+	_, err := ts.GenerateContent("globex", "system")
+	require.NoError(t, err)
+	_, err = ts.GenerateContent("globex", "system")
+	require.NoError(t, err)
+
+	// Budget erschöpft: Warten hilft nicht
+	ok, wait = ts.Allow("globex", 10)
+	require.False(t, ok)
+	require.Zero(t, wait)
+
+	ok, _ = ts.Allow("initech", 10)
+	require.False(t, ok)
+}
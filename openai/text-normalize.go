@@ -0,0 +1,77 @@
+package openai
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var mojibakeReplacer = strings.NewReplacer(
+	"Ã¤", "ä",
+	"Ã¶", "ö",
+	"Ã¼", "ü",
+	"Ã„", "Ä",
+	"Ã–", "Ö",
+	"Ãœ", "Ü",
+	"ÃŸ", "ß",
+	"Ã©", "é",
+	"Ã¨", "è",
+	"Ã§", "ç",
+	"Ã±", "ñ",
+	"â‚¬", "€",
+	"â€ž", "„",
+	"â€œ", "“",
+	"â€\u009d", "”",
+	"â€˜", "‘",
+	"â€™", "’",
+	"â€“", "–",
+	"â€”", "—",
+	"â€¦", "…",
+	"Â§", "§",
+	"Â°", "°",
+	"Â\u00a0", "\u00a0", // geschütztes Leerzeichen
+)
+
+// zeroWidth sind unsichtbare Zeichen, die aus gescannten Dokumenten oder Kopien aus
+// Webseiten stammen und Vergleiche und Schlüssel zerstören.
+var zeroWidth = map[rune]bool{
+	'\ufeff': true, // BOM
+	'\u200b': true, // zero width space
+	'\u200c': true, // zero width non-joiner
+	'\u200d': true, // zero width joiner
+	'\u2060': true, // word joiner
+	'\u00ad': true, // soft hyphen
+}
+
+// NormalizeText bereinigt Text aus Modellantworten, ohne Werte zu verändern: ungültiges
+// UTF-8, ein führendes BOM und Steuerzeichen außer Tab und Zeilenumbruch werden entfernt,
+// zerlegte Umlaute und Akzente zusammengesetzt (NFC). Weitergehende Reparaturen macht
+// CleanText bzw. der Post-Prozessor PostProcessFixEncoding; dafür bleibt U+009D im
+// verwechselten Anführungszeichen "â€\u009d" stehen.
+func NormalizeText(s string) string {
+	s = strings.TrimPrefix(strings.ToValidUTF8(s, ""), "\ufeff")
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, r := range s {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' &&
+			!(r == '\u009d' && strings.HasSuffix(s[:i], "â€")) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// CleanText wie NormalizeText, entfernt zusätzlich BOM, Zero-Width-Zeichen und weiche
+// Trennstriche und repariert typische UTF-8/Latin-1-Verwechslungen. Die Reparatur läuft
+// vor dem Entfernen der Steuerzeichen, denn manche Verwechslungen enthalten welche.
+func CleanText(s string) string {
+	s = NormalizeText(mojibakeReplacer.Replace(s))
+	return strings.Map(func(r rune) rune {
+		if zeroWidth[r] {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"leading bom", "\ufeff{\"name\": \"Müller\"}", `{"name": "Müller"}`},
+		{"decomposed umlauts", "Mu\u0308ller Cafe\u0301", "Müller Café"},
+		{"nfc beyond latin", "\u1100\u1161 A\u0323\u0302", "\uac00 \u1eac"},
+		{"canonical ordering", "a\u0302\u0323", "\u1ead"},
+		{"control characters", "a\x00b\x1bc\td\ne", "abc\td\ne"},
+		{"invalid utf-8", "a\xffb", "ab"},
+		{"unknown combining mark stays", "q\u0308", "q\u0308"},
+		{"values stay unchanged", "Mül\u200bler Tren\u00adnung GrÃ¶ÃŸe", "Mül\u200bler Tren\u00adnung GrÃ¶ÃŸe"},
+		{"mojibake quote keeps its control character", "â€žgutâ€\u009d a\u009db", "â€žgutâ€\u009d ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, NormalizeText(tt.in))
+		})
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"bom and zero width", "\ufeff{\"name\": \"Mül\u200bler\ufeff\"}", `{"name": "Müller"}`},
+		{"soft hyphen", "Tren\u00adnung", "Trennung"},
+		{"mojibake", "GrÃ¶ÃŸe: 5 â‚¬ â€“ â€žgutâ€œ", "Größe: 5 € – „gut“"},
+		{"mojibake with control character", "â€žgutâ€\u009d", "„gut”"},
+		{"decomposed umlauts", "Mu\u0308ller", "Müller"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, CleanText(tt.in))
+		})
+	}
+	content, err := ApplyPostProcessors("Mül\u200bler", PostProcessFixEncoding)
+	require.NoError(t, err)
+	require.Equal(t, "Müller", content)
+	// wie bei Modellantworten: erst normalisiert, dann repariert
+	content, err = ApplyPostProcessors(NormalizeText("â€žgutâ€\u009d"), PostProcessFixEncoding)
+	require.NoError(t, err)
+	require.Equal(t, "„gut”", content)
+}
+
+func TestGenerateContent_NormalizesResponse(t *testing.T) {
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, `"\ufeff{\"name\": \"Mu\u0308ller\u0000\"}"`, 1)))
+	})
+	content, err := ai.GenerateContent("system")
+	require.NoError(t, err)
+	require.Equal(t, `{"name": "Müller"}`, content)
+}
@@ -0,0 +1,100 @@
+package openai
+
+import (
+	"sync"
+)
+
+const (
+	defaultEstimatorAlpha      = 0.3
+	defaultPromptTokensPerByte = 0.25 // grobe Annahme ohne Messwerte: 4 Bytes pro Token
+	defaultCompletionTokens    = 500
+)
+
+// TokenEstimator lernt je Dokumenttyp aus den tatsächlich verbrauchten Tokens und glättet
+// die Werte exponentiell. Prompt-Tokens werden pro Byte Eingabe, Completion-Tokens pro
+// Dokument geschätzt.
+type TokenEstimator struct {
+	Alpha float64 // Gewicht neuer Messwerte (0..1], Default: 0.3
+
+	mu    sync.Mutex
+	stats map[string]*TokenStats
+}
+
+// TokenStats sind die geglätteten Messwerte eines Dokumenttyps.
+type TokenStats struct {
+	PromptTokensPerByte float64 `json:"promptTokensPerByte"`
+	CompletionTokens    float64 `json:"completionTokens"`
+	Samples             int     `json:"samples"`
+}
+
+func NewTokenEstimator() *TokenEstimator {
+	return &TokenEstimator{
+		Alpha: defaultEstimatorAlpha,
+		stats: map[string]*TokenStats{},
+	}
+}
+
+// Observe nimmt den tatsächlichen Verbrauch eines Aufrufs auf.
+func (te *TokenEstimator) Observe(docType string, sizeBytes int, promptTokens, completionTokens int64) {
+	if sizeBytes <= 0 {
+		return
+	}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+
+	if te.stats == nil {
+		te.stats = map[string]*TokenStats{}
+	}
+	perByte := float64(promptTokens) / float64(sizeBytes)
+	s, ok := te.stats[docType]
+	if !ok {
+		te.stats[docType] = &TokenStats{
+			PromptTokensPerByte: perByte,
+			CompletionTokens:    float64(completionTokens),
+			Samples:             1,
+		}
+		return
+	}
+	alpha := te.alpha()
+	s.PromptTokensPerByte = alpha*perByte + (1-alpha)*s.PromptTokensPerByte
+	s.CompletionTokens = alpha*float64(completionTokens) + (1-alpha)*s.CompletionTokens
+	s.Samples++
+}
+
+// Estimate liefert die geschätzten Prompt- und Completion-Tokens für ein Dokument.
+// Ohne Messwerte für den Typ werden Defaults verwendet.
+func (te *TokenEstimator) Estimate(docType string, sizeBytes int) (promptTokens, completionTokens int64) {
+	s := te.Stats(docType)
+	if s.Samples == 0 {
+		s.PromptTokensPerByte = defaultPromptTokensPerByte
+		s.CompletionTokens = defaultCompletionTokens
+	}
+	return int64(s.PromptTokensPerByte * float64(sizeBytes)), int64(s.CompletionTokens)
+}
+
+// Stats liefert die geglätteten Werte eines Dokumenttyps.
+func (te *TokenEstimator) Stats(docType string) TokenStats {
+	if te == nil {
+		return TokenStats{}
+	}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if s, ok := te.stats[docType]; ok {
+		return *s
+	}
+	return TokenStats{}
+}
+
+func (te *TokenEstimator) alpha() float64 {
+	if te.Alpha <= 0 || te.Alpha > 1 {
+		return defaultEstimatorAlpha
+	}
+	return te.Alpha
+}
+
+// EstimateCost schätzt die Kosten (USD) für ein Dokument des Typs docType mit sizeBytes Bytes.
+func (ai *AiCommunicationService) EstimateCost(docType string, sizeBytes int) float64 {
+	promptTokens, completionTokens := ai.Estimator.Estimate(docType, sizeBytes)
+	cost, _, _ := costFor(promptTokens, completionTokens)
+	return cost
+}
@@ -0,0 +1,31 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenEstimator(t *testing.T) {
+	te := NewTokenEstimator()
+	te.Alpha = 0.5
+
+	prompt, completion := te.Estimate("invoice", 1000)
+	require.EqualValues(t, 250, prompt)
+	require.EqualValues(t, 500, completion)
+
+	te.Observe("invoice", 1000, 2000, 300)
+	prompt, completion = te.Estimate("invoice", 500)
+	require.EqualValues(t, 1000, prompt)
+	require.EqualValues(t, 300, completion)
+
+	te.Observe("invoice", 1000, 1000, 100)
+	s := te.Stats("invoice")
+	require.InDelta(t, 1.5, s.PromptTokensPerByte, 1e-9)
+	require.InDelta(t, 200, s.CompletionTokens, 1e-9)
+	require.Equal(t, 2, s.Samples)
+
+	ai := &AiCommunicationService{Estimator: te}
+	// 1500 Prompt-Tokens à 0.005 + 200 Completion-Tokens à 0.015 je 1k
+	require.InDelta(t, 0.0105, ai.EstimateCost("invoice", 1000), 1e-9)
+}
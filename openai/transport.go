@@ -0,0 +1,52 @@
+package openai
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// TransportConfig steuert den Connection-Pool des gemeinsam genutzten HTTP-Clients.
+// Nullwerte übernehmen die Defaults von http.DefaultTransport.
+type TransportConfig struct {
+	MaxIdleConns        int           // über alle Hosts
+	MaxIdleConnsPerHost int           // Default von net/http ist 2 und für viele parallele Aufrufe zu klein
+	MaxConnsPerHost     int           // 0 = unbegrenzt
+	IdleConnTimeout     time.Duration // wie lange ungenutzte Verbindungen offen bleiben
+	DisableHTTP2        bool          // erzwingt HTTP/1.1, z.B. hinter Proxys mit HTTP/2-Problemen
+}
+
+// DefaultTransportConfig ist auf viele parallele Aufrufe gegen einen Host ausgelegt.
+var DefaultTransportConfig = TransportConfig{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 32,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// newTransport baut einen http.Transport auf Basis von http.DefaultTransport.
+func (tc TransportConfig) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if tc.MaxIdleConns > 0 {
+		t.MaxIdleConns = tc.MaxIdleConns
+	}
+	if tc.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = tc.MaxIdleConnsPerHost
+	}
+	if tc.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = tc.MaxConnsPerHost
+	}
+	if tc.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = tc.IdleConnTimeout
+	}
+	if tc.DisableHTTP2 {
+		// Ein leeres, nicht-nil TLSNextProto schaltet HTTP/2 ab.
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
+
+// NewHTTPClient liefert einen http.Client mit dem konfigurierten Transport.
+func (tc TransportConfig) NewHTTPClient() *http.Client {
+	return &http.Client{Transport: tc.newTransport()}
+}
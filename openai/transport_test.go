@@ -0,0 +1,30 @@
+package openai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportConfig_NewTransport(t *testing.T) {
+	tr := TransportConfig{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 5,
+		IdleConnTimeout:     time.Minute,
+		DisableHTTP2:        true,
+	}.newTransport()
+
+	require.Equal(t, 10, tr.MaxIdleConns)
+	require.Equal(t, 5, tr.MaxIdleConnsPerHost)
+	require.Equal(t, time.Minute, tr.IdleConnTimeout)
+	require.False(t, tr.ForceAttemptHTTP2)
+	require.NotNil(t, tr.TLSNextProto)
+	require.Empty(t, tr.TLSNextProto)
+}
+
+func TestTransportConfig_Defaults(t *testing.T) {
+	tr := TransportConfig{}.newTransport()
+	require.True(t, tr.ForceAttemptHTTP2)
+	require.Nil(t, tr.TLSNextProto)
+}
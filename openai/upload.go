@@ -0,0 +1,101 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// DefaultMaxUploadSize entspricht dem Limit der Files API je Datei.
+const DefaultMaxUploadSize int64 = 512 << 20
+
+// UploadProgress wird während eines Uploads mit den bisher gesendeten Bytes aufgerufen.
+type UploadProgress func(sent, total int64)
+
+// uploadFile lädt eine Datei direkt von der Platte zur Files API hoch. Anders als
+// Files.New wird der Multipart-Body nicht im Speicher aufgebaut, sondern gestreamt.
+func (ai *AiCommunicationService) uploadFile(ctx context.Context, client *openai.Client, fileName string, cfg requestConfig) (*openai.FileObject, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+	maxSize := ai.MaxUploadSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUploadSize
+	}
+	if info.Size() > maxSize {
+		return nil, log.WrapError(fmt.Errorf("file %s is too large for upload: %d bytes (max %d)", fileName, info.Size(), maxSize))
+	}
+
+	contentType, body, err := multipartFileBody(f, filepath.Base(fileName), info.Size(), cfg.uploadProgress)
+	if err != nil {
+		return nil, log.WrapError(err)
+	}
+
+	var res openai.FileObject
+	opts := append(cfg.uploadOptions(), option.WithRequestBody(contentType, body))
+	if err := client.Post(ctx, "files", nil, &res, opts...); err != nil {
+		return nil, log.WrapError(fmt.Errorf("error uploading file to OpenAI: %s", err.Error()))
+	}
+	return &res, nil
+}
+
+// multipartFileBody setzt den Multipart-Body aus Kopf, Datei und Abschluss zusammen,
+// ohne die Datei zu lesen.
+func multipartFileBody(f io.Reader, name string, size int64, progress UploadProgress) (string, io.Reader, error) {
+	var head bytes.Buffer
+	mw := multipart.NewWriter(&head)
+	if err := mw.WriteField("purpose", string(openai.FilePurposeUserData)); err != nil {
+		return "", nil, err
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, name))
+	h.Set("Content-Type", "application/pdf")
+	if _, err := mw.CreatePart(h); err != nil {
+		return "", nil, err
+	}
+	prefix := bytes.Clone(head.Bytes())
+
+	head.Reset()
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	trailer := bytes.Clone(head.Bytes())
+
+	var file io.Reader = f
+	if progress != nil {
+		file = &progressReader{r: f, total: size, progress: progress}
+	}
+	return mw.FormDataContentType(), io.MultiReader(bytes.NewReader(prefix), file, bytes.NewReader(trailer)), nil
+}
+
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress UploadProgress
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.progress(p.sent, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateContentWithPDF_StreamsUpload(t *testing.T) {
+	pdf := strings.Repeat("%PDF-1.7 ", 10000) + "\n%%EOF"
+	fileName := filepath.Join(t.TempDir(), "report.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(pdf), 0o644))
+
+	var uploaded, purpose, uploadedName string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files") {
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			purpose = r.FormValue("purpose")
+			f, header, err := r.FormFile("file")
+			require.NoError(t, err)
+			data, err := io.ReadAll(f)
+			require.NoError(t, err)
+			uploaded, uploadedName = string(data), header.Filename
+			_, _ = w.Write([]byte(`{"id": "file-1", "object": "file", "bytes": 1, "created_at": 1, "filename": "report.pdf", "purpose": "user_data", "status": "processed"}`))
+			return
+		}
+		_, _ = w.Write([]byte(testChatCompletion))
+	})
+
+	var lastSent, total int64
+	content, err := ai.GenerateContentWithPDF("system", fileName, WithUploadProgress(func(sent, t int64) {
+		lastSent, total = sent, t
+	}))
+	require.NoError(t, err)
+	require.Equal(t, `{"ok": true}`, content)
+	require.Equal(t, pdf, uploaded)
+	require.Equal(t, "report.pdf", uploadedName)
+	require.Equal(t, "user_data", purpose)
+	require.EqualValues(t, len(pdf), total)
+	require.Equal(t, total, lastSent)
+}
+
+func TestGenerateContentWithPDF_TooLarge(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "big.pdf")
+	require.NoError(t, os.WriteFile(fileName, []byte(testPDF+strings.Repeat(" ", 100)), 0o644))
+
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	ai.MaxUploadSize = 10
+
+	_, err := ai.GenerateContentWithPDF("system", fileName)
+	require.ErrorContains(t, err, "too large")
+}
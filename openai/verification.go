@@ -0,0 +1,185 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// verificationSystemMessage ist die Anweisung für den Prüfdurchlauf.
+const verificationSystemMessage = `Du prüfst extrahierte Daten gegen das Dokument. Prüfe jede einzelne Angabe im JSON der Nachricht am Dokument, insbesondere Zahlen und Beträge. ` +
+	`Antworte ausschließlich mit JSON der Form {"unsupported": [{"path": "<Feldpfad wie items[0].total>", "reason": "<kurze Begründung>"}]}. ` +
+	`Liste nur Angaben, die das Dokument nicht belegt oder die davon abweichen; ist alles belegt, antworte mit {"unsupported": []}.`
+
+// VerificationPolicy beschreibt Chain-of-Verification: ein zweiter Aufruf prüft jede
+// extrahierte Angabe am selben Dokument und markiert nicht belegte Werte. Das verringert
+// erfundene Zahlen, kostet aber einen weiteren Request je Dokument.
+type VerificationPolicy struct {
+	Model openai.ChatModel // Modell des Prüfdurchlaufs; leer = Modell des Aufrufs
+
+	// Remove setzt nicht belegte Werte auf null. Mit WithFieldConfidence wird ihre
+	// Konfidenz unabhängig davon auf 0 gesetzt.
+	Remove bool
+
+	OnResult func(VerificationResult) // erhält das Prüfergebnis, optional
+}
+
+// UnsupportedClaim ist eine Angabe, die der Prüfdurchlauf nicht im Dokument gefunden hat.
+type UnsupportedClaim struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// VerificationResult ist das Ergebnis des Prüfdurchlaufs.
+type VerificationResult struct {
+	Unsupported []UnsupportedClaim `json:"unsupported"`
+}
+
+// WithVerification aktiviert für diesen Aufruf den Prüfdurchlauf und ersetzt die Policy des Services.
+func WithVerification(p VerificationPolicy) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.verification = &p
+	}
+}
+
+// verifiedRequest führt request aus und prüft das Ergebnis in einem zweiten Aufruf.
+func (ai *AiCommunicationService) verifiedRequest(request requestFunc) requestFunc {
+	return func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+		content, err := request(ctx, systemMessage, f, cfg)
+		if err != nil {
+			return "", err
+		}
+		p := cfg.verification
+
+		var extraction *Extraction
+		claims := content
+		if cfg.extraction() {
+			if extraction, err = ParseExtraction(content); err != nil {
+				return "", err
+			}
+			claims = string(extraction.Data)
+		}
+
+		// Zahlen als json.Number, damit sie beim Entfernen unbelegter Werte exakt bleiben
+		var data any
+		if err := DecodeJSON([]byte(claims), &data, NumberJSON); err != nil {
+			return "", fmt.Errorf("cannot verify non-JSON result: %w", err)
+		}
+		result, err := ai.verify(ctx, f, cfg, claims, data)
+		if err != nil {
+			return "", fmt.Errorf("verification failed: %w", err)
+		}
+		if p.OnResult != nil {
+			p.OnResult(result)
+		}
+		if len(result.Unsupported) == 0 {
+			return content, nil
+		}
+		log.Warn("verification flagged %d unsupported values", len(result.Unsupported))
+
+		if p.Remove {
+			for _, claim := range result.Unsupported {
+				setPath(data, claim.Path, nil)
+			}
+			verified, err := json.Marshal(data)
+			if err != nil {
+				return "", log.WrapError(err)
+			}
+			claims = string(verified)
+		}
+		if extraction == nil {
+			return claims, nil
+		}
+		extraction.Data = json.RawMessage(claims)
+		if extraction.Confidence == nil {
+			extraction.Confidence = map[string]FieldConfidence{}
+		}
+		for _, claim := range result.Unsupported {
+			c := extraction.Confidence[claim.Path]
+			c.Score = 0
+			extraction.Confidence[claim.Path] = c
+		}
+		verified, err := json.Marshal(extraction)
+		if err != nil {
+			return "", log.WrapError(err)
+		}
+		return string(verified), nil
+	}
+}
+
+// verify stellt den Prüfdurchlauf. Pfade, die es im Ergebnis nicht gibt, werden verworfen.
+func (ai *AiCommunicationService) verify(ctx context.Context, f onGetDocument, cfg requestConfig, claims string, data any) (VerificationResult, error) {
+	verifyCfg := cfg
+	verifyCfg.prompt = "Extrahierte Daten:\n" + claims
+	verifyCfg.temperature = 0
+	verifyCfg.postProcessors = []string{PostProcessStripFences}
+	verifyCfg.fieldConfidence = false
+	verifyCfg.citations = false
+	verifyCfg.idempotencyKey = ""
+	if cfg.verification.Model != "" {
+		verifyCfg.model = cfg.verification.Model
+	}
+
+	content, err := ai.requestJsonContent(ctx, verificationSystemMessage, f, verifyCfg)
+	if err != nil {
+		return VerificationResult{}, err
+	}
+	var raw VerificationResult
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return VerificationResult{}, fmt.Errorf("invalid verification answer: %w", err)
+	}
+	result := VerificationResult{Unsupported: []UnsupportedClaim{}}
+	for _, claim := range raw.Unsupported {
+		if _, ok := lookupPath(data, claim.Path); !ok {
+			log.Debug("verification named unknown field %s", claim.Path)
+			continue
+		}
+		result.Unsupported = append(result.Unsupported, claim)
+	}
+	return result, nil
+}
+
+// setPath ersetzt den Wert unter path (z.B. "items[0].total") in dekodiertem JSON.
+func setPath(data any, path string, value any) bool {
+	parentPath, last := "", path
+	if strings.HasSuffix(path, "]") {
+		i := strings.LastIndex(path, "[")
+		if i < 0 {
+			return false
+		}
+		parentPath, last = path[:i], path[i:]
+	} else if i := strings.LastIndex(path, "."); i >= 0 {
+		parentPath, last = path[:i], path[i+1:]
+	}
+
+	parent := data
+	if parentPath != "" {
+		var ok bool
+		if parent, ok = lookupPath(data, parentPath); !ok {
+			return false
+		}
+	}
+	if strings.HasPrefix(last, "[") {
+		idx, err := strconv.Atoi(strings.Trim(last, "[]"))
+		arr, ok := parent.([]any)
+		if err != nil || !ok || idx < 0 || idx >= len(arr) {
+			return false
+		}
+		arr[idx] = value
+		return true
+	}
+	obj, ok := parent.(map[string]any)
+	if !ok {
+		return false
+	}
+	if _, ok := obj[last]; !ok {
+		return false
+	}
+	obj[last] = value
+	return true
+}
@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newVerificationTestService antwortet auf die Extraktion mit extracted und auf den
+// Prüfdurchlauf mit verdict.
+func newVerificationTestService(t *testing.T, extracted, verdict string) *AiCommunicationService {
+	t.Helper()
+	return newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content any `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		answer := extracted
+		if system, _ := body.Messages[0].Content.(string); system == verificationSystemMessage {
+			require.Contains(t, body.Messages[1].Content, `"total"`)
+			answer = verdict
+		}
+		content, err := json.Marshal(answer)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, string(content), 1)))
+	})
+}
+
+func TestGenerateContent_Verification(t *testing.T) {
+	ai := newVerificationTestService(t,
+		`{"total": 1250, "items": [{"amount": 1000}, {"amount": 250}]}`,
+		`{"unsupported": [{"path": "items[1].amount", "reason": "not in document"}, {"path": "tax", "reason": "unknown"}]}`)
+
+	var result VerificationResult
+	content, err := ai.GenerateContent("system", WithVerification(VerificationPolicy{
+		Remove:   true,
+		OnResult: func(r VerificationResult) { result = r },
+	}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"total": 1250, "items": [{"amount": 1000}, {"amount": null}]}`, content)
+	require.Equal(t, []UnsupportedClaim{{Path: "items[1].amount", Reason: "not in document"}}, result.Unsupported)
+	require.Len(t, ai.Costs, 2)
+}
+
+func TestGenerateContent_VerificationWithConfidence(t *testing.T) {
+	ai := newVerificationTestService(t,
+		`{"data": {"total": 1250}, "confidence": {"total": {"score": 0.9, "pages": [1]}}}`,
+		`{"unsupported": [{"path": "total"}]}`)
+
+	content, err := ai.GenerateContent("system", WithFieldConfidence(), WithVerification(VerificationPolicy{}))
+	require.NoError(t, err)
+	e, err := ParseExtraction(content)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"total": 1250}`, string(e.Data))
+	require.Equal(t, FieldConfidence{Score: 0, Pages: []int{1}}, e.Confidence["total"])
+}
+
+func TestSetPath(t *testing.T) {
+	var data any
+	require.NoError(t, json.Unmarshal([]byte(`{"a": {"b": [1, [2, 3]]}}`), &data))
+	require.True(t, setPath(data, "a.b[1][0]", nil))
+	require.True(t, setPath(data, "a.b[0]", "x"))
+	require.False(t, setPath(data, "a.c", 1))
+	require.False(t, setPath(data, "a.b[5]", 1))
+	out, err := json.Marshal(data)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": {"b": ["x", [null, 3]]}}`, string(out))
+}
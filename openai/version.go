@@ -0,0 +1,15 @@
+package openai
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Version ist die Version von myailib. Sie steht im User-Agent jedes Requests, damit sich
+// Probleme auf API-Seite einer Client-Version zuordnen lassen.
+const Version = "1.0.0"
+
+// UserAgent liefert den User-Agent der Bibliothek, z.B. "myailib/v1.0.0 (go1.24.6; linux/amd64)".
+func UserAgent() string {
+	return fmt.Sprintf("myailib/v%s (%s; %s/%s)", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
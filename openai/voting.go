@@ -0,0 +1,216 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// VotingPolicy beschreibt Self-Consistency: derselbe Aufruf läuft Runs-mal (parallel), das
+// Ergebnis wird je Feld per Mehrheit bestimmt. Die Kosten steigen etwa um den Faktor Runs,
+// dafür werden Ausreißer, z.B. bei schlechten Scans, überstimmt.
+type VotingPolicy struct {
+	Runs int // Anzahl der Durchläufe, mindestens 2 (ungerade vermeidet Gleichstände)
+
+	// Models und Temperatures werden reihum auf die Durchläufe verteilt; leer = Werte des Aufrufs.
+	Models       []openai.ChatModel
+	Temperatures []float64
+
+	OnResult func(VoteResult) // erhält das Abstimmungsergebnis, optional
+}
+
+// VoteResult beschreibt eine Abstimmung. Disagreements enthält die Felder, bei denen sich
+// die Durchläufe nicht einig waren.
+type VoteResult struct {
+	Runs          int                `json:"runs"`
+	Failed        int                `json:"failed"`
+	Disagreements []VoteDisagreement `json:"disagreements,omitempty"`
+}
+
+// VoteDisagreement ist ein uneinheitliches Feld. Votes zählt je Wert (als JSON) die Stimmen.
+type VoteDisagreement struct {
+	Path   string         `json:"path"`
+	Chosen string         `json:"chosen"`
+	Votes  map[string]int `json:"votes"`
+}
+
+// WithVoting aktiviert für diesen Aufruf Self-Consistency und ersetzt die Policy des Services.
+func WithVoting(p VotingPolicy) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.voting = &p
+	}
+}
+
+// votingRequest führt request Runs-mal aus und führt die Ergebnisse zusammen.
+func (ai *AiCommunicationService) votingRequest(request requestFunc) requestFunc {
+	return func(ctx context.Context, systemMessage string, f onGetDocument, cfg requestConfig) (string, error) {
+		p := cfg.voting
+		runCfg := cfg
+		runCfg.voting = nil
+		// jeder Durchlauf ist ein eigener Request
+		runCfg.idempotencyKey = ""
+
+		contents := make([]string, p.Runs)
+		errs := make([]error, p.Runs)
+		var wg sync.WaitGroup
+		for i := range p.Runs {
+			c := runCfg
+			if len(p.Models) > 0 {
+				c.model = p.Models[i%len(p.Models)]
+			}
+			if len(p.Temperatures) > 0 {
+				c.temperature = p.Temperatures[i%len(p.Temperatures)]
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				contents[i], errs[i] = request(ctx, systemMessage, f, c)
+			}()
+		}
+		wg.Wait()
+
+		result := VoteResult{Runs: p.Runs}
+		succeeded := []string{}
+		for i, err := range errs {
+			if err != nil {
+				result.Failed++
+				log.Warn("voting run %d of %d failed: %v", i+1, p.Runs, err)
+				continue
+			}
+			succeeded = append(succeeded, contents[i])
+		}
+		if len(succeeded) == 0 {
+			return "", errs[0]
+		}
+
+		content := mergeVotes(succeeded, &result)
+		if len(result.Disagreements) > 0 {
+			log.Info("voting over %d runs disagreed on %d fields", len(succeeded), len(result.Disagreements))
+		}
+		if p.OnResult != nil {
+			p.OnResult(result)
+		}
+		return content, nil
+	}
+}
+
+// mergeVotes bestimmt das Ergebnis je Feld per Mehrheit. Antworten, die kein JSON sind,
+// werden als Ganzes abgestimmt.
+func mergeVotes(contents []string, result *VoteResult) string {
+	values := make([]any, 0, len(contents))
+	for _, content := range contents {
+		v, err := decodeCanonical([]byte(content), NumberJSON)
+		if err != nil {
+			return voteLeaf("", stringsAsAny(contents), result).(string)
+		}
+		values = append(values, v)
+	}
+	merged, err := json.Marshal(voteValue("", values, result))
+	if err != nil {
+		return contents[0]
+	}
+	return string(merged)
+}
+
+func voteValue(path string, values []any, result *VoteResult) any {
+	if objects, ok := allOf[map[string]any](values); ok {
+		merged := map[string]any{}
+		keys := map[string]bool{}
+		for _, obj := range objects {
+			for k := range obj {
+				keys[k] = true
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(keys)) {
+			present := []any{}
+			for _, obj := range objects {
+				if v, ok := obj[key]; ok {
+					present = append(present, v)
+				}
+			}
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			// ein Feld bleibt nur, wenn die Mehrheit es liefert
+			if 2*len(present) <= len(objects) {
+				result.Disagreements = append(result.Disagreements, VoteDisagreement{
+					Path:   child,
+					Chosen: "<missing>",
+					Votes:  map[string]int{"<missing>": len(objects) - len(present), "<present>": len(present)},
+				})
+				continue
+			}
+			merged[key] = voteValue(child, present, result)
+		}
+		return merged
+	}
+	if arrays, ok := allOf[[]any](values); ok && sameLength(arrays) {
+		merged := make([]any, len(arrays[0]))
+		for i := range merged {
+			items := make([]any, len(arrays))
+			for j, arr := range arrays {
+				items[j] = arr[i]
+			}
+			merged[i] = voteValue(fmt.Sprintf("%s[%d]", path, i), items, result)
+		}
+		return merged
+	}
+	return voteLeaf(path, values, result)
+}
+
+// voteLeaf wählt den häufigsten Wert; bei Gleichstand gewinnt der frühere Durchlauf.
+func voteLeaf(path string, values []any, result *VoteResult) any {
+	votes := map[string]int{}
+	keys := make([]string, len(values))
+	for i, v := range values {
+		data, _ := json.Marshal(v)
+		keys[i] = string(data)
+		votes[keys[i]]++
+	}
+	best := 0
+	for i, key := range keys {
+		if votes[key] > votes[keys[best]] {
+			best = i
+		}
+	}
+	if len(votes) > 1 {
+		result.Disagreements = append(result.Disagreements, VoteDisagreement{Path: path, Chosen: keys[best], Votes: votes})
+	}
+	return values[best]
+}
+
+func allOf[T any](values []any) ([]T, bool) {
+	typed := make([]T, 0, len(values))
+	for _, v := range values {
+		t, ok := v.(T)
+		if !ok {
+			return nil, false
+		}
+		typed = append(typed, t)
+	}
+	return typed, true
+}
+
+func sameLength(arrays [][]any) bool {
+	for _, arr := range arrays {
+		if len(arr) != len(arrays[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func stringsAsAny(values []string) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
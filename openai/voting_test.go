@@ -0,0 +1,59 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeVotes(t *testing.T) {
+	var result VoteResult
+	content := mergeVotes([]string{
+		`{"total": 12.5, "items": [{"sku": "A"}, {"sku": "B"}], "note": "x"}`,
+		`{"total": 12.50, "items": [{"sku": "A"}, {"sku": "8"}]}`,
+		`{"total": 17.5, "items": [{"sku": "A"}, {"sku": "B"}]}`,
+	}, &result)
+	require.JSONEq(t, `{"total": 12.5, "items": [{"sku": "A"}, {"sku": "B"}]}`, content)
+	require.Equal(t, []VoteDisagreement{
+		{Path: "items[1].sku", Chosen: `"B"`, Votes: map[string]int{`"B"`: 2, `"8"`: 1}},
+		{Path: "note", Chosen: "<missing>", Votes: map[string]int{"<missing>": 2, "<present>": 1}},
+		{Path: "total", Chosen: "12.5", Votes: map[string]int{"12.5": 2, "17.5": 1}},
+	}, result.Disagreements)
+
+	result = VoteResult{}
+	require.Equal(t, "b", mergeVotes([]string{"a", "b", "b"}, &result))
+}
+
+func TestGenerateContent_Voting(t *testing.T) {
+	answers := map[string]string{
+		string(openai.ChatModelGPT4_1):     `{"total": 12.5}`,
+		string(openai.ChatModelGPT4_1Mini): `{"total": 17.5}`,
+		string(openai.ChatModelGPT4o):      `{"total": 12.5}`,
+	}
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		content, err := json.Marshal(answers[body.Model])
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"{\"ok\": true}"`, string(content), 1)))
+	})
+
+	var vote VoteResult
+	content, err := ai.GenerateContent("system", WithVoting(VotingPolicy{
+		Runs:     3,
+		Models:   []openai.ChatModel{openai.ChatModelGPT4_1, openai.ChatModelGPT4_1Mini, openai.ChatModelGPT4o},
+		OnResult: func(r VoteResult) { vote = r },
+	}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"total": 12.5}`, content)
+	require.Equal(t, 3, vote.Runs)
+	require.Len(t, vote.Disagreements, 1)
+	require.Len(t, ai.Costs, 3)
+}
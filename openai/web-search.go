@@ -0,0 +1,128 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dchaykin/mygolib/log"
+	"github.com/openai/openai-go"
+)
+
+// WebSearchToolName ist der Name, unter dem WebSearchTool dem Modell angeboten wird.
+const WebSearchToolName = "web_search"
+
+const defaultWebSearchResults = 5
+
+// WebCitation ist eine Quelle, auf die sich eine Antwort stützt. Start- und EndIndex
+// markieren bei der Websuche des Providers die belegte Stelle in der Antwort, sonst sind sie 0.
+type WebCitation struct {
+	Title      string `json:"title,omitempty"`
+	URL        string `json:"url"`
+	StartIndex int64  `json:"startIndex,omitempty"`
+	EndIndex   int64  `json:"endIndex,omitempty"`
+}
+
+// SearchResult ist ein Treffer einer externen Suche.
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchProvider bindet eine externe Suchmaschine an, siehe WebSearchTool.
+type SearchProvider interface {
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// WebSearchOptions aktiviert im Agenten die Websuche des Providers. Sie steht nur mit
+// Such-Modellen (z.B. gpt-4o-search-preview) zur Verfügung.
+type WebSearchOptions struct {
+	ContextSize string // "low", "medium" oder "high"; leer = Default des Providers
+}
+
+// WebSearchTool bietet dem Modell eine externe Suche als Tool an. Die Treffer gehen als
+// JSON an das Modell und landen als Quellen in AgentResult.Citations.
+func WebSearchTool(provider SearchProvider, limit int) Tool {
+	if limit <= 0 {
+		limit = defaultWebSearchResults
+	}
+	return Tool{
+		Name:        WebSearchToolName,
+		Description: "Sucht im Web und liefert Titel, URL und Ausschnitt der besten Treffer.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "Suchanfrage"},
+			},
+			"required": []string{"query"},
+		},
+		Run: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil || args.Query == "" {
+				return "", fmt.Errorf("invalid search arguments: %s", arguments)
+			}
+			results, err := provider.Search(ctx, args.Query, limit)
+			if err != nil {
+				return "", err
+			}
+			if len(results) > limit {
+				results = results[:limit]
+			}
+			data, err := json.Marshal(results)
+			if err != nil {
+				return "", log.WrapError(err)
+			}
+			return string(data), nil
+		},
+		Citations: searchCitations,
+	}
+}
+
+// searchCitations liest die Quellen aus dem Ergebnis von WebSearchTool.
+func searchCitations(result string) []WebCitation {
+	var results []SearchResult
+	if err := json.Unmarshal([]byte(result), &results); err != nil {
+		return nil
+	}
+	citations := make([]WebCitation, 0, len(results))
+	for _, r := range results {
+		citations = append(citations, WebCitation{Title: r.Title, URL: r.URL})
+	}
+	return citations
+}
+
+// messageCitations liefert die Quellen der Websuche des Providers aus einer Antwort.
+func messageCitations(message openai.ChatCompletionMessage) []WebCitation {
+	citations := []WebCitation{}
+	for _, a := range message.Annotations {
+		if a.URLCitation.URL == "" {
+			continue
+		}
+		citations = append(citations, WebCitation{
+			Title:      a.URLCitation.Title,
+			URL:        a.URLCitation.URL,
+			StartIndex: a.URLCitation.StartIndex,
+			EndIndex:   a.URLCitation.EndIndex,
+		})
+	}
+	return citations
+}
+
+// addCitations ergänzt Quellen, jede URL nur einmal.
+func (r *AgentResult) addCitations(citations []WebCitation) {
+	for _, c := range citations {
+		known := false
+		for _, existing := range r.Citations {
+			if existing.URL == c.URL && existing.StartIndex == c.StartIndex {
+				known = true
+				break
+			}
+		}
+		if !known {
+			r.Citations = append(r.Citations, c)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type staticSearch []SearchResult
+
+func (s staticSearch) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return s, nil
+}
+
+func TestAgent_WebSearchTool(t *testing.T) {
+	var toolResult string
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content any    `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		if last := body.Messages[len(body.Messages)-1]; last.Role == "tool" {
+			toolResult = last.Content.(string)
+			_, _ = w.Write([]byte(testChatCompletion))
+			return
+		}
+		call := strings.Replace(testToolCallCompletion, `"name": "search"`, `"name": "web_search"`, 1)
+		_, _ = w.Write([]byte(strings.Replace(call, `{\"q\": \"myailib\"}`, `{\"query\": \"myailib\"}`, 1)))
+	})
+
+	search := staticSearch{
+		{Title: "A", URL: "https://example.com/a"},
+		{Title: "B", URL: "https://example.com/b"},
+		{Title: "C", URL: "https://example.com/c"},
+	}
+	agent := NewAgent(ai, "", WebSearchTool(search, 2))
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Contains(t, toolResult, "https://example.com/b")
+	require.NotContains(t, toolResult, "https://example.com/c")
+	require.Equal(t, []WebCitation{{Title: "A", URL: "https://example.com/a"}, {Title: "B", URL: "https://example.com/b"}}, result.Citations)
+}
+
+func TestAgent_HostedWebSearch(t *testing.T) {
+	var body map[string]any
+	ai := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(testChatCompletion, `"role": "assistant",`,
+			`"role": "assistant", "annotations": [{"type": "url_citation", "url_citation": {"start_index": 1, "end_index": 5, "title": "Doku", "url": "https://example.com/doku"}}],`, 1)))
+	})
+	agent := NewAgent(ai, "")
+	agent.WebSearch = &WebSearchOptions{ContextSize: "low"}
+
+	result, err := agent.Run(context.Background(), "Was ist myailib?")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"search_context_size": "low"}, body["web_search_options"])
+	require.NotContains(t, body, "temperature")
+	require.Equal(t, []WebCitation{{Title: "Doku", URL: "https://example.com/doku", StartIndex: 1, EndIndex: 5}}, result.Citations)
+}
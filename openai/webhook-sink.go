@@ -0,0 +1,166 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dchaykin/mygolib/helper"
+	"github.com/dchaykin/mygolib/log"
+)
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+)
+
+// WebhookSink sendet jedes fertige Ergebnis per POST an eine URL. Mit Secret wird der Body
+// per HMAC-SHA256 signiert und im Header X-Signature-256 als "sha256=<hex>" mitgeschickt.
+// Netzwerkfehler, 429 und 5xx werden mit verdoppelter Pause wiederholt.
+type WebhookSink struct {
+	URL        string
+	Secret     []byte
+	MaxRetries int           // Default: 3
+	Backoff    time.Duration // Pause vor der ersten Wiederholung, Default: 1s
+	HTTPClient *http.Client
+}
+
+func NewWebhookSink(url string, secret []byte) *WebhookSink {
+	return &WebhookSink{
+		URL:        url,
+		Secret:     secret,
+		MaxRetries: defaultWebhookRetries,
+		Backoff:    defaultWebhookBackoff,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// resultPayload ist die JSON-Darstellung eines Ergebnisses für externe Empfänger.
+type resultPayload struct {
+	SourceFile  string                     `json:"sourceFile"`
+	OutputFile  string                     `json:"outputFile"`
+	Cost        float64                    `json:"cost"`
+	Status      DocumentStatus             `json:"status,omitempty"`
+	Error       string                     `json:"error,omitempty"`
+	CompletedAt time.Time                  `json:"completedAt"`
+	Result      json.RawMessage            `json:"result"`
+	Provenance  *Provenance                `json:"provenance,omitempty"`
+	Confidence  map[string]FieldConfidence `json:"confidence,omitempty"`
+	Citations   map[string][]Citation      `json:"citations,omitempty"`
+}
+
+func (w *WebhookSink) Publish(ctx context.Context, result DocumentResult) error {
+	body, err := marshalResult(result)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	return w.send(ctx, body)
+}
+
+// Submit sendet ein ReviewItem; so kann der Webhook auch als ReviewSink dienen.
+func (w *WebhookSink) Submit(ctx context.Context, item ReviewItem) error {
+	body, err := json.Marshal(item)
+	if err != nil {
+		return log.WrapError(err)
+	}
+	return w.send(ctx, body)
+}
+
+// send signiert den Body und schickt ihn mit Wiederholungen.
+func (w *WebhookSink) send(ctx context.Context, body []byte) error {
+	signature := ""
+	var err error
+	if len(w.Secret) > 0 {
+		signature, err = helper.SignInput(body, w.Secret)
+		if err != nil {
+			return log.WrapError(err)
+		}
+	}
+
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = defaultWebhookBackoff
+	}
+	retries := w.MaxRetries
+	if retries < 0 {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body, signature)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= retries {
+			return log.WrapError(err)
+		}
+		log.Warn("webhook %s failed (attempt %d): %v", w.URL, attempt+1, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return log.WrapError(ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// post sendet den Body einmal und meldet, ob ein Fehler wiederholt werden sollte.
+func (w *WebhookSink) post(ctx context.Context, body []byte, signature string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent())
+	if signature != "" {
+		req.Header.Set("X-Signature-256", "sha256="+signature)
+	}
+
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook returned %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func marshalResult(result DocumentResult) ([]byte, error) {
+	return json.Marshal(resultPayload{
+		SourceFile:  result.SourceFile,
+		OutputFile:  result.OutputFile,
+		Cost:        result.Cost,
+		Status:      result.Status,
+		Error:       result.Error,
+		CompletedAt: result.CompletedAt,
+		Result:      resultJSON(result.Content),
+		Provenance:  result.Provenance,
+		Confidence:  result.Confidence,
+		Citations:   result.Citations,
+	})
+}
+
+// resultJSON bettet gültiges JSON direkt ein, alles andere als JSON-String.
+func resultJSON(content string) json.RawMessage {
+	if json.Valid([]byte(content)) {
+		return json.RawMessage(content)
+	}
+	quoted, _ := json.Marshal(content)
+	return quoted
+}